@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// requireAdmin authenticates a request and rejects non-admin users. When
+// authentication is disabled every caller is treated as an admin.
+func (s *APIServer) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return s.requireUser(func(w http.ResponseWriter, r *http.Request) {
+		if s.users != nil && !userFromContext(r.Context()).Admin {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// handleGetSettings returns the current runtime settings
+func (s *APIServer) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.settings.Get())
+}
+
+// handleUpdateSettings applies a partial settings update
+func (s *APIServer) handleUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var update SettingsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := s.settings.Update(update)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	infof("Settings updated: %+v", settings)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
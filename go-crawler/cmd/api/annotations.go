@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Limits on result annotations
+const (
+	maxLabels      = 20
+	maxLabelLength = 64
+	maxNoteLength  = 4096
+)
+
+// resultID identifies a page within a job: the first 16 hex digits of the
+// SHA-256 of its URL
+func resultID(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ResultPatch is the request body of PATCH /crawl/{id}/results/{urlhash}.
+// Labels replaces all labels; AddLabels and RemoveLabels change them
+// one at a time. Note replaces the note; an empty string clears it.
+type ResultPatch struct {
+	Labels       *[]string `json:"labels"`
+	AddLabels    []string  `json:"addLabels"`
+	RemoveLabels []string  `json:"removeLabels"`
+	Note         *string   `json:"note"`
+}
+
+// apply returns labels and note with the patch applied, or an error if the
+// result would break the annotation limits
+func (p ResultPatch) apply(labels []string, note string) ([]string, string, error) {
+	next := labels
+	if p.Labels != nil {
+		next = *p.Labels
+	}
+	next = append(append([]string(nil), next...), p.AddLabels...)
+
+	remove := make(map[string]bool, len(p.RemoveLabels))
+	for _, l := range p.RemoveLabels {
+		remove[strings.TrimSpace(l)] = true
+	}
+	seen := make(map[string]bool, len(next))
+	var cleaned []string
+	for _, l := range next {
+		l = strings.TrimSpace(l)
+		if l == "" || remove[l] || seen[l] {
+			continue
+		}
+		if len(l) > maxLabelLength {
+			return nil, "", fmt.Errorf("label %q is longer than %d characters", l, maxLabelLength)
+		}
+		seen[l] = true
+		cleaned = append(cleaned, l)
+	}
+	if len(cleaned) > maxLabels {
+		return nil, "", fmt.Errorf("at most %d labels are allowed", maxLabels)
+	}
+
+	if p.Note != nil {
+		note = *p.Note
+	}
+	if len(note) > maxNoteLength {
+		return nil, "", fmt.Errorf("note is longer than %d characters", maxNoteLength)
+	}
+	return cleaned, note, nil
+}
+
+// Annotate applies a patch to the result with the given ID and returns the
+// updated result. ok is false if there is no such result.
+func (l *ResultLog) Annotate(id string, patch ResultPatch) (page PageResult, ok bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i, ok := l.index[id]
+	if !ok {
+		return PageResult{}, false, nil
+	}
+	p := &l.results[i]
+	labels, note, err := patch.apply(p.Labels, p.Note)
+	if err != nil {
+		return PageResult{}, true, err
+	}
+	// Replace rather than modify the slice, which earlier copies share
+	p.Labels, p.Note = labels, note
+	now := time.Now()
+	p.AnnotatedAt = &now
+	return *p, true, nil
+}
+
+// handleAnnotateResult sets labels and a note on one of a job's results,
+// e.g. to track "needs redirect" and "fixed" in an audit
+func (s *APIServer) handleAnnotateResult(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var patch ResultPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	page, found, err := job.Results.Annotate(mux.Vars(r)["urlhash"], patch)
+	if !found {
+		http.Error(w, "Result not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.store != nil {
+		if err := s.store.UpdateResult(r.Context(), job.ID, page); err != nil {
+			warnf("Error storing annotations of %s in job %s: %v", page.URL, job.ID, err)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// hasLabel reports whether a result carries a label
+func (p PageResult) hasLabel(label string) bool {
+	for _, l := range p.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// User is an API key holder; each user only sees their own jobs
+type User struct {
+	Name   string `json:"name"`
+	APIKey string `json:"apiKey"`
+	Admin  bool   `json:"admin"`
+	Quota  Quota  `json:"quota"`
+}
+
+// Quota limits the resources a single user may consume. Zero means no limit.
+type Quota struct {
+	MaxActiveJobs int `json:"maxActiveJobs"` // Queued plus running jobs
+	MaxWorkers    int `json:"maxWorkers"`    // Workers per job
+	MaxDepth      int `json:"maxDepth"`      // Crawl depth per job
+}
+
+// Apply clamps a crawl request to the quota's per-job limits
+func (q Quota) Apply(req *CrawlRequest) {
+	if q.MaxWorkers > 0 && req.Workers > q.MaxWorkers {
+		req.Workers = q.MaxWorkers
+	}
+	if q.MaxDepth > 0 && req.Depth > q.MaxDepth {
+		req.Depth = q.MaxDepth
+	}
+}
+
+// UserStore maps API keys to users. A nil store disables authentication and
+// every request is treated as coming from the same anonymous user.
+type UserStore struct {
+	byKey map[string]*User
+}
+
+// LoadUsers reads a JSON array of users from path
+func LoadUsers(path string) (*UserStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading users file: %v", err)
+	}
+
+	var users []*User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("error parsing users file: %v", err)
+	}
+
+	store := &UserStore{byKey: make(map[string]*User)}
+	for _, u := range users {
+		if u.Name == "" || u.APIKey == "" {
+			return nil, fmt.Errorf("user entries need both a name and an apiKey")
+		}
+		if _, dup := store.byKey[u.APIKey]; dup {
+			return nil, fmt.Errorf("duplicate API key for user %s", u.Name)
+		}
+		store.byKey[u.APIKey] = u
+	}
+	return store, nil
+}
+
+// Lookup returns the user owning an API key
+func (s *UserStore) Lookup(key string) (*User, bool) {
+	u, ok := s.byKey[key]
+	return u, ok
+}
+
+// ByName returns the user with the given name
+func (s *UserStore) ByName(name string) (*User, bool) {
+	for _, u := range s.byKey {
+		if u.Name == name {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+// anonymousUser is used for every request when authentication is disabled
+var anonymousUser = &User{}
+
+type userContextKey struct{}
+
+// userFromContext returns the authenticated user for a request
+func userFromContext(ctx context.Context) *User {
+	if u, ok := ctx.Value(userContextKey{}).(*User); ok {
+		return u
+	}
+	return anonymousUser
+}
+
+// apiKeyFromRequest reads the key from the Authorization or X-API-Key header,
+// falling back to the api_key query parameter for browser WebSockets
+func apiKeyFromRequest(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// requireUser authenticates a request and stores the user in its context
+func (s *APIServer) requireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.users == nil {
+			next(w, r)
+			return
+		}
+
+		user, ok := s.users.Lookup(apiKeyFromRequest(r))
+		if !ok {
+			http.Error(w, "Invalid or missing API key", http.StatusUnauthorized)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, user)))
+	}
+}
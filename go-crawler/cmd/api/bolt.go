@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltFile is the name of the database file in the data directory
+const boltFile = "crawls.db"
+
+// Buckets of the Bolt database. Each job's results are in a bucket of
+// their own under boltResults, named by the job's ID, with the pages keyed
+// by their order and an index of their positions by result ID.
+var (
+	boltJobs      = []byte("jobs") // Job ID to its JobInfo as JSON
+	boltResults   = []byte("results")
+	boltPages     = []byte("pages")     // Sequence number to a PageResult as JSON
+	boltIndex     = []byte("index")     // Result ID to the page's sequence number
+	boltSchedules = []byte("schedules") // Schedule ID to the Schedule as JSON
+)
+
+// BoltStore keeps jobs and their results in an embedded database file, the
+// server's default store. Unlike other stores it loads them back when the
+// server starts.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens the database in dir, creating both if needed. Only
+// one server at a time can use a data directory.
+func OpenBoltStore(dir string) (*BoltStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating data directory: %v", err)
+	}
+	path := filepath.Join(dir, boltFile)
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("error opening %s: another server is using it", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltJobs, boltResults, boltSchedules} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error initializing %s: %v", path, err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// SaveJob implements Store
+func (s *BoltStore) SaveJob(ctx context.Context, info JobInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		jobs := tx.Bucket(boltJobs)
+		if stored := jobs.Get([]byte(info.ID)); stored != nil && info.Status != JobCompleted {
+			var previous JobInfo
+			if json.Unmarshal(stored, &previous) == nil && previous.Status == JobCompleted {
+				return nil
+			}
+		}
+		return jobs.Put([]byte(info.ID), data)
+	})
+}
+
+// AddResults implements Store
+func (s *BoltStore) AddResults(ctx context.Context, jobID string, pages []PageResult) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(boltResults).CreateBucketIfNotExists([]byte(jobID))
+		if err != nil {
+			return err
+		}
+		pageBucket, err := b.CreateBucketIfNotExists(boltPages)
+		if err != nil {
+			return err
+		}
+		index, err := b.CreateBucketIfNotExists(boltIndex)
+		if err != nil {
+			return err
+		}
+		for _, p := range pages {
+			if index.Get([]byte(p.ID)) != nil {
+				continue
+			}
+			data, err := json.Marshal(p)
+			if err != nil {
+				return err
+			}
+			seq, _ := pageBucket.NextSequence()
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, seq)
+			if err := pageBucket.Put(key, data); err != nil {
+				return err
+			}
+			if err := index.Put([]byte(p.ID), key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateResult implements Store
+func (s *BoltStore) UpdateResult(ctx context.Context, jobID string, page PageResult) error {
+	data, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltResults).Bucket([]byte(jobID))
+		if b == nil {
+			return nil
+		}
+		key := b.Bucket(boltIndex).Get([]byte(page.ID))
+		if key == nil {
+			return nil
+		}
+		return b.Bucket(boltPages).Put(key, data)
+	})
+}
+
+// DeleteJob implements Store
+func (s *BoltStore) DeleteJob(ctx context.Context, jobID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return deleteBoltJob(tx, jobID)
+	})
+}
+
+func deleteBoltJob(tx *bolt.Tx, jobID string) error {
+	if err := tx.Bucket(boltJobs).Delete([]byte(jobID)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltResults).DeleteBucket([]byte(jobID)); err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+	return nil
+}
+
+// Prune implements Store
+func (s *BoltStore) Prune(ctx context.Context, r Retention) (int, error) {
+	if !r.enabled() {
+		return 0, nil
+	}
+	var n int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var crawls []retainedCrawl
+		err := tx.Bucket(boltJobs).ForEach(func(k, v []byte) error {
+			var info JobInfo
+			if err := json.Unmarshal(v, &info); err != nil || info.Status != JobCompleted || info.FinishedAt == nil {
+				return nil
+			}
+			crawls = append(crawls, retainedCrawl{ID: info.ID, Owner: info.Owner, URL: info.Request.URL, FinishedAt: *info.FinishedAt})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range expiredCrawls(crawls, r, time.Now()) {
+			if err := deleteBoltJob(tx, id); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// LoadJobs implements JobLoader
+func (s *BoltStore) LoadJobs(ctx context.Context) ([]StoredJob, error) {
+	var jobs []StoredJob
+	err := s.db.View(func(tx *bolt.Tx) error {
+		results := tx.Bucket(boltResults)
+		return tx.Bucket(boltJobs).ForEach(func(k, v []byte) error {
+			var job StoredJob
+			if err := json.Unmarshal(v, &job.Info); err != nil {
+				warnf("Skipping stored job %s: %v", k, err)
+				return nil
+			}
+			b := results.Bucket(k)
+			if b == nil {
+				jobs = append(jobs, job)
+				return nil
+			}
+			err := b.Bucket(boltPages).ForEach(func(_, v []byte) error {
+				page, err := decodeStoredResult(v)
+				if err != nil {
+					return fmt.Errorf("job %s: %v", k, err)
+				}
+				job.Results = append(job.Results, page)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+// SaveSchedule implements ScheduleKeeper
+func (s *BoltStore) SaveSchedule(ctx context.Context, sched Schedule) error {
+	data, err := json.Marshal(sched)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSchedules).Put([]byte(sched.ID), data)
+	})
+}
+
+// DeleteSchedule implements ScheduleKeeper
+func (s *BoltStore) DeleteSchedule(ctx context.Context, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSchedules).Delete([]byte(id))
+	})
+}
+
+// LoadSchedules implements ScheduleKeeper
+func (s *BoltStore) LoadSchedules(ctx context.Context) ([]Schedule, error) {
+	var scheds []Schedule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSchedules).ForEach(func(k, v []byte) error {
+			var sched Schedule
+			if err := json.Unmarshal(v, &sched); err != nil {
+				warnf("Skipping stored schedule %s: %v", k, err)
+				return nil
+			}
+			scheds = append(scheds, sched)
+			return nil
+		})
+	})
+	return scheds, err
+}
+
+// Close implements Store
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go-crawler/internal/crawler"
+)
+
+// maxComplianceEntries bounds how many robots.txt decisions a job keeps;
+// counts are always exact
+const maxComplianceEntries = 100000
+
+// ComplianceLog records every robots.txt decision a job made
+type ComplianceLog struct {
+	mu         sync.Mutex
+	decisions  []crawler.RobotsDecision
+	allowed    int
+	disallowed int
+	truncated  bool
+}
+
+func NewComplianceLog() *ComplianceLog {
+	return &ComplianceLog{}
+}
+
+// Record adds a decision; it is safe for concurrent use
+func (l *ComplianceLog) Record(d crawler.RobotsDecision) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if d.Allowed {
+		l.allowed++
+	} else {
+		l.disallowed++
+	}
+	if len(l.decisions) >= maxComplianceEntries {
+		l.truncated = true
+		return
+	}
+	l.decisions = append(l.decisions, d)
+}
+
+// ComplianceReport is the JSON response body of GET /crawl/{id}/compliance
+type ComplianceReport struct {
+	UserAgent  string                   `json:"userAgent"`
+	Allowed    int                      `json:"allowed"`
+	Disallowed int                      `json:"disallowed"`
+	Decisions  []crawler.RobotsDecision `json:"decisions"`
+	Truncated  bool                     `json:"truncated,omitempty"`
+}
+
+// Report returns the recorded decisions, optionally only the disallowed ones
+func (l *ComplianceLog) Report(disallowedOnly bool) ComplianceReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	report := ComplianceReport{
+		Allowed:    l.allowed,
+		Disallowed: l.disallowed,
+		Decisions:  []crawler.RobotsDecision{},
+		Truncated:  l.truncated,
+	}
+	for _, d := range l.decisions {
+		if !disallowedOnly || !d.Allowed {
+			report.Decisions = append(report.Decisions, d)
+		}
+	}
+	return report
+}
+
+// handleGetCompliance returns a job's robots.txt compliance log as JSON or,
+// with format=csv, as a CSV download. disallowed=true lists only the URLs
+// robots.txt blocked.
+func (s *APIServer) handleGetCompliance(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	report := job.Compliance.Report(r.URL.Query().Get("disallowed") == "true")
+	if c := job.Crawler(); c != nil {
+		report.UserAgent = c.UserAgent()
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=compliance-%s.csv", job.ID))
+		crawler.WriteComplianceCSV(w, report.Decisions)
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"time"
+
+	"go-crawler/internal/crawler"
+)
+
+// DebugInfo is the server's internal state as served by /debug/crawler
+type DebugInfo struct {
+	Goroutines int        `json:"goroutines"`
+	HeapBytes  uint64     `json:"heapBytes"`
+	GCCycles   uint32     `json:"gcCycles"`
+	Queued     int        `json:"queued"` // Jobs waiting to start
+	Jobs       []JobDebug `json:"jobs"`
+}
+
+// JobDebug is the state of a running job's crawler
+type JobDebug struct {
+	ID         string              `json:"id"`
+	Owner      string              `json:"owner,omitempty"`
+	URL        string              `json:"url"`
+	Running    time.Duration       `json:"running"`
+	StoreQueue int                 `json:"storeQueue"` // Results waiting to be written to the store
+	Crawler    *crawler.DebugState `json:"crawler,omitempty"`
+	Labels     map[string]string   `json:"labels,omitempty"`
+}
+
+// enableDebug serves the runtime profiles under /debug/pprof/ and the
+// crawlers' state at /debug/crawler, to admins only
+func (s *APIServer) enableDebug() {
+	s.router.HandleFunc("/debug/crawler", s.requireAdmin(s.handleDebugCrawler)).Methods("GET")
+	s.router.HandleFunc("/debug/pprof/cmdline", s.requireAdmin(pprof.Cmdline))
+	s.router.HandleFunc("/debug/pprof/profile", s.requireAdmin(pprof.Profile))
+	s.router.HandleFunc("/debug/pprof/symbol", s.requireAdmin(pprof.Symbol))
+	s.router.HandleFunc("/debug/pprof/trace", s.requireAdmin(pprof.Trace))
+	// The index and named profiles such as heap and goroutine
+	s.router.PathPrefix("/debug/pprof/").HandlerFunc(s.requireAdmin(pprof.Index))
+}
+
+// runningJobs returns the running jobs, oldest first, and how many are
+// queued
+func (m *JobManager) runningJobs() ([]*Job, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]*Job, 0, len(m.running))
+	for job := range m.running {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].StartedAt.Before(jobs[j].StartedAt) })
+	return jobs, len(m.queue)
+}
+
+// handleDebugCrawler dumps the running jobs' frontiers, workers and
+// per-host limits with a few runtime figures. ?job= limits it to one job.
+func (s *APIServer) handleDebugCrawler(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	jobs, queued := s.jobs.runningJobs()
+	info := DebugInfo{
+		Goroutines: runtime.NumGoroutine(),
+		HeapBytes:  mem.HeapAlloc,
+		GCCycles:   mem.NumGC,
+		Queued:     queued,
+		Jobs:       []JobDebug{},
+	}
+
+	only := r.URL.Query().Get("job")
+	for _, job := range jobs {
+		if only != "" && job.ID != only {
+			continue
+		}
+		jd := JobDebug{
+			ID:      job.ID,
+			Owner:   job.Owner,
+			URL:     job.Request.URL,
+			Running: time.Since(job.StartedAt),
+			Labels:  job.Request.Labels,
+		}
+		if writer := job.Results.writer; writer != nil {
+			jd.StoreQueue = len(writer.queue)
+		}
+		if c := job.Crawler(); c != nil {
+			state := c.DebugState()
+			jd.Crawler = &state
+		}
+		info.Jobs = append(info.Jobs, jd)
+	}
+	if only != "" && len(info.Jobs) == 0 {
+		http.Error(w, "Job not found or not running", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
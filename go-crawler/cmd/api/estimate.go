@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"go-crawler/internal/crawler"
+)
+
+// estimateCrawl projects the size and cost of a request's crawl with a
+// throwaway crawler, so that its dry run doesn't touch a job's logs
+func (s *APIServer) estimateCrawl(ctx context.Context, req CrawlRequest) (*crawler.Estimate, error) {
+	c := s.newCrawler(&Job{Request: req, Skips: NewSkipLog(), Compliance: NewComplianceLog()})
+	return c.EstimateCrawl(ctx, req.URL)
+}
+
+// handleEstimate estimates a crawl request without submitting it: the
+// pages its sitemaps list within scope, and the projected duration and
+// bandwidth with the request's and the server's settings
+func (s *APIServer) handleEstimate(w http.ResponseWriter, r *http.Request) {
+	req, err := s.decodeCrawlRequest(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.URL == "" {
+		http.Error(w, "URL is required", http.StatusBadRequest)
+		return
+	}
+	if err := s.checkSeedURL(req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.applyDefaults(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userFromContext(r.Context()).Quota.Apply(&req)
+
+	est, err := s.estimateCrawl(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(est)
+}
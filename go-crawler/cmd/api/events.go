@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go-crawler/internal/crawler"
+)
+
+// eventStreamBuffer is how many events a slow /events client may fall
+// behind by before events are dropped for it
+const eventStreamBuffer = 1000
+
+// EventLog counts a job's crawl events by type
+type EventLog struct {
+	mu     sync.Mutex
+	counts map[crawler.EventType]int
+}
+
+func NewEventLog() *EventLog {
+	return &EventLog{counts: make(map[crawler.EventType]int)}
+}
+
+// Record counts an event; it is safe for concurrent use
+func (l *EventLog) Record(e crawler.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[e.Type]++
+}
+
+// Counts returns a copy of the counts so far
+func (l *EventLog) Counts() map[crawler.EventType]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counts := make(map[crawler.EventType]int, len(l.counts))
+	for t, n := range l.counts {
+		counts[t] = n
+	}
+	return counts
+}
+
+// newJobEvents returns the bus a job's crawler publishes on, with the
+// job's logs subscribed to it
+func newJobEvents(job *Job) *crawler.EventBus {
+	bus := crawler.NewEventBus()
+	bus.Subscribe(job.EventCounts.Record)
+	bus.Subscribe(func(e crawler.Event) {
+		job.Skips.Record(crawler.SkippedURL{URL: e.URL, Source: e.Source, Reason: crawler.SkipReason(e.Reason), Detail: e.Detail})
+	}, crawler.EventURLSkipped)
+	return bus
+}
+
+// parseEventTypes parses a comma-separated list of event types
+func parseEventTypes(s string) ([]crawler.EventType, error) {
+	var types []crawler.EventType
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		known := false
+		for _, t := range crawler.EventTypes {
+			if string(t) == name {
+				types, known = append(types, t), true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown event type %q", name)
+		}
+	}
+	return types, nil
+}
+
+// handleStreamEvents streams a running job's events as newline-delimited
+// JSON from the time of the request until the job finishes or the client
+// goes away, optionally only those of the types listed in ?type=
+func (s *APIServer) handleStreamEvents(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+	types, err := parseEventTypes(r.URL.Query().Get("type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := job.Events.SubscribeChan(eventStreamBuffer, types...)
+	defer unsubscribe()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case e := <-events:
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-job.done:
+			// Send what was published before the job finished
+			for {
+				select {
+				case e := <-events:
+					if err := enc.Encode(e); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
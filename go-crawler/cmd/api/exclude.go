@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ExcludeRequest is the body of POST /crawl/{id}/exclude
+type ExcludeRequest struct {
+	// Pattern is a path prefix starting with /, or a regular expression
+	// matched against the whole URL
+	Pattern string `json:"pattern"`
+}
+
+// handleExclude stops a running job from fetching URLs that match a
+// pattern, for a crawl that wandered into a section it shouldn't cover.
+// Links found from then on are skipped, and so are matching URLs already
+// queued.
+func (s *APIServer) handleExclude(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req ExcludeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	info, _ := s.jobs.Info(job.ID)
+	c := job.Crawler()
+	if c == nil || (info.Status != JobRunning && info.Status != JobPaused) {
+		http.Error(w, "Crawl is not running", http.StatusConflict)
+		return
+	}
+	exclusion, err := c.Exclude(req.Pattern)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	infof("Crawl %s excludes %s", job.ID, req.Pattern)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(exclusion)
+}
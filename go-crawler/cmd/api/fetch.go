@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"go-crawler/internal/crawler"
+)
+
+// FetchReport is the diagnosis of a single fetch by POST /fetch, with the
+// page as a crawl would have recorded it
+type FetchReport struct {
+	*crawler.FetchDiagnosis
+	Page PageResult `json:"page"`
+}
+
+// handleFetch fetches the URL of a crawl request through the crawler's
+// whole pipeline with the request's settings and reports every step, to
+// find out why a page doesn't crawl. The optional from parameter names the
+// crawl's start URL, to check the page against its scope too.
+func (s *APIServer) handleFetch(w http.ResponseWriter, r *http.Request) {
+	req, err := s.decodeCrawlRequest(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.URL == "" {
+		http.Error(w, "URL is required", http.StatusBadRequest)
+		return
+	}
+	if err := s.checkSeedURL(req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from := r.URL.Query().Get("from")
+	if from != "" {
+		if _, err := url.ParseRequestURI(from); err != nil {
+			http.Error(w, "Invalid from URL: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := s.applyDefaults(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userFromContext(r.Context()).Quota.Apply(&req)
+
+	c := s.newCrawler(&Job{Request: req, Skips: NewSkipLog(), Compliance: NewComplianceLog()})
+	diagnosis, err := c.Diagnose(r.Context(), req.URL, from)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FetchReport{FetchDiagnosis: diagnosis, Page: newPageResult(diagnosis.Result)})
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"go-crawler/internal/crawler"
+)
+
+// graphQLRequest is the body of POST /graphql
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// gqlJob pairs a job with the snapshot taken when it was resolved
+type gqlJob struct {
+	job  *Job
+	info JobInfo
+}
+
+// gqlPage is a fetched page with its links resolved to absolute URLs and
+// the crawled pages linking to it
+type gqlPage struct {
+	PageResult
+	links      []string
+	linkedFrom []string
+}
+
+// handleGraphQL runs a GraphQL query over the requesting user's jobs. Queries
+// may be sent as a JSON body or, for GET, in the query parameter.
+func (s *APIServer) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if v := r.URL.Query().Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				http.Error(w, "Invalid variables", http.StatusBadRequest)
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Query == "" {
+		http.Error(w, "query is required", http.StatusBadRequest)
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         s.graphql,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        r.Context(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// newGraphQLSchema builds the schema served at /graphql
+func (s *APIServer) newGraphQLSchema() (graphql.Schema, error) {
+	pageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Page",
+		Fields: graphql.Fields{
+			"id":             pageField(graphql.NewNonNull(graphql.String), func(p *gqlPage) interface{} { return p.ID }),
+			"url":            pageField(graphql.NewNonNull(graphql.String), func(p *gqlPage) interface{} { return p.URL }),
+			"depth":          pageField(graphql.NewNonNull(graphql.Int), func(p *gqlPage) interface{} { return p.Depth }),
+			"statusCode":     pageField(graphql.Int, func(p *gqlPage) interface{} { return nonZero(p.StatusCode) }),
+			"contentType":    pageField(graphql.String, func(p *gqlPage) interface{} { return nonEmpty(p.ContentType) }),
+			"contentHash":    pageField(graphql.String, func(p *gqlPage) interface{} { return nonEmpty(p.ContentHash) }),
+			"canonical":      pageField(graphql.String, func(p *gqlPage) interface{} { return nonEmpty(p.Canonical) }),
+			"language":       pageField(graphql.String, func(p *gqlPage) interface{} { return nonEmpty(p.Language) }),
+			"text":           pageField(graphql.String, func(p *gqlPage) interface{} { return nonEmpty(p.Text) }),
+			"wordCount":      pageField(graphql.Int, func(p *gqlPage) interface{} { return p.WordCount }),
+			"change":         pageField(graphql.String, func(p *gqlPage) interface{} { return nonEmpty(string(p.Change)) }),
+			"score":          pageField(graphql.Float, func(p *gqlPage) interface{} { return p.Score }),
+			"error":          pageField(graphql.String, func(p *gqlPage) interface{} { return nonEmpty(p.Error) }),
+			"errorClass":     pageField(graphql.String, func(p *gqlPage) interface{} { return nonEmpty(string(p.ErrorClass)) }),
+			"links":          pageField(graphql.NewList(graphql.NewNonNull(graphql.String)), func(p *gqlPage) interface{} { return p.links }),
+			"linksTruncated": pageField(graphql.Int, func(p *gqlPage) interface{} { return nonZero(p.LinksTruncated) }),
+			"linkedFrom":     pageField(graphql.NewList(graphql.NewNonNull(graphql.String)), func(p *gqlPage) interface{} { return p.linkedFrom }),
+			"labels":         pageField(graphql.NewList(graphql.NewNonNull(graphql.String)), func(p *gqlPage) interface{} { return p.Labels }),
+			"note":           pageField(graphql.String, func(p *gqlPage) interface{} { return nonEmpty(p.Note) }),
+			"schemaVersion":  pageField(graphql.NewNonNull(graphql.Int), func(p *gqlPage) interface{} { return p.SchemaVersion }),
+		},
+	})
+
+	skippedType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SkippedURL",
+		Fields: graphql.Fields{
+			"url":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"source": &graphql.Field{Type: graphql.String},
+			"reason": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"detail": &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	jobType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Job",
+		Fields: graphql.Fields{
+			"id":         jobField(graphql.NewNonNull(graphql.ID), func(j *gqlJob) interface{} { return j.info.ID }),
+			"owner":      jobField(graphql.String, func(j *gqlJob) interface{} { return nonEmpty(j.info.Owner) }),
+			"status":     jobField(graphql.NewNonNull(graphql.String), func(j *gqlJob) interface{} { return string(j.info.Status) }),
+			"priority":   jobField(graphql.NewNonNull(graphql.String), func(j *gqlJob) interface{} { return string(j.info.Priority) }),
+			"url":        jobField(graphql.NewNonNull(graphql.String), func(j *gqlJob) interface{} { return j.info.Request.URL }),
+			"depth":      jobField(graphql.NewNonNull(graphql.Int), func(j *gqlJob) interface{} { return j.info.Request.Depth }),
+			"createdAt":  jobField(graphql.NewNonNull(graphql.String), func(j *gqlJob) interface{} { return formatTime(&j.info.CreatedAt) }),
+			"startedAt":  jobField(graphql.String, func(j *gqlJob) interface{} { return formatTime(j.info.StartedAt) }),
+			"finishedAt": jobField(graphql.String, func(j *gqlJob) interface{} { return formatTime(j.info.FinishedAt) }),
+			"pageCount": jobField(graphql.NewNonNull(graphql.Int), func(j *gqlJob) interface{} {
+				return len(j.job.Results.Report("", "").Results)
+			}),
+			"labels": jobField(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), func(j *gqlJob) interface{} {
+				return jobLabelPairs(j.info.Request.Labels)
+			}),
+			"pages": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(pageType))),
+				Args: pageFilterArgs(),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return filterPages(jobPages(p.Source.(*gqlJob).job), p.Args), nil
+				},
+			},
+			"errors": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(pageType))),
+				Args: graphql.FieldConfigArgument{"limit": &graphql.ArgumentConfig{Type: graphql.Int}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					args := map[string]interface{}{"hasError": true, "limit": p.Args["limit"]}
+					return filterPages(jobPages(p.Source.(*gqlJob).job), args), nil
+				},
+			},
+			"skipped": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(skippedType))),
+				Args: graphql.FieldConfigArgument{
+					"reason": &graphql.ArgumentConfig{Type: graphql.String},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					reason, _ := p.Args["reason"].(string)
+					skipped := p.Source.(*gqlJob).job.Skips.Report(crawler.SkipReason(reason)).Skipped
+					if limit, ok := p.Args["limit"].(int); ok && limit >= 0 && limit < len(skipped) {
+						skipped = skipped[:limit]
+					}
+					return skipped, nil
+				},
+			},
+		},
+	})
+
+	jobsForUser := func(p graphql.ResolveParams) []*gqlJob {
+		var jobs []*gqlJob
+		for _, info := range s.jobs.List(userFromContext(p.Context).Name) {
+			if job, ok := s.jobs.Get(info.ID); ok {
+				jobs = append(jobs, &gqlJob{job: job, info: info})
+			}
+		}
+		return jobs
+	}
+	jobForUser := func(p graphql.ResolveParams, id string) (*gqlJob, bool) {
+		job, ok := s.jobs.Get(id)
+		if !ok || job.Owner != userFromContext(p.Context).Name {
+			return nil, false
+		}
+		info, _ := s.jobs.Info(id)
+		return &gqlJob{job: job, info: info}, true
+	}
+
+	pagesArgs := pageFilterArgs()
+	pagesArgs["job"] = &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"jobs": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(jobType))),
+				Args: graphql.FieldConfigArgument{
+					"status": &graphql.ArgumentConfig{Type: graphql.String},
+					"label":  &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					jobs := []*gqlJob{}
+					status, _ := p.Args["status"].(string)
+					var selectors []string
+					if list, ok := p.Args["label"].([]interface{}); ok {
+						for _, s := range list {
+							selectors = append(selectors, s.(string))
+						}
+					}
+					for _, j := range jobsForUser(p) {
+						if (status == "" || string(j.info.Status) == status) && matchJobLabels(j.info.Request.Labels, selectors) {
+							jobs = append(jobs, j)
+						}
+					}
+					return jobs, nil
+				},
+			},
+			"job": &graphql.Field{
+				Type: jobType,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if j, ok := jobForUser(p, p.Args["id"].(string)); ok {
+						return j, nil
+					}
+					return nil, nil
+				},
+			},
+			"pages": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(pageType))),
+				Args: pagesArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id := p.Args["job"].(string)
+					j, ok := jobForUser(p, id)
+					if !ok {
+						return nil, fmt.Errorf("crawl %s not found", id)
+					}
+					return filterPages(jobPages(j.job), p.Args), nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// pageFilterArgs are the filters accepted wherever pages are listed
+func pageFilterArgs() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"depth":       &graphql.ArgumentConfig{Type: graphql.Int},
+		"status":      &graphql.ArgumentConfig{Type: graphql.Int, Description: "HTTP status code"},
+		"hasError":    &graphql.ArgumentConfig{Type: graphql.Boolean},
+		"change":      &graphql.ArgumentConfig{Type: graphql.String, Description: "unchanged, changed or gone"},
+		"errorClass":  &graphql.ArgumentConfig{Type: graphql.String, Description: "status, timeout, fetch, auth, robots and so on"},
+		"urlContains": &graphql.ArgumentConfig{Type: graphql.String},
+		"label":       &graphql.ArgumentConfig{Type: graphql.String},
+		"linkedFrom": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "Only pages linked from a page whose URL, or path if this starts with /, has this prefix",
+		},
+		"limit":  &graphql.ArgumentConfig{Type: graphql.Int},
+		"offset": &graphql.ArgumentConfig{Type: graphql.Int},
+	}
+}
+
+// filterPages applies the pageFilterArgs to a list of pages
+func filterPages(pages []*gqlPage, args map[string]interface{}) []*gqlPage {
+	filtered := []*gqlPage{}
+	for _, p := range pages {
+		if depth, ok := args["depth"].(int); ok && p.Depth != depth {
+			continue
+		}
+		if status, ok := args["status"].(int); ok && p.StatusCode != status {
+			continue
+		}
+		if hasError, ok := args["hasError"].(bool); ok && (p.Error != "") != hasError {
+			continue
+		}
+		if change, ok := args["change"].(string); ok && string(p.Change) != change {
+			continue
+		}
+		if class, ok := args["errorClass"].(string); ok && string(p.ErrorClass) != class {
+			continue
+		}
+		if label, ok := args["label"].(string); ok && !p.hasLabel(label) {
+			continue
+		}
+		if sub, ok := args["urlContains"].(string); ok && !strings.Contains(p.URL, sub) {
+			continue
+		}
+		if from, ok := args["linkedFrom"].(string); ok && !linkedFromPrefix(p.linkedFrom, from) {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+
+	if offset, ok := args["offset"].(int); ok && offset > 0 {
+		if offset > len(filtered) {
+			offset = len(filtered)
+		}
+		filtered = filtered[offset:]
+	}
+	if limit, ok := args["limit"].(int); ok && limit >= 0 && limit < len(filtered) {
+		filtered = filtered[:limit]
+	}
+	return filtered
+}
+
+// linkedFromPrefix reports whether any linking page matches prefix, by URL
+// or, for prefixes starting with /, by path
+func linkedFromPrefix(linkers []string, prefix string) bool {
+	for _, l := range linkers {
+		if strings.HasPrefix(l, prefix) {
+			return true
+		}
+		if strings.HasPrefix(prefix, "/") {
+			if u, err := url.Parse(l); err == nil && strings.HasPrefix(u.Path, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jobPages snapshots a job's results with every link resolved and the
+// inbound links between fetched pages indexed
+func jobPages(job *Job) []*gqlPage {
+	results := job.Results.Report("", "").Results
+	pages := make([]*gqlPage, len(results))
+	byURL := make(map[string]*gqlPage, len(results))
+	for i, r := range results {
+		pages[i] = &gqlPage{PageResult: r, links: []string{}, linkedFrom: []string{}}
+		byURL[r.URL] = pages[i]
+	}
+
+	for _, p := range pages {
+		base, err := url.Parse(p.URL)
+		if err != nil {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, link := range p.Links {
+			u, err := base.Parse(link)
+			if err != nil {
+				continue
+			}
+			u.Fragment = ""
+			target := u.String()
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+			p.links = append(p.links, target)
+			if linked, ok := byURL[target]; ok && linked != p {
+				linked.linkedFrom = append(linked.linkedFrom, p.URL)
+			}
+		}
+	}
+	return pages
+}
+
+func pageField(t graphql.Output, get func(*gqlPage) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: t,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(*gqlPage)), nil
+		},
+	}
+}
+
+func jobField(t graphql.Output, get func(*gqlJob) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: t,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(*gqlJob)), nil
+		},
+	}
+}
+
+// nonEmpty and nonZero map unset values to GraphQL null
+func nonEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nonZero(n int) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+func formatTime(t *time.Time) interface{} {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Limits on a crawl request's labels
+const (
+	maxJobLabels          = 20
+	maxJobLabelValueBytes = 256
+)
+
+// jobLabelName is the form of label names: letters, digits and underscores,
+// not starting with a digit, so that they are valid metric label names too
+var jobLabelName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// validateJobLabels checks a crawl request's labels against the limits
+func validateJobLabels(labels map[string]string) error {
+	if len(labels) > maxJobLabels {
+		return fmt.Errorf("too many labels: %d, at most %d", len(labels), maxJobLabels)
+	}
+	for name, value := range labels {
+		if !jobLabelName.MatchString(name) {
+			return fmt.Errorf("invalid label name %q: want letters, digits and underscores, not starting with a digit, at most 63 characters", name)
+		}
+		if len(value) > maxJobLabelValueBytes {
+			return fmt.Errorf("label %s is longer than %d bytes", name, maxJobLabelValueBytes)
+		}
+	}
+	return nil
+}
+
+// jobLabelPairs returns labels as name=value pairs sorted by name
+func jobLabelPairs(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + labels[name]
+	}
+	return pairs
+}
+
+// formatJobLabels renders labels as name=value pairs sorted by name and
+// separated by sep
+func formatJobLabels(labels map[string]string, sep string) string {
+	return strings.Join(jobLabelPairs(labels), sep)
+}
+
+// matchJobLabels reports whether labels satisfy every selector: name=value
+// needs the label with that value, and a bare name only the label
+func matchJobLabels(labels map[string]string, selectors []string) bool {
+	for _, selector := range selectors {
+		name, value, hasValue := strings.Cut(selector, "=")
+		got, ok := labels[name]
+		if !ok || (hasValue && got != value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,560 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-crawler/internal/crawler"
+)
+
+// JobStatus describes where a crawl job is in its lifecycle
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobPaused    JobStatus = "paused" // Running, but outside its time windows
+	JobCompleted JobStatus = "completed"
+)
+
+// Priority controls queue order and worker allocation for a job
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityNormal Priority = "normal"
+	PriorityHigh   Priority = "high"
+)
+
+// ParsePriority validates a priority name; an empty name means normal
+func ParsePriority(name string) (Priority, error) {
+	switch p := Priority(name); p {
+	case "":
+		return PriorityNormal, nil
+	case PriorityLow, PriorityNormal, PriorityHigh:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid priority %q (want low, normal or high)", name)
+	}
+}
+
+// weight is the relative share of workers a job of this priority receives
+// when it runs alongside other jobs
+func (p Priority) weight() int {
+	switch p {
+	case PriorityLow:
+		return 1
+	case PriorityHigh:
+		return 4
+	default:
+		return 2
+	}
+}
+
+// ErrQuotaExceeded is returned when a user already has as many active jobs
+// as their quota allows
+var ErrQuotaExceeded = errors.New("active job quota exceeded")
+
+// ErrJobNotRunning is returned when a running job's settings are changed
+// after it finished
+var ErrJobNotRunning = errors.New("crawl is not running")
+
+// Job is a single crawl submitted to the server
+type Job struct {
+	ID         string
+	Owner      string // Name of the submitting user, empty when auth is off
+	Request    CrawlRequest
+	Priority   Priority
+	Workers    int // Workers allocated by priority, rebalanced as jobs start and finish
+	Status     JobStatus
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Skips      *SkipLog
+	Results    *ResultLog
+	Compliance *ComplianceLog
+	// Events is the bus the job's crawler publishes on; the skip log and
+	// EventCounts are fed from it
+	Events      *crawler.EventBus
+	EventCounts *EventLog
+	// RerunOf is the job this one reran with the same request, and Lineage
+	// the first job of that request, which every rerun shares
+	RerunOf string
+	Lineage string
+	// Interrupted is set on a job the server stopped before it finished,
+	// restored from a store with the results it had recorded
+	Interrupted bool
+
+	restored *JobInfo                         // As stored, for a job restored from a store
+	workers  int                              // Workers asked for, before scaling by priority
+	resume   *crawler.Checkpoint              // Where to continue from, for a resumed job
+	done     chan struct{}                    // Closed when the job finishes
+	crawler  atomic.Pointer[crawler.Crawler]  // Set once the job starts crawling
+	estimate atomic.Pointer[crawler.Estimate] // Set if the request asked for one
+	run      func(ctx context.Context, job *Job)
+	cancel   context.CancelFunc
+}
+
+// Crawler returns the job's crawler, or nil if it hasn't started yet
+func (j *Job) Crawler() *crawler.Crawler {
+	return j.crawler.Load()
+}
+
+// JobInfo is a point-in-time view of a job that is safe to serialize
+type JobInfo struct {
+	ID         string       `json:"id"`
+	Owner      string       `json:"owner,omitempty"`
+	Status     JobStatus    `json:"status"`
+	Priority   Priority     `json:"priority"`
+	Workers    int          `json:"workers,omitempty"`
+	Position   int          `json:"position,omitempty"`
+	Request    CrawlRequest `json:"request"`
+	Pages      int          `json:"pages"`   // Results so far
+	Errors     int          `json:"errors"`  // Results with an error
+	Skipped    int          `json:"skipped"` // URLs skipped so far
+	CreatedAt  time.Time    `json:"createdAt"`
+	StartedAt  *time.Time   `json:"startedAt,omitempty"`
+	FinishedAt *time.Time   `json:"finishedAt,omitempty"`
+
+	// ThinPages counts the pages whose main text is under the request's
+	// thinContent words, when it is set
+	ThinPages int `json:"thinPages,omitempty"`
+	// Events counts the crawl's events so far by type
+	Events map[crawler.EventType]int `json:"events,omitempty"`
+	// Throttling lists the hosts that paused the job with 429 or 503
+	Throttling []crawler.HostThrottle `json:"throttling,omitempty"`
+	// Bytes is the page body bytes read so far, to compare with Estimate
+	Bytes    int64             `json:"bytes,omitempty"`
+	Estimate *crawler.Estimate `json:"estimate,omitempty"`
+	// RerunOf is the job this one reran and Lineage the first job of its
+	// request, shared by all of its reruns
+	RerunOf string `json:"rerunOf,omitempty"`
+	Lineage string `json:"lineage,omitempty"`
+	// Interrupted is set when the server stopped before the job finished,
+	// so its results are partial
+	Interrupted bool `json:"interrupted,omitempty"`
+	// Exclusions are the patterns added while the job ran
+	Exclusions []crawler.Exclusion `json:"exclusions,omitempty"`
+	// Settings are a running job's current workers, delay and per-host
+	// limit, which PATCH /crawl/{id}/settings may have changed
+	Settings *crawler.Settings `json:"settings,omitempty"`
+}
+
+// JobManager runs at most maxConcurrent crawl jobs at once and holds the
+// rest in a queue ordered by priority, FIFO within the same priority
+type JobManager struct {
+	mu            sync.Mutex
+	maxConcurrent int
+	running       map[*Job]struct{}
+	queue         []*Job
+	jobs          map[string]*Job
+	store         Store // Where jobs and results are persisted, if set
+}
+
+func NewJobManager(maxConcurrent int) *JobManager {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &JobManager{
+		maxConcurrent: maxConcurrent,
+		running:       make(map[*Job]struct{}),
+		jobs:          make(map[string]*Job),
+	}
+}
+
+// Submit registers a new job and starts it if a slot is free, otherwise it is
+// queued. The returned position is 0 when the job started immediately.
+func (m *JobManager) Submit(user *User, req CrawlRequest, priority Priority, run func(ctx context.Context, job *Job)) (*Job, int, error) {
+	return m.submit(user, req, priority, run, nil)
+}
+
+// Rerun submits a job like Submit, recording it as a rerun of previous,
+// whose request req should be
+func (m *JobManager) Rerun(user *User, previous *Job, req CrawlRequest, priority Priority, run func(ctx context.Context, job *Job)) (*Job, int, error) {
+	return m.submit(user, req, priority, run, previous)
+}
+
+func (m *JobManager) submit(user *User, req CrawlRequest, priority Priority, run func(ctx context.Context, job *Job), previous *Job) (*Job, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if max := user.Quota.MaxActiveJobs; max > 0 && m.activeJobs(user.Name) >= max {
+		return nil, 0, ErrQuotaExceeded
+	}
+
+	job := &Job{
+		ID:         newJobID(),
+		Owner:      user.Name,
+		Request:    req,
+		Priority:   priority,
+		Status:     JobQueued,
+		CreatedAt:  time.Now(),
+		Skips:      NewSkipLog(),
+		Results:    NewResultLog(),
+		Compliance: NewComplianceLog(),
+		run:        run,
+		workers:    req.Workers,
+	}
+	if previous != nil {
+		job.RerunOf, job.Lineage = previous.ID, previous.Lineage
+		if job.Lineage == "" {
+			job.Lineage = previous.ID
+		}
+	}
+	job.EventCounts = NewEventLog()
+	job.Events = newJobEvents(job)
+	job.done = make(chan struct{})
+	m.jobs[job.ID] = job
+	if m.store != nil {
+		job.Results.writer = newResultWriter(m.store, job.ID)
+		go saveJob(m.store, m.info(job))
+	}
+
+	return job, m.enqueue(job), nil
+}
+
+// enqueue starts a job if a slot is free, otherwise it queues it after
+// every queued job of equal or higher priority. It returns the job's queue
+// position, 0 when it started. The caller must hold m.mu.
+func (m *JobManager) enqueue(job *Job) int {
+	if len(m.running) < m.maxConcurrent {
+		m.start(job)
+		return 0
+	}
+
+	pos := len(m.queue)
+	for i, queued := range m.queue {
+		if queued.Priority.weight() < job.Priority.weight() {
+			pos = i
+			break
+		}
+	}
+	m.queue = append(m.queue, nil)
+	copy(m.queue[pos+1:], m.queue[pos:])
+	m.queue[pos] = job
+	return pos + 1
+}
+
+// Resume runs an interrupted job again from a checkpoint, keeping its ID
+// and the results it had recorded. It returns the job's queue position, 0
+// when it started.
+func (m *JobManager) Resume(job *Job, cp *crawler.Checkpoint, run func(ctx context.Context, job *Job)) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job.Status, job.Interrupted, job.FinishedAt = JobQueued, false, time.Time{}
+	job.workers, job.resume, job.run = job.Request.Workers, cp, run
+	job.done = make(chan struct{})
+	job.Results.reopen()
+	if m.store != nil {
+		job.Results.writer = newResultWriter(m.store, job.ID)
+		go saveJob(m.store, m.info(job))
+	}
+	return m.enqueue(job)
+}
+
+// Interrupted returns the jobs the server stopped before they finished
+func (m *JobManager) Interrupted() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []*Job
+	for _, job := range m.jobs {
+		if job.Interrupted {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// Restore adds jobs loaded from a store, all as completed. Jobs the server
+// stopped while they were queued or running are marked interrupted, with
+// the results they had recorded, and stored again.
+func (m *JobManager) Restore(stored []StoredJob) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range stored {
+		info := s.Info
+		job := &Job{
+			ID:          info.ID,
+			Owner:       info.Owner,
+			Request:     info.Request,
+			Priority:    info.Priority,
+			Workers:     info.Workers,
+			Status:      JobCompleted,
+			CreatedAt:   info.CreatedAt,
+			Skips:       NewSkipLog(),
+			Results:     restoredResultLog(s.Results),
+			Compliance:  NewComplianceLog(),
+			RerunOf:     info.RerunOf,
+			Lineage:     info.Lineage,
+			Interrupted: info.Interrupted,
+			restored:    &info,
+		}
+		job.EventCounts = NewEventLog()
+		job.Events = newJobEvents(job)
+		job.estimate.Store(info.Estimate)
+		if info.StartedAt != nil {
+			job.StartedAt = *info.StartedAt
+		}
+		if info.FinishedAt != nil {
+			job.FinishedAt = *info.FinishedAt
+		}
+		job.done = make(chan struct{})
+		close(job.done)
+		m.jobs[job.ID] = job
+
+		if info.Status != JobCompleted {
+			warnf("Crawl %s was interrupted by a restart after recording %d results", job.ID, len(s.Results))
+			job.Interrupted, job.FinishedAt = true, time.Now()
+			if m.store != nil {
+				go saveJob(m.store, m.info(job))
+			}
+		}
+	}
+}
+
+// activeJobs counts an owner's queued and running jobs; the caller must
+// hold m.mu
+func (m *JobManager) activeJobs(owner string) int {
+	count := 0
+	for _, job := range m.jobs {
+		if job.Owner == owner && job.Status != JobCompleted {
+			count++
+		}
+	}
+	return count
+}
+
+// Get returns the job with the given ID
+func (m *JobManager) Get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	return job, ok
+}
+
+// List returns snapshots of an owner's jobs, oldest first
+func (m *JobManager) List(owner string) []JobInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	infos := []JobInfo{}
+	for _, job := range m.jobs {
+		if job.Owner == owner {
+			infos = append(infos, m.info(job))
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].CreatedAt.Before(infos[j].CreatedAt) })
+	return infos
+}
+
+// Info returns a snapshot of the job with the given ID
+func (m *JobManager) Info(id string) (JobInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return JobInfo{}, false
+	}
+	return m.info(job), true
+}
+
+// info builds a JobInfo; the caller must hold m.mu
+func (m *JobManager) info(job *Job) JobInfo {
+	info := JobInfo{
+		ID:        job.ID,
+		Owner:     job.Owner,
+		Status:    job.Status,
+		Priority:  job.Priority,
+		Workers:   job.Workers,
+		Request:   job.Request,
+		Skipped:   job.Skips.Total(),
+		CreatedAt: job.CreatedAt,
+		RerunOf:   job.RerunOf,
+		Lineage:   job.Lineage,
+
+		Interrupted: job.Interrupted,
+	}
+	info.Request.Proxy = redactProxy(info.Request.Proxy)
+	info.Request.Journey = info.Request.Journey.Redacted()
+	info.Pages, info.Errors = job.Results.Counts()
+	info.Events = job.EventCounts.Counts()
+	if job.Request.ThinContent > 0 {
+		info.ThinPages = len(job.Results.ThinPages(job.Request.ThinContent))
+	}
+	info.Estimate = job.estimate.Load()
+	if r := job.restored; r != nil {
+		// The logs and crawler these come from are not stored
+		info.Skipped, info.Events, info.Throttling, info.Bytes = r.Skipped, r.Events, r.Throttling, r.Bytes
+		info.Exclusions = r.Exclusions
+	}
+	if c := job.Crawler(); c != nil {
+		info.Throttling = c.Throttling()
+		info.Bytes = c.BytesRead()
+		info.Exclusions = c.Exclusions()
+		if job.Status == JobRunning && c.Paused() {
+			info.Status = JobPaused
+		}
+		if job.Status == JobRunning {
+			settings := c.Settings()
+			info.Settings = &settings
+		}
+	}
+	if !job.StartedAt.IsZero() {
+		started := job.StartedAt
+		info.StartedAt = &started
+	}
+	if !job.FinishedAt.IsZero() {
+		finished := job.FinishedAt
+		info.FinishedAt = &finished
+	}
+	if job.Status == JobQueued {
+		for i, queued := range m.queue {
+			if queued == job {
+				info.Position = i + 1
+				break
+			}
+		}
+	}
+	return info
+}
+
+// start launches a job in its own goroutine; the caller must hold m.mu
+func (m *JobManager) start(job *Job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	job.cancel = cancel
+	job.Status = JobRunning
+	if job.StartedAt.IsZero() {
+		job.StartedAt = time.Now() // Kept when a job resumes
+	}
+	m.running[job] = struct{}{}
+	m.rebalance()
+
+	if m.store != nil {
+		go saveJob(m.store, m.info(job))
+	}
+
+	go func() {
+		defer cancel()
+		job.run(ctx, job)
+		m.finish(job)
+		if m.store != nil {
+			job.Results.writer.close()
+			info, _ := m.Info(job.ID)
+			saveJob(m.store, info)
+		}
+	}()
+}
+
+// SetWorkers changes the workers a running job asks for and returns those
+// it gets once scaled by its priority, as when it started. It returns
+// ErrJobNotRunning if the job finished in the meantime.
+func (m *JobManager) SetWorkers(job *Job, workers int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.running[job]; !ok {
+		return 0, ErrJobNotRunning
+	}
+	job.workers = workers
+	job.Workers = m.allocateWorkers(job)
+	return job.Workers, nil
+}
+
+// finish marks a job as done and starts the next queued job, if any
+func (m *JobManager) finish(job *Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job.Status = JobCompleted
+	job.FinishedAt = time.Now()
+	job.Results.Close()
+	close(job.done)
+	delete(m.running, job)
+
+	for len(m.running) < m.maxConcurrent && len(m.queue) > 0 {
+		next := m.queue[0]
+		m.queue = m.queue[1:]
+		m.start(next)
+	}
+	m.rebalance()
+}
+
+// rebalance reallocates the workers of every running job, after a job
+// starts or finishes, and applies the changes to the crawlers already
+// running. The caller must hold m.mu.
+func (m *JobManager) rebalance() {
+	for job := range m.running {
+		workers := m.allocateWorkers(job)
+		if workers == job.Workers {
+			continue
+		}
+		job.Workers = workers
+		if c := job.Crawler(); c != nil {
+			settings := c.Settings()
+			settings.Workers = workers
+			c.UpdateSettings(settings)
+		}
+	}
+}
+
+// attachCrawler builds a job's crawler with the job's workers and records
+// it, under m.mu so that a rebalance either sees the crawler or is already
+// reflected in its workers
+func (m *JobManager) attachCrawler(job *Job, build func(workers int) *crawler.Crawler) *crawler.Crawler {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := build(job.Workers)
+	job.crawler.Store(c)
+	return c
+}
+
+// allocateWorkers scales a job's requested workers by its priority weight
+// relative to the highest-priority job running alongside it, so a low
+// priority batch crawl yields workers to an interactive high priority one
+// and gets them back when it finishes. A job running on its own always
+// gets what it asked for. The caller must hold m.mu.
+func (m *JobManager) allocateWorkers(job *Job) int {
+	maxWeight := 0
+	for running := range m.running {
+		if w := running.Priority.weight(); w > maxWeight {
+			maxWeight = w
+		}
+	}
+	if maxWeight == 0 {
+		return job.workers // Not running, so there is nothing to share
+	}
+
+	workers := job.workers * job.Priority.weight() / maxWeight
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+// newJobID returns a short random identifier for a job
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// redactProxy hides the password of a proxy URL from job listings
+func redactProxy(proxy string) string {
+	if u, err := crawler.ParseProxy(proxy); err == nil {
+		return u.Redacted()
+	}
+	return proxy
+}
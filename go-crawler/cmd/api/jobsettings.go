@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// JobSettingsUpdate is a partial update of a running job's politeness
+// settings; nil fields are left unchanged
+type JobSettingsUpdate struct {
+	Workers      *int           `json:"workers"`
+	Delay        *time.Duration `json:"delay"`
+	PerHostLimit *int           `json:"perHostLimit"` // 0 = unlimited
+}
+
+// handleUpdateJobSettings changes a running job's workers, delay and
+// per-host limit without restarting it, e.g. to back off when a site
+// complains about the load. Workers are capped by the user's quota and the
+// server's limit, then scaled by the job's priority like at start.
+func (s *APIServer) handleUpdateJobSettings(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var update JobSettingsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	info, _ := s.jobs.Info(job.ID)
+	c := job.Crawler()
+	if c == nil || (info.Status != JobRunning && info.Status != JobPaused) {
+		http.Error(w, "Crawl is not running", http.StatusConflict)
+		return
+	}
+
+	settings := c.Settings()
+	if update.Workers != nil {
+		settings.Workers = *update.Workers
+		if max := userFromContext(r.Context()).Quota.MaxWorkers; max > 0 && settings.Workers > max {
+			settings.Workers = max
+		}
+		if max := s.settings.Get().MaxWorkersPerJob; max > 0 && settings.Workers > max {
+			settings.Workers = max
+		}
+	}
+	if update.Delay != nil {
+		settings.Delay = *update.Delay
+	}
+	if update.PerHostLimit != nil {
+		settings.PerHostLimit = *update.PerHostLimit
+	}
+	if err := settings.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if update.Workers != nil {
+		workers, err := s.jobs.SetWorkers(job, settings.Workers)
+		if err == ErrJobNotRunning {
+			http.Error(w, "Crawl is not running", http.StatusConflict)
+			return
+		}
+		settings.Workers = workers
+	}
+	if err := c.UpdateSettings(settings); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	infof("Crawl %s settings updated: %+v", job.ID, settings)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LinkRotRun is the internal link health of one completed crawl
+type LinkRotRun struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Pages      int       `json:"pages"`  // Results on the site's host
+	Broken     int       `json:"broken"` // Of those, the ones that failed
+	New        int       `json:"new"`    // Broken now but not in the previous run
+	Fixed      int       `json:"fixed"`  // Broken in the previous run but not now
+}
+
+// LinkRotSeries is the broken link count of a site over its crawls, oldest
+// first. Trend compares the last run with the one before it.
+type LinkRotSeries struct {
+	Host   string       `json:"host"`
+	Runs   []LinkRotRun `json:"runs,omitempty"`
+	Count  int          `json:"count"`  // Runs in the series
+	Broken int          `json:"broken"` // Broken links in the last run
+	Change int          `json:"change"` // Broken links compared to the run before
+	Trend  string       `json:"trend"`  // improving, regressing, steady or new
+}
+
+// BrokenURLs returns the URLs on host that failed, and how many results on
+// host were recorded
+func (l *ResultLog) BrokenURLs(host string) (map[string]bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	broken := make(map[string]bool)
+	pages := 0
+	for _, p := range l.results {
+		if urlHost(p.URL) != host {
+			continue
+		}
+		pages++
+		if p.Error != "" {
+			broken[p.URL] = true
+		}
+	}
+	return broken, pages
+}
+
+// urlHost returns the lower-case host of a URL, with its port
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// completedJobs returns an owner's completed jobs, oldest first by when they
+// finished
+func (m *JobManager) completedJobs(owner string) []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []*Job
+	for _, job := range m.jobs {
+		if job.Owner == owner && job.Status == JobCompleted {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].FinishedAt.Before(jobs[j].FinishedAt) })
+	return jobs
+}
+
+// linkRot builds the series of every site an owner crawled, keeping the
+// last limit runs of each
+func (s *APIServer) linkRot(owner string, limit int) []LinkRotSeries {
+	byHost := make(map[string]*LinkRotSeries)
+	var hosts []string
+	previous := make(map[string]map[string]bool)
+	for _, job := range s.jobs.completedJobs(owner) {
+		host := urlHost(job.Request.URL)
+		broken, pages := job.Results.BrokenURLs(host)
+		run := LinkRotRun{
+			ID:         job.ID,
+			URL:        job.Request.URL,
+			FinishedAt: job.FinishedAt,
+			Pages:      pages,
+			Broken:     len(broken),
+		}
+
+		series, ok := byHost[host]
+		if !ok {
+			series = &LinkRotSeries{Host: host}
+			byHost[host] = series
+			hosts = append(hosts, host)
+			run.New = len(broken)
+		} else {
+			for u := range broken {
+				if !previous[host][u] {
+					run.New++
+				}
+			}
+			for u := range previous[host] {
+				if !broken[u] {
+					run.Fixed++
+				}
+			}
+		}
+		previous[host] = broken
+		series.Runs = append(series.Runs, run)
+	}
+
+	sort.Strings(hosts)
+	all := make([]LinkRotSeries, 0, len(hosts))
+	for _, host := range hosts {
+		series := byHost[host]
+		runs := series.Runs
+		series.Count = len(runs)
+		series.Broken = runs[len(runs)-1].Broken
+		series.Trend = "new"
+		if len(runs) > 1 {
+			series.Change = series.Broken - runs[len(runs)-2].Broken
+			switch {
+			case series.Change < 0:
+				series.Trend = "improving"
+			case series.Change > 0:
+				series.Trend = "regressing"
+			default:
+				series.Trend = "steady"
+			}
+		}
+		if len(runs) > limit {
+			series.Runs = runs[len(runs)-limit:]
+		}
+		all = append(all, *series)
+	}
+	return all
+}
+
+// handleLinkRot reports broken internal links per crawl over time. Without
+// a host parameter it summarises every site the user crawled; with one it
+// lists that site's runs, the last 50 or limit of them.
+func (s *APIServer) handleLinkRot(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 50
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	all := s.linkRot(userFromContext(r.Context()).Name, limit)
+	host := strings.ToLower(query.Get("host"))
+	if host == "" {
+		for i := range all {
+			all[i].Runs = nil
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(all)
+		return
+	}
+	for _, series := range all {
+		if series.Host == host {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(series)
+			return
+		}
+	}
+	http.Error(w, "No completed crawls of "+host, http.StatusNotFound)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// logLevel filters server log output; errors are always logged
+type logLevel int32
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+var currentLogLevel atomic.Int32
+
+func init() {
+	currentLogLevel.Store(int32(levelInfo))
+}
+
+func (l logLevel) String() string {
+	return logLevelNames[l]
+}
+
+// parseLogLevel converts a level name such as "debug" to a logLevel
+func parseLogLevel(name string) (logLevel, error) {
+	for i, n := range logLevelNames {
+		if strings.EqualFold(name, n) {
+			return logLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid log level %q (want debug, info, warn or error)", name)
+}
+
+func setLogLevel(l logLevel) {
+	currentLogLevel.Store(int32(l))
+}
+
+func getLogLevel() logLevel {
+	return logLevel(currentLogLevel.Load())
+}
+
+func logAt(l logLevel, format string, args ...interface{}) {
+	if l >= getLogLevel() {
+		log.Printf(format, args...)
+	}
+}
+
+func debugf(format string, args ...interface{}) { logAt(levelDebug, format, args...) }
+func infof(format string, args ...interface{})  { logAt(levelInfo, format, args...) }
+func warnf(format string, args ...interface{})  { logAt(levelWarn, format, args...) }
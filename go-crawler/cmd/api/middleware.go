@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// logRequests logs every request with its status, response size and latency
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		infof("%s %s %d %dB %v", r.Method, r.URL.RequestURI(), rec.status, rec.bytes, time.Since(start).Round(time.Microsecond))
+	})
+}
+
+// statusRecorder records the status code and body size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets WebSocket upgrades through the logger
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not support hijacking")
+	}
+	rec.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// compressResponses gzips responses for clients that accept it. WebSocket
+// upgrades are passed through untouched.
+func compressResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipResponseWriter{ResponseWriter: w, head: r.Method == http.MethodHead}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(strings.SplitN(enc, ";", 2)[0]) == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter compresses the body unless the handler already encoded
+// it or the response has no body
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	head        bool
+	wroteHeader bool
+}
+
+func (gw *gzipResponseWriter) WriteHeader(code int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+
+	h := gw.Header()
+	if !gw.head && code != http.StatusNoContent && code != http.StatusNotModified &&
+		code >= http.StatusOK && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		gw.gz = gzip.NewWriter(gw.ResponseWriter)
+	}
+	gw.ResponseWriter.WriteHeader(code)
+}
+
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !gw.wroteHeader {
+		if gw.Header().Get("Content-Type") == "" {
+			// Sniff before compressing, as the server would have
+			gw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.gz == nil {
+		return gw.ResponseWriter.Write(b)
+	}
+	return gw.gz.Write(b)
+}
+
+// Flush pushes compressed data to the client so streamed responses keep
+// flowing
+func (gw *gzipResponseWriter) Flush() {
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	if f, ok := gw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (gw *gzipResponseWriter) close() {
+	if gw.gz != nil {
+		gw.gz.Close()
+	}
+}
+
+// cacheResponses adds an ETag to successful GET responses and answers
+// If-None-Match with 304 Not Modified, so the UI's assets and unchanged
+// API responses aren't sent again. Responses that flush early, such as
+// streams, are passed through without an ETag.
+func cacheResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &etagResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+		cw.finish(r)
+	})
+}
+
+// etagResponseWriter buffers a response to hash it, until the handler
+// flushes or sets a non-200 status
+type etagResponseWriter struct {
+	http.ResponseWriter
+	buf         bytes.Buffer
+	status      int
+	passthrough bool
+}
+
+func (cw *etagResponseWriter) WriteHeader(code int) {
+	cw.status = code
+	if code != http.StatusOK {
+		cw.startPassthrough()
+	}
+}
+
+func (cw *etagResponseWriter) Write(b []byte) (int, error) {
+	if cw.passthrough {
+		return cw.ResponseWriter.Write(b)
+	}
+	return cw.buf.Write(b)
+}
+
+func (cw *etagResponseWriter) Flush() {
+	cw.startPassthrough()
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// startPassthrough sends the status and anything buffered so far and
+// stops buffering
+func (cw *etagResponseWriter) startPassthrough() {
+	if cw.passthrough {
+		return
+	}
+	cw.passthrough = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.ResponseWriter.Write(cw.buf.Bytes())
+	cw.buf.Reset()
+}
+
+func (cw *etagResponseWriter) finish(r *http.Request) {
+	if cw.passthrough {
+		return
+	}
+
+	h := cw.Header()
+	if h.Get("ETag") == "" {
+		sum := sha256.Sum256(cw.buf.Bytes())
+		h.Set("ETag", `W/"`+hex.EncodeToString(sum[:16])+`"`)
+	}
+	if h.Get("Cache-Control") == "" {
+		// Cache, but always revalidate so clients see new results
+		h.Set("Cache-Control", "no-cache")
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), h.Get("ETag")) {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		cw.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.ResponseWriter.Write(cw.buf.Bytes())
+}
+
+// etagMatches implements the weak comparison used by If-None-Match
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+CREATE TABLE crawl_jobs (
+    id          text PRIMARY KEY,
+    owner       text NOT NULL DEFAULT '',
+    status      text NOT NULL,
+    priority    text NOT NULL,
+    url         text NOT NULL,
+    request     jsonb NOT NULL,
+    pages       integer NOT NULL DEFAULT 0,
+    errors      integer NOT NULL DEFAULT 0,
+    skipped     integer NOT NULL DEFAULT 0,
+    bytes       bigint NOT NULL DEFAULT 0,
+    created_at  timestamptz NOT NULL,
+    started_at  timestamptz,
+    finished_at timestamptz,
+    updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX crawl_jobs_owner_created_at ON crawl_jobs (owner, created_at);
+
+CREATE TABLE crawl_results (
+    job_id           text NOT NULL,
+    id               text NOT NULL,
+    url              text NOT NULL,
+    depth            integer NOT NULL,
+    status_code      integer NOT NULL DEFAULT 0,
+    content_type     text NOT NULL DEFAULT '',
+    language         text NOT NULL DEFAULT '',
+    title            text NOT NULL DEFAULT '',
+    meta_description text NOT NULL DEFAULT '',
+    h1_count         integer NOT NULL DEFAULT 0,
+    canonical        text NOT NULL DEFAULT '',
+    change           text NOT NULL DEFAULT '',
+    error            text NOT NULL DEFAULT '',
+    links            text[] NOT NULL DEFAULT '{}',
+    labels           text[] NOT NULL DEFAULT '{}',
+    note             text NOT NULL DEFAULT '',
+    annotated_at     timestamptz,
+    data             jsonb NOT NULL,
+    recorded_at      timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (job_id, id)
+);
+
+CREATE INDEX crawl_results_url ON crawl_results (url);
+CREATE INDEX crawl_results_status_code ON crawl_results (job_id, status_code);
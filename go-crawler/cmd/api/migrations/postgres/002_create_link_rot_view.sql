@@ -0,0 +1,16 @@
+-- Broken internal links per completed crawl, the same figures as GET /linkrot.
+-- A result is internal when its host, with any port, is the start URL's.
+CREATE VIEW crawl_link_rot AS
+SELECT j.owner,
+       lower(split_part(split_part(j.url, '://', 2), '/', 1)) AS host,
+       j.id AS job_id,
+       j.url,
+       j.finished_at,
+       count(r.id) AS pages,
+       count(r.id) FILTER (WHERE r.error <> '') AS broken
+FROM crawl_jobs j
+LEFT JOIN crawl_results r
+       ON r.job_id = j.id
+      AND lower(split_part(split_part(r.url, '://', 2), '/', 1)) = lower(split_part(split_part(j.url, '://', 2), '/', 1))
+WHERE j.status = 'completed'
+GROUP BY j.owner, j.id, j.url, j.finished_at;
@@ -0,0 +1,7 @@
+-- Reruns of a crawl request: the job each one reran and the first job of
+-- the request, shared by all of its reruns.
+ALTER TABLE crawl_jobs
+    ADD COLUMN rerun_of text,
+    ADD COLUMN lineage  text;
+
+CREATE INDEX crawl_jobs_lineage ON crawl_jobs (lineage) WHERE lineage IS NOT NULL;
@@ -0,0 +1,4 @@
+-- The result schema version each result's data column follows. Results
+-- stored before versioning are version 1.
+ALTER TABLE crawl_results
+    ADD COLUMN schema_version integer NOT NULL DEFAULT 1;
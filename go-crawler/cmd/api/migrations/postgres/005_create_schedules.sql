@@ -0,0 +1,8 @@
+-- Schedules, with their webhook tokens, so that they survive restarts.
+-- data is the whole schedule as JSON.
+CREATE TABLE crawl_schedules (
+    id         text PRIMARY KEY,
+    owner      text NOT NULL DEFAULT '',
+    data       jsonb NOT NULL,
+    created_at timestamptz NOT NULL
+);
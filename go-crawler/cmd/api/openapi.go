@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// openAPIJSON renders the OpenAPI document of routes
+func openAPIJSON(routes []route) []byte {
+	doc, err := json.MarshalIndent(buildOpenAPI(routes), "", "  ")
+	if err != nil {
+		panic(fmt.Sprintf("building OpenAPI document: %v", err))
+	}
+	return doc
+}
+
+// pathParam matches a {name} segment of a route path
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// buildOpenAPI describes routes as an OpenAPI 3 document
+func buildOpenAPI(routes []route) map[string]any {
+	schemas := newSchemaSet()
+	paths := map[string]map[string]any{}
+	for _, rt := range routes {
+		op := map[string]any{
+			"summary":     rt.summary,
+			"tags":        []string{rt.tag},
+			"operationId": operationID(routes, rt),
+		}
+		var params []map[string]any
+		for _, m := range pathParam.FindAllStringSubmatch(rt.path, -1) {
+			params = append(params, map[string]any{
+				"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+			})
+		}
+		for _, q := range rt.query {
+			params = append(params, map[string]any{
+				"name": q.name, "in": "query", "description": q.description, "schema": map[string]any{"type": "string"},
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if rt.request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schemas.of(reflect.TypeOf(rt.request))}},
+			}
+		}
+
+		status := rt.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		ok := map[string]any{"description": http.StatusText(status)}
+		content := map[string]any{}
+		if rt.response != nil {
+			schema := schemas.of(reflect.TypeOf(rt.response))
+			if rt.stream {
+				content["application/x-ndjson"] = map[string]any{"schema": schema}
+			} else {
+				content["application/json"] = map[string]any{"schema": schema}
+			}
+		}
+		for _, ct := range rt.formats {
+			content[ct] = map[string]any{"schema": map[string]any{"type": "string"}}
+		}
+		if len(content) > 0 {
+			ok["content"] = content
+		}
+		responses := map[string]any{strconv.Itoa(status): ok}
+		if rt.request != nil || len(rt.query) > 0 {
+			responses["400"] = map[string]any{"description": "Invalid request"}
+		}
+		if strings.Contains(rt.path, "{") {
+			responses["404"] = map[string]any{"description": "Not found"}
+		}
+		switch rt.access {
+		case accessPublic:
+			op["security"] = []any{}
+		case accessUser:
+			responses["401"] = map[string]any{"description": "Missing or unknown API key"}
+		case accessAdmin:
+			responses["401"] = map[string]any{"description": "Missing or unknown API key"}
+			responses["403"] = map[string]any{"description": "Not an admin"}
+		}
+		op["responses"] = responses
+
+		if paths[rt.path] == nil {
+			paths[rt.path] = map[string]any{}
+		}
+		paths[rt.path][strings.ToLower(rt.method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Crawler API",
+			"version":     "1.0",
+			"description": "Start and inspect web crawls. API keys are needed only when the server runs with -users.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.defs,
+			"securitySchemes": map[string]any{
+				"apiKeyHeader": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearer":       map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKeyQuery":  map[string]any{"type": "apiKey", "in": "query", "name": "api_key"},
+			},
+		},
+		"security": []any{
+			map[string]any{"apiKeyHeader": []string{}},
+			map[string]any{"bearer": []string{}},
+			map[string]any{"apiKeyQuery": []string{}},
+		},
+	}
+}
+
+// operationID names a route's operation after its handler, e.g.
+// handleGetCrawl becomes getCrawl; routes sharing a handler get the method
+// appended
+func operationID(routes []route, rt route) string {
+	name := handlerName(rt)
+	for _, other := range routes {
+		if other.path == rt.path && other.method != rt.method && handlerName(other) == name {
+			return name + strings.ToUpper(rt.method[:1]) + strings.ToLower(rt.method[1:])
+		}
+	}
+	return name
+}
+
+func handlerName(rt route) string {
+	name := runtime.FuncForPC(reflect.ValueOf(rt.handler).Pointer()).Name()
+	name = strings.TrimPrefix(name[strings.LastIndex(name, ".")+1:], "handle")
+	name = strings.TrimSuffix(name, "-fm")
+	if name == "" {
+		return strings.ToLower(rt.method) + rt.path
+	}
+	// Lower the leading word, keeping an acronym whole: APIDocs is apiDocs
+	r := []rune(name)
+	for i := 0; i < len(r) && unicode.IsUpper(r[i]); i++ {
+		if i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1]) {
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
+
+// schemaSet collects the component schemas of struct types, named after
+// their Go types
+type schemaSet struct {
+	defs  map[string]any
+	names map[reflect.Type]string
+	taken map[string]reflect.Type
+}
+
+func newSchemaSet() *schemaSet {
+	return &schemaSet{defs: map[string]any{}, names: map[reflect.Type]string{}, taken: map[string]reflect.Type{}}
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	rawJSONType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// of returns the schema of values of t as encoding/json writes them,
+// referring to struct types by name
+func (ss *schemaSet) of(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]any{"type": "integer", "format": "int64", "description": "Nanoseconds"}
+	case rawJSONType:
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return ss.of(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": ss.of(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": ss.of(t.Elem())}
+	case reflect.Struct:
+		return map[string]any{"$ref": "#/components/schemas/" + ss.define(t)}
+	}
+	// Interfaces and anything else may hold any JSON value
+	return map[string]any{}
+}
+
+// define adds t's schema to the set if it isn't there yet and returns its
+// name
+func (ss *schemaSet) define(t reflect.Type) string {
+	if name, ok := ss.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if name == "" {
+		name = "Object"
+	}
+	name = strings.ToUpper(name[:1]) + name[1:]
+	if other, ok := ss.taken[name]; ok && other != t {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	ss.names[t], ss.taken[name] = name, t
+	// Register the name before the properties so that recursive types
+	// refer to themselves
+	props := map[string]any{}
+	ss.defs[name] = map[string]any{"type": "object", "properties": props}
+	ss.addFields(t, props)
+	return name
+}
+
+// addFields adds the JSON properties of a struct's fields to props,
+// flattening embedded structs as encoding/json does
+func (ss *schemaSet) addFields(t reflect.Type, props map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				ss.addFields(ft, props)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		var schema map[string]any
+		switch {
+		case f.Tag.Get("openapi") != "":
+			// A raw JSON field documented as the named type
+			schema = map[string]any{"$ref": "#/components/schemas/" + f.Tag.Get("openapi")}
+		case strings.Contains(opts, "string"):
+			schema = map[string]any{"type": "string"}
+		default:
+			schema = ss.of(f.Type)
+		}
+		props[name] = schema
+	}
+}
+
+// handleOpenAPI serves the OpenAPI document
+func (s *APIServer) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.openAPI)
+}
+
+// apiDocsPage is Swagger UI pointed at /openapi.json
+const apiDocsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Crawler API</title>
+    <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+    <div id="swagger-ui"></div>
+    <script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+    <script>
+        SwaggerUIBundle({url: '/openapi.json', dom_id: '#swagger-ui', persistAuthorization: true});
+    </script>
+</body>
+</html>
+`
+
+// handleAPIDocs serves Swagger UI for the OpenAPI document
+func (s *APIServer) handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(apiDocsPage))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"go-crawler/internal/crawler"
+)
+
+// pluginFlag is the repeatable -plugin flag: name=program [args...]
+type pluginFlag map[string][]string
+
+func (f pluginFlag) String() string {
+	return strings.Join(f.names(), ", ")
+}
+
+func (f pluginFlag) Set(v string) error {
+	name, command, ok := strings.Cut(v, "=")
+	args := strings.Fields(command)
+	if !ok || strings.TrimSpace(name) == "" || len(args) == 0 {
+		return fmt.Errorf("want name=program [args...]")
+	}
+	f[strings.TrimSpace(name)] = args
+	return nil
+}
+
+func (f pluginFlag) names() []string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkPlugins starts every plugin once so a broken one is reported when
+// the server starts rather than when a crawl uses it
+func checkPlugins(plugins pluginFlag) error {
+	for _, name := range plugins.names() {
+		args := plugins[name]
+		p := crawler.NewPlugin(args[0], args[1:]...)
+		if err := p.Start(); err != nil {
+			return fmt.Errorf("plugin %s: %v", name, err)
+		}
+		p.Close()
+	}
+	return nil
+}
+
+// checkPluginNames reports the first plugin a crawl request names that the
+// server does not offer
+func (s *APIServer) checkPluginNames(names []string) error {
+	for _, name := range names {
+		if _, ok := s.plugins[name]; !ok {
+			return fmt.Errorf("Unknown plugin %q", name)
+		}
+	}
+	return nil
+}
+
+// pluginProcessors returns a fresh instance of each named plugin for one
+// crawl. The programs start when the crawl first uses them and exit when
+// it finishes.
+func (s *APIServer) pluginProcessors(names []string) []crawler.Processor {
+	var processors []crawler.Processor
+	for _, name := range names {
+		args := s.plugins[name]
+		processors = append(processors, crawler.NewPlugin(args[0], args[1:]...))
+	}
+	return processors
+}
+
+// handlePlugins lists the plugins crawl requests can enable
+func (s *APIServer) handlePlugins(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.plugins.names())
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresMigrations are applied in file name order; each file's name
+// starts with its version number
+//
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// postgresMigrationLock is the advisory lock key held while migrating, so
+// servers starting together don't apply a migration twice
+const postgresMigrationLock = 7262113
+
+// PostgresStore is a Store backed by a PostgreSQL connection pool
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// OpenPostgresStore connects to PostgreSQL and applies any pending schema
+// migrations. The pool size and timeouts can be set with pool_max_conns and
+// similar parameters in the connection string.
+func OpenPostgresStore(ctx context.Context, dsn string) (*PostgresStore, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PostgreSQL connection string: %v", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("error connecting to PostgreSQL: %v", err)
+	}
+	if err := migratePostgres(ctx, pool); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return &PostgresStore{pool: pool}, nil
+}
+
+// migratePostgres applies the migrations not yet recorded in
+// schema_migrations, each in its own transaction
+func migratePostgres(ctx context.Context, pool *pgxpool.Pool) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("error migrating PostgreSQL: %v", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", postgresMigrationLock); err != nil {
+		return fmt.Errorf("error migrating PostgreSQL: %v", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", postgresMigrationLock)
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    integer PRIMARY KEY,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("error migrating PostgreSQL: %v", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := conn.Query(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return fmt.Errorf("error migrating PostgreSQL: %v", err)
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return fmt.Errorf("error migrating PostgreSQL: %v", err)
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error migrating PostgreSQL: %v", err)
+	}
+
+	files, err := fs.Glob(postgresMigrations, "migrations/postgres/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		name := path.Base(file)
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("migration %s has no version number", name)
+		}
+		if applied[version] {
+			continue
+		}
+
+		sql, err := postgresMigrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, string(sql)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error applying migration %s: %v", name, err)
+		}
+		infof("Applied PostgreSQL migration %s", name)
+	}
+	return nil
+}
+
+// SaveJob implements Store
+func (s *PostgresStore) SaveJob(ctx context.Context, info JobInfo) error {
+	request, err := json.Marshal(info.Request)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO crawl_jobs (id, owner, status, priority, url, request, pages, errors, skipped, bytes, created_at, started_at, finished_at, rerun_of, lineage)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NULLIF($14, ''), NULLIF($15, ''))
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			pages = EXCLUDED.pages,
+			errors = EXCLUDED.errors,
+			skipped = EXCLUDED.skipped,
+			bytes = EXCLUDED.bytes,
+			started_at = EXCLUDED.started_at,
+			finished_at = EXCLUDED.finished_at,
+			updated_at = now()
+		WHERE crawl_jobs.status <> 'completed'`,
+		info.ID, info.Owner, string(info.Status), string(info.Priority), info.Request.URL, string(request),
+		info.Pages, info.Errors, info.Skipped, info.Bytes, info.CreatedAt, info.StartedAt, info.FinishedAt, info.RerunOf, info.Lineage)
+	return err
+}
+
+// resultColumns are the crawl_results columns a PageResult fills, in the
+// order of postgresResultArgs
+const resultColumns = `job_id, id, url, depth, status_code, content_type, language, title, meta_description,
+	h1_count, canonical, change, error, links, labels, note, annotated_at, data, schema_version`
+
+// resultColumnNames lists resultColumns for COPY
+var resultColumnNames = strings.Fields(strings.ReplaceAll(resultColumns, ",", " "))
+
+func postgresResultArgs(jobID string, p PageResult) ([]any, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	links, labels := p.Links, p.Labels
+	if links == nil {
+		links = []string{}
+	}
+	if labels == nil {
+		labels = []string{}
+	}
+	return []any{
+		jobID, p.ID, p.URL, p.Depth, p.StatusCode, p.ContentType, p.Language, p.Title, p.MetaDescription,
+		p.H1Count, p.Canonical, string(p.Change), p.Error, links, labels, p.Note, p.AnnotatedAt, string(data),
+		p.SchemaVersion,
+	}, nil
+}
+
+// AddResults implements Store. The results are copied into a temporary
+// table and inserted from there in one transaction, which takes a fraction
+// of the round trips and parsing of an INSERT per result.
+func (s *PostgresStore) AddResults(ctx context.Context, jobID string, pages []PageResult) error {
+	rows := make([][]any, 0, len(pages))
+	for _, p := range pages {
+		args, err := postgresResultArgs(jobID, p)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, args)
+	}
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		// Kept for the connection's session and emptied at commit
+		_, err := tx.Exec(ctx, `CREATE TEMP TABLE IF NOT EXISTS crawl_results_batch
+			(LIKE crawl_results INCLUDING DEFAULTS) ON COMMIT DELETE ROWS`)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"crawl_results_batch"}, resultColumnNames, pgx.CopyFromRows(rows)); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `INSERT INTO crawl_results (`+resultColumns+`)
+			SELECT `+resultColumns+` FROM crawl_results_batch
+			ON CONFLICT (job_id, id) DO NOTHING`)
+		return err
+	})
+}
+
+// UpdateResult implements Store
+func (s *PostgresStore) UpdateResult(ctx context.Context, jobID string, page PageResult) error {
+	args, err := postgresResultArgs(jobID, page)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(ctx, `INSERT INTO crawl_results (`+resultColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		ON CONFLICT (job_id, id) DO UPDATE SET
+			labels = EXCLUDED.labels,
+			note = EXCLUDED.note,
+			annotated_at = EXCLUDED.annotated_at,
+			data = EXCLUDED.data,
+			schema_version = EXCLUDED.schema_version`, args...)
+	return err
+}
+
+// DeleteJob implements Store
+func (s *PostgresStore) DeleteJob(ctx context.Context, jobID string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `DELETE FROM crawl_results WHERE job_id = $1`, jobID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM crawl_jobs WHERE id = $1`, jobID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Prune implements Store. Sites are grouped by the start URL's host and
+// port, as in the crawl_link_rot view.
+func (s *PostgresStore) Prune(ctx context.Context, r Retention) (int, error) {
+	if !r.enabled() {
+		return 0, nil
+	}
+	var before *time.Time
+	if r.MaxAge > 0 {
+		t := time.Now().Add(-r.MaxAge)
+		before = &t
+	}
+	var n int
+	err := s.pool.QueryRow(ctx, `
+		WITH ranked AS (
+			SELECT id, finished_at,
+			       row_number() OVER (
+			           PARTITION BY owner, lower(split_part(split_part(url, '://', 2), '/', 1))
+			           ORDER BY finished_at DESC) AS n
+			FROM crawl_jobs
+			WHERE status = 'completed'
+		), gone AS (
+			DELETE FROM crawl_jobs
+			WHERE id IN (
+				SELECT id FROM ranked
+				WHERE ($1 > 0 AND n > $1) OR ($2::timestamptz IS NOT NULL AND finished_at < $2)
+			)
+			RETURNING id
+		), results AS (
+			DELETE FROM crawl_results WHERE job_id IN (SELECT id FROM gone)
+		)
+		SELECT count(*) FROM gone`, r.KeepCrawls, before).Scan(&n)
+	return n, err
+}
+
+// SaveSchedule implements ScheduleKeeper
+func (s *PostgresStore) SaveSchedule(ctx context.Context, sched Schedule) error {
+	data, err := json.Marshal(sched)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO crawl_schedules (id, owner, data, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data`,
+		sched.ID, sched.Owner, data, sched.CreatedAt)
+	return err
+}
+
+// DeleteSchedule implements ScheduleKeeper
+func (s *PostgresStore) DeleteSchedule(ctx context.Context, id string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM crawl_schedules WHERE id = $1`, id)
+	return err
+}
+
+// LoadSchedules implements ScheduleKeeper
+func (s *PostgresStore) LoadSchedules(ctx context.Context) ([]Schedule, error) {
+	rows, err := s.pool.Query(ctx, `SELECT id, data FROM crawl_schedules ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scheds []Schedule
+	for rows.Next() {
+		var id string
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, err
+		}
+		var sched Schedule
+		if err := json.Unmarshal(data, &sched); err != nil {
+			warnf("Skipping stored schedule %s: %v", id, err)
+			continue
+		}
+		scheds = append(scheds, sched)
+	}
+	return scheds, rows.Err()
+}
+
+// Close implements Store
+func (s *PostgresStore) Close() error {
+	s.pool.Close()
+	return nil
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"go-crawler/internal/crawler"
+)
+
+// protocolSchema is the JSON Schema of the WebSocket and event stream
+// messages, served at /schema. The message types below must match it.
+//
+//go:embed protocol.schema.json
+var protocolSchema []byte
+
+// ClientMessage is a message from a WebSocket client: "start" with the
+// crawl's settings, or "stop"
+type ClientMessage struct {
+	Type     string `json:"type"`
+	URL      string `json:"url,omitempty"`
+	Depth    int    `json:"depth,omitempty"`
+	Workers  int    `json:"workers,omitempty"`
+	Delay    int    `json:"delay,omitempty"` // Milliseconds
+	Priority string `json:"priority,omitempty"`
+	SameHost bool   `json:"sameHost,omitempty"`
+	MaxPages int    `json:"maxPages,omitempty"`
+	Preset   string `json:"preset,omitempty"`
+	Script   string `json:"script,omitempty"`
+}
+
+// StartData is the data of "start" and "queued" acknowledgements
+type StartData struct {
+	ID       string `json:"id"`
+	URL      string `json:"url"`
+	Depth    int    `json:"depth"`
+	Workers  int    `json:"workers"`
+	Delay    int    `json:"delay"` // Milliseconds
+	Position int    `json:"position,omitempty"`
+}
+
+// ResultData is the data of a "result" message in version 1 of the result
+// schema. Clients that connect with ?schema=2 get a PageResultV2 instead.
+type ResultData struct {
+	SchemaVersion int `json:"schemaVersion"`
+
+	URL        string                 `json:"url"`
+	Status     string                 `json:"status,omitempty"`
+	Change     crawler.ChangeState    `json:"change,omitempty"`
+	Links      []string               `json:"links,omitempty"`
+	Matches    []crawler.ContentMatch `json:"matches,omitempty"`
+	Findings   []crawler.TextFinding  `json:"findings,omitempty"`
+	Fields     map[string]string      `json:"fields,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	ErrorClass crawler.ErrorClass     `json:"errorClass,omitempty"`
+}
+
+// CompleteData is the data of a "complete" message
+type CompleteData struct {
+	ID           string `json:"id"`
+	URL          string `json:"url"`
+	PagesCrawled int    `json:"pagesCrawled"`
+}
+
+// ValidationError locates a part of a client message that breaks the
+// schema
+type ValidationError struct {
+	Path    string `json:"path"` // JSON Pointer, empty for the whole message
+	Message string `json:"message"`
+}
+
+// ValidationErrors is the data of an "error" message rejecting a client
+// message
+type ValidationErrors struct {
+	Errors []ValidationError `json:"errors"`
+}
+
+// jsonSchema is the subset of JSON Schema protocol.schema.json uses
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+	Type                 schemaTypes            `json:"type"`
+	Const                any                    `json:"const"`
+	Enum                 []any                  `json:"enum"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"` // false or a schema
+	Items                *jsonSchema            `json:"items"`
+	Minimum              *float64               `json:"minimum"`
+	MinLength            *int                   `json:"minLength"`
+	OneOf                []*jsonSchema          `json:"oneOf"`
+	Discriminator        *struct {
+		PropertyName string `json:"propertyName"`
+	} `json:"discriminator"`
+}
+
+// schemaTypes is a schema's "type": one name or a list of them
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// clientSchema validates ClientMessage values; it is parsed at startup so
+// that a broken schema file fails fast
+var clientSchema = mustParseSchema(protocolSchema, "ClientMessage")
+
+// mustParseSchema returns a validator for one of the schema's definitions
+func mustParseSchema(data []byte, def string) *schemaValidator {
+	var root jsonSchema
+	if err := json.Unmarshal(data, &root); err != nil {
+		panic(fmt.Sprintf("invalid protocol schema: %v", err))
+	}
+	s, ok := root.Defs[def]
+	if !ok {
+		panic(fmt.Sprintf("protocol schema has no definition %q", def))
+	}
+	return &schemaValidator{root: &root, schema: s}
+}
+
+// schemaValidator checks decoded JSON values against a schema, resolving
+// references within its root document
+type schemaValidator struct {
+	root   *jsonSchema
+	schema *jsonSchema
+}
+
+// Validate returns where v breaks the schema, or nothing if it conforms
+func (sv *schemaValidator) Validate(v any) []ValidationError {
+	var errs []ValidationError
+	sv.validate(sv.schema, v, "", &errs)
+	return errs
+}
+
+func (sv *schemaValidator) validate(s *jsonSchema, v any, path string, errs *[]ValidationError) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.Ref != "" {
+		ref, ok := sv.root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if !ok {
+			fail("unresolved reference %s", s.Ref)
+			return
+		}
+		s = ref
+	}
+	if s.Discriminator != nil {
+		sv.validateOneOf(s, v, path, errs)
+		return
+	}
+	if len(s.Type) > 0 && !hasType(s.Type, v) {
+		fail("must be of type %s", strings.Join(s.Type, " or "))
+		return
+	}
+	if s.Const != nil && v != s.Const {
+		fail("must be %s", jsonText(s.Const))
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			found = found || v == e
+		}
+		if !found {
+			names := make([]string, len(s.Enum))
+			for i, e := range s.Enum {
+				names[i] = jsonText(e)
+			}
+			fail("must be one of %s", strings.Join(names, ", "))
+		}
+	}
+
+	switch v := v.(type) {
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("must be at least %v", *s.Minimum)
+		}
+	case string:
+		if s.MinLength != nil && len(v) < *s.MinLength {
+			fail("must not be empty")
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				sv.validate(s.Items, item, fmt.Sprintf("%s/%d", path, i), errs)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, ValidationError{Path: path + "/" + name, Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := path + "/" + pointerEscape(name)
+			if prop, ok := s.Properties[name]; ok {
+				sv.validate(prop, v[name], child, errs)
+				continue
+			}
+			switch extra := strings.TrimSpace(string(s.AdditionalProperties)); {
+			case extra == "false":
+				*errs = append(*errs, ValidationError{Path: child, Message: "is not allowed"})
+			case strings.HasPrefix(extra, "{"):
+				var schema jsonSchema
+				if err := json.Unmarshal(s.AdditionalProperties, &schema); err == nil {
+					sv.validate(&schema, v[name], child, errs)
+				}
+			}
+		}
+	}
+}
+
+// validateOneOf validates v against the branch of a discriminated oneOf
+// whose discriminator property's const matches v's
+func (sv *schemaValidator) validateOneOf(s *jsonSchema, v any, path string, errs *[]ValidationError) {
+	prop := s.Discriminator.PropertyName
+	obj, ok := v.(map[string]any)
+	if !ok {
+		*errs = append(*errs, ValidationError{Path: path, Message: "must be of type object"})
+		return
+	}
+	var names []string
+	for _, branch := range s.OneOf {
+		b := branch
+		if b.Ref != "" {
+			b = sv.root.Defs[strings.TrimPrefix(b.Ref, "#/$defs/")]
+		}
+		if b == nil || b.Properties[prop] == nil {
+			continue
+		}
+		name := b.Properties[prop].Const
+		if obj[prop] == name {
+			sv.validate(b, v, path, errs)
+			return
+		}
+		names = append(names, jsonText(name))
+	}
+	message := "is required"
+	if _, ok := obj[prop]; ok {
+		message = "must be one of " + strings.Join(names, ", ")
+	}
+	*errs = append(*errs, ValidationError{Path: path + "/" + pointerEscape(prop), Message: message})
+}
+
+// hasType reports whether a decoded JSON value is of one of the types
+func hasType(types []string, v any) bool {
+	for _, t := range types {
+		switch t {
+		case "null":
+			if v == nil {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "number":
+			if _, ok := v.(float64); ok {
+				return true
+			}
+		case "integer":
+			if f, ok := v.(float64); ok && f == math.Trunc(f) {
+				return true
+			}
+		case "string":
+			if _, ok := v.(string); ok {
+				return true
+			}
+		case "array":
+			if _, ok := v.([]any); ok {
+				return true
+			}
+		case "object":
+			if _, ok := v.(map[string]any); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonText renders a schema value as JSON for error messages
+func jsonText(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// pointerEscape escapes a property name for a JSON Pointer
+func pointerEscape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+// decodeClientMessage parses and validates a WebSocket client message
+func decodeClientMessage(data []byte) (ClientMessage, []ValidationError) {
+	var msg ClientMessage
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return msg, []ValidationError{{Message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+	if errs := clientSchema.Validate(v); len(errs) > 0 {
+		return msg, errs
+	}
+	// Integers may have been written as 2.0, which only the round trip
+	// through float64 lets the typed message accept
+	normalized, _ := json.Marshal(v)
+	if err := json.Unmarshal(normalized, &msg); err != nil {
+		return msg, []ValidationError{{Message: err.Error()}}
+	}
+	return msg, nil
+}
+
+// handleSchema serves the JSON Schema of the WebSocket protocol and the
+// event stream
+func (s *APIServer) handleSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(protocolSchema)
+}
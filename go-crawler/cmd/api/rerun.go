@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// handleRerun starts a new job with the stored request of a previous one,
+// recorded as its rerun so the runs can be compared
+func (s *APIServer) handleRerun(w http.ResponseWriter, r *http.Request) {
+	previous, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user := userFromContext(r.Context())
+	req := previous.Request
+	priority, err := s.prepareCrawl(user, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	job, position, err := s.jobs.Rerun(user, previous, req, priority, s.broadcastCrawl)
+	if err == ErrQuotaExceeded {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	infof("Crawl %s reruns crawl %s", job.ID, previous.ID)
+
+	resp := CrawlAccepted{ID: job.ID, Status: "Crawl started"}
+	if position > 0 {
+		resp.Status, resp.Position = "queued", position
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// RunComparison is how a job's pages differ from an earlier run's
+type RunComparison struct {
+	Job            string `json:"job"`
+	Previous       string `json:"previous"`
+	Pages          int    `json:"pages"`
+	PreviousPages  int    `json:"previousPages"`
+	Errors         int    `json:"errors"`
+	PreviousErrors int    `json:"previousErrors"`
+	// NewBroken failed in this run only, Fixed failed in the previous run
+	// only, and Added and Removed were fetched in one run and not the other
+	NewBroken []string `json:"newBroken"`
+	Fixed     []string `json:"fixed"`
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+}
+
+// compareRuns compares the pages of two jobs, each list sorted by URL
+func compareRuns(job, previous *Job) RunComparison {
+	c := RunComparison{
+		Job: job.ID, Previous: previous.ID,
+		NewBroken: []string{}, Fixed: []string{}, Added: []string{}, Removed: []string{},
+	}
+	before := map[string]bool{} // URL to whether it failed
+	for _, p := range previous.Results.Report("", "").Results {
+		before[p.URL] = p.Error != ""
+	}
+	c.PreviousPages, c.PreviousErrors = previous.Results.Counts()
+	c.Pages, c.Errors = job.Results.Counts()
+
+	seen := map[string]bool{}
+	for _, p := range job.Results.Report("", "").Results {
+		seen[p.URL] = true
+		failed, fetched := before[p.URL]
+		switch {
+		case !fetched:
+			c.Added = append(c.Added, p.URL)
+			if p.Error != "" {
+				c.NewBroken = append(c.NewBroken, p.URL)
+			}
+		case p.Error != "" && !failed:
+			c.NewBroken = append(c.NewBroken, p.URL)
+		case p.Error == "" && failed:
+			c.Fixed = append(c.Fixed, p.URL)
+		}
+	}
+	for url := range before {
+		if !seen[url] {
+			c.Removed = append(c.Removed, url)
+		}
+	}
+	for _, urls := range [][]string{c.NewBroken, c.Fixed, c.Added, c.Removed} {
+		sort.Strings(urls)
+	}
+	return c
+}
+
+// handleCompareRuns compares a job with the job it reran, or with the job
+// named by the with parameter
+func (s *APIServer) handleCompareRuns(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+	id := r.URL.Query().Get("with")
+	if id == "" {
+		id = job.RerunOf
+	}
+	if id == "" {
+		http.Error(w, "Crawl "+job.ID+" is not a rerun; name the crawl to compare it with in ?with=", http.StatusBadRequest)
+		return
+	}
+	previous, ok := s.jobs.Get(id)
+	if !ok || previous.Owner != job.Owner {
+		http.Error(w, "Crawl "+id+" not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(compareRuns(job, previous))
+}
@@ -0,0 +1,863 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-crawler/internal/crawler"
+)
+
+// PageResult is what a job recorded about one fetched URL
+type PageResult struct {
+	// SchemaVersion is the result schema the page follows, always 1 for
+	// this layout
+	SchemaVersion int `json:"schemaVersion"`
+
+	ID           string               `json:"id"` // resultID of the URL
+	URL          string               `json:"url"`
+	Depth        int                  `json:"depth"`
+	StatusCode   int                  `json:"statusCode,omitempty"`
+	ContentType  string               `json:"contentType,omitempty"`
+	SniffedType  string               `json:"sniffedType,omitempty"` // Detected from the body of pages whose Content-Type didn't say how to parse them
+	ETag         string               `json:"etag,omitempty"`
+	LastModified string               `json:"lastModified,omitempty"`
+	ContentHash  string               `json:"contentHash,omitempty"`
+	Change       crawler.ChangeState  `json:"change,omitempty"`
+	Canonical    string               `json:"canonical,omitempty"`
+	MetaRefresh  *crawler.MetaRefresh `json:"metaRefresh,omitempty"`
+	Alternates   []crawler.Alternate  `json:"alternates,omitempty"`
+	Language     string               `json:"language,omitempty"`
+	Score        float64              `json:"score,omitempty"`
+	// UnavailableAfter is the page's robots unavailable_after date
+	UnavailableAfter *time.Time             `json:"unavailableAfter,omitempty"`
+	FreshUntil       *time.Time             `json:"freshUntil,omitempty"` // When the response goes stale by its caching headers
+	Matches          []crawler.ContentMatch `json:"matches,omitempty"`
+	Findings         []crawler.TextFinding  `json:"findings,omitempty"` // What the request's scanRules found
+	Fields           map[string]string      `json:"fields,omitempty"`
+	Text             string                 `json:"text,omitempty"` // Main text, when the request asked to extract it
+	WordCount        *int                   `json:"wordCount,omitempty"`
+	Links            []string               `json:"links,omitempty"`
+	LinksTruncated   int                    `json:"linksTruncated,omitempty"` // Links dropped over maxLinksPerPage
+	Error            string                 `json:"error,omitempty"`
+	ErrorClass       crawler.ErrorClass     `json:"errorClass,omitempty"` // The kind of error, as crawler.Classify names it
+	// RedirectChain is every URL of a redirect loop or too long a chain
+	RedirectChain []string `json:"redirectChain,omitempty"`
+
+	// Title, MetaDescription and H1Count describe an HTML page's markup
+	Title           string `json:"title,omitempty"`
+	MetaDescription string `json:"metaDescription,omitempty"`
+	H1Count         int    `json:"h1Count,omitempty"`
+
+	// Metrics is the page's size and fetch timing, if it was fetched
+	Metrics *crawler.PageMetrics `json:"metrics,omitempty"`
+
+	// Accessibility lists the issues found when the request asked to
+	// checkAccessibility
+	Accessibility []crawler.AccessibilityIssue `json:"accessibility,omitempty"`
+	// HTMLWarnings lists the markup anomalies found when the request asked
+	// to checkHtml
+	HTMLWarnings []crawler.HTMLWarning `json:"htmlWarnings,omitempty"`
+
+	// Labels and Note are user annotations set with PATCH
+	Labels      []string   `json:"labels,omitempty"`
+	Note        string     `json:"note,omitempty"`
+	AnnotatedAt *time.Time `json:"annotatedAt,omitempty"`
+}
+
+// ResultLog collects a job's results in the order they arrived
+type ResultLog struct {
+	mu      sync.Mutex
+	results []PageResult
+	index   map[string]int // Position in results by result ID
+	changed chan struct{}  // Closed and replaced on every change
+	closed  bool           // Set once the job has finished
+	writer  *resultWriter  // Persists results when the server has a store
+}
+
+func NewResultLog() *ResultLog {
+	return &ResultLog{index: make(map[string]int), changed: make(chan struct{})}
+}
+
+// restoredResultLog returns a closed log of results loaded from a store
+func restoredResultLog(pages []PageResult) *ResultLog {
+	l := NewResultLog()
+	for _, p := range pages {
+		l.index[p.ID] = len(l.results)
+		l.results = append(l.results, p)
+	}
+	l.Close()
+	return l
+}
+
+// Since returns the results after the first n, a channel that is closed
+// when more arrive, and whether the log is closed, i.e. complete
+func (l *ResultLog) Since(n int) ([]PageResult, <-chan struct{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var pages []PageResult
+	if n < len(l.results) {
+		pages = append(pages, l.results[n:]...)
+	}
+	return pages, l.changed, l.closed
+}
+
+// Close marks the log complete once its job has finished
+func (l *ResultLog) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.closed {
+		l.closed = true
+		close(l.changed)
+	}
+}
+
+// reopen lets a restored log record results again, for a resumed job
+func (l *ResultLog) reopen() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		l.closed = false
+		l.changed = make(chan struct{})
+	}
+}
+
+// Record adds a crawl result; it is safe for concurrent use
+func (l *ResultLog) Record(r crawler.CrawlResult) {
+	page := newPageResult(r)
+
+	l.mu.Lock()
+	if i, ok := l.index[page.ID]; ok {
+		// Fetched again after resuming from a checkpoint
+		l.results[i] = page
+	} else {
+		l.index[page.ID] = len(l.results)
+		l.results = append(l.results, page)
+	}
+	if !l.closed {
+		close(l.changed)
+		l.changed = make(chan struct{})
+	}
+	writer := l.writer
+	l.mu.Unlock()
+
+	// Outside the lock, as a store that falls behind blocks here
+	if writer != nil {
+		writer.add(page)
+	}
+}
+
+// newPageResult converts a crawl result to the form the API reports
+func newPageResult(r crawler.CrawlResult) PageResult {
+	page := PageResult{
+		SchemaVersion:  resultSchemaV1,
+		ID:             resultID(r.URL),
+		URL:            r.URL,
+		Depth:          r.Depth,
+		StatusCode:     r.StatusCode,
+		ContentType:    r.ContentType,
+		SniffedType:    r.SniffedType,
+		ETag:           r.ETag,
+		LastModified:   r.LastModified,
+		ContentHash:    r.ContentHash,
+		Change:         r.Change,
+		Canonical:      r.Canonical,
+		MetaRefresh:    r.MetaRefresh,
+		Alternates:     r.Alternates,
+		Language:       r.Language,
+		Score:          r.Score,
+		Matches:        r.Matches,
+		Findings:       r.Findings,
+		Fields:         r.Fields,
+		Text:           r.Text,
+		Links:          r.Links,
+		LinksTruncated: r.LinksTruncated,
+
+		Title:           r.Title,
+		MetaDescription: r.MetaDescription,
+		H1Count:         r.H1Count,
+
+		Accessibility: r.Accessibility,
+		HTMLWarnings:  r.HTMLWarnings,
+	}
+	if !r.UnavailableAfter.IsZero() {
+		t := r.UnavailableAfter
+		page.UnavailableAfter = &t
+	}
+	if !r.FreshUntil.IsZero() {
+		t := r.FreshUntil
+		page.FreshUntil = &t
+	}
+	if words, ok := crawler.NewPageWords(r); ok {
+		page.WordCount = &words.Words
+	}
+	if r.Error != nil {
+		page.Error, page.ErrorClass = r.Error.Error(), crawler.Classify(r.Error)
+		var redirect *crawler.RedirectError
+		if errors.As(r.Error, &redirect) {
+			page.RedirectChain = redirect.Chain
+		}
+	}
+	if r.Metrics.TTFB > 0 {
+		metrics := r.Metrics
+		page.Metrics = &metrics
+	}
+	return page
+}
+
+// Counts returns how many results were recorded and how many of them
+// were errors
+func (l *ResultLog) Counts() (pages, errors int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, p := range l.results {
+		if p.Error != "" {
+			errors++
+		}
+	}
+	return len(l.results), errors
+}
+
+// Broken returns the URLs of the results that were errors
+func (l *ResultLog) Broken() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var urls []string
+	for _, p := range l.results {
+		if p.Error != "" {
+			urls = append(urls, p.URL)
+		}
+	}
+	return urls
+}
+
+// RevisitEntries returns the pages a refresh crawl should revalidate: every
+// page that was fetched successfully, or found unchanged
+func (l *ResultLog) RevisitEntries() []crawler.RevisitEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var entries []crawler.RevisitEntry
+	for _, p := range l.results {
+		if p.Error != "" && p.Change != crawler.ChangeUnchanged {
+			continue
+		}
+		entry := crawler.RevisitEntry{
+			URL:          p.URL,
+			ETag:         p.ETag,
+			LastModified: p.LastModified,
+			ContentHash:  p.ContentHash,
+		}
+		if p.FreshUntil != nil {
+			entry.FreshUntil = *p.FreshUntil
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// LinkGraph builds the internal link graph of the pages fetched so far
+func (l *ResultLog) LinkGraph(root string) *crawler.LinkGraph {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	graph := crawler.NewLinkGraph(root)
+	for _, p := range l.results {
+		if p.Error == "" {
+			graph.Add(p.URL, p.Links)
+		}
+	}
+	return graph
+}
+
+// Inlinks indexes the links of the pages fetched so far by the URL they
+// point at
+func (l *ResultLog) Inlinks() *crawler.InlinkIndex {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	index := crawler.NewInlinkIndex()
+	for _, p := range l.results {
+		index.Add(p.URL, p.Links)
+	}
+	return index
+}
+
+// ResultReport is the response body of GET /crawl/{id}/results
+type ResultReport struct {
+	Changes      map[crawler.ChangeState]int `json:"changes,omitempty"`
+	ErrorClasses map[crawler.ErrorClass]int  `json:"errorClasses,omitempty"` // Errors by class
+	Results      []PageResult                `json:"results"`
+
+	// JobLabels are the labels of the crawl request
+	JobLabels map[string]string `json:"jobLabels,omitempty"`
+}
+
+// Report returns the recorded results, optionally limited to one change
+// state and one error class, with per-state counts for refresh crawls and
+// per-class counts of the errors
+func (l *ResultLog) Report(change crawler.ChangeState, class crawler.ErrorClass) ResultReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	report := ResultReport{Results: []PageResult{}}
+	for _, p := range l.results {
+		if p.Change != "" {
+			if report.Changes == nil {
+				report.Changes = make(map[crawler.ChangeState]int)
+			}
+			report.Changes[p.Change]++
+		}
+		if p.ErrorClass != "" {
+			if report.ErrorClasses == nil {
+				report.ErrorClasses = make(map[crawler.ErrorClass]int)
+			}
+			report.ErrorClasses[p.ErrorClass]++
+		}
+		if (change == "" || p.Change == change) && (class == "" || p.ErrorClass == class) {
+			report.Results = append(report.Results, p)
+		}
+	}
+	return report
+}
+
+// handleGetResults lists the pages a job fetched, filtered by the optional
+// change, errorClass and label parameters. With format=csv the pages are sent as a CSV
+// download, with format=seo as a CSV in the layout of SEO audit tools, and
+// with format=junit as a JUnit XML link-check report. JSON pages follow the
+// result schema version asked for with schema, 1 by default.
+func (s *APIServer) handleGetResults(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	change := crawler.ChangeState(r.URL.Query().Get("change"))
+	var class crawler.ErrorClass
+	if name := r.URL.Query().Get("errorClass"); name != "" {
+		var err error
+		if class, err = crawler.ParseErrorClass(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	version, err := parseResultSchema(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report := job.Results.Report(change, class)
+	report.JobLabels = job.Request.Labels
+	if label := r.URL.Query().Get("label"); label != "" {
+		labelled := []PageResult{}
+		for _, p := range report.Results {
+			if p.hasLabel(label) {
+				labelled = append(labelled, p)
+			}
+		}
+		report.Results = labelled
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		if version == resultSchemaV2 {
+			json.NewEncoder(w).Encode(report.v2())
+			return
+		}
+		json.NewEncoder(w).Encode(report)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=crawl-%s.csv", job.ID))
+		writeResultsCSV(w, report.Results, job.Results.Inlinks(), job.Request.Labels)
+	case "seo":
+		pages := make([]crawler.AuditPage, len(report.Results))
+		for i, p := range report.Results {
+			pages[i] = p.auditPage()
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=crawl-%s-seo.csv", job.ID))
+		crawler.WriteAuditCSV(w, pages)
+	case "junit":
+		checks := make([]crawler.LinkCheck, len(report.Results))
+		for i, p := range report.Results {
+			checks[i] = crawler.LinkCheck{URL: p.URL, StatusCode: p.StatusCode, Error: p.Error, Class: p.ErrorClass, Links: p.Links}
+		}
+		var assets []crawler.BrokenAsset
+		var alternates []crawler.BrokenAlternate
+		if c := job.Crawler(); c != nil {
+			assets, alternates = c.BrokenAssets(), c.BrokenAlternates()
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=crawl-%s.xml", job.ID))
+		crawler.WriteJUnitReport(w, checks, assets, alternates)
+	default:
+		http.Error(w, "format must be json, csv, seo or junit", http.StatusBadRequest)
+	}
+}
+
+// writeResultsCSV writes one row per page with its link count, the number
+// of crawled pages linking to it and the job's labels
+func writeResultsCSV(w io.Writer, pages []PageResult, inlinks *crawler.InlinkIndex, jobLabels map[string]string) {
+	cw := csv.NewWriter(w)
+	labels := formatJobLabels(jobLabels, ";")
+	cw.Write([]string{"url", "depth", "status_code", "content_type", "language", "change", "score", "links", "error", "error_class", "labels", "note", "inlinks", "job_labels", "schema_version"})
+	for _, p := range pages {
+		cw.Write([]string{
+			p.URL,
+			strconv.Itoa(p.Depth),
+			strconv.Itoa(p.StatusCode),
+			p.ContentType,
+			p.Language,
+			string(p.Change),
+			strconv.FormatFloat(p.Score, 'f', -1, 64),
+			strconv.Itoa(len(p.Links)),
+			p.Error,
+			string(p.ErrorClass),
+			strings.Join(p.Labels, ";"),
+			p.Note,
+			strconv.Itoa(inlinks.Count(p.URL)),
+			labels,
+			strconv.Itoa(resultSchemaV1),
+		})
+	}
+	cw.Flush()
+}
+
+func (p PageResult) auditPage() crawler.AuditPage {
+	page := crawler.AuditPage{
+		URL:             p.URL,
+		Depth:           p.Depth,
+		StatusCode:      p.StatusCode,
+		Title:           p.Title,
+		MetaDescription: p.MetaDescription,
+		H1Count:         p.H1Count,
+		Canonical:       p.Canonical,
+		Links:           p.Links,
+		WordCount:       p.WordCount,
+		ContentType:     p.ContentType,
+	}
+	if p.SniffedType != "" {
+		page.ContentType = p.SniffedType
+	}
+	return page
+}
+
+func (p PageResult) accessibilityPage() crawler.AccessibilityPage {
+	page := crawler.AccessibilityPage{URL: p.URL, StatusCode: p.StatusCode, ContentType: p.ContentType, Issues: p.Accessibility}
+	if p.SniffedType != "" {
+		page.ContentType = p.SniffedType
+	}
+	return page
+}
+
+func (p PageResult) hostPage() crawler.HostPage {
+	page := crawler.HostPage{URL: p.URL, Failed: p.Error != ""}
+	if p.Metrics != nil {
+		page.TTFB = p.Metrics.TTFB
+	}
+	return page
+}
+
+func (p PageResult) sitemapPage() crawler.SitemapPage {
+	return crawler.SitemapPage{URL: p.URL, StatusCode: p.StatusCode, Canonical: p.Canonical, LastModified: p.LastModified}
+}
+
+// handleGetSitemap serves a sitemap of the pages a job fetched, optionally
+// only those the filter parameter selects (ok, canonical), as XML or, with
+// format=txt, plain text. When the pages don't fit in one sitemap it
+// serves an index of the numbered files, fetched with page=1 and so on.
+func (s *APIServer) handleGetSitemap(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := crawler.ParseSitemapFilter(query.Get("filter"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := crawler.ParseSitemapFormat(query.Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := job.Results.Report("", "").Results
+	pages := make([]crawler.SitemapPage, len(results))
+	for i, p := range results {
+		pages[i] = p.sitemapPage()
+	}
+	files := crawler.SplitSitemap(crawler.SitemapEntries(pages, filter), format)
+
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > len(files) {
+			http.Error(w, fmt.Sprintf("page must be between 1 and %d", len(files)), http.StatusBadRequest)
+			return
+		}
+		files = files[n-1 : n]
+	} else if len(files) > 1 {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		locs := make([]string, len(files))
+		for i := range files {
+			query.Set("page", strconv.Itoa(i+1))
+			locs[i] = fmt.Sprintf("%s://%s%s?%s", scheme, r.Host, r.URL.Path, query.Encode())
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		crawler.WriteSitemapIndex(w, locs)
+		return
+	}
+
+	if format == crawler.SitemapText {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/xml")
+	}
+	crawler.WriteSitemap(w, files[0], format)
+}
+
+// InlinksReport is the response body of GET /crawl/{id}/inlinks
+type InlinksReport struct {
+	URL     string           `json:"url"`
+	Count   int              `json:"count"` // Pages linking to the URL
+	Inlinks []crawler.Inlink `json:"inlinks"`
+}
+
+// handleGetInlinks lists the crawled pages that link to the url parameter,
+// with each link as the page wrote it, to find where a broken link is
+// referenced
+func (s *APIServer) handleGetInlinks(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+	target := r.URL.Query().Get("url")
+	if target == "" {
+		http.Error(w, "url is required", http.StatusBadRequest)
+		return
+	}
+
+	report := InlinksReport{URL: target, Inlinks: []crawler.Inlink{}}
+	report.Inlinks = append(report.Inlinks, job.Results.Inlinks().Inlinks(target)...)
+	report.Count = len(report.Inlinks)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleGetHosts aggregates a job's pages by host with the robots.txt
+// crawl delays and throttling the crawl saw, most pages first. Crawl delays
+// and throttling are only known while the server keeps the job's crawler,
+// not for jobs loaded from storage after a restart.
+func (s *APIServer) handleGetHosts(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	results := job.Results.Report("", "").Results
+	pages := make([]crawler.HostPage, len(results))
+	for i, p := range results {
+		pages[i] = p.hostPage()
+	}
+	var delays map[string]time.Duration
+	var throttling []crawler.HostThrottle
+	if c := job.Crawler(); c != nil {
+		delays, throttling = c.RobotsCrawlDelays(), c.Throttling()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(crawler.SummarizeHosts(pages, delays, throttling))
+}
+
+// handleGetAccessibility summarizes the accessibility issues of a job's
+// pages by check and lists the pages with issues, most first
+func (s *APIServer) handleGetAccessibility(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+	if !job.Request.CheckAccessibility {
+		http.Error(w, "crawl did not check accessibility", http.StatusNotFound)
+		return
+	}
+
+	results := job.Results.Report("", "").Results
+	pages := make([]crawler.AccessibilityPage, len(results))
+	for i, p := range results {
+		pages[i] = p.accessibilityPage()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(crawler.SummarizeAccessibility(pages))
+}
+
+// handleGetMetadata reports the pages of a job that share their title or
+// meta description with another page, and those without one; with
+// format=csv as a CSV download with one row per group of pages
+func (s *APIServer) handleGetMetadata(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	results := job.Results.Report("", "").Results
+	pages := make([]crawler.AuditPage, len(results))
+	for i, p := range results {
+		pages[i] = p.auditPage()
+	}
+	report := crawler.CheckMetadata(pages)
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=crawl-%s-metadata.csv", job.ID))
+		crawler.WriteMetadataCSV(w, report)
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+	}
+}
+
+// ThinPages returns the pages whose extracted text has fewer than minWords
+// words, fewest first
+func (l *ResultLog) ThinPages(minWords int) []crawler.PageWords {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var pages []crawler.PageWords
+	for _, p := range l.results {
+		if p.WordCount != nil {
+			pages = append(pages, crawler.PageWords{URL: p.URL, Words: *p.WordCount})
+		}
+	}
+	return crawler.ThinPages(pages, minWords)
+}
+
+// ThinReport is the response body of GET /crawl/{id}/thin
+type ThinReport struct {
+	MinWords int                 `json:"minWords"`
+	Pages    []crawler.PageWords `json:"pages"`
+}
+
+// handleGetThin lists the pages of a job whose main text has fewer words
+// than the words parameter, the request's thinContent, or
+// crawler.DefaultThinWords, fewest first
+func (s *APIServer) handleGetThin(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	report := ThinReport{MinWords: job.Request.ThinContent, Pages: []crawler.PageWords{}}
+	if v := r.URL.Query().Get("words"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "words must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		report.MinWords = n
+	}
+	if report.MinWords <= 0 {
+		report.MinWords = crawler.DefaultThinWords
+	}
+	report.Pages = append(report.Pages, job.Results.ThinPages(report.MinWords)...)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleGetText sends the main text a job extracted from its pages as
+// newline-delimited JSON, one page per line, for search and NLP pipelines
+func (s *APIServer) handleGetText(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=crawl-%s-text.jsonl", job.ID))
+	enc := json.NewEncoder(w)
+	for _, p := range job.Results.Report("", "").Results {
+		if p.Text == "" {
+			continue
+		}
+		if err := enc.Encode(crawler.PageText{URL: p.URL, Title: p.Title, Language: p.Language, Text: p.Text}); err != nil {
+			return
+		}
+	}
+}
+
+// Performance returns the metrics of every page fetched without error
+func (l *ResultLog) Performance() []crawler.PagePerformance {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var pages []crawler.PagePerformance
+	for _, p := range l.results {
+		if p.Metrics != nil && p.Error == "" {
+			pages = append(pages, crawler.PagePerformance{URL: p.URL, PageMetrics: *p.Metrics})
+		}
+	}
+	return pages
+}
+
+// handleGetPerformance summarises the weight and speed of a job's pages,
+// with the heaviest and slowest of them. The optional top parameter sets
+// the list lengths.
+func (s *APIServer) handleGetPerformance(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	top := 10
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "top must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+
+	summary := crawler.SummarizePerformance(job.Results.Performance(), top)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// handleGetAssets lists the broken scripts, stylesheets and images a job
+// found, with the pages that reference them
+func (s *APIServer) handleGetAssets(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	assets := []crawler.BrokenAsset{}
+	if c := job.Crawler(); c != nil {
+		assets = append(assets, c.BrokenAssets()...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assets)
+}
+
+// handleGetAlternates lists the AMP and mobile versions a job found missing
+// or broken, with the pages that advertise them
+func (s *APIServer) handleGetAlternates(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	alternates := []crawler.BrokenAlternate{}
+	if c := job.Crawler(); c != nil {
+		alternates = append(alternates, c.BrokenAlternates()...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alternates)
+}
+
+// handleGetExternal lists the links to other sites a job found dead, with
+// the pages that link to them
+func (s *APIServer) handleGetExternal(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	links := []crawler.ExternalLink{}
+	if c := job.Crawler(); c != nil {
+		links = append(links, c.BrokenExternalLinks()...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+// handleGraphStats reports PageRank and in-degree statistics over a job's
+// internal link graph. The optional top parameter sets the list lengths.
+func (s *APIServer) handleGraphStats(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	top := 10
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "top must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+
+	graph := job.Results.LinkGraph(job.Request.URL)
+	if job.Request.HostPolicy != nil {
+		graph.SetHostPolicy(*job.Request.HostPolicy)
+	}
+	stats := graph.Stats(top)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleStreamResults streams a job's results as newline-delimited JSON,
+// starting with those recorded so far and following the job until it
+// finishes or the client goes away, in the result schema version asked for
+// with schema
+func (s *APIServer) handleStreamResults(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	version, err := parseResultSchema(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	enc := json.NewEncoder(w)
+	sent := 0
+	for {
+		pages, changed, closed := job.Results.Since(sent)
+		for _, p := range pages {
+			if err := enc.Encode(resultRecord(p, version)); err != nil {
+				return
+			}
+		}
+		sent += len(pages)
+		flusher.Flush()
+		if closed {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
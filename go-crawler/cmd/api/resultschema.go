@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"go-crawler/internal/crawler"
+)
+
+// Result schema versions. Every page the server emits or stores says in
+// schemaVersion which layout it follows, so that the layout can change
+// without breaking clients that read an older one: version 1 is the flat
+// PageResult clients have always read and the default, version 2 groups the
+// same fields by what they describe.
+const (
+	resultSchemaV1 = 1
+	resultSchemaV2 = 2
+)
+
+// resultSchemaVersions lists the versions clients may ask for
+var resultSchemaVersions = []int{resultSchemaV1, resultSchemaV2}
+
+// PageResultV2 is a page in version 2 of the result schema
+type PageResultV2 struct {
+	SchemaVersion int     `json:"schemaVersion"`
+	ID            string  `json:"id"`
+	URL           string  `json:"url"`
+	Depth         int     `json:"depth"`
+	Score         float64 `json:"score,omitempty"`
+
+	Fetch       *ResultFetch       `json:"fetch,omitempty"`
+	Error       *ResultError       `json:"error,omitempty"`
+	Page        *ResultPage        `json:"page,omitempty"`
+	Links       *ResultLinks       `json:"links,omitempty"`
+	Checks      *ResultChecks      `json:"checks,omitempty"`
+	Annotations *ResultAnnotations `json:"annotations,omitempty"`
+}
+
+// ResultFetch is the response a page was fetched with
+type ResultFetch struct {
+	StatusCode   int                  `json:"statusCode,omitempty"`
+	ContentType  string               `json:"contentType,omitempty"`
+	SniffedType  string               `json:"sniffedType,omitempty"`
+	ETag         string               `json:"etag,omitempty"`
+	LastModified string               `json:"lastModified,omitempty"`
+	ContentHash  string               `json:"contentHash,omitempty"`
+	FreshUntil   *time.Time           `json:"freshUntil,omitempty"`
+	Change       crawler.ChangeState  `json:"change,omitempty"`
+	Metrics      *crawler.PageMetrics `json:"metrics,omitempty"`
+}
+
+// ResultError is why a page failed
+type ResultError struct {
+	Message       string             `json:"message"`
+	Class         crawler.ErrorClass `json:"class,omitempty"`
+	RedirectChain []string           `json:"redirectChain,omitempty"`
+}
+
+// ResultPage is what a page's content says about it
+type ResultPage struct {
+	Title            string               `json:"title,omitempty"`
+	MetaDescription  string               `json:"metaDescription,omitempty"`
+	H1Count          int                  `json:"h1Count,omitempty"`
+	Language         string               `json:"language,omitempty"`
+	Canonical        string               `json:"canonical,omitempty"`
+	MetaRefresh      *crawler.MetaRefresh `json:"metaRefresh,omitempty"`
+	Alternates       []crawler.Alternate  `json:"alternates,omitempty"`
+	UnavailableAfter *time.Time           `json:"unavailableAfter,omitempty"`
+	Text             string               `json:"text,omitempty"`
+	WordCount        *int                 `json:"wordCount,omitempty"`
+}
+
+// ResultLinks are the links found on a page
+type ResultLinks struct {
+	URLs      []string `json:"urls,omitempty"`
+	Truncated int      `json:"truncated,omitempty"` // Links dropped over maxLinksPerPage
+}
+
+// ResultChecks is what the request's checks and extraction found
+type ResultChecks struct {
+	Matches       []crawler.ContentMatch       `json:"matches,omitempty"`
+	Findings      []crawler.TextFinding        `json:"findings,omitempty"`
+	Fields        map[string]string            `json:"fields,omitempty"`
+	Accessibility []crawler.AccessibilityIssue `json:"accessibility,omitempty"`
+	HTMLWarnings  []crawler.HTMLWarning        `json:"htmlWarnings,omitempty"`
+}
+
+// ResultAnnotations are the user annotations set with PATCH
+type ResultAnnotations struct {
+	Labels      []string   `json:"labels,omitempty"`
+	Note        string     `json:"note,omitempty"`
+	AnnotatedAt *time.Time `json:"annotatedAt,omitempty"`
+}
+
+// section returns s, or nil if none of its fields are set, so that empty
+// sections are left out of a record
+func section[T any](s T) *T {
+	if reflect.ValueOf(s).IsZero() {
+		return nil
+	}
+	return &s
+}
+
+// v2 returns the page in version 2 of the result schema
+func (p PageResult) v2() PageResultV2 {
+	page := PageResultV2{
+		SchemaVersion: resultSchemaV2,
+		ID:            p.ID,
+		URL:           p.URL,
+		Depth:         p.Depth,
+		Score:         p.Score,
+		Fetch: section(ResultFetch{
+			StatusCode:   p.StatusCode,
+			ContentType:  p.ContentType,
+			SniffedType:  p.SniffedType,
+			ETag:         p.ETag,
+			LastModified: p.LastModified,
+			ContentHash:  p.ContentHash,
+			FreshUntil:   p.FreshUntil,
+			Change:       p.Change,
+			Metrics:      p.Metrics,
+		}),
+		Page: section(ResultPage{
+			Title:            p.Title,
+			MetaDescription:  p.MetaDescription,
+			H1Count:          p.H1Count,
+			Language:         p.Language,
+			Canonical:        p.Canonical,
+			MetaRefresh:      p.MetaRefresh,
+			Alternates:       p.Alternates,
+			UnavailableAfter: p.UnavailableAfter,
+			Text:             p.Text,
+			WordCount:        p.WordCount,
+		}),
+		Links: section(ResultLinks{URLs: p.Links, Truncated: p.LinksTruncated}),
+		Checks: section(ResultChecks{
+			Matches:       p.Matches,
+			Findings:      p.Findings,
+			Fields:        p.Fields,
+			Accessibility: p.Accessibility,
+			HTMLWarnings:  p.HTMLWarnings,
+		}),
+		Annotations: section(ResultAnnotations{Labels: p.Labels, Note: p.Note, AnnotatedAt: p.AnnotatedAt}),
+	}
+	if p.Error != "" {
+		page.Error = &ResultError{Message: p.Error, Class: p.ErrorClass, RedirectChain: p.RedirectChain}
+	}
+	return page
+}
+
+// v1 returns the page in version 1 of the result schema
+func (p PageResultV2) v1() PageResult {
+	page := PageResult{SchemaVersion: resultSchemaV1, ID: p.ID, URL: p.URL, Depth: p.Depth, Score: p.Score}
+	if f := p.Fetch; f != nil {
+		page.StatusCode, page.ContentType, page.SniffedType = f.StatusCode, f.ContentType, f.SniffedType
+		page.ETag, page.LastModified, page.ContentHash = f.ETag, f.LastModified, f.ContentHash
+		page.FreshUntil, page.Change, page.Metrics = f.FreshUntil, f.Change, f.Metrics
+	}
+	if e := p.Error; e != nil {
+		page.Error, page.ErrorClass, page.RedirectChain = e.Message, e.Class, e.RedirectChain
+	}
+	if c := p.Page; c != nil {
+		page.Title, page.MetaDescription, page.H1Count = c.Title, c.MetaDescription, c.H1Count
+		page.Language, page.Canonical, page.MetaRefresh, page.Alternates = c.Language, c.Canonical, c.MetaRefresh, c.Alternates
+		page.UnavailableAfter, page.Text, page.WordCount = c.UnavailableAfter, c.Text, c.WordCount
+	}
+	if l := p.Links; l != nil {
+		page.Links, page.LinksTruncated = l.URLs, l.Truncated
+	}
+	if c := p.Checks; c != nil {
+		page.Matches, page.Findings, page.Fields = c.Matches, c.Findings, c.Fields
+		page.Accessibility, page.HTMLWarnings = c.Accessibility, c.HTMLWarnings
+	}
+	if a := p.Annotations; a != nil {
+		page.Labels, page.Note, page.AnnotatedAt = a.Labels, a.Note, a.AnnotatedAt
+	}
+	return page
+}
+
+// parseResultSchema returns the result schema version asked for with
+// ?schema=, or version 1 if none is
+func parseResultSchema(query url.Values) (int, error) {
+	s := query.Get("schema")
+	if s == "" {
+		return resultSchemaV1, nil
+	}
+	version, err := strconv.Atoi(s)
+	if err == nil {
+		for _, v := range resultSchemaVersions {
+			if v == version {
+				return version, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("schema must be %d or %d", resultSchemaV1, resultSchemaV2)
+}
+
+// resultRecord returns a page in the given version of the result schema
+func resultRecord(p PageResult, version int) any {
+	if version == resultSchemaV2 {
+		return p.v2()
+	}
+	return p
+}
+
+// decodeStoredResult decodes a page as stored in any version of the result
+// schema; pages stored before versioning have no schemaVersion and are
+// version 1
+func decodeStoredResult(data []byte) (PageResult, error) {
+	var head struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return PageResult{}, err
+	}
+	switch head.SchemaVersion {
+	case 0, resultSchemaV1:
+		var page PageResult
+		err := json.Unmarshal(data, &page)
+		page.SchemaVersion = resultSchemaV1
+		return page, err
+	case resultSchemaV2:
+		var page PageResultV2
+		err := json.Unmarshal(data, &page)
+		return page.v1(), err
+	default:
+		return PageResult{}, fmt.Errorf("unknown result schema version %d", head.SchemaVersion)
+	}
+}
+
+// ResultReportV2 is the response body of GET /crawl/{id}/results in
+// version 2 of the result schema
+type ResultReportV2 struct {
+	ResultReport
+	Results []PageResultV2 `json:"results"`
+}
+
+// v2 returns the report with its pages in version 2 of the result schema
+func (r ResultReport) v2() ResultReportV2 {
+	report := ResultReportV2{ResultReport: r, Results: make([]PageResultV2, len(r.Results))}
+	for i, p := range r.Results {
+		report.Results[i] = p.v2()
+	}
+	return report
+}
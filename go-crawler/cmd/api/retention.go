@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// janitorInterval is how often completed crawls are checked against the
+// retention policy
+const janitorInterval = 10 * time.Minute
+
+// errJobRunning is returned when deleting a crawl that is still running
+var errJobRunning = errors.New("crawl is still running")
+
+// Retention says how long completed crawls are kept. Zero fields keep
+// crawls forever.
+type Retention struct {
+	KeepCrawls int           // Most recent completed crawls kept per owner and site
+	MaxAge     time.Duration // Completed crawls older than this are removed
+}
+
+// enabled reports whether the policy removes anything
+func (r Retention) enabled() bool {
+	return r.KeepCrawls > 0 || r.MaxAge > 0
+}
+
+// crawlSite is the site a crawl is kept per: its start URL's host and port
+func crawlSite(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return strings.ToLower(u.Host)
+}
+
+// retainedCrawl is what the retention policy looks at of a completed crawl
+type retainedCrawl struct {
+	ID         string
+	Owner      string
+	URL        string
+	FinishedAt time.Time
+}
+
+// expiredCrawls returns the IDs of the crawls the retention policy no
+// longer keeps as of now
+func expiredCrawls(crawls []retainedCrawl, r Retention, now time.Time) []string {
+	bySite := map[string][]retainedCrawl{}
+	for _, c := range crawls {
+		key := c.Owner + "\x00" + crawlSite(c.URL)
+		bySite[key] = append(bySite[key], c)
+	}
+
+	var ids []string
+	for _, site := range bySite {
+		sort.Slice(site, func(i, j int) bool { return site[i].FinishedAt.After(site[j].FinishedAt) })
+		for i, c := range site {
+			if (r.KeepCrawls > 0 && i >= r.KeepCrawls) || (r.MaxAge > 0 && now.Sub(c.FinishedAt) > r.MaxAge) {
+				ids = append(ids, c.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// Expired returns the IDs of the completed jobs the retention policy no
+// longer keeps as of now
+func (m *JobManager) Expired(r Retention, now time.Time) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var crawls []retainedCrawl
+	for _, job := range m.jobs {
+		if job.Status == JobCompleted {
+			crawls = append(crawls, retainedCrawl{ID: job.ID, Owner: job.Owner, URL: job.Request.URL, FinishedAt: job.FinishedAt})
+		}
+	}
+	return expiredCrawls(crawls, r, now)
+}
+
+// Delete removes a queued or completed job and its results from memory.
+// Running jobs can't be deleted.
+func (m *JobManager) Delete(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	if job.Status == JobQueued {
+		for i, queued := range m.queue {
+			if queued == job {
+				m.queue = append(m.queue[:i], m.queue[i+1:]...)
+				break
+			}
+		}
+		job.Results.Close()
+		if job.Results.writer != nil {
+			go job.Results.writer.close()
+		}
+	} else if job.Status != JobCompleted {
+		return job, errJobRunning
+	}
+	delete(m.jobs, id)
+	return job, nil
+}
+
+// deleteCrawl removes a job everywhere the server keeps its data: memory,
+// its checkpoint file and the store
+func (s *APIServer) deleteCrawl(ctx context.Context, id string) error {
+	job, err := s.jobs.Delete(id)
+	if job == nil || err != nil {
+		return err
+	}
+	if s.checkpointDir != "" {
+		path := filepath.Join(s.checkpointDir, job.ID+".json")
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			warnf("Error removing checkpoint of crawl %s: %v", job.ID, err)
+		}
+	}
+	if s.store != nil {
+		if err := s.store.DeleteJob(ctx, job.ID); err != nil {
+			warnf("Error deleting crawl %s from the store: %v", job.ID, err)
+		}
+	}
+	return nil
+}
+
+// runJanitor removes crawls the retention policy no longer keeps, now and
+// every janitorInterval
+func (s *APIServer) runJanitor(r Retention) {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+	for {
+		s.pruneCrawls(r)
+		<-ticker.C
+	}
+}
+
+// pruneCrawls applies the retention policy once
+func (s *APIServer) pruneCrawls(r Retention) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	ids := s.jobs.Expired(r, time.Now())
+	for _, id := range ids {
+		if err := s.deleteCrawl(ctx, id); err != nil {
+			warnf("Error pruning crawl %s: %v", id, err)
+		}
+	}
+	if len(ids) > 0 {
+		infof("Pruned %d crawls past their retention", len(ids))
+	}
+
+	// The store may hold crawls from earlier runs of the server
+	if s.store != nil {
+		n, err := s.store.Prune(ctx, r)
+		if err != nil {
+			warnf("Error pruning stored crawls: %v", err)
+		} else if n > 0 {
+			infof("Pruned %d stored crawls past their retention", n)
+		}
+	}
+}
+
+// handleDeleteCrawl deletes a queued or completed crawl and its results
+func (s *APIServer) handleDeleteCrawl(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := s.deleteCrawl(r.Context(), job.ID); err == errJobRunning {
+		http.Error(w, "Crawl is still running", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go-crawler/internal/crawler"
+)
+
+// RobotsPreview is the interpreted robots.txt of a host as the crawler sees it
+type RobotsPreview struct {
+	RobotsURL         string          `json:"robotsUrl"`
+	UserAgent         string          `json:"userAgent"`
+	StatusCode        int             `json:"statusCode"`
+	CrawlDelaySeconds float64         `json:"crawlDelaySeconds"`
+	Disallow          []string        `json:"disallow"`
+	Sitemaps          []string        `json:"sitemaps"`
+	Test              RobotsPathCheck `json:"test"`
+}
+
+// RobotsPathCheck reports whether a single URL may be crawled
+type RobotsPathCheck struct {
+	URL         string `json:"url"`
+	Allowed     bool   `json:"allowed"`
+	MatchedRule string `json:"matchedRule,omitempty"`
+}
+
+// handleRobots fetches and interprets robots.txt for GET /robots?url=...,
+// testing the URL itself or the path given in the optional path parameter
+func (s *APIServer) handleRobots(w http.ResponseWriter, r *http.Request) {
+	target, err := url.ParseRequestURI(r.URL.Query().Get("url"))
+	if err != nil || target.Host == "" {
+		http.Error(w, "A valid url parameter is required", http.StatusBadRequest)
+		return
+	}
+	if err := s.settings.CheckDomain(target); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	testURL := target
+	if path := r.URL.Query().Get("path"); path != "" {
+		ref, err := url.Parse(path)
+		if err != nil {
+			http.Error(w, "Invalid path parameter", http.StatusBadRequest)
+			return
+		}
+		testURL = target.ResolveReference(ref)
+	}
+
+	c := crawler.NewCrawler(1, 0, 0)
+	rules, err := c.RobotsRules(target.String())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	rule, blocked := rules.MatchingRule(testURL.String())
+	preview := RobotsPreview{
+		RobotsURL:         fmt.Sprintf("%s://%s/robots.txt", target.Scheme, target.Host),
+		UserAgent:         c.UserAgent(),
+		StatusCode:        rules.StatusCode(),
+		CrawlDelaySeconds: rules.GetCrawlDelay().Seconds(),
+		Disallow:          rules.DisallowRules(),
+		Sitemaps:          rules.Sitemaps(),
+		Test: RobotsPathCheck{
+			URL:         testURL.String(),
+			Allowed:     !blocked,
+			MatchedRule: rule,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
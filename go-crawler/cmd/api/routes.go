@@ -0,0 +1,228 @@
+package main
+
+import (
+	"net/http"
+
+	"go-crawler/internal/crawler"
+)
+
+// access says who may call a route
+type access int
+
+const (
+	accessPublic access = iota
+	accessUser          // Any user, with an API key when -users is set
+	accessAdmin
+)
+
+// route is an API endpoint. The router and the OpenAPI document are both
+// built from apiRoutes, so a handler can't be served without being
+// documented.
+type route struct {
+	method  string
+	path    string
+	access  access
+	handler func(*APIServer, http.ResponseWriter, *http.Request)
+	tag     string
+	summary string
+	query   []queryParam
+	// request and response are values of the JSON body types, or nil for
+	// none. A response is JSON, or JSON lines when stream is set, and
+	// formats lists other content types ?format= selects.
+	request  any
+	response any
+	status   int // Success status when not 200
+	stream   bool
+	formats  map[string]string // ?format= value to content type
+}
+
+// queryParam documents a query string parameter
+type queryParam struct {
+	name        string
+	description string
+}
+
+// apiRoutes lists every endpoint the server registers besides static files
+// and the admin debug endpoints
+var apiRoutes = []route{
+	{method: "GET", path: "/ws", access: accessUser, handler: (*APIServer).handleWebSocket, tag: "Crawls",
+		summary: "WebSocket for starting crawls and receiving their results; messages are described at /schema",
+		status:  http.StatusSwitchingProtocols,
+		query:   []queryParam{{"schema", "Result schema version of result messages, 1 (the default) or 2"}}},
+	{method: "POST", path: "/crawl", access: accessUser, handler: (*APIServer).handleCrawl, tag: "Crawls",
+		summary: "Submit a crawl job", request: CrawlRequest{}, response: CrawlAccepted{}, status: http.StatusAccepted},
+	{method: "GET", path: "/crawl", access: accessUser, handler: (*APIServer).handleListCrawls, tag: "Crawls",
+		summary: "List your crawl jobs, oldest first", response: []JobInfo{},
+		query: []queryParam{{"label", "Only jobs with this label, as name=value or just name; repeat to require several"}}},
+	{method: "GET", path: "/crawls", access: accessUser, handler: (*APIServer).handleSearchCrawls, tag: "Crawls",
+		summary: "Search your crawl jobs, newest first, a page at a time", response: JobPage{},
+		query: []queryParam{
+			{"status", "Comma-separated statuses: queued, running, paused or completed"},
+			{"label", "Only jobs with this label, as name=value or just name; repeat to require several"},
+			{"domain", "Only jobs whose start URL is on this host or its subdomains"},
+			{"lineage", "Only this job and its reruns"},
+			{"since", "Only jobs created at or after this RFC 3339 time or date"},
+			{"until", "Only jobs created before this RFC 3339 time, or on or before this date"},
+			{"sort", "createdAt, finishedAt, pages, errors or url; prefix with - for descending (default -createdAt)"},
+			{"limit", "Jobs per page, 50 by default and at most 500"},
+			{"offset", "Jobs to skip, for the following pages"},
+		}},
+	{method: "POST", path: "/crawl/estimate", access: accessUser, handler: (*APIServer).handleEstimate, tag: "Crawls",
+		summary: "Estimate a crawl's size and cost without starting it", request: CrawlRequest{}, response: crawler.Estimate{}},
+	{method: "POST", path: "/fetch", access: accessUser, handler: (*APIServer).handleFetch, tag: "Crawls",
+		summary: "Fetch one URL as a crawl with these settings would, reporting each step, to find out why a page doesn't crawl",
+		request: CrawlRequest{}, response: FetchReport{},
+		query: []queryParam{{"from", "Start URL of the crawl, to also check the URL against its scope and limits as a link on it"}}},
+	{method: "GET", path: "/crawl/{id}", access: accessUser, handler: (*APIServer).handleGetCrawl, tag: "Crawls",
+		summary: "Get a crawl job's status and progress", response: JobInfo{}},
+	{method: "DELETE", path: "/crawl/{id}", access: accessUser, handler: (*APIServer).handleDeleteCrawl, tag: "Crawls",
+		summary: "Delete a queued or completed crawl and its results", status: http.StatusNoContent},
+	{method: "POST", path: "/crawl/{id}/rerun", access: accessUser, handler: (*APIServer).handleRerun, tag: "Crawls",
+		summary: "Start a new crawl with the same request as this one", response: CrawlAccepted{}, status: http.StatusAccepted},
+	{method: "PATCH", path: "/crawl/{id}/settings", access: accessUser, handler: (*APIServer).handleUpdateJobSettings, tag: "Crawls",
+		summary: "Change a running crawl's workers, delay and per-host limit", request: JobSettingsUpdate{}, response: crawler.Settings{}},
+	{method: "POST", path: "/crawl/{id}/exclude", access: accessUser, handler: (*APIServer).handleExclude, tag: "Crawls",
+		summary: "Stop a running crawl from fetching URLs that match a pattern", request: ExcludeRequest{},
+		response: crawler.Exclusion{}, status: http.StatusCreated},
+	{method: "GET", path: "/crawl/{id}/compare", access: accessUser, handler: (*APIServer).handleCompareRuns, tag: "Results",
+		summary: "Pages broken, fixed, added and removed since the crawl this one reran", response: RunComparison{},
+		query: []queryParam{{"with", "Crawl to compare with instead of the one this crawl reran"}}},
+	{method: "GET", path: "/crawl/{id}/skipped", access: accessUser, handler: (*APIServer).handleGetSkipped, tag: "Results",
+		summary: "URLs the crawl skipped and why", response: SkipReport{},
+		query: []queryParam{{"reason", "Only URLs skipped for this reason"}}},
+	{method: "GET", path: "/crawl/{id}/results", access: accessUser, handler: (*APIServer).handleGetResults, tag: "Results",
+		summary: "Every fetched page", response: ResultReport{},
+		query: []queryParam{
+			{"change", "Only pages with this change state, for refresh crawls"},
+			{"errorClass", "Only pages that failed with this error class"},
+			{"label", "Only pages with this label"},
+			{"format", "csv for a CSV download, seo for an SEO audit spreadsheet, junit for a JUnit XML link-check report"},
+			{"schema", "Result schema version of JSON pages, 1 (the default) or 2"},
+		},
+		formats: map[string]string{"csv": "text/csv", "seo": "text/csv", "junit": "application/xml"}},
+	{method: "GET", path: "/crawl/{id}/stream", access: accessUser, handler: (*APIServer).handleStreamResults, tag: "Results",
+		summary: "Stream the crawl's pages as JSON lines until it completes", response: PageResult{}, stream: true,
+		query: []queryParam{{"schema", "Result schema version of the pages, 1 (the default) or 2"}}},
+	{method: "GET", path: "/crawl/{id}/events", access: accessUser, handler: (*APIServer).handleStreamEvents, tag: "Results",
+		summary: "Stream the running crawl's events as JSON lines until it completes", response: crawler.Event{}, stream: true,
+		query: []queryParam{{"type", "Comma-separated event types to send"}}},
+	{method: "GET", path: "/crawl/{id}/compliance", access: accessUser, handler: (*APIServer).handleGetCompliance, tag: "Results",
+		summary: "The crawl's robots.txt decisions", response: ComplianceReport{},
+		query: []queryParam{
+			{"disallowed", "true for disallowed URLs only"},
+			{"format", "csv for a CSV download"},
+		},
+		formats: map[string]string{"csv": "text/csv"}},
+	{method: "GET", path: "/crawl/{id}/sitemap", access: accessUser, handler: (*APIServer).handleGetSitemap, tag: "Results",
+		summary: "A sitemap of the fetched pages, or a sitemap index of numbered sitemaps when they don't fit in one",
+		query: []queryParam{
+			{"filter", "Comma-separated: ok for 200 responses only, canonical to leave out pages canonicalised to another URL"},
+			{"format", "xml (default) or txt"},
+			{"page", "Which numbered sitemap of an index to send"},
+		},
+		formats: map[string]string{"xml": "application/xml", "txt": "text/plain"}},
+	{method: "GET", path: "/crawl/{id}/text", access: accessUser, handler: (*APIServer).handleGetText, tag: "Results",
+		summary: "The main text of each page, for crawls that extracted it, as JSON lines", response: crawler.PageText{}, stream: true},
+	{method: "GET", path: "/crawl/{id}/inlinks", access: accessUser, handler: (*APIServer).handleGetInlinks, tag: "Results",
+		summary: "The crawled pages that link to a URL", response: InlinksReport{},
+		query: []queryParam{{"url", "The linked URL, required; its fragment is ignored"}}},
+	{method: "GET", path: "/crawl/{id}/hosts", access: accessUser, handler: (*APIServer).handleGetHosts, tag: "Results",
+		summary: "Pages, errors, latency, robots.txt crawl delay and throttling by host", response: []crawler.HostReport{}},
+	{method: "GET", path: "/crawl/{id}/accessibility", access: accessUser, handler: (*APIServer).handleGetAccessibility, tag: "Results",
+		summary: "Accessibility issues by check, and the pages with issues, for crawls that checked accessibility", response: crawler.AccessibilityReport{}},
+	{method: "GET", path: "/crawl/{id}/metadata", access: accessUser, handler: (*APIServer).handleGetMetadata, tag: "Results",
+		summary: "Duplicate and missing titles and meta descriptions, grouped by the pages they affect", response: crawler.MetadataReport{},
+		query:   []queryParam{{"format", "csv for a CSV download with one row per group"}},
+		formats: map[string]string{"csv": "text/csv"}},
+	{method: "GET", path: "/crawl/{id}/thin", access: accessUser, handler: (*APIServer).handleGetThin, tag: "Results",
+		summary: "Pages with thin content: fewer words of main text than a threshold, fewest first", response: ThinReport{},
+		query: []queryParam{{"words", "Word threshold, by default the request's thinContent or 300"}}},
+	{method: "PATCH", path: "/crawl/{id}/results/{urlhash}", access: accessUser, handler: (*APIServer).handleAnnotateResult, tag: "Results",
+		summary: "Label a page or add a note to it", request: ResultPatch{}, response: PageResult{}},
+	{method: "GET", path: "/crawl/{id}/assets", access: accessUser, handler: (*APIServer).handleGetAssets, tag: "Results",
+		summary: "Broken images, scripts and stylesheets", response: []crawler.BrokenAsset{}},
+	{method: "GET", path: "/crawl/{id}/alternates", access: accessUser, handler: (*APIServer).handleGetAlternates, tag: "Results",
+		summary: "Broken AMP and mobile alternates", response: []crawler.BrokenAlternate{}},
+	{method: "GET", path: "/crawl/{id}/external", access: accessUser, handler: (*APIServer).handleGetExternal, tag: "Results",
+		summary: "Dead links to other sites", response: []crawler.ExternalLink{}},
+	{method: "GET", path: "/crawl/{id}/graph/stats", access: accessUser, handler: (*APIServer).handleGraphStats, tag: "Results",
+		summary: "Link graph statistics", response: crawler.GraphStats{},
+		query: []queryParam{{"top", "How many pages each ranking lists"}}},
+	{method: "GET", path: "/crawl/{id}/performance", access: accessUser, handler: (*APIServer).handleGetPerformance, tag: "Results",
+		summary: "Page weight and timing summary", response: crawler.PerformanceSummary{},
+		query: []queryParam{{"top", "How many of the slowest and heaviest pages to list"}}},
+	{method: "GET", path: "/crawl/{id}/traps", access: accessUser, handler: (*APIServer).handleGetTraps, tag: "Results",
+		summary: "Crawl trap patterns the crawl detected", response: []crawler.Trap{}},
+	{method: "POST", path: "/schedules", access: accessUser, handler: (*APIServer).handleCreateSchedule, tag: "Schedules",
+		summary: "Create a recurring crawl", request: ScheduleRequest{}, response: Schedule{}, status: http.StatusCreated},
+	{method: "GET", path: "/schedules", access: accessUser, handler: (*APIServer).handleListSchedules, tag: "Schedules",
+		summary: "List your schedules", response: []Schedule{}},
+	{method: "GET", path: "/schedules/{id}", access: accessUser, handler: (*APIServer).handleGetSchedule, tag: "Schedules",
+		summary: "Get a schedule", response: Schedule{}},
+	{method: "DELETE", path: "/schedules/{id}", access: accessUser, handler: (*APIServer).handleDeleteSchedule, tag: "Schedules",
+		summary: "Delete a schedule", status: http.StatusNoContent},
+	{method: "POST", path: "/schedules/{id}/trigger", access: accessUser, handler: (*APIServer).handleTriggerSchedule, tag: "Schedules",
+		summary: "Run a schedule now", response: CrawlAccepted{}, status: http.StatusAccepted},
+	{method: "POST", path: "/hooks/{token}", access: accessPublic, handler: (*APIServer).handleScheduleHook, tag: "Schedules",
+		summary: "Run the schedule a deploy webhook token belongs to; the token is the credential", response: CrawlAccepted{}, status: http.StatusAccepted},
+	{method: "POST", path: "/templates", access: accessUser, handler: (*APIServer).handleSaveTemplate, tag: "Templates",
+		summary: "Save a crawl request template", request: Template{}, response: Template{}},
+	{method: "GET", path: "/templates", access: accessUser, handler: (*APIServer).handleListTemplates, tag: "Templates",
+		summary: "List templates", response: []Template{}},
+	{method: "GET", path: "/templates/{name}", access: accessUser, handler: (*APIServer).handleGetTemplate, tag: "Templates",
+		summary: "Get a template", response: Template{}},
+	{method: "DELETE", path: "/templates/{name}", access: accessUser, handler: (*APIServer).handleDeleteTemplate, tag: "Templates",
+		summary: "Delete a template", status: http.StatusNoContent},
+	{method: "GET", path: "/linkrot", access: accessUser, handler: (*APIServer).handleLinkRot, tag: "Results",
+		summary: "Broken links over time, per host; with host, that host's series with its runs", response: []LinkRotSeries{},
+		query: []queryParam{
+			{"host", "Only this host, with its runs"},
+			{"limit", "How many completed crawls per host to look at"},
+		}},
+	{method: "GET", path: "/graphql", access: accessUser, handler: (*APIServer).handleGraphQL, tag: "GraphQL",
+		summary: "Run a GraphQL query", response: map[string]any{},
+		query: []queryParam{
+			{"query", "The query"},
+			{"operationName", "Operation to run"},
+			{"variables", "Variables as a JSON object"},
+		}},
+	{method: "POST", path: "/graphql", access: accessUser, handler: (*APIServer).handleGraphQL, tag: "GraphQL",
+		summary: "Run a GraphQL query", request: graphQLRequest{}, response: map[string]any{}},
+	{method: "GET", path: "/presets", access: accessPublic, handler: (*APIServer).handlePresets, tag: "Server",
+		summary: "Politeness presets", response: []crawler.Politeness{}},
+	{method: "GET", path: "/schema", access: accessPublic, handler: (*APIServer).handleSchema, tag: "Server",
+		summary: "JSON Schema of the WebSocket messages and event stream lines", response: map[string]any{}},
+	{method: "GET", path: "/openapi.json", access: accessPublic, handler: (*APIServer).handleOpenAPI, tag: "Server",
+		summary: "This OpenAPI document", response: map[string]any{}},
+	{method: "GET", path: "/docs", access: accessPublic, handler: (*APIServer).handleAPIDocs, tag: "Server",
+		summary: "Swagger UI for this API"},
+	{method: "GET", path: "/plugins", access: accessUser, handler: (*APIServer).handlePlugins, tag: "Server",
+		summary: "Plugins crawl requests may enable", response: []string{}},
+	{method: "GET", path: "/robots", access: accessUser, handler: (*APIServer).handleRobots, tag: "Server",
+		summary: "Fetch and interpret a site's robots.txt", response: RobotsPreview{},
+		query: []queryParam{
+			{"url", "URL whose robots.txt to fetch and test (required)"},
+			{"path", "Path to test instead of the URL's own"},
+		}},
+	{method: "GET", path: "/admin/settings", access: accessAdmin, handler: (*APIServer).handleGetSettings, tag: "Admin",
+		summary: "Runtime settings", response: Settings{}},
+	{method: "PATCH", path: "/admin/settings", access: accessAdmin, handler: (*APIServer).handleUpdateSettings, tag: "Admin",
+		summary: "Change runtime settings", request: SettingsUpdate{}, response: Settings{}},
+}
+
+// registerRoutes adds apiRoutes to the router behind their access checks
+// and builds their OpenAPI document
+func (s *APIServer) registerRoutes() {
+	s.openAPI = openAPIJSON(apiRoutes)
+	for _, rt := range apiRoutes {
+		handler := rt.handler
+		fn := func(w http.ResponseWriter, r *http.Request) { handler(s, w, r) }
+		switch rt.access {
+		case accessUser:
+			fn = s.requireUser(fn)
+		case accessAdmin:
+			fn = s.requireAdmin(fn)
+		}
+		s.router.HandleFunc(rt.path, fn).Methods(rt.method)
+	}
+}
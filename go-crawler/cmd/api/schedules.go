@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// minScheduleInterval is the shortest interval a schedule may repeat at
+const minScheduleInterval = time.Minute
+
+// errScheduleBusy is returned when a schedule's previous crawl is still
+// queued or running
+var errScheduleBusy = errors.New("the schedule's previous crawl has not finished")
+
+// Schedule is a saved crawl that runs every Every, when it is triggered
+// through the API, or when an external system such as a CMS publish hook or
+// a CI deploy calls its webhook
+type Schedule struct {
+	ID        string        `json:"id"`
+	Owner     string        `json:"owner,omitempty"`
+	Name      string        `json:"name,omitempty"`
+	Request   CrawlRequest  `json:"request"`
+	Every     time.Duration `json:"every,omitempty"` // 0 = only when triggered
+	HookToken string        `json:"hookToken"`       // Secret part of the webhook URL
+	CreatedAt time.Time     `json:"createdAt"`
+	LastRunAt *time.Time    `json:"lastRunAt,omitempty"`
+	LastJob   string        `json:"lastJob,omitempty"` // ID of the latest crawl
+
+	// Notify lists where each finished crawl's summary is sent, and Alerts
+	// the thresholds, such as new-broken-links>10, that are flagged in it
+	Notify []NotifierConfig `json:"notify,omitempty"`
+	Alerts []string         `json:"alerts,omitempty"`
+
+	user *User         // Owner whose quota the crawls count against
+	stop chan struct{} // Closed to stop the interval timer
+}
+
+// ScheduleStore holds the server's schedules in memory, and in keeper when
+// the server's store keeps them so that they survive restarts
+type ScheduleStore struct {
+	mu      sync.Mutex
+	byID    map[string]*Schedule
+	byToken map[string]*Schedule
+
+	keeper ScheduleKeeper // nil keeps schedules in memory only
+}
+
+func NewScheduleStore() *ScheduleStore {
+	return &ScheduleStore{
+		byID:    make(map[string]*Schedule),
+		byToken: make(map[string]*Schedule),
+	}
+}
+
+// Add stores a new schedule
+func (s *ScheduleStore) Add(sched *Schedule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.byID[sched.ID] = sched
+	s.byToken[sched.HookToken] = sched
+	s.save(sched)
+}
+
+// Restore adds schedules loaded from the keeper, which already holds them
+func (s *ScheduleStore) Restore(scheds []*Schedule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sched := range scheds {
+		s.byID[sched.ID] = sched
+		s.byToken[sched.HookToken] = sched
+	}
+}
+
+// save writes a schedule to the keeper, logging failures. s.mu must be
+// held, which keeps a schedule's writes in order.
+func (s *ScheduleStore) save(sched *Schedule) {
+	if s.keeper == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := s.keeper.SaveSchedule(ctx, *sched); err != nil {
+		warnf("Error storing schedule %s: %v", sched.ID, err)
+	}
+}
+
+// Get returns the schedule with the given ID
+func (s *ScheduleStore) Get(id string) (*Schedule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sched, ok := s.byID[id]
+	return sched, ok
+}
+
+// ByToken returns the schedule whose webhook token is token
+func (s *ScheduleStore) ByToken(token string) (*Schedule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sched, ok := s.byToken[token]
+	return sched, ok
+}
+
+// Remove deletes a schedule and stops its timer
+func (s *ScheduleStore) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sched, ok := s.byID[id]; ok {
+		delete(s.byID, id)
+		delete(s.byToken, sched.HookToken)
+		if sched.stop != nil {
+			close(sched.stop)
+		}
+		if s.keeper != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+			defer cancel()
+			if err := s.keeper.DeleteSchedule(ctx, id); err != nil {
+				warnf("Error deleting schedule %s from the store: %v", id, err)
+			}
+		}
+	}
+}
+
+// List returns copies of an owner's schedules, oldest first
+func (s *ScheduleStore) List(owner string) []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scheds := []Schedule{}
+	for _, sched := range s.byID {
+		if sched.Owner == owner {
+			scheds = append(scheds, *sched)
+		}
+	}
+	sort.Slice(scheds, func(i, j int) bool { return scheds[i].CreatedAt.Before(scheds[j].CreatedAt) })
+	return scheds
+}
+
+// Snapshot returns a copy of a schedule that is safe to serialize
+func (s *ScheduleStore) Snapshot(sched *Schedule) Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *sched
+}
+
+// newHookToken returns a random, unguessable webhook token
+func newHookToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// runSchedule submits a crawl for a schedule unless its previous crawl is
+// still active, and returns the new job and its queue position
+func (s *APIServer) runSchedule(sched *Schedule) (*Job, int, error) {
+	s.schedules.mu.Lock()
+	defer s.schedules.mu.Unlock()
+
+	if sched.LastJob != "" {
+		if info, ok := s.jobs.Info(sched.LastJob); ok && info.Status != JobCompleted {
+			return nil, 0, errScheduleBusy
+		}
+	}
+
+	req := sched.Request
+	priority, err := s.prepareCrawl(sched.user, &req)
+	if err != nil {
+		return nil, 0, err
+	}
+	previous, snapshot := sched.LastJob, *sched
+	run := func(ctx context.Context, job *Job) {
+		s.broadcastCrawl(ctx, job)
+		go s.notifySchedule(snapshot, job, previous)
+	}
+	job, position, err := s.jobs.Submit(sched.user, req, priority, run)
+	if err != nil {
+		return nil, 0, err
+	}
+	now := time.Now()
+	sched.LastRunAt = &now
+	sched.LastJob = job.ID
+	s.schedules.save(sched)
+	return job, position, nil
+}
+
+// restoreSchedules adds the schedules the store kept and restarts their
+// interval timers. A schedule whose owner is no longer in the users file
+// is left out until they are back.
+func (s *APIServer) restoreSchedules(stored []Schedule) int {
+	scheds := make([]*Schedule, 0, len(stored))
+	for i := range stored {
+		sched := &stored[i]
+		sched.user = anonymousUser
+		if s.users != nil {
+			user, ok := s.users.ByName(sched.Owner)
+			if !ok {
+				warnf("Not restoring schedule %s: its owner %q is not a user", sched.ID, sched.Owner)
+				continue
+			}
+			sched.user = user
+		}
+		if sched.Every > 0 {
+			sched.stop = make(chan struct{})
+			go s.runEvery(sched)
+		}
+		scheds = append(scheds, sched)
+	}
+	s.schedules.Restore(scheds)
+	return len(scheds)
+}
+
+// runEvery runs a schedule at its interval until it is removed
+func (s *APIServer) runEvery(sched *Schedule) {
+	ticker := time.NewTicker(sched.Every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sched.stop:
+			return
+		case <-ticker.C:
+			job, _, err := s.runSchedule(sched)
+			if err != nil {
+				warnf("Schedule %s did not run: %v", sched.ID, err)
+				continue
+			}
+			infof("Schedule %s started crawl %s", sched.ID, job.ID)
+		}
+	}
+}
+
+// ScheduleRequest is the body of POST /schedules
+type ScheduleRequest struct {
+	Name string `json:"name"`
+	// Request is a crawl request, decoded with its template like POST /crawl
+	Request json.RawMessage `json:"request" openapi:"CrawlRequest"`
+	Every   time.Duration   `json:"every"`
+
+	Notify []NotifierConfig `json:"notify"`
+	Alerts []string         `json:"alerts"`
+}
+
+// handleCreateSchedule saves a crawl request as a schedule. The request is
+// validated now and again each time the schedule runs.
+func (s *APIServer) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var body ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.Every != 0 && body.Every < minScheduleInterval {
+		http.Error(w, fmt.Sprintf("every must be at least %v", minScheduleInterval), http.StatusBadRequest)
+		return
+	}
+
+	for _, cfg := range body.Notify {
+		if _, err := s.newNotifier(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	for _, expr := range body.Alerts {
+		if _, err := parseAlertThreshold(expr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	request, err := s.decodeCrawlRequest(bytes.NewReader(body.Request))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user := userFromContext(r.Context())
+	req := request
+	if _, err := s.prepareCrawl(user, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sched := &Schedule{
+		ID:        newJobID(),
+		Owner:     user.Name,
+		Name:      strings.TrimSpace(body.Name),
+		Request:   request,
+		Every:     body.Every,
+		HookToken: newHookToken(),
+		CreatedAt: time.Now(),
+		Notify:    body.Notify,
+		Alerts:    body.Alerts,
+		user:      user,
+	}
+	if sched.Every > 0 {
+		sched.stop = make(chan struct{})
+		go s.runEvery(sched)
+	}
+	s.schedules.Add(sched)
+	infof("User %q created schedule %s for %s", user.Name, sched.ID, sched.Request.URL)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s.schedules.Snapshot(sched))
+}
+
+// scheduleForRequest returns the schedule named by the {id} route variable,
+// writing a 404 if it doesn't exist or belongs to another user
+func (s *APIServer) scheduleForRequest(w http.ResponseWriter, r *http.Request) (*Schedule, bool) {
+	sched, ok := s.schedules.Get(mux.Vars(r)["id"])
+	if !ok || sched.Owner != userFromContext(r.Context()).Name {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return nil, false
+	}
+	return sched, true
+}
+
+// handleListSchedules lists the requesting user's schedules
+func (s *APIServer) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.schedules.List(userFromContext(r.Context()).Name))
+}
+
+// handleGetSchedule returns a schedule
+func (s *APIServer) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	sched, ok := s.scheduleForRequest(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.schedules.Snapshot(sched))
+}
+
+// handleDeleteSchedule removes a schedule; crawls it started keep running
+func (s *APIServer) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	sched, ok := s.scheduleForRequest(w, r)
+	if !ok {
+		return
+	}
+	s.schedules.Remove(sched.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTriggerSchedule runs a schedule now
+func (s *APIServer) handleTriggerSchedule(w http.ResponseWriter, r *http.Request) {
+	sched, ok := s.scheduleForRequest(w, r)
+	if !ok {
+		return
+	}
+	s.triggerSchedule(w, sched, "API")
+}
+
+// handleScheduleHook runs the schedule a webhook token belongs to. It is not
+// behind requireUser: the token in the URL is the credential, so that CMS
+// and CI systems can call it without an API key.
+func (s *APIServer) handleScheduleHook(w http.ResponseWriter, r *http.Request) {
+	sched, ok := s.schedules.ByToken(mux.Vars(r)["token"])
+	if !ok {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	s.triggerSchedule(w, sched, "webhook")
+}
+
+// triggerSchedule runs a schedule and responds like POST /crawl
+func (s *APIServer) triggerSchedule(w http.ResponseWriter, sched *Schedule, source string) {
+	job, position, err := s.runSchedule(sched)
+	switch {
+	case err == errScheduleBusy:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err == ErrQuotaExceeded:
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	infof("Schedule %s started crawl %s from the %s", sched.ID, job.ID, source)
+
+	resp := CrawlAccepted{ID: job.ID, Schedule: sched.ID, Status: "Crawl started"}
+	if position > 0 {
+		resp.Status, resp.Position = "queued", position
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes of GET /crawls
+const (
+	defaultJobPageSize = 50
+	maxJobPageSize     = 500
+)
+
+// JobPage is one page of the jobs GET /crawls found
+type JobPage struct {
+	Total  int       `json:"total"` // Jobs matching the filters, on all pages
+	Offset int       `json:"offset"`
+	Limit  int       `json:"limit"`
+	Jobs   []JobInfo `json:"jobs"`
+}
+
+// jobSearch is the filters, order and page of GET /crawls
+type jobSearch struct {
+	statuses map[JobStatus]bool // Any status when empty
+	labels   []string           // Selectors as matchJobLabels takes them
+	lineage  string             // ID of the first job of a rerun request
+	domain   string             // Host of the start URL, or a parent domain of it
+	since    time.Time          // Created at or after, if set
+	until    time.Time          // Created before, if set
+	sortBy   string
+	desc     bool
+	offset   int
+	limit    int
+}
+
+// jobSortKeys compare two jobs by the field a sort parameter names
+var jobSortKeys = map[string]func(a, b JobInfo) bool{
+	"createdAt": func(a, b JobInfo) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	"finishedAt": func(a, b JobInfo) bool {
+		// Unfinished jobs sort after finished ones
+		if a.FinishedAt == nil || b.FinishedAt == nil {
+			return a.FinishedAt != nil && b.FinishedAt == nil
+		}
+		return a.FinishedAt.Before(*b.FinishedAt)
+	},
+	"pages":  func(a, b JobInfo) bool { return a.Pages < b.Pages },
+	"errors": func(a, b JobInfo) bool { return a.Errors < b.Errors },
+	"url":    func(a, b JobInfo) bool { return a.Request.URL < b.Request.URL },
+}
+
+// parseJobSearch reads GET /crawls's query parameters
+func parseJobSearch(query url.Values) (jobSearch, error) {
+	search := jobSearch{
+		statuses: map[JobStatus]bool{},
+		labels:   query["label"],
+		lineage:  query.Get("lineage"),
+		domain:   strings.TrimPrefix(strings.ToLower(query.Get("domain")), "."),
+		sortBy:   "createdAt",
+		desc:     true,
+		limit:    defaultJobPageSize,
+	}
+
+	for _, status := range strings.Split(query.Get("status"), ",") {
+		switch status := JobStatus(strings.TrimSpace(status)); status {
+		case "":
+		case JobQueued, JobRunning, JobPaused, JobCompleted:
+			search.statuses[status] = true
+		default:
+			return jobSearch{}, fmt.Errorf("unknown status %q: want queued, running, paused or completed", status)
+		}
+	}
+
+	var err error
+	if v := query.Get("since"); v != "" {
+		if search.since, err = parseSearchTime(v, false); err != nil {
+			return jobSearch{}, fmt.Errorf("invalid since: %v", err)
+		}
+	}
+	if v := query.Get("until"); v != "" {
+		if search.until, err = parseSearchTime(v, true); err != nil {
+			return jobSearch{}, fmt.Errorf("invalid until: %v", err)
+		}
+	}
+
+	if v := query.Get("sort"); v != "" {
+		search.sortBy, search.desc = strings.TrimPrefix(v, "-"), strings.HasPrefix(v, "-")
+		if _, ok := jobSortKeys[search.sortBy]; !ok {
+			return jobSearch{}, fmt.Errorf("unknown sort %q: want createdAt, finishedAt, pages, errors or url, with - for descending", v)
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		search.limit, err = strconv.Atoi(v)
+		if err != nil || search.limit < 1 || search.limit > maxJobPageSize {
+			return jobSearch{}, fmt.Errorf("limit must be between 1 and %d", maxJobPageSize)
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		search.offset, err = strconv.Atoi(v)
+		if err != nil || search.offset < 0 {
+			return jobSearch{}, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+	return search, nil
+}
+
+// parseSearchTime parses an RFC 3339 time or a date. A date ends a range
+// at the end of the day when end is set.
+func parseSearchTime(v string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date such as 2024-05-23 or a time such as 2024-05-23T10:00:00Z", v)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// matches reports whether a job passes the search's filters
+func (q jobSearch) matches(info JobInfo) bool {
+	if len(q.statuses) > 0 && !q.statuses[info.Status] {
+		return false
+	}
+	if q.lineage != "" && info.ID != q.lineage && info.Lineage != q.lineage {
+		return false
+	}
+	if !q.since.IsZero() && info.CreatedAt.Before(q.since) {
+		return false
+	}
+	if !q.until.IsZero() && !info.CreatedAt.Before(q.until) {
+		return false
+	}
+	if q.domain != "" {
+		u, err := url.Parse(info.Request.URL)
+		if err != nil {
+			return false
+		}
+		host := strings.ToLower(u.Hostname())
+		if host != q.domain && !strings.HasSuffix(host, "."+q.domain) {
+			return false
+		}
+	}
+	return matchJobLabels(info.Request.Labels, q.labels)
+}
+
+// run filters, sorts and pages jobs
+func (q jobSearch) run(jobs []JobInfo) JobPage {
+	found := []JobInfo{}
+	for _, info := range jobs {
+		if q.matches(info) {
+			found = append(found, info)
+		}
+	}
+	less := jobSortKeys[q.sortBy]
+	sort.SliceStable(found, func(i, j int) bool {
+		if q.desc {
+			return less(found[j], found[i])
+		}
+		return less(found[i], found[j])
+	})
+
+	page := JobPage{Total: len(found), Offset: q.offset, Limit: q.limit, Jobs: []JobInfo{}}
+	if q.offset < len(found) {
+		page.Jobs = found[q.offset:min(q.offset+q.limit, len(found))]
+	}
+	return page
+}
+
+// handleSearchCrawls finds the requesting user's jobs by status, label,
+// start URL domain, lineage and creation date, newest first unless sorted
+// otherwise, one page at a time
+func (s *APIServer) handleSearchCrawls(w http.ResponseWriter, r *http.Request) {
+	search, err := parseJobSearch(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page := search.run(s.jobs.List(userFromContext(r.Context()).Name))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
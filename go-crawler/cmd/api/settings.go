@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"go-crawler/internal/crawler"
+)
+
+// Settings are server-wide knobs that admins can change at runtime
+type Settings struct {
+	RateLimit        float64  `json:"rateLimit"`        // Requests per second across all jobs, 0 = unlimited
+	BandwidthLimit   int64    `json:"bandwidthLimit"`   // Bytes per second across all jobs, 0 = unlimited
+	Blocklist        []string `json:"blocklist"`        // Domains that are never crawled
+	Allowlist        []string `json:"allowlist"`        // If set, the only domains that may be crawled
+	MaxWorkersPerJob int      `json:"maxWorkersPerJob"` // 0 = unlimited
+	LogLevel         string   `json:"logLevel"`
+	// AllowPrivateHosts lets a crawl's hosts and dnsServer reach loopback,
+	// private and link-local addresses
+	AllowPrivateHosts bool `json:"allowPrivateHosts"`
+}
+
+// SettingsUpdate is a partial update; nil fields are left unchanged
+type SettingsUpdate struct {
+	RateLimit         *float64  `json:"rateLimit"`
+	BandwidthLimit    *int64    `json:"bandwidthLimit"`
+	Blocklist         *[]string `json:"blocklist"`
+	Allowlist         *[]string `json:"allowlist"`
+	MaxWorkersPerJob  *int      `json:"maxWorkersPerJob"`
+	LogLevel          *string   `json:"logLevel"`
+	AllowPrivateHosts *bool     `json:"allowPrivateHosts"`
+}
+
+// SettingsStore holds the live settings. Running crawls pick up changes
+// immediately through the shared rate limiter and URL filter.
+type SettingsStore struct {
+	mu          sync.RWMutex
+	settings    Settings
+	rateLimiter *crawler.RateLimiter
+	bandwidth   *crawler.BandwidthLimiter
+}
+
+func NewSettingsStore(initial Settings) *SettingsStore {
+	initial.Blocklist = normalizeDomains(initial.Blocklist)
+	initial.Allowlist = normalizeDomains(initial.Allowlist)
+	if initial.LogLevel == "" {
+		initial.LogLevel = getLogLevel().String()
+	}
+	return &SettingsStore{
+		settings:    initial,
+		rateLimiter: crawler.NewRateLimiter(initial.RateLimit),
+		bandwidth:   crawler.NewBandwidthLimiter(initial.BandwidthLimit),
+	}
+}
+
+// Get returns a copy of the current settings
+func (s *SettingsStore) Get() Settings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings := s.settings
+	settings.Blocklist = append([]string(nil), s.settings.Blocklist...)
+	settings.Allowlist = append([]string(nil), s.settings.Allowlist...)
+	return settings
+}
+
+// Update validates and applies a partial update, returning the new settings
+func (s *SettingsStore) Update(u SettingsUpdate) (Settings, error) {
+	var level logLevel
+	if u.LogLevel != nil {
+		var err error
+		if level, err = parseLogLevel(*u.LogLevel); err != nil {
+			return Settings{}, err
+		}
+	}
+
+	s.mu.Lock()
+	if u.RateLimit != nil {
+		s.settings.RateLimit = *u.RateLimit
+		s.rateLimiter.SetRate(*u.RateLimit)
+	}
+	if u.BandwidthLimit != nil {
+		s.settings.BandwidthLimit = *u.BandwidthLimit
+		s.bandwidth.SetRate(*u.BandwidthLimit)
+	}
+	if u.Blocklist != nil {
+		s.settings.Blocklist = normalizeDomains(*u.Blocklist)
+	}
+	if u.Allowlist != nil {
+		s.settings.Allowlist = normalizeDomains(*u.Allowlist)
+	}
+	if u.MaxWorkersPerJob != nil {
+		s.settings.MaxWorkersPerJob = *u.MaxWorkersPerJob
+	}
+	if u.LogLevel != nil {
+		s.settings.LogLevel = level.String()
+		setLogLevel(level)
+	}
+	if u.AllowPrivateHosts != nil {
+		s.settings.AllowPrivateHosts = *u.AllowPrivateHosts
+	}
+	s.mu.Unlock()
+
+	return s.Get(), nil
+}
+
+// RateLimiter returns the limiter shared by every crawl on the server
+func (s *SettingsStore) RateLimiter() *crawler.RateLimiter {
+	return s.rateLimiter
+}
+
+// BandwidthLimiter returns the bandwidth limiter shared by every crawl
+func (s *SettingsStore) BandwidthLimiter() *crawler.BandwidthLimiter {
+	return s.bandwidth
+}
+
+// CheckDomain returns an error if a URL's host is blocklisted, or if an
+// allowlist is configured and the host is not on it
+func (s *SettingsStore) CheckDomain(u *url.URL) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	host := strings.ToLower(u.Hostname())
+	if domain, ok := matchDomain(host, s.settings.Blocklist); ok {
+		return fmt.Errorf("domain %s is blocked on this server (matches %s)", host, domain)
+	}
+	if len(s.settings.Allowlist) > 0 {
+		if _, ok := matchDomain(host, s.settings.Allowlist); !ok {
+			return fmt.Errorf("domain %s is not on this server's allowlist", host)
+		}
+	}
+	return nil
+}
+
+// matchDomain returns the first entry that host equals or is a subdomain of.
+// Entries may be whole suffixes such as "gov" to match every .gov host.
+func matchDomain(host string, domains []string) (string, bool) {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return domain, true
+		}
+	}
+	return "", false
+}
+
+// LoadDomainList reads domains from a file, one per line. Blank lines and
+// lines starting with # are ignored.
+func LoadDomainList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening domain list: %v", err)
+	}
+	defer f.Close()
+
+	var domains []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading domain list: %v", err)
+	}
+	return domains, nil
+}
+
+// normalizeDomains lowercases domains, strips "*." and leading or trailing
+// dots, and drops empty entries
+func normalizeDomains(domains []string) []string {
+	out := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "*.")
+		d = strings.Trim(d, ".")
+		if d != "" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
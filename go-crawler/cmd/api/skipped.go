@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"go-crawler/internal/crawler"
+)
+
+// maxSkipEntries bounds how many skipped URLs a job keeps; counts are
+// always exact
+const maxSkipEntries = 10000
+
+// SkipLog collects the URLs a job skipped and why
+type SkipLog struct {
+	mu        sync.Mutex
+	counts    map[crawler.SkipReason]int
+	entries   []crawler.SkippedURL
+	seen      map[string]struct{} // url + reason, to list each pair once
+	truncated bool
+}
+
+func NewSkipLog() *SkipLog {
+	return &SkipLog{
+		counts: make(map[crawler.SkipReason]int),
+		seen:   make(map[string]struct{}),
+	}
+}
+
+// Record adds a skipped URL; it is safe for concurrent use
+func (l *SkipLog) Record(s crawler.SkippedURL) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.counts[s.Reason]++
+
+	key := string(s.Reason) + " " + s.URL
+	if _, ok := l.seen[key]; ok {
+		return
+	}
+	if len(l.entries) >= maxSkipEntries {
+		l.truncated = true
+		return
+	}
+	l.seen[key] = struct{}{}
+	l.entries = append(l.entries, s)
+}
+
+// Total returns how many URLs were skipped for any reason
+func (l *SkipLog) Total() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	total := 0
+	for _, n := range l.counts {
+		total += n
+	}
+	return total
+}
+
+// SkipReport is the response body of GET /crawl/{id}/skipped
+type SkipReport struct {
+	Counts    map[crawler.SkipReason]int `json:"counts"`
+	Skipped   []crawler.SkippedURL       `json:"skipped"`
+	Truncated bool                       `json:"truncated,omitempty"`
+}
+
+// Report returns the counts and the skipped URLs, optionally limited to
+// a single reason
+func (l *SkipLog) Report(reason crawler.SkipReason) SkipReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	report := SkipReport{
+		Counts:    make(map[crawler.SkipReason]int, len(l.counts)),
+		Skipped:   []crawler.SkippedURL{},
+		Truncated: l.truncated,
+	}
+	for r, n := range l.counts {
+		report.Counts[r] = n
+	}
+	for _, s := range l.entries {
+		if reason == "" || s.Reason == reason {
+			report.Skipped = append(report.Skipped, s)
+		}
+	}
+	return report
+}
+
+// handleGetSkipped explains which URLs a job skipped, filtered by the
+// optional reason parameter
+func (s *APIServer) handleGetSkipped(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	reason := crawler.SkipReason(r.URL.Query().Get("reason"))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job.Skips.Report(reason))
+}
+
+// handleGetTraps lists the crawl trap patterns a job detected and blocked
+func (s *APIServer) handleGetTraps(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	traps := []crawler.Trap{}
+	if c := job.Crawler(); c != nil {
+		traps = append(traps, c.Traps()...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(traps)
+}
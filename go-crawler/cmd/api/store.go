@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Store persists jobs and their results outside the server's memory, so
+// crawl data can be queried alongside other data. The server still serves
+// its API from memory; a Store is a write-through copy.
+type Store interface {
+	// SaveJob inserts or updates a job's record. A completed job's record
+	// is not moved back to an earlier status.
+	SaveJob(ctx context.Context, info JobInfo) error
+	// AddResults inserts a batch of a job's results in one transaction,
+	// ignoring any already stored. It is retried if it fails and must not
+	// keep pages after it returns.
+	AddResults(ctx context.Context, jobID string, pages []PageResult) error
+	// UpdateResult stores a result again after it was annotated
+	UpdateResult(ctx context.Context, jobID string, page PageResult) error
+	// DeleteJob removes a job and its results
+	DeleteJob(ctx context.Context, jobID string) error
+	// Prune removes the completed jobs, with their results, that the
+	// retention policy no longer keeps, and returns how many it removed
+	Prune(ctx context.Context, r Retention) (int, error)
+	Close() error
+}
+
+// JobLoader is a Store that loads the jobs it holds back, so that the
+// server remembers them across restarts
+type JobLoader interface {
+	LoadJobs(ctx context.Context) ([]StoredJob, error)
+}
+
+// StoredJob is a job as a JobLoader loads it, with its results in the order
+// they were recorded
+type StoredJob struct {
+	Info    JobInfo
+	Results []PageResult
+}
+
+// ScheduleKeeper is a Store that also keeps schedules, so that they, their
+// webhook tokens and their timers survive restarts
+type ScheduleKeeper interface {
+	// SaveSchedule inserts or updates a schedule
+	SaveSchedule(ctx context.Context, sched Schedule) error
+	DeleteSchedule(ctx context.Context, id string) error
+	LoadSchedules(ctx context.Context) ([]Schedule, error)
+}
+
+const (
+	storeBatchSize     = 500             // Results per transaction
+	storeFlushInterval = 2 * time.Second // Longest a result waits to be stored
+	storeQueueSize     = 10000           // Results waiting per job before its crawl is held back
+	storeAttempts      = 3               // Tries per batch before it is dropped
+	storeTimeout       = 30 * time.Second
+)
+
+// resultWriter batches a job's results into a store as they are recorded.
+// Results wait in a queue while the previous batch is written; when the
+// store falls behind and the queue is full, add blocks, which holds the
+// crawl back instead of letting memory grow.
+type resultWriter struct {
+	store Store
+	jobID string
+
+	queue chan PageResult
+	stop  chan struct{}
+	done  chan struct{}
+
+	stored  atomic.Int64
+	dropped atomic.Int64
+	held    atomic.Int64 // Nanoseconds add spent waiting for room
+}
+
+func newResultWriter(store Store, jobID string) *resultWriter {
+	w := &resultWriter{
+		store: store,
+		jobID: jobID,
+		queue: make(chan PageResult, storeQueueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// run writes a batch whenever storeBatchSize results are waiting, and
+// every storeFlushInterval, until closed
+func (w *resultWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(storeFlushInterval)
+	defer ticker.Stop()
+	batch := make([]PageResult, 0, storeBatchSize)
+	for {
+		select {
+		case page := <-w.queue:
+			batch = append(batch, page)
+			if len(batch) >= storeBatchSize {
+				batch = w.write(batch)
+			}
+		case <-ticker.C:
+			batch = w.write(batch)
+		case <-w.stop:
+			for {
+				select {
+				case page := <-w.queue:
+					batch = append(batch, page)
+					if len(batch) >= storeBatchSize {
+						batch = w.write(batch)
+					}
+				default:
+					w.write(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// add queues a result, waiting while the queue is full
+func (w *resultWriter) add(page PageResult) {
+	select {
+	case w.queue <- page:
+		return
+	default:
+	}
+	start := time.Now()
+	select {
+	case w.queue <- page:
+	case <-w.stop:
+	}
+	w.held.Add(int64(time.Since(start)))
+}
+
+// write stores a batch in one call, retrying with backoff, and returns the
+// batch emptied for reuse
+func (w *resultWriter) write(pages []PageResult) []PageResult {
+	if len(pages) == 0 {
+		return pages
+	}
+	var err error
+	for attempt := 1; attempt <= storeAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		err = w.store.AddResults(ctx, w.jobID, pages)
+		cancel()
+		if err == nil {
+			w.stored.Add(int64(len(pages)))
+			return pages[:0]
+		}
+		if attempt < storeAttempts {
+			debugf("Error storing %d results of job %s, retrying: %v", len(pages), w.jobID, err)
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+	warnf("Error storing %d results of job %s, dropping them: %v", len(pages), w.jobID, err)
+	w.dropped.Add(int64(len(pages)))
+	return pages[:0]
+}
+
+// close writes the remaining results and stops the writer. Results added
+// after it is called may be lost.
+func (w *resultWriter) close() {
+	close(w.stop)
+	<-w.done
+	if held := time.Duration(w.held.Load()); held >= time.Second {
+		infof("Job %s was held back %s waiting for the store", w.jobID, held.Round(time.Second))
+	}
+	if dropped := w.dropped.Load(); dropped > 0 {
+		warnf("Job %s: stored %d results, dropped %d", w.jobID, w.stored.Load(), dropped)
+	}
+}
+
+// saveJob stores a snapshot of a job, logging failures
+func saveJob(store Store, info JobInfo) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := store.SaveJob(ctx, info); err != nil {
+		warnf("Error storing job %s: %v", info.ID, err)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// templateName is what template names may look like, so that they can be
+// used in URLs as they are
+var templateName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Template is a named crawl configuration saved on the server. Crawl
+// requests that name it start from its settings and override any of them.
+// Every user can launch a template; only its owner or an admin may replace
+// or delete it.
+type Template struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Owner       string       `json:"owner,omitempty"`
+	Request     CrawlRequest `json:"request"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
+}
+
+// TemplateStore holds the server's templates in memory by name
+type TemplateStore struct {
+	mu        sync.Mutex
+	templates map[string]Template
+}
+
+func NewTemplateStore() *TemplateStore {
+	return &TemplateStore{templates: make(map[string]Template)}
+}
+
+// Get returns the template with the given name
+func (s *TemplateStore) Get(name string) (Template, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.templates[name]
+	return t, ok
+}
+
+// Put saves a template, replacing any template of the same name, and
+// reports whether it replaced one
+func (s *TemplateStore) Put(t Template) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, replaced := s.templates[t.Name]
+	s.templates[t.Name] = t
+	return replaced
+}
+
+// Delete removes the template with the given name
+func (s *TemplateStore) Delete(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.templates, name)
+}
+
+// List returns every template, by name
+func (s *TemplateStore) List() []Template {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	templates := []Template{}
+	for _, t := range s.templates {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates
+}
+
+// decodeCrawlRequest reads a crawl request. If it names a template, the
+// template's request is the starting point and every field the body sets
+// overrides the template's value for it; objects such as hostPolicy are
+// replaced as a whole.
+func (s *APIServer) decodeCrawlRequest(body io.Reader) (CrawlRequest, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return CrawlRequest{}, fmt.Errorf("Invalid request body")
+	}
+	var req CrawlRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return CrawlRequest{}, fmt.Errorf("Invalid request body")
+	}
+	if req.Template == "" {
+		return req, nil
+	}
+
+	t, ok := s.templates.Get(req.Template)
+	if !ok {
+		return CrawlRequest{}, fmt.Errorf("Template %q not found", req.Template)
+	}
+	base, err := json.Marshal(t.Request)
+	if err != nil {
+		return CrawlRequest{}, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(base, &fields); err != nil {
+		return CrawlRequest{}, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return CrawlRequest{}, fmt.Errorf("Invalid request body")
+	}
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return CrawlRequest{}, err
+	}
+	req = CrawlRequest{}
+	if err := json.Unmarshal(merged, &req); err != nil {
+		return CrawlRequest{}, fmt.Errorf("Invalid request body")
+	}
+	return req, nil
+}
+
+// checkTemplate validates a template's request as far as it can without a
+// URL, which launches may supply instead
+func (s *APIServer) checkTemplate(t Template) error {
+	if !templateName.MatchString(t.Name) {
+		return fmt.Errorf("invalid template name %q: use up to 64 letters, digits, '.', '_' and '-'", t.Name)
+	}
+	req := t.Request
+	if req.Template != "" {
+		return fmt.Errorf("a template can't be based on another template")
+	}
+	if req.RefreshOf != "" {
+		return fmt.Errorf("a template can't refresh a particular crawl")
+	}
+	if req.URL != "" {
+		if err := s.checkSeedURL(req.URL); err != nil {
+			return err
+		}
+	}
+	if _, err := ParsePriority(req.Priority); err != nil {
+		return err
+	}
+	return s.applyDefaults(&req)
+}
+
+// handleSaveTemplate saves a template, replacing one of the same name that
+// the user may change
+func (s *APIServer) handleSaveTemplate(w http.ResponseWriter, r *http.Request) {
+	var t Template
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	t.Name = strings.TrimSpace(t.Name)
+	if err := s.checkTemplate(t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := userFromContext(r.Context())
+	if existing, ok := s.templates.Get(t.Name); ok && !canChangeTemplate(user, existing) {
+		http.Error(w, fmt.Sprintf("Template %q belongs to %s", t.Name, existing.Owner), http.StatusForbidden)
+		return
+	}
+	t.Owner = user.Name
+	t.UpdatedAt = time.Now()
+
+	status := http.StatusCreated
+	if s.templates.Put(t) {
+		status = http.StatusOK
+	}
+	infof("User %q saved template %q", user.Name, t.Name)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(t)
+}
+
+// canChangeTemplate reports whether user may replace or delete t
+func canChangeTemplate(user *User, t Template) bool {
+	return user.Admin || user.Name == t.Owner
+}
+
+// handleListTemplates lists every template
+func (s *APIServer) handleListTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.templates.List())
+}
+
+// handleGetTemplate returns a template
+func (s *APIServer) handleGetTemplate(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.templates.Get(mux.Vars(r)["name"])
+	if !ok {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// handleDeleteTemplate removes a template; crawls and schedules created
+// from it are unaffected
+func (s *APIServer) handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.templates.Get(mux.Vars(r)["name"])
+	if !ok {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	if !canChangeTemplate(userFromContext(r.Context()), t) {
+		http.Error(w, fmt.Sprintf("Template %q belongs to %s", t.Name, t.Owner), http.StatusForbidden)
+		return
+	}
+	s.templates.Delete(t.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+
+	"go-crawler/internal/crawler"
+)
+
+// promptCredentials asks for a user name and password on the terminal,
+// without echoing the password
+func promptCredentials(challenge *crawler.AuthChallenge) (string, string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", "", fmt.Errorf("not a terminal; pass -auth-user and -auth-pass")
+	}
+	if challenge.Realm != "" {
+		fmt.Fprintf(os.Stderr, "Authentication required (%s)\n", challenge.Realm)
+	} else {
+		fmt.Fprintln(os.Stderr, "Authentication required")
+	}
+
+	fmt.Fprint(os.Stderr, "User: ")
+	user, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", "", fmt.Errorf("error reading user name: %v", err)
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", "", fmt.Errorf("error reading password: %v", err)
+	}
+	return strings.TrimSpace(user), string(password), nil
+}
+
+// authenticate probes the start URL and, if it asks for basic auth,
+// returns options with credentials from the flags or the terminal. It fails
+// if the server rejects them.
+func authenticate(ctx context.Context, newCrawler func(...crawler.Option) *crawler.Crawler, startURL, user, password string) ([]crawler.Option, error) {
+	var opts []crawler.Option
+	if user != "" || password != "" {
+		opts = append(opts, crawler.WithBasicAuth(user, password))
+	}
+
+	challenge, err := newCrawler(opts...).ProbeAuth(ctx, startURL)
+	if err != nil || challenge == nil {
+		// Fetch errors are reported by the crawl itself
+		return opts, nil
+	}
+	if !strings.EqualFold(challenge.Scheme, "basic") {
+		return nil, fmt.Errorf("%s requires %s authentication, which is not supported", startURL, challenge.Scheme)
+	}
+	if len(opts) > 0 {
+		return nil, fmt.Errorf("%s rejected the credentials", startURL)
+	}
+
+	if user, password, err = promptCredentials(challenge); err != nil {
+		return nil, err
+	}
+	opts = append(opts, crawler.WithBasicAuth(user, password))
+	if challenge, err = newCrawler(opts...).ProbeAuth(ctx, startURL); err == nil && challenge != nil {
+		return nil, fmt.Errorf("%s rejected the credentials", startURL)
+	}
+	return opts, nil
+}
+
+// loadJourney reads the -journey file at path. Form values and headers may
+// refer to environment variables as $NAME or ${NAME}, to keep passwords
+// out of the file.
+func loadJourney(path string) (*crawler.Journey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening journey: %v", err)
+	}
+	defer f.Close()
+	journey, err := crawler.ParseJourney(f)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range journey.Steps {
+		for name, value := range step.Form {
+			step.Form[name] = os.ExpandEnv(value)
+		}
+		for name, value := range step.Headers {
+			step.Headers[name] = os.ExpandEnv(value)
+		}
+	}
+	return journey, nil
+}
+
+// protectedCollector groups the pages that answered 401 Unauthorized by
+// realm and directory
+type protectedCollector map[string]map[string]int
+
+func (p protectedCollector) record(result crawler.CrawlResult) {
+	if result.Challenge == nil {
+		return
+	}
+	dir := result.URL
+	if u, err := url.Parse(result.URL); err == nil {
+		u.Path, u.RawQuery, u.Fragment = path.Dir(u.Path), "", ""
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		dir = u.String()
+	}
+	realm := result.Challenge.Realm
+	if p[realm] == nil {
+		p[realm] = make(map[string]int)
+	}
+	p[realm][dir]++
+}
+
+// printProtected lists the sections that required authentication
+func (p protectedCollector) printProtected(w io.Writer) {
+	if len(p) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nProtected sections (401 Unauthorized):")
+	realms := make([]string, 0, len(p))
+	for realm := range p {
+		realms = append(realms, realm)
+	}
+	sort.Strings(realms)
+	for _, realm := range realms {
+		if realm == "" {
+			fmt.Fprintln(w, "  no realm")
+		} else {
+			fmt.Fprintf(w, "  realm %q\n", realm)
+		}
+		dirs := make([]string, 0, len(p[realm]))
+		for dir := range p[realm] {
+			dirs = append(dirs, dir)
+		}
+		sort.Strings(dirs)
+		for _, dir := range dirs {
+			fmt.Fprintf(w, "    %s (%d pages)\n", dir, p[realm][dir])
+		}
+	}
+}
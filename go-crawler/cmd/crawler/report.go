@@ -0,0 +1,675 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-crawler/internal/crawler"
+)
+
+// maxSkipSamples bounds how many skipped URLs the report lists; counts are
+// always exact
+const maxSkipSamples = 10000
+
+// skipCollector counts the URLs the crawler skips by reason and keeps a
+// bounded sample of them, each URL listed once per reason
+type skipCollector struct {
+	mu        sync.Mutex
+	counts    map[crawler.SkipReason]int
+	samples   []crawler.SkippedURL
+	seen      map[string]struct{} // reason + url, to list each pair once
+	truncated bool
+}
+
+func newSkipCollector() *skipCollector {
+	return &skipCollector{
+		counts: make(map[crawler.SkipReason]int),
+		seen:   make(map[string]struct{}),
+	}
+}
+
+func (s *skipCollector) record(skip crawler.SkippedURL) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counts[skip.Reason]++
+	// Duplicates are only counted, since they were crawled anyway
+	if skip.Reason == crawler.SkipDuplicate {
+		return
+	}
+	key := string(skip.Reason) + " " + skip.URL
+	if _, ok := s.seen[key]; ok {
+		return
+	}
+	if len(s.samples) >= maxSkipSamples {
+		s.truncated = true
+		return
+	}
+	s.seen[key] = struct{}{}
+	s.samples = append(s.samples, skip)
+}
+
+// eventLog writes the crawler's events to a file as newline-delimited
+// JSON. Writes aren't buffered, so nothing is lost on os.Exit.
+type eventLog struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+func openEventLog(path string) (*eventLog, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLog{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (l *eventLog) record(e crawler.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.enc.Encode(e); err != nil {
+		log.Printf("Error writing event: %v", err)
+	}
+}
+
+func (l *eventLog) Close() error {
+	return l.f.Close()
+}
+
+// robotsCollector gathers the crawler's robots.txt decisions
+type robotsCollector struct {
+	mu        sync.Mutex
+	decisions []crawler.RobotsDecision
+}
+
+func (r *robotsCollector) record(d crawler.RobotsDecision) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decisions = append(r.decisions, d)
+}
+
+// writeCSV saves the decisions as a compliance log
+func (r *robotsCollector) writeCSV(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := crawler.WriteComplianceCSV(f, r.decisions); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeAuditCSV saves the crawled pages as an SEO audit spreadsheet
+func writeAuditCSV(path string, pages []crawler.AuditPage) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := crawler.WriteAuditCSV(f, pages); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeJUnitReport saves the link check as a JUnit XML report
+func writeJUnitReport(path string, checks []crawler.LinkCheck, assets []crawler.BrokenAsset, alternates []crawler.BrokenAlternate) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := crawler.WriteJUnitReport(f, checks, assets, alternates); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeMetadataCSV saves the duplicate and missing titles and meta
+// descriptions
+func writeMetadataCSV(path string, report crawler.MetadataReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := crawler.WriteMetadataCSV(f, report); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeSitemaps saves the entries as a sitemap, plain text if path ends in
+// .txt. When they don't fit in one file they are written to numbered files
+// beside path, e.g. sitemap-1.xml, listed by a sitemap index at path (at
+// the .xml path for text sitemaps) with their URLs under base, by default
+// the start URL's site root.
+func writeSitemaps(path, base, startURL string, entries []crawler.SitemapEntry) error {
+	format := crawler.SitemapXML
+	if strings.EqualFold(filepath.Ext(path), ".txt") {
+		format = crawler.SitemapText
+	}
+	files := crawler.SplitSitemap(entries, format)
+	if len(files) == 1 {
+		return writeSitemap(path, files[0], format)
+	}
+
+	if base == "" {
+		u, err := url.Parse(startURL)
+		if err != nil {
+			return err
+		}
+		base = u.Scheme + "://" + u.Host + "/"
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+	locs := make([]string, len(files))
+	for i, file := range files {
+		name := fmt.Sprintf("%s-%d.%s", stem, i+1, format)
+		if err := writeSitemap(name, file, format); err != nil {
+			return err
+		}
+		locs[i] = base + filepath.Base(name)
+	}
+
+	index := stem + ".xml"
+	f, err := os.Create(index)
+	if err != nil {
+		return err
+	}
+	if err := crawler.WriteSitemapIndex(f, locs); err != nil {
+		f.Close()
+		return err
+	}
+	fmt.Printf("Wrote %d URLs to %d sitemaps listed in %s\n", len(entries), len(files), index)
+	return f.Close()
+}
+
+// writeSitemap saves one sitemap file
+func writeSitemap(path string, entries []crawler.SitemapEntry, format crawler.SitemapFormat) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := crawler.WriteSitemap(f, entries, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sortedKeys returns a map's keys in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printSkipReport writes skip counts by reason followed by the sampled
+// skipped URLs, except duplicates, which are only counted
+func (s *skipCollector) printSkipReport(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byReason := make(map[crawler.SkipReason][]crawler.SkippedURL)
+	for _, skip := range s.samples {
+		byReason[skip.Reason] = append(byReason[skip.Reason], skip)
+	}
+
+	reasons := make([]string, 0, len(s.counts))
+	for reason := range s.counts {
+		reasons = append(reasons, string(reason))
+	}
+	sort.Strings(reasons)
+
+	fmt.Fprintln(w, "\nSkipped URLs:")
+	if len(reasons) == 0 {
+		fmt.Fprintln(w, "  none")
+		return
+	}
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "  %-10s %d\n", reason, s.counts[crawler.SkipReason(reason)])
+	}
+
+	for _, reason := range reasons {
+		skips := byReason[crawler.SkipReason(reason)]
+		if len(skips) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n[%s]\n", reason)
+		for _, skip := range skips {
+			fmt.Fprintf(w, "  %s", skip.URL)
+			if skip.Detail != "" {
+				fmt.Fprintf(w, " (%s)", skip.Detail)
+			}
+			if skip.Source != "" {
+				fmt.Fprintf(w, "\n    found on %s", skip.Source)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	if s.truncated {
+		fmt.Fprintf(w, "\nOnly the first %d skipped URLs are listed\n", maxSkipSamples)
+	}
+}
+
+// printTraps lists the crawl traps the crawler detected, if any
+func printTraps(w io.Writer, traps []crawler.Trap) {
+	if len(traps) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nCrawl traps detected and blocked:")
+	for _, t := range traps {
+		fmt.Fprintf(w, "  [%s] %s (%d URLs blocked)\n    e.g. %s\n", t.Kind, t.Pattern, t.Blocked, t.Example)
+	}
+}
+
+// printDryRun lists the sitemaps read and the URLs a crawl would fetch
+func printDryRun(w io.Writer, report *crawler.DryRunReport) {
+	fmt.Fprintf(w, "Sitemaps read: %d, URLs listed: %d\n", len(report.Sitemap.Files), len(report.Sitemap.URLs))
+	for _, e := range report.Sitemap.Errors {
+		fmt.Fprintf(w, "  error: %s\n", e)
+	}
+	if report.Sitemap.Truncated {
+		fmt.Fprintln(w, "  (sitemap limits reached, not all URLs were read)")
+	}
+	fmt.Fprintf(w, "\nWould crawl %d URLs:\n", len(report.URLs))
+	for _, u := range report.URLs {
+		fmt.Fprintf(w, "  %s\n", u)
+	}
+}
+
+// printEstimate shows the projected size and cost of the crawl
+func printEstimate(w io.Writer, est *crawler.Estimate) {
+	fmt.Fprintf(w, "Estimate: %d pages (%d URLs in sitemaps), %s, %v (limited by %s)\n",
+		est.Pages, est.SitemapURLs, formatBytes(est.Bytes), est.Duration.Round(time.Second), est.LimitedBy)
+	fmt.Fprintf(w, "  based on %s per page and %v latency\n\n", formatBytes(est.PageBytes), est.Latency.Round(time.Millisecond))
+}
+
+// printEstimateComparison compares the finished crawl with its estimate
+func printEstimateComparison(w io.Writer, est *crawler.Estimate, pages int, bytes int64, elapsed time.Duration) {
+	fmt.Fprintln(w, "\nActual vs estimate:")
+	fmt.Fprintf(w, "  pages:    %d / %d\n", pages, est.Pages)
+	fmt.Fprintf(w, "  bytes:    %s / %s\n", formatBytes(bytes), formatBytes(est.Bytes))
+	fmt.Fprintf(w, "  duration: %v / %v\n", elapsed.Round(time.Second), est.Duration.Round(time.Second))
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// printErrorClasses counts the pages that failed by kind of error
+func printErrorClasses(w io.Writer, counts map[crawler.ErrorClass]int) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nErrors by type:")
+	for _, class := range crawler.ErrorClasses {
+		if n := counts[class]; n > 0 {
+			fmt.Fprintf(w, "  %s: %d\n", class, n)
+		}
+	}
+	if n := counts[crawler.ClassChallenge]; n > 0 {
+		fmt.Fprintf(w, "%d pages answered with a bot challenge or CAPTCHA: the site blocked the crawler, so they are not site errors\n", n)
+	}
+}
+
+// printMetadata summarises duplicate and missing titles and meta
+// descriptions and lists the pages of each group
+func printMetadata(w io.Writer, report crawler.MetadataReport) {
+	fmt.Fprintf(w, "\nTitles and meta descriptions of %d pages:\n", report.Pages)
+	fmt.Fprintf(w, "  Duplicate titles: %d pages\n", report.DuplicateTitles)
+	fmt.Fprintf(w, "  Missing titles: %d pages\n", report.MissingTitles)
+	fmt.Fprintf(w, "  Duplicate descriptions: %d pages\n", report.DuplicateDescriptions)
+	fmt.Fprintf(w, "  Missing descriptions: %d pages\n", report.MissingDescriptions)
+	for _, g := range report.Groups {
+		if g.Issue == crawler.MetadataDuplicate {
+			fmt.Fprintf(w, "\n  [duplicate %s] %q (%d pages)\n", g.Field, g.Value, len(g.URLs))
+		} else {
+			fmt.Fprintf(w, "\n  [missing %s] (%d pages)\n", g.Field, len(g.URLs))
+		}
+		for _, u := range g.URLs {
+			fmt.Fprintf(w, "    %s\n", u)
+		}
+	}
+}
+
+// printAccessibility counts the accessibility issues of each check and
+// lists the n pages with the most
+func printAccessibility(w io.Writer, report crawler.AccessibilityReport, n int) {
+	fmt.Fprintf(w, "\nAccessibility of %d pages:\n", report.Pages)
+	for _, c := range report.Checks {
+		fmt.Fprintf(w, "  %s: %d issues on %d pages\n", c.Check, c.Issues, c.Pages)
+	}
+	if len(report.Failed) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "  Most issues:")
+	for _, p := range report.Failed[:min(n, len(report.Failed))] {
+		fmt.Fprintf(w, "  %5d  %s\n", len(p.Issues), p.URL)
+	}
+}
+
+// htmlWarningCollector counts markup warnings by check, and the pages
+// with each
+type htmlWarningCollector struct {
+	warnings, pages map[crawler.HTMLCheck]int
+}
+
+func (c *htmlWarningCollector) record(result crawler.CrawlResult) {
+	if c.warnings == nil {
+		c.warnings, c.pages = map[crawler.HTMLCheck]int{}, map[crawler.HTMLCheck]int{}
+	}
+	seen := map[crawler.HTMLCheck]bool{}
+	for _, w := range result.HTMLWarnings {
+		c.warnings[w.Check]++
+		if !seen[w.Check] {
+			seen[w.Check] = true
+			c.pages[w.Check]++
+		}
+	}
+}
+
+// printHTMLWarnings prints the number of markup warnings of each check
+func (c *htmlWarningCollector) printHTMLWarnings(w io.Writer) {
+	fmt.Fprintln(w, "\nHTML warnings:")
+	for _, check := range crawler.HTMLChecks {
+		fmt.Fprintf(w, "  %s: %d on %d pages\n", check, c.warnings[check], c.pages[check])
+	}
+}
+
+// findingCollector counts the findings of text processors by processor
+// and rule
+type findingCollector struct {
+	findings, pages int
+	rules           map[string]*ruleFindings
+}
+
+// ruleFindings counts the findings of one rule and the pages they're on
+type ruleFindings struct {
+	rule            string // Processor and rule
+	findings, pages int
+}
+
+func (c *findingCollector) record(result crawler.CrawlResult) {
+	if len(result.Findings) == 0 {
+		return
+	}
+	if c.rules == nil {
+		c.rules = make(map[string]*ruleFindings)
+	}
+	c.findings += len(result.Findings)
+	c.pages++
+	seen := make(map[string]bool)
+	for _, f := range result.Findings {
+		key := f.Processor + ": " + f.Rule
+		r := c.rules[key]
+		if r == nil {
+			r = &ruleFindings{rule: key}
+			c.rules[key] = r
+		}
+		r.findings++
+		if !seen[key] {
+			seen[key] = true
+			r.pages++
+		}
+	}
+}
+
+// printFindings summarises the findings of each rule, most found first
+func (c *findingCollector) printFindings(w io.Writer) {
+	fmt.Fprintf(w, "\nContent scan: %d findings on %d pages\n", c.findings, c.pages)
+	rules := make([]*ruleFindings, 0, len(c.rules))
+	for _, r := range c.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].findings != rules[j].findings {
+			return rules[i].findings > rules[j].findings
+		}
+		return rules[i].rule < rules[j].rule
+	})
+	for _, r := range rules {
+		fmt.Fprintf(w, "  %5d  %s (%d pages)\n", r.findings, r.rule, r.pages)
+	}
+}
+
+// printThinPages lists the pages with thin content out of the pages whose
+// words were counted
+func printThinPages(w io.Writer, thin []crawler.PageWords, counted, minWords int) {
+	fmt.Fprintf(w, "\nThin content (under %d words): %d of %d pages\n", minWords, len(thin), counted)
+	for _, p := range thin {
+		fmt.Fprintf(w, "  %5d  %s\n", p.Words, p.URL)
+	}
+}
+
+// printThrottling lists the hosts that asked the crawler to slow down and
+// the time lost waiting for them
+func printThrottling(w io.Writer, hosts []crawler.HostThrottle) {
+	if len(hosts) == 0 {
+		return
+	}
+	var total time.Duration
+	fmt.Fprintln(w, "\nThrottled by servers (429/503):")
+	for _, h := range hosts {
+		fmt.Fprintf(w, "  %s: %d responses, paused %v\n", h.Host, h.Responses, h.Paused.Round(time.Millisecond))
+		total += h.Paused
+	}
+	fmt.Fprintf(w, "  Time lost to throttling: %v\n", total.Round(time.Millisecond))
+}
+
+// printBloomStats reports the size of a -bloom-visited filter and how many
+// URLs it likely skipped by mistake
+func printBloomStats(w io.Writer, s crawler.BloomStats) {
+	fmt.Fprintln(w, "\nVisited set (Bloom filter):")
+	fmt.Fprintf(w, "  %d URLs of %d expected in %d bytes, %d hashes, %.1f%% full\n", s.Items, s.Expected, s.Bytes, s.Hashes, 100*s.Fill)
+	fmt.Fprintf(w, "  False-positive rate now: %.4f%%\n", 100*s.FalsePositiveRate)
+	fmt.Fprintf(w, "  Estimated URLs missed as false positives: %.1f\n", s.EstimatedMisses)
+	if s.Items > s.Expected {
+		fmt.Fprintln(w, "  More URLs than expected: raise -bloom-visited to keep the rate down")
+	}
+}
+
+// printBrokenAssets lists the scripts, stylesheets and images that failed
+// to load with the pages referencing them
+func printBrokenAssets(w io.Writer, assets []crawler.BrokenAsset) {
+	fmt.Fprintln(w, "\nBroken assets:")
+	if len(assets) == 0 {
+		fmt.Fprintln(w, "  none")
+		return
+	}
+	for _, a := range assets {
+		problem := a.Error
+		if problem == "" {
+			problem = fmt.Sprintf("status %d", a.StatusCode)
+		}
+		fmt.Fprintf(w, "  [%s] %s (%s)\n", a.Kind, a.URL, problem)
+		for _, page := range a.Pages {
+			fmt.Fprintf(w, "    used on %s\n", page)
+		}
+	}
+}
+
+// printBrokenExternalLinks lists the links to other sites that failed with
+// the pages linking to them
+func printBrokenExternalLinks(w io.Writer, links []crawler.ExternalLink) {
+	fmt.Fprintln(w, "\nBroken external links:")
+	if len(links) == 0 {
+		fmt.Fprintln(w, "  none")
+		return
+	}
+	for _, l := range links {
+		problem := l.Error
+		if problem == "" {
+			problem = fmt.Sprintf("status %d", l.StatusCode)
+		}
+		fmt.Fprintf(w, "  %s (%s)\n", l.URL, problem)
+		for _, page := range l.Pages {
+			fmt.Fprintf(w, "    linked from %s\n", page)
+		}
+	}
+}
+
+// printBrokenAlternates lists the AMP and mobile versions that are missing
+// or broken with the pages advertising them
+func printBrokenAlternates(w io.Writer, alternates []crawler.BrokenAlternate) {
+	fmt.Fprintln(w, "\nBroken AMP and mobile versions:")
+	if len(alternates) == 0 {
+		fmt.Fprintln(w, "  none")
+		return
+	}
+	for _, a := range alternates {
+		fmt.Fprintf(w, "  [%s] %s (%s)\n", a.Kind, a.URL, a.Problem)
+		for _, page := range a.Pages {
+			fmt.Fprintf(w, "    advertised by %s\n", page)
+		}
+	}
+}
+
+// printGraphStats lists the most important and the hardest to reach pages
+// of the crawled link graph
+func printGraphStats(w io.Writer, stats crawler.GraphStats) {
+	fmt.Fprintf(w, "\nLink graph: %d pages, %d internal links\n", stats.Pages, stats.Links)
+	sections := []struct {
+		title string
+		pages []crawler.PageStat
+	}{
+		{"Top pages by PageRank", stats.TopPages},
+		{"Most linked pages", stats.MostLinked},
+		{"Orphan-ish pages (at most one internal link in)", stats.Orphans},
+	}
+	for _, s := range sections {
+		fmt.Fprintf(w, "\n%s:\n", s.title)
+		if len(s.pages) == 0 {
+			fmt.Fprintln(w, "  none")
+		}
+		for _, p := range s.pages {
+			fmt.Fprintf(w, "  %.4f  in %-4d out %-4d %s\n", p.PageRank, p.InDegree, p.OutDegree, p.URL)
+		}
+	}
+}
+
+func printPerformance(w io.Writer, s crawler.PerformanceSummary) {
+	fmt.Fprintf(w, "\nPage weight: %d pages, %s, median TTFB %v, 95th percentile %v\n",
+		s.Pages, formatBytes(s.TotalBytes), s.MedianTTFB.Round(time.Millisecond), s.P95TTFB.Round(time.Millisecond))
+	sections := []struct {
+		title string
+		pages []crawler.PagePerformance
+	}{
+		{"Heaviest pages", s.Heaviest},
+		{"Slowest pages", s.Slowest},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(w, "\n%s:\n", section.title)
+		if len(section.pages) == 0 {
+			fmt.Fprintln(w, "  none")
+		}
+		for _, p := range section.pages {
+			fmt.Fprintf(w, "  %8s  TTFB %-7v total %-7v %3d resources  %s\n", formatBytes(p.Bytes),
+				p.TTFB.Round(time.Millisecond), p.Download.Round(time.Millisecond), p.Resources(), p.URL)
+		}
+	}
+}
+
+// openHTTPDebug creates the -debug-http file and a recorder for it. Text
+// logs are written as requests complete; a HAR archive is written by the
+// returned close function.
+func openHTTPDebug(path string, sample float64, bodies bool) (*crawler.HTTPRecorder, func(), error) {
+	if sample <= 0 || sample > 1 {
+		return nil, nil, fmt.Errorf("-debug-sample must be more than 0 and at most 1")
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".har") {
+		recorder := crawler.NewHTTPRecorder(sample, bodies, f)
+		return recorder, func() { f.Close() }, nil
+	}
+
+	recorder := crawler.NewHTTPRecorder(sample, bodies, nil)
+	return recorder, harWriter(recorder, f, path), nil
+}
+
+// openRecording creates the -record file and a recorder of whole exchanges,
+// returning the function that writes the recording
+func openRecording(path string) (*crawler.HTTPRecorder, func(), error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	recorder := crawler.NewCrawlRecorder()
+	return recorder, harWriter(recorder, f, path), nil
+}
+
+// harWriter returns a function that writes the recorder's exchanges to f
+// as a HAR and closes it, once
+func harWriter(recorder *crawler.HTTPRecorder, f *os.File, path string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			err := recorder.WriteHAR(f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				log.Printf("Error writing %s: %v", path, err)
+				return
+			}
+			log.Printf("Recorded %d requests to %s", recorder.Len(), path)
+		})
+	}
+}
+
+// loadReplay reads the -replay recording at path
+func loadReplay(path string) (*crawler.Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return crawler.LoadReplay(f)
+}
+
+// reportReplayMisses logs the requests a -replay crawl made that weren't
+// recorded, which is where it diverged from the recorded crawl
+func reportReplayMisses(replay *crawler.Replay) {
+	missing := replay.Missing()
+	if len(missing) == 0 {
+		return
+	}
+	log.Printf("%d requests were not in the recording:", len(missing))
+	for _, m := range missing {
+		log.Printf("  %s", m)
+	}
+}
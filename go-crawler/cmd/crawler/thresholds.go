@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// exitThresholds is the exit status when a -fail-on threshold is exceeded,
+// distinct from 1 for errors that stop the crawl
+const exitThresholds = 3
+
+// crawlMetrics are the values -fail-on thresholds are checked against
+var crawlMetrics = map[string]struct {
+	percent bool
+	value   func(crawlStats) float64
+}{
+	"broken-links":      {false, func(s crawlStats) float64 { return float64(s.errors) }},
+	"error-rate":        {true, func(s crawlStats) float64 { return s.errorRate() }},
+	"broken-assets":     {false, func(s crawlStats) float64 { return float64(s.brokenAssets) }},
+	"broken-alternates": {false, func(s crawlStats) float64 { return float64(s.brokenAlternates) }},
+	"broken-external":   {false, func(s crawlStats) float64 { return float64(s.brokenExternal) }},
+}
+
+// crawlStats summarizes a finished crawl
+type crawlStats struct {
+	pages            int
+	errors           int
+	brokenAssets     int
+	brokenAlternates int
+	brokenExternal   int
+}
+
+// errorRate is the percentage of fetched pages that failed
+func (s crawlStats) errorRate() float64 {
+	if s.pages == 0 {
+		return 0
+	}
+	return 100 * float64(s.errors) / float64(s.pages)
+}
+
+// threshold is one -fail-on condition such as broken-links>0
+type threshold struct {
+	expr   string
+	metric string
+	op     string // ">" or ">="
+	limit  float64
+}
+
+// exceeded reports whether the crawl breaks the threshold, and the value
+// it was checked with
+func (t threshold) exceeded(s crawlStats) (bool, float64) {
+	v := crawlMetrics[t.metric].value(s)
+	if t.op == ">=" {
+		return v >= t.limit, v
+	}
+	return v > t.limit, v
+}
+
+// thresholdList is the repeatable -fail-on flag
+type thresholdList []threshold
+
+func (l *thresholdList) String() string {
+	exprs := make([]string, len(*l))
+	for i, t := range *l {
+		exprs[i] = t.expr
+	}
+	return strings.Join(exprs, ", ")
+}
+
+func (l *thresholdList) Set(v string) error {
+	t, err := parseThreshold(v)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, t)
+	return nil
+}
+
+// parseThreshold parses metric>limit or metric>=limit. Rates take a
+// percentage, with or without the % sign.
+func parseThreshold(expr string) (threshold, error) {
+	s := strings.ReplaceAll(expr, " ", "")
+	i := strings.Index(s, ">")
+	if i <= 0 {
+		return threshold{}, fmt.Errorf("invalid threshold %q: expected metric>limit, e.g. broken-links>0", expr)
+	}
+	t := threshold{expr: s, metric: s[:i], op: ">"}
+	rest := s[i+1:]
+	if strings.HasPrefix(rest, "=") {
+		t.op, rest = ">=", rest[1:]
+	}
+
+	metric, ok := crawlMetrics[t.metric]
+	if !ok {
+		return threshold{}, fmt.Errorf("invalid threshold %q: unknown metric %q (want %s)", expr, t.metric, strings.Join(metricNames(), ", "))
+	}
+	if strings.HasSuffix(rest, "%") {
+		if !metric.percent {
+			return threshold{}, fmt.Errorf("invalid threshold %q: %s is a count, not a percentage", expr, t.metric)
+		}
+		rest = strings.TrimSuffix(rest, "%")
+	}
+	limit, err := strconv.ParseFloat(rest, 64)
+	if err != nil || limit < 0 {
+		return threshold{}, fmt.Errorf("invalid threshold %q: limit must be a non-negative number", expr)
+	}
+	t.limit = limit
+	return t, nil
+}
+
+func metricNames() []string {
+	names := make([]string, 0, len(crawlMetrics))
+	for name := range crawlMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkThresholds prints every exceeded threshold and reports whether any
+// were
+func checkThresholds(w io.Writer, thresholds []threshold, s crawlStats) bool {
+	failed := false
+	for _, t := range thresholds {
+		exceeded, v := t.exceeded(s)
+		if !exceeded {
+			continue
+		}
+		if !failed {
+			fmt.Fprintln(w, "\nFailure thresholds exceeded:")
+			failed = true
+		}
+		value := strconv.FormatFloat(v, 'f', -1, 64)
+		if crawlMetrics[t.metric].percent {
+			value = fmt.Sprintf("%.1f%%", v)
+		}
+		fmt.Fprintf(w, "  %s (%s was %s)\n", t.expr, t.metric, value)
+	}
+	return failed
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go-crawler/internal/crawler"
+)
+
+// verifySummary counts the outcomes of a -verify run
+type verifySummary struct {
+	checked    int
+	ok         int
+	redirected int
+	broken     int
+}
+
+// openURLList opens a -verify list or -verify-redirects map, with "-"
+// meaning stdin
+func openURLList(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// readURLList sends the URLs of a list with one per line, skipping blank
+// lines and # comments, and closes urls at the end
+func readURLList(ctx context.Context, r io.Reader, urls chan<- string) error {
+	defer close(urls)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		select {
+		case urls <- line:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+// runVerify checks every URL in the list at path and writes one CSV row per
+// URL to w as they complete, so even very long lists use little memory
+func runVerify(ctx context.Context, c *crawler.Crawler, path string, w io.Writer) (verifySummary, error) {
+	var summary verifySummary
+	f, err := openURLList(path)
+	if err != nil {
+		return summary, err
+	}
+	defer f.Close()
+
+	urls := make(chan string, 1000)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readURLList(ctx, f, urls)
+	}()
+
+	cw := csv.NewWriter(w)
+	cw.Write(crawler.URLStatusHeader)
+	for status := range c.VerifyURLs(ctx, urls) {
+		cw.Write(status.Record())
+		summary.checked++
+		switch {
+		case status.Broken():
+			summary.broken++
+		case status.Redirected():
+			summary.redirected++
+		default:
+			summary.ok++
+		}
+		if summary.checked%100 == 0 {
+			cw.Flush()
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return summary, err
+	}
+	if err := <-readErr; err != nil && ctx.Err() == nil {
+		return summary, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return summary, ctx.Err()
+}
+
+func printVerifySummary(w io.Writer, s verifySummary) {
+	fmt.Fprintf(w, "\nVerified %d URLs: %d OK, %d redirected, %d broken\n", s.checked, s.ok, s.redirected, s.broken)
+}
+
+// loadRedirectMap reads the -verify-redirects map at path
+func loadRedirectMap(path string) ([]crawler.RedirectRule, error) {
+	f, err := openURLList(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return crawler.ParseRedirectMap(f)
+}
+
+// runVerifyRedirects checks a redirect map and writes a CSV row to w for
+// every rule that does not hold. It returns the number of rules checked and
+// the number that failed.
+func runVerifyRedirects(ctx context.Context, c *crawler.Crawler, rules []crawler.RedirectRule, w io.Writer) (int, int, error) {
+	checked, failed := 0, 0
+	cw := csv.NewWriter(w)
+	cw.Write(crawler.RedirectCheckHeader)
+	for check := range c.VerifyRedirects(ctx, rules) {
+		checked++
+		if check.Problem == "" {
+			continue
+		}
+		failed++
+		cw.Write(check.Record())
+		cw.Flush()
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return checked, failed, err
+	}
+	return checked, failed, ctx.Err()
+}
+
+// parseRegion parses a -region flag, name=proxy-url or name=direct
+func parseRegion(spec string) (string, *url.URL, error) {
+	name, proxy, ok := strings.Cut(spec, "=")
+	name, proxy = strings.TrimSpace(name), strings.TrimSpace(proxy)
+	if !ok || name == "" || proxy == "" {
+		return "", nil, fmt.Errorf("invalid region %q: want name=proxy-url or name=direct", spec)
+	}
+	if proxy == "direct" {
+		return name, nil, nil
+	}
+	u, err := crawler.ParseProxy(proxy)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid region %s: %v", name, err)
+	}
+	return name, u, nil
+}
+
+// compareSummary counts the outcomes of a -compare-regions run
+type compareSummary struct {
+	compared    int
+	differing   int
+	differences map[string]int
+}
+
+// runCompareRegions fetches every URL in the list at path in each region
+// and writes one CSV row per URL and region to w as they complete
+func runCompareRegions(ctx context.Context, regions []crawler.Region, path string, w io.Writer) (compareSummary, error) {
+	summary := compareSummary{differences: make(map[string]int)}
+	f, err := openURLList(path)
+	if err != nil {
+		return summary, err
+	}
+	defer f.Close()
+
+	urls := make(chan string, 1000)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readURLList(ctx, f, urls)
+	}()
+
+	cw := csv.NewWriter(w)
+	cw.Write(crawler.RegionComparisonHeader)
+	for comparison := range crawler.CompareRegions(ctx, regions, urls) {
+		cw.WriteAll(comparison.Records())
+		summary.compared++
+		if len(comparison.Differences) > 0 {
+			summary.differing++
+		}
+		for _, d := range comparison.Differences {
+			summary.differences[d]++
+		}
+	}
+	if err := cw.Error(); err != nil {
+		return summary, err
+	}
+	if err := <-readErr; err != nil && ctx.Err() == nil {
+		return summary, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return summary, ctx.Err()
+}
+
+func printCompareSummary(w io.Writer, s compareSummary, regions int) {
+	fmt.Fprintf(w, "\nCompared %d URLs in %d regions: %d differ", s.compared, regions, s.differing)
+	var kinds []string
+	for _, d := range []string{crawler.DiffStatus, crawler.DiffRedirect, crawler.DiffSize, crawler.DiffError} {
+		if s.differences[d] > 0 {
+			kinds = append(kinds, fmt.Sprintf("%d by %s", s.differences[d], d))
+		}
+	}
+	if len(kinds) > 0 {
+		fmt.Fprintf(w, " (%s)", strings.Join(kinds, ", "))
+	}
+	fmt.Fprintln(w)
+}
+
+// warmSummary counts the outcomes of a -warm run
+type warmSummary struct {
+	fetched int
+	failed  int
+	cached  int // Responses with a cache verdict
+	hits    int
+	bytes   int64
+}
+
+// runWarm fetches every URL in the list at path to prime caches and writes
+// one CSV row per URL to w as they complete
+func runWarm(ctx context.Context, c *crawler.Crawler, path string, w io.Writer) (warmSummary, error) {
+	var summary warmSummary
+	f, err := openURLList(path)
+	if err != nil {
+		return summary, err
+	}
+	defer f.Close()
+
+	urls := make(chan string, 1000)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readURLList(ctx, f, urls)
+	}()
+
+	cw := csv.NewWriter(w)
+	cw.Write(crawler.WarmStatusHeader)
+	for status := range c.WarmURLs(ctx, urls) {
+		cw.Write(status.Record())
+		summary.fetched++
+		summary.bytes += status.Bytes
+		if status.Failed() {
+			summary.failed++
+		}
+		if status.Cache != "" {
+			summary.cached++
+			if status.Hit() {
+				summary.hits++
+			}
+		}
+		if summary.fetched%100 == 0 {
+			cw.Flush()
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return summary, err
+	}
+	if err := <-readErr; err != nil && ctx.Err() == nil {
+		return summary, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return summary, ctx.Err()
+}
+
+func printWarmSummary(w io.Writer, s warmSummary, elapsed time.Duration) {
+	fmt.Fprintf(w, "\nWarmed %d URLs (%s) in %v: %d OK, %d failed\n", s.fetched, formatBytes(s.bytes), elapsed.Round(time.Second), s.fetched-s.failed, s.failed)
+	if s.cached > 0 {
+		fmt.Fprintf(w, "Cache hits: %d of %d responses with a cache status\n", s.hits, s.cached)
+	}
+}
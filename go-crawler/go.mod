@@ -5,5 +5,20 @@ go 1.21
 require (
 	github.com/gorilla/mux v1.8.1
 	github.com/gorilla/websocket v1.5.1
+	github.com/graphql-go/graphql v0.8.1
+	github.com/jackc/pgx/v5 v5.5.5
+	go.etcd.io/bbolt v1.3.8
+	go.starlark.net v0.0.0-20231121155337-90ade8b19d09
 	golang.org/x/net v0.17.0
+	golang.org/x/term v0.15.0
+)
+
+require (
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
+	github.com/jackc/puddle/v2 v2.2.1 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/sync v0.1.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 )
@@ -0,0 +1,256 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// AccessibilityCheck names a quick accessibility check
+type AccessibilityCheck string
+
+const (
+	A11yEmptyLink    AccessibilityCheck = "empty-link"    // A link with no text or accessible name
+	A11yMissingLabel AccessibilityCheck = "missing-label" // A form field without a label
+	A11yMissingLang  AccessibilityCheck = "missing-lang"  // No lang attribute on <html>
+	A11yHeadingOrder AccessibilityCheck = "heading-order" // A heading that skips a level
+)
+
+// AccessibilityChecks lists every check, in the order reports show them
+var AccessibilityChecks = []AccessibilityCheck{
+	A11yMissingLang, A11yEmptyLink, A11yMissingLabel, A11yHeadingOrder,
+}
+
+// maxIssuesPerPage bounds the accessibility issues reported for a page
+const maxIssuesPerPage = 100
+
+// AccessibilityIssue is a problem one of the checks found on a page
+type AccessibilityIssue struct {
+	Check   AccessibilityCheck `json:"check"`
+	Element string             `json:"element"` // The element's start tag
+	Message string             `json:"message"`
+}
+
+// WithAccessibilityChecks checks every HTML page for links without text,
+// form fields without labels, a missing lang attribute and headings that
+// skip levels, reporting the issues in each result's Accessibility. These
+// are quick checks of the markup, not an audit.
+func WithAccessibilityChecks() Option {
+	return func(c *Crawler) {
+		c.checkAccessibility = true
+	}
+}
+
+// checkAccessibility runs the accessibility checks over a document, in
+// document order
+func checkAccessibility(doc *html.Node) []AccessibilityIssue {
+	var issues []AccessibilityIssue
+	report := func(check AccessibilityCheck, n *html.Node, format string, args ...any) {
+		if len(issues) < maxIssuesPerPage {
+			issues = append(issues, AccessibilityIssue{Check: check, Element: startTag(n), Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	if root := findElement(doc, "html"); root != nil && strings.TrimSpace(attr(root, "lang")) == "" && strings.TrimSpace(attr(root, "xml:lang")) == "" {
+		report(A11yMissingLang, root, "The page doesn't declare its language")
+	}
+
+	labelled := make(map[string]bool) // IDs of fields a <label for> names
+	var labels func(*html.Node)
+	labels = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "label" && attr(n, "for") != "" {
+			labelled[attr(n, "for")] = true
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			labels(c)
+		}
+	}
+	labels(doc)
+
+	heading := 0 // Level of the last heading
+	var walk func(n *html.Node, inLabel bool)
+	walk = func(n *html.Node, inLabel bool) {
+		if n.Type == html.ElementNode {
+			if isHiddenFromReaders(n) {
+				return
+			}
+			switch n.Data {
+			case "a":
+				if _, ok := attrValue(n, "href"); ok && !hasAccessibleName(n) {
+					report(A11yEmptyLink, n, "The link has no text or accessible name")
+				}
+			case "input", "select", "textarea":
+				if needsLabel(n) && !inLabel && !labelled[attr(n, "id")] && !hasLabelAttr(n) {
+					report(A11yMissingLabel, n, "The form field has no label")
+				}
+			case "label":
+				inLabel = true
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				level := int(n.Data[1] - '0')
+				if heading > 0 && level > heading+1 {
+					report(A11yHeadingOrder, n, "Heading level %d %q follows level %d", level, truncate(collapsedText(n, nil), 60), heading)
+				}
+				heading = level
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, inLabel)
+		}
+	}
+	walk(doc, false)
+	return issues
+}
+
+// isHiddenFromReaders reports whether screen readers skip an element and
+// its content
+func isHiddenFromReaders(n *html.Node) bool {
+	switch n.Data {
+	case "head", "script", "style", "template":
+		return true
+	}
+	_, hidden := attrValue(n, "hidden")
+	return hidden || attr(n, "aria-hidden") == "true"
+}
+
+// hasAccessibleName reports whether an element has text, an image with alt
+// text, or an ARIA label a screen reader can announce
+func hasAccessibleName(n *html.Node) bool {
+	if n.Type == html.TextNode {
+		return strings.TrimSpace(n.Data) != ""
+	}
+	if n.Type != html.ElementNode || isHiddenFromReaders(n) {
+		return false
+	}
+	if hasLabelAttr(n) {
+		return true
+	}
+	if (n.Data == "img" || n.Data == "area" || n.Data == "input") && strings.TrimSpace(attr(n, "alt")) != "" {
+		return true
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if hasAccessibleName(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLabelAttr reports whether an element is labelled by its attributes
+func hasLabelAttr(n *html.Node) bool {
+	return strings.TrimSpace(attr(n, "aria-label")) != "" || strings.TrimSpace(attr(n, "aria-labelledby")) != "" ||
+		strings.TrimSpace(attr(n, "title")) != ""
+}
+
+// needsLabel reports whether a form field needs a label, unlike buttons,
+// which are named by their value, and hidden inputs
+func needsLabel(n *html.Node) bool {
+	if n.Data != "input" {
+		return true
+	}
+	switch strings.ToLower(attr(n, "type")) {
+	case "hidden", "submit", "reset", "button", "image":
+		return false
+	}
+	return true
+}
+
+// startTag renders an element's start tag for reports, shortening long
+// attribute values
+func startTag(n *html.Node) string {
+	var b strings.Builder
+	b.WriteString("<" + n.Data)
+	for _, a := range n.Attr {
+		b.WriteString(" " + a.Key)
+		if a.Val != "" {
+			b.WriteString(`="` + html.EscapeString(truncate(a.Val, 60)) + `"`)
+		}
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// truncate shortens s to at most n bytes, without splitting UTF-8
+// sequences, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !isRuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
+
+// AccessibilityPage is a page's accessibility issues, as summarized by
+// SummarizeAccessibility
+type AccessibilityPage struct {
+	URL         string               `json:"url"`
+	StatusCode  int                  `json:"-"`
+	ContentType string               `json:"-"` // The sniffed type when Content-Type didn't say
+	Issues      []AccessibilityIssue `json:"issues"`
+}
+
+// NewAccessibilityPage returns the accessibility summary entry of a crawl
+// result
+func NewAccessibilityPage(r CrawlResult) AccessibilityPage {
+	page := AccessibilityPage{URL: r.URL, StatusCode: r.StatusCode, ContentType: r.ContentType, Issues: r.Accessibility}
+	if r.SniffedType != "" {
+		page.ContentType = r.SniffedType
+	}
+	return page
+}
+
+// AccessibilityCount is how often a check failed across a crawl
+type AccessibilityCount struct {
+	Check  AccessibilityCheck `json:"check"`
+	Issues int                `json:"issues"`
+	Pages  int                `json:"pages"` // Pages with at least one issue
+}
+
+// AccessibilityReport summarizes the accessibility issues of a crawl
+type AccessibilityReport struct {
+	Pages  int                  `json:"pages"`  // Pages checked
+	Checks []AccessibilityCount `json:"checks"` // Every check, in AccessibilityChecks order
+	Failed []AccessibilityPage  `json:"failed"` // Pages with issues, most first
+}
+
+// SummarizeAccessibility counts the issues of each check across the HTML
+// pages answered with 200 OK, the ones that are checked
+func SummarizeAccessibility(pages []AccessibilityPage) AccessibilityReport {
+	report := AccessibilityReport{Failed: []AccessibilityPage{}}
+	counts := make(map[AccessibilityCheck]*AccessibilityCount)
+	for _, check := range AccessibilityChecks {
+		report.Checks = append(report.Checks, AccessibilityCount{Check: check})
+	}
+	for i := range report.Checks {
+		counts[report.Checks[i].Check] = &report.Checks[i]
+	}
+
+	for _, p := range pages {
+		if p.StatusCode != http.StatusOK || !isHTML(p.ContentType) {
+			continue
+		}
+		report.Pages++
+		if len(p.Issues) == 0 {
+			continue
+		}
+		report.Failed = append(report.Failed, p)
+		seen := make(map[AccessibilityCheck]bool)
+		for _, issue := range p.Issues {
+			count := counts[issue.Check]
+			if count == nil {
+				continue
+			}
+			count.Issues++
+			if !seen[issue.Check] {
+				seen[issue.Check] = true
+				count.Pages++
+			}
+		}
+	}
+	sort.SliceStable(report.Failed, func(i, j int) bool { return len(report.Failed[i].Issues) > len(report.Failed[j].Issues) })
+	return report
+}
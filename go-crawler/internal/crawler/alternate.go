@@ -0,0 +1,266 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// Alternate kinds reported in CrawlResult.Alternates
+const (
+	AlternateAMP    = "amp"
+	AlternateMobile = "mobile"
+)
+
+// Alternate is an AMP or mobile version a page advertises with
+// <link rel="amphtml"> or a <link rel="alternate"> with a media query
+type Alternate struct {
+	Kind string `json:"kind"`
+	URL  string `json:"url"`
+}
+
+// BrokenAlternate is an AMP or mobile version that could not be fetched,
+// did not return 200 OK, or does not name the page as its canonical URL
+type BrokenAlternate struct {
+	URL        string   `json:"url"`
+	Kind       string   `json:"kind"`
+	StatusCode int      `json:"statusCode,omitempty"`
+	Problem    string   `json:"problem"`
+	Pages      []string `json:"pages"`
+}
+
+// alternateCheck is the shared state of one alternate URL
+type alternateCheck struct {
+	once      sync.Once
+	kind      string
+	status    int
+	canonical string
+	err       error
+	checked   bool // False when robots.txt or cancellation prevented the check
+
+	mu    sync.Mutex
+	pages []string
+}
+
+// WithAlternateCrawl crawls the AMP and mobile versions pages advertise,
+// at the same depth as the page
+func WithAlternateCrawl() Option {
+	return func(c *Crawler) {
+		c.crawlAlternates = true
+	}
+}
+
+// WithAlternateCheck fetches the AMP and mobile versions pages advertise,
+// once per URL, so that missing or broken ones can be reported by
+// BrokenAlternates. A version is broken unless it returns 200 OK and its
+// rel=canonical names the page that advertised it.
+func WithAlternateCheck() Option {
+	return func(c *Crawler) {
+		c.checkAlternates = true
+	}
+}
+
+// isMobileAlternate reports whether a <link rel="alternate"> media query
+// targets small screens, as used for separate mobile URLs
+func isMobileAlternate(media string) bool {
+	media = strings.ToLower(media)
+	return strings.Contains(media, "max-width") || strings.Contains(media, "handheld")
+}
+
+// resolveAlternates resolves the alternates found on a page, dropping
+// invalid and duplicate ones
+func resolveAlternates(page *url.URL, found []Alternate) []Alternate {
+	var alternates []Alternate
+	seen := make(map[string]bool, len(found))
+	for _, a := range found {
+		target, err := page.Parse(strings.TrimSpace(a.URL))
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+			continue
+		}
+		target.Fragment = ""
+		if seen[target.String()] {
+			continue
+		}
+		seen[target.String()] = true
+		alternates = append(alternates, Alternate{Kind: a.Kind, URL: target.String()})
+	}
+	return alternates
+}
+
+// alternateURLs returns the URLs of a page's alternates
+func alternateURLs(alternates []Alternate) []string {
+	urls := make([]string, len(alternates))
+	for i, a := range alternates {
+		urls[i] = a.URL
+	}
+	return urls
+}
+
+// BrokenAlternates returns the AMP and mobile versions that are missing or
+// broken, sorted by URL
+func (c *Crawler) BrokenAlternates() []BrokenAlternate {
+	var broken []BrokenAlternate
+	c.alternates.Range(func(key, value interface{}) bool {
+		a := value.(*alternateCheck)
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if !a.checked {
+			return true
+		}
+		problem := alternateProblem(a)
+		if problem == "" {
+			return true
+		}
+		b := BrokenAlternate{
+			URL:        key.(string),
+			Kind:       a.kind,
+			StatusCode: a.status,
+			Problem:    problem,
+			Pages:      append([]string(nil), a.pages...),
+		}
+		sort.Strings(b.Pages)
+		broken = append(broken, b)
+		return true
+	})
+	sort.Slice(broken, func(i, j int) bool { return broken[i].URL < broken[j].URL })
+	return broken
+}
+
+// alternateProblem describes what is wrong with a checked alternate, or
+// returns "" if nothing is
+func alternateProblem(a *alternateCheck) string {
+	switch {
+	case a.err != nil:
+		return a.err.Error()
+	case a.status != http.StatusOK:
+		return fmt.Sprintf("status %d", a.status)
+	case a.canonical == "":
+		return "no rel=canonical"
+	}
+	for _, page := range a.pages {
+		if a.canonical == page {
+			return ""
+		}
+	}
+	return "rel=canonical names " + a.canonical
+}
+
+// checkPageAlternates records the alternates a page advertises and checks
+// the ones not seen before
+func (c *Crawler) checkPageAlternates(ctx context.Context, pageURL string, alternates []Alternate) {
+	for _, alt := range alternates {
+		u, err := url.Parse(alt.URL)
+		if err != nil {
+			continue
+		}
+		c.mapHost(u)
+		if c.urlFilter != nil && !c.urlFilter(u) {
+			continue
+		}
+
+		value, _ := c.alternates.LoadOrStore(u.String(), &alternateCheck{kind: alt.Kind})
+		a := value.(*alternateCheck)
+		a.mu.Lock()
+		if len(a.pages) < maxAssetPages {
+			a.pages = append(a.pages, pageURL)
+		}
+		a.mu.Unlock()
+
+		a.once.Do(func() {
+			status, canonical, checked, err := c.fetchAlternate(ctx, u)
+			a.mu.Lock()
+			a.status, a.canonical, a.err, a.checked = status, canonical, err, checked
+			a.mu.Unlock()
+		})
+	}
+}
+
+// fetchAlternate requests an alternate and returns its status code and
+// rel=canonical URL. checked is false when it was not requested at all.
+func (c *Crawler) fetchAlternate(ctx context.Context, u *url.URL) (int, string, bool, error) {
+	rules, err := c.getRobotsRules(u)
+	if err != nil {
+		return 0, "", false, err
+	}
+	if _, allowed := c.checkRobots(rules, u.String()); !allowed {
+		return 0, "", false, nil
+	}
+
+	if c.rateLimiter != nil {
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			return 0, "", false, err
+		}
+	}
+	release, err := c.acquireHost(ctx, u.Host)
+	if err != nil {
+		return 0, "", false, err
+	}
+	defer release()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, "", true, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.doWithRetries(ctx, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, "", false, err
+		}
+		return 0, "", true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, "", true, nil
+	}
+	var body io.Reader = resp.Body
+	if c.bandwidth != nil {
+		body = c.bandwidth.Reader(ctx, body)
+	}
+	if !isHTML(resp.Header.Get("Content-Type")) {
+		var sniffed string
+		if body, sniffed, err = sniffContent(body); err != nil || !isHTML(sniffed) {
+			return resp.StatusCode, "", true, nil
+		}
+	}
+	doc, err := html.Parse(body)
+	if err != nil {
+		return resp.StatusCode, "", true, fmt.Errorf("error parsing HTML: %v", err)
+	}
+	return resp.StatusCode, c.canonicalURL(u, doc), true, nil
+}
+
+// canonicalURL returns the absolute rel=canonical URL of a document with
+// host mappings applied, or ""
+func (c *Crawler) canonicalURL(page *url.URL, doc *html.Node) string {
+	var href string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "link" && hasField(strings.Fields(strings.ToLower(attr(n, "rel"))), "canonical") {
+			href = attr(n, "href")
+			return
+		}
+		for child := n.FirstChild; child != nil && href == ""; child = child.NextSibling {
+			f(child)
+		}
+	}
+	f(doc)
+	if href == "" {
+		return ""
+	}
+	target, err := page.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+	target.Fragment = ""
+	c.mapHost(target)
+	return target.String()
+}
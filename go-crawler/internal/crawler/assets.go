@@ -0,0 +1,175 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Asset kinds checked by WithAssetCheck
+const (
+	AssetScript     = "script"
+	AssetStylesheet = "stylesheet"
+	AssetImage      = "image"
+)
+
+// maxAssetPages bounds how many referencing pages are kept per asset
+const maxAssetPages = 100
+
+// BrokenAsset is a script, stylesheet or image that failed to load, with
+// the pages that reference it
+type BrokenAsset struct {
+	URL        string   `json:"url"`
+	Kind       string   `json:"kind"`
+	StatusCode int      `json:"statusCode,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Pages      []string `json:"pages"`
+}
+
+// pageAsset is an asset reference found in an HTML page
+type pageAsset struct {
+	kind string
+	href string
+}
+
+// assetCheck is the shared state of one asset URL
+type assetCheck struct {
+	once    sync.Once
+	kind    string
+	status  int
+	err     error
+	checked bool // False when robots.txt or cancellation prevented the check
+
+	mu    sync.Mutex
+	pages []string
+}
+
+// WithAssetCheck fetches every script, stylesheet and image referenced by
+// crawled pages, once per URL, so that broken ones can be reported by
+// BrokenAssets. Assets are requested with HEAD, falling back to GET for
+// servers that don't support it.
+func WithAssetCheck() Option {
+	return func(c *Crawler) {
+		c.checkAssets = true
+	}
+}
+
+// BrokenAssets returns the assets that returned an error status or could
+// not be fetched, sorted by URL
+func (c *Crawler) BrokenAssets() []BrokenAsset {
+	var broken []BrokenAsset
+	c.assets.Range(func(key, value interface{}) bool {
+		a := value.(*assetCheck)
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if !a.checked || (a.err == nil && a.status < 400) {
+			return true
+		}
+		b := BrokenAsset{
+			URL:        key.(string),
+			Kind:       a.kind,
+			StatusCode: a.status,
+			Pages:      append([]string(nil), a.pages...),
+		}
+		if a.err != nil {
+			b.Error = a.err.Error()
+		}
+		sort.Strings(b.Pages)
+		broken = append(broken, b)
+		return true
+	})
+	sort.Slice(broken, func(i, j int) bool { return broken[i].URL < broken[j].URL })
+	return broken
+}
+
+// checkPageAssets records the assets a page references and checks the ones
+// not seen before
+func (c *Crawler) checkPageAssets(ctx context.Context, pageURL string, assets []pageAsset) {
+	seen := make(map[string]bool, len(assets))
+	for _, asset := range assets {
+		u, err := resolveURL(pageURL, asset.href)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		c.mapHost(u)
+		if c.urlFilter != nil && !c.urlFilter(u) {
+			continue
+		}
+		u.Fragment = ""
+		assetURL := u.String()
+		if seen[assetURL] {
+			continue
+		}
+		seen[assetURL] = true
+
+		value, _ := c.assets.LoadOrStore(assetURL, &assetCheck{kind: asset.kind})
+		a := value.(*assetCheck)
+		a.mu.Lock()
+		if len(a.pages) < maxAssetPages {
+			a.pages = append(a.pages, pageURL)
+		}
+		a.mu.Unlock()
+
+		a.once.Do(func() {
+			status, checked, err := c.fetchAsset(ctx, u)
+			a.mu.Lock()
+			a.status, a.err, a.checked = status, err, checked
+			a.mu.Unlock()
+		})
+	}
+}
+
+// fetchAsset requests an asset and returns its status code. checked is
+// false when the asset was not requested at all.
+func (c *Crawler) fetchAsset(ctx context.Context, u *url.URL) (int, bool, error) {
+	return c.headOrGet(ctx, u, func(status int) bool {
+		return status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented
+	})
+}
+
+// headOrGet requests a URL with HEAD, and again with GET when retryGet
+// returns true for the HEAD response's status, and returns the last status
+// code. checked is false when the URL was not requested at all.
+func (c *Crawler) headOrGet(ctx context.Context, u *url.URL, retryGet func(status int) bool) (int, bool, error) {
+	rules, err := c.getRobotsRules(u)
+	if err != nil {
+		return 0, false, err
+	}
+	if _, allowed := c.checkRobots(rules, u.String()); !allowed {
+		return 0, false, nil
+	}
+
+	if c.rateLimiter != nil {
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			return 0, false, err
+		}
+	}
+	release, err := c.acquireHost(ctx, u.Host)
+	if err != nil {
+		return 0, false, err
+	}
+	defer release()
+
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequest(method, u.String(), nil)
+		if err != nil {
+			return 0, true, fmt.Errorf("error creating request: %v", err)
+		}
+		req.Header.Set("User-Agent", c.userAgent)
+		resp, err := c.doWithRetries(ctx, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, false, err
+			}
+			return 0, true, err
+		}
+		resp.Body.Close()
+		if method == http.MethodGet || !retryGet(resp.StatusCode) {
+			return resp.StatusCode, true, nil
+		}
+	}
+	return 0, true, nil
+}
@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// AuditPage is a crawled page as it appears in an SEO audit export
+type AuditPage struct {
+	URL             string
+	Depth           int
+	StatusCode      int
+	Title           string
+	MetaDescription string
+	H1Count         int
+	Canonical       string
+	Links           []string // As reported in CrawlResult.Links
+	WordCount       *int     // Words in the page's main text, if it was extracted
+	ContentType     string   // The sniffed type when Content-Type didn't say
+}
+
+// NewAuditPage returns the audit view of a crawl result
+func NewAuditPage(r CrawlResult) AuditPage {
+	page := AuditPage{
+		URL:             r.URL,
+		Depth:           r.Depth,
+		StatusCode:      r.StatusCode,
+		Title:           r.Title,
+		MetaDescription: r.MetaDescription,
+		H1Count:         r.H1Count,
+		Canonical:       r.Canonical,
+		Links:           r.Links,
+		ContentType:     r.ContentType,
+	}
+	if r.SniffedType != "" {
+		page.ContentType = r.SniffedType
+	}
+	if words, ok := NewPageWords(r); ok {
+		page.WordCount = &words.Words
+	}
+	return page
+}
+
+// WriteAuditCSV writes one row per page in the column layout SEO tools
+// use. Inlinks counts the other crawled pages on the same host that link to
+// a page and outlinks the distinct URLs a page links to, and word_count is
+// empty for pages whose text wasn't extracted. The file starts
+// with a UTF-8 byte order mark so spreadsheet applications read titles
+// correctly.
+func WriteAuditCSV(w io.Writer, pages []AuditPage) error {
+	outlinks := make([]map[string]bool, len(pages))
+	inlinks := make(map[string]int)
+	for i, p := range pages {
+		outlinks[i] = make(map[string]bool)
+		base, err := url.Parse(p.URL)
+		if err != nil {
+			continue
+		}
+		for _, link := range p.Links {
+			u, err := base.Parse(link)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				continue
+			}
+			u.Fragment = ""
+			target := u.String()
+			if target == p.URL || outlinks[i][target] {
+				continue
+			}
+			outlinks[i][target] = true
+			if strings.EqualFold(base.Hostname(), u.Hostname()) {
+				inlinks[target]++
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"url", "status_code", "title", "title_length", "meta_description", "meta_description_length", "h1_count", "canonical", "depth", "inlinks", "outlinks", "word_count"})
+	for i, p := range pages {
+		words := ""
+		if p.WordCount != nil {
+			words = strconv.Itoa(*p.WordCount)
+		}
+		cw.Write([]string{
+			p.URL,
+			strconv.Itoa(p.StatusCode),
+			p.Title,
+			strconv.Itoa(utf8.RuneCountInString(p.Title)),
+			p.MetaDescription,
+			strconv.Itoa(utf8.RuneCountInString(p.MetaDescription)),
+			strconv.Itoa(p.H1Count),
+			p.Canonical,
+			strconv.Itoa(p.Depth),
+			strconv.Itoa(inlinks[p.URL]),
+			strconv.Itoa(len(outlinks[i])),
+			words,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,88 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthChallenge is a server's request for credentials: the scheme and
+// realm from a 401 response's WWW-Authenticate header
+type AuthChallenge struct {
+	Scheme string
+	Realm  string
+}
+
+// WithBasicAuth sends HTTP basic auth credentials with every request to
+// the start URL's host. Other hosts never see them.
+func WithBasicAuth(user, password string) Option {
+	return func(c *Crawler) {
+		c.authUser, c.authPassword = user, password
+	}
+}
+
+// setAuth adds the basic auth credentials to requests for the start host
+func (c *Crawler) setAuth(req *http.Request) {
+	if c.authUser == "" && c.authPassword == "" {
+		return
+	}
+	if strings.EqualFold(req.URL.Host, c.authHost) {
+		req.SetBasicAuth(c.authUser, c.authPassword)
+	}
+}
+
+// parseAuthChallenge reads the first challenge of a WWW-Authenticate
+// header, e.g. `Basic realm="Staging"`
+func parseAuthChallenge(header string) AuthChallenge {
+	scheme, params, _ := strings.Cut(strings.TrimSpace(header), " ")
+	challenge := AuthChallenge{Scheme: scheme}
+	for _, param := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok && strings.EqualFold(key, "realm") {
+			challenge.Realm = strings.Trim(value, `"`)
+			break
+		}
+	}
+	return challenge
+}
+
+// authError is returned for pages that answered 401 Unauthorized
+func authError(urlStr string, challenge AuthChallenge) error {
+	if challenge.Realm != "" {
+		return fmt.Errorf("%w for %s (%s realm %q)", ErrAuthRequired, urlStr, challenge.Scheme, challenge.Realm)
+	}
+	return fmt.Errorf("%w for %s", ErrAuthRequired, urlStr)
+}
+
+// ProbeAuth requests a URL once, without crawling it, and returns the
+// server's challenge if it answers 401 Unauthorized. The crawler's
+// credentials, if any, are sent.
+func (c *Crawler) ProbeAuth(ctx context.Context, urlStr string) (*AuthChallenge, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %v", urlStr, err)
+	}
+	if c.authHost == "" {
+		c.authHost = u.Host
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	c.setAuth(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %v", urlStr, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return nil, nil
+	}
+	challenge := parseAuthChallenge(resp.Header.Get("WWW-Authenticate"))
+	return &challenge, nil
+}
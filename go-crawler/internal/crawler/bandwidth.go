@@ -0,0 +1,155 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BandwidthLimiter is a token bucket over bytes read from response bodies.
+// Share one between crawlers to enforce a limit across all of them; the rate
+// can be changed while crawls are running.
+type BandwidthLimiter struct {
+	mu       sync.Mutex
+	rate     float64 // Bytes per second, 0 = unlimited
+	tokens   float64
+	lastFill time.Time
+}
+
+// NewBandwidthLimiter creates a limiter allowing bytesPerSec bytes per
+// second; zero or less means unlimited
+func NewBandwidthLimiter(bytesPerSec int64) *BandwidthLimiter {
+	l := &BandwidthLimiter{lastFill: time.Now()}
+	l.SetRate(bytesPerSec)
+	return l
+}
+
+// SetRate changes the allowed bytes per second; zero or less means unlimited
+func (l *BandwidthLimiter) SetRate(bytesPerSec int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if bytesPerSec < 0 {
+		bytesPerSec = 0
+	}
+	l.rate = float64(bytesPerSec)
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+}
+
+// Rate returns the allowed bytes per second, or 0 when unlimited
+func (l *BandwidthLimiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(l.rate)
+}
+
+// burst is the most a single read may take at once: a tenth of a second's
+// worth of bytes, but at least 4 KiB so slow limits still make progress.
+// The caller must hold l.mu.
+func (l *BandwidthLimiter) burst() int {
+	b := int(l.rate / 10)
+	if b < 4096 {
+		b = 4096
+	}
+	return b
+}
+
+// waitN blocks until n bytes may be read or ctx is cancelled
+func (l *BandwidthLimiter) waitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	if l.rate == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	l.tokens += now.Sub(l.lastFill).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate // At most one second of burst
+	}
+	l.lastFill = now
+	l.tokens -= float64(n)
+
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	return sleepCtx(ctx, wait)
+}
+
+// Reader throttles reads from r. Reads are split into chunks no larger than
+// the limiter's burst so that many concurrent readers share bandwidth fairly.
+func (l *BandwidthLimiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &throttledReader{ctx: ctx, r: r, l: l}
+}
+
+type throttledReader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *BandwidthLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	t.l.mu.Lock()
+	unlimited := t.l.rate == 0
+	max := t.l.burst()
+	t.l.mu.Unlock()
+
+	if unlimited {
+		return t.r.Read(p)
+	}
+	if len(p) > max {
+		p = p[:max]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if werr := t.l.waitN(t.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// WithBandwidthLimit throttles reading response bodies through l
+func WithBandwidthLimit(l *BandwidthLimiter) Option {
+	return func(c *Crawler) {
+		c.bandwidth = l
+	}
+}
+
+// ParseByteRate parses sizes such as "512KB", "5MB" or "1.5MiB" (with an
+// optional "/s" suffix) into bytes. Plain numbers are bytes.
+func ParseByteRate(s string) (int64, error) {
+	str := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "/S")
+	units := []struct {
+		suffix string
+		mult   float64
+	}{
+		{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+		{"K", 1e3}, {"M", 1e6}, {"G", 1e9},
+		{"B", 1},
+	}
+	mult := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(str, u.suffix) {
+			str = strings.TrimSuffix(str, u.suffix)
+			mult = u.mult
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid byte rate %q", s)
+	}
+	return int64(n * mult), nil
+}
@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"bytes"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// maxPooledBuffer is the largest buffer kept for reuse. Buffers grown by
+// unusually large pages are left to the garbage collector so that the pool
+// doesn't pin their memory.
+const maxPooledBuffer = 1 << 20
+
+// buffers holds the scratch buffers that page bodies are read into and
+// page text is collected in, reused across pages to spare the garbage
+// collector at high throughput
+var buffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	return buffers.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns a buffer to the pool. Nothing may use its bytes after.
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	buffers.Put(b)
+}
+
+// collapsedText returns the text nodes under n, with runs of whitespace
+// collapsed to a single space and no leading or trailing space, skipping
+// elements for which skip returns true. Words are collected in a pooled
+// buffer, so the result is the only allocation.
+func collapsedText(n *html.Node, skip func(*html.Node) bool) string {
+	b := getBuffer()
+	defer putBuffer(b)
+
+	space := false // Whitespace seen since the last word
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if skip != nil && skip(n) {
+			return
+		}
+		if n.Type == html.TextNode {
+			// Text nodes are separated by whitespace too
+			space = true
+			text := n.Data
+			for i := 0; i < len(text); {
+				if size := spaceAt(text, i); size > 0 {
+					space = true
+					i += size
+					continue
+				}
+				word := i
+				for i < len(text) && spaceAt(text, i) == 0 {
+					if text[i] < utf8.RuneSelf {
+						i++
+					} else {
+						_, size := utf8.DecodeRuneInString(text[i:])
+						i += size
+					}
+				}
+				if space && b.Len() > 0 {
+					b.WriteByte(' ')
+				}
+				space = false
+				b.WriteString(text[word:i])
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return b.String()
+}
+
+// spaceAt returns the length of the whitespace character at s[i], or 0 if
+// there is none, with unicode.IsSpace's notion of whitespace
+func spaceAt(s string, i int) int {
+	if c := s[i]; c < utf8.RuneSelf {
+		if c == ' ' || ('\t' <= c && c <= '\r') {
+			return 1
+		}
+		return 0
+	}
+	if r, size := utf8.DecodeRuneInString(s[i:]); unicode.IsSpace(r) {
+		return size
+	}
+	return 0
+}
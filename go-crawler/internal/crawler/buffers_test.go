@@ -0,0 +1,95 @@
+package crawler_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go-crawler/internal/crawler"
+	"go-crawler/internal/crawler/crawlertest"
+)
+
+// Size of the site the allocation benchmarks crawl
+const (
+	benchPages     = 100
+	benchLinks     = 100       // Links on each page, to other pages
+	benchPageBytes = 30 * 1024 // Roughly, of markup and text
+)
+
+// benchPage returns the markup of page i: a title, paragraphs of text and
+// links to benchLinks pages
+func benchPage(i int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html><head><title>Page %d</title>"+
+		"<meta name=\"description\" content=\"Page %d of the benchmark site\"></head><body>\n<h1>Page %d</h1>\n", i, i, i)
+	for l := 0; l < benchLinks; l++ {
+		fmt.Fprintf(&b, "<p>See <a href=\"/p/%d\">page   %d\n of the site</a> for more.</p>\n", (i+l)%benchPages, l)
+	}
+	for b.Len() < benchPageBytes {
+		b.WriteString("<p>Lorem ipsum dolor sit amet,\tconsectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.</p>\n")
+	}
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+// benchSite is benchPages pages under /p/, each linking to benchLinks of
+// them
+func benchSite() *crawlertest.Site {
+	site := crawlertest.NewSite("https://example.com/")
+	for i := 0; i < benchPages; i++ {
+		site.HTML(fmt.Sprintf("/p/%d", i), benchPage(i))
+	}
+	return site
+}
+
+// fieldsProcessor extracts a field from every page's body, so that the body
+// is copied out for processors
+type fieldsProcessor struct{}
+
+func (fieldsProcessor) Name() string { return "fields" }
+
+func (fieldsProcessor) Follow(crawler.Link) (bool, string, error) { return true, "", nil }
+
+func (fieldsProcessor) Extract(page crawler.Page) (map[string]string, error) {
+	return map[string]string{"bytes": fmt.Sprint(len(page.Body))}, nil
+}
+
+func (fieldsProcessor) Result(crawler.CrawlResult) error { return nil }
+
+// BenchmarkCrawl measures the allocations of crawling the benchmark site
+// with 4 workers, with and without a processor
+func BenchmarkCrawl(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts []crawler.Option
+	}{
+		{"plain", nil},
+		{"processor", []crawler.Option{crawler.WithProcessors(fieldsProcessor{})}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			site := benchSite()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c := crawler.NewCrawler(4, 2, 0, append([]crawler.Option{site.Option()}, bc.opts...)...)
+				if n := len(site.Crawl(context.Background(), c, "/p/0")); n != benchPages {
+					b.Fatalf("crawled %d pages, want %d", n, benchPages)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkExtractLinks measures the allocations of parsing one page of the
+// benchmark site
+func BenchmarkExtractLinks(b *testing.B) {
+	page := benchPage(0)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(page)))
+	for i := 0; i < b.N; i++ {
+		if _, err := crawler.ExtractLinks(strings.NewReader(page), "https://example.com/p/0"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
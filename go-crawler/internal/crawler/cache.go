@@ -0,0 +1,66 @@
+package crawler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangeFresh marks a page a refresh crawl skipped because its last
+// response was still fresh according to its caching headers
+const ChangeFresh ChangeState = "fresh"
+
+// WithSkipFresh makes Revalidate skip pages whose last response is still
+// fresh, as its Cache-Control max-age or Expires header said, reporting
+// them as ChangeFresh with their previous validators instead of fetching
+// them again
+func WithSkipFresh() Option {
+	return func(c *Crawler) {
+		c.skipFresh = true
+	}
+}
+
+// freshUntil returns when a response fetched at fetched stops being fresh:
+// Cache-Control s-maxage or max-age take precedence over Expires, and
+// no-cache or no-store make it stale at once. It returns the zero time when
+// the response has no caching headers.
+func freshUntil(h http.Header, fetched time.Time) time.Time {
+	age := time.Duration(0)
+	if v, err := strconv.Atoi(strings.TrimSpace(h.Get("Age"))); err == nil && v > 0 {
+		age = time.Duration(v) * time.Second
+	}
+
+	maxAge, sharedMaxAge := -1, -1
+	for _, directive := range strings.Split(strings.Join(h.Values("Cache-Control"), ","), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		seconds, err := strconv.Atoi(strings.Trim(strings.TrimSpace(value), `"`))
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "no-cache", "no-store":
+			return fetched
+		case "max-age":
+			if err == nil {
+				maxAge = seconds
+			}
+		case "s-maxage":
+			if err == nil {
+				sharedMaxAge = seconds
+			}
+		}
+	}
+	if sharedMaxAge >= 0 {
+		maxAge = sharedMaxAge
+	}
+	if maxAge >= 0 {
+		return fetched.Add(time.Duration(maxAge)*time.Second - age)
+	}
+
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return fetched // Invalid dates such as "0" mean already expired
+		}
+		return expires
+	}
+	return time.Time{}
+}
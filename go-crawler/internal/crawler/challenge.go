@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ChallengeError is returned for pages answered with a bot challenge or
+// CAPTCHA instead of their content, i.e. the crawler was blocked
+type ChallengeError struct {
+	Provider string // E.g. "Cloudflare", or "CAPTCHA" for an unknown one
+	Code     int
+	URL      string
+}
+
+func (e *ChallengeError) Error() string {
+	return fmt.Sprintf("blocked by a %s bot challenge (status %d) for %s", e.Provider, e.Code, e.URL)
+}
+
+// Is makes ChallengeError match ErrBotChallenge
+func (e *ChallengeError) Is(target error) bool {
+	return target == ErrBotChallenge
+}
+
+// maxChallengeBody bounds the bytes of an error response searched for
+// challenge markers
+const maxChallengeBody = 64 << 10
+
+// challengeMarker is text in a response body that gives a bot challenge
+// away. Markers that are not strong also appear on ordinary pages, such as
+// a contact form's CAPTCHA, and only count on 403, 429 and 503 responses.
+type challengeMarker struct {
+	provider string
+	text     string
+	strong   bool
+}
+
+var challengeMarkers = []challengeMarker{
+	{"Cloudflare", "/cdn-cgi/challenge-platform/", true},
+	{"Cloudflare", "<title>Just a moment...</title>", true},
+	{"Cloudflare", "Attention Required! | Cloudflare", true},
+	{"DataDome", "captcha-delivery.com", true},
+	{"Imperva", "_Incapsula_Resource", true},
+	{"PerimeterX", "px-captcha", true},
+	{"AWS WAF", "AwsWafIntegration", true},
+	{"Sucuri", "Sucuri WebSite Firewall", true},
+	{"Akamai", "errors.edgesuite.net", false},
+	{"CAPTCHA", "cf-turnstile", false},
+	{"CAPTCHA", "g-recaptcha", false},
+	{"CAPTCHA", "h-captcha", false},
+	{"CAPTCHA", "hcaptcha.com/1/api.js", false},
+	{"CAPTCHA", "www.google.com/recaptcha/", false},
+}
+
+// challengeHeader returns the provider of a challenge its response headers
+// announce, or ""
+func challengeHeader(h http.Header) string {
+	switch {
+	case strings.EqualFold(h.Get("Cf-Mitigated"), "challenge"):
+		return "Cloudflare"
+	case h.Get("X-Amzn-Waf-Action") == "captcha" || h.Get("X-Amzn-Waf-Action") == "challenge":
+		return "AWS WAF"
+	}
+	return ""
+}
+
+// mayChallenge reports whether a status is one bot challenges are served
+// with, the ones whose bodies detectChallenge searches for weak markers
+func mayChallenge(code int) bool {
+	return code == http.StatusForbidden || code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+// detectChallenge returns the provider of a bot challenge a response
+// carries, or "" for a regular response
+func detectChallenge(code int, h http.Header, body []byte) string {
+	if provider := challengeHeader(h); provider != "" {
+		return provider
+	}
+	weak := mayChallenge(code)
+	for _, m := range challengeMarkers {
+		if (m.strong || weak) && bytes.Contains(body, []byte(m.text)) {
+			return m.provider
+		}
+	}
+	return ""
+}
+
+// checkChallenge reads the start of an error response's body and returns
+// a ChallengeError if it is a bot challenge
+func checkChallenge(resp *http.Response, urlStr string) error {
+	if !mayChallenge(resp.StatusCode) && challengeHeader(resp.Header) == "" {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxChallengeBody))
+	if provider := detectChallenge(resp.StatusCode, resp.Header, body); provider != "" {
+		return &ChallengeError{Provider: provider, Code: resp.StatusCode, URL: urlStr}
+	}
+	return nil
+}
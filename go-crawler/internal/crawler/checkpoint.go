@@ -0,0 +1,128 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Checkpoint is a snapshot of a crawl's progress from which it can resume
+type Checkpoint struct {
+	StartURL string           `json:"startUrl"`
+	SavedAt  time.Time        `json:"savedAt"`
+	Visited  []string         `json:"visited"`
+	Frontier []CheckpointTask `json:"frontier"`
+}
+
+// CheckpointTask is a URL that was queued but not yet fetched
+type CheckpointTask struct {
+	URL    string  `json:"url"`
+	Depth  int     `json:"depth"`
+	Source string  `json:"source,omitempty"`
+	Score  float64 `json:"score,omitempty"`
+}
+
+// WithCheckpointFile writes a checkpoint to path whenever the crawl pauses
+// for its schedule
+func WithCheckpointFile(path string) Option {
+	return func(c *Crawler) {
+		c.checkpointFile = path
+	}
+}
+
+// trackTask adds a task to the frontier used for checkpoints. A disk
+// frontier tracks only the tasks in flight, as dispatchDisk hands them out.
+func (c *Crawler) trackTask(task crawlTask) {
+	if c.disk != nil {
+		return
+	}
+	c.frontierMu.Lock()
+	c.frontier[task.URL] = task
+	c.frontierMu.Unlock()
+}
+
+// taskDone removes a finished task from the frontier and the pending count
+func (c *Crawler) taskDone(task crawlTask) {
+	c.frontierMu.Lock()
+	delete(c.frontier, task.URL)
+	c.frontierMu.Unlock()
+	c.pending.Done()
+}
+
+// Checkpoint snapshots the visited set and the queued or in-flight URLs.
+// URLs still in the frontier are not listed as visited so that they are
+// fetched again on resume. A visited store that can't list its URLs, such
+// as a Bloom filter, contributes none.
+func (c *Crawler) Checkpoint() *Checkpoint {
+	c.frontierMu.Lock()
+	cp := &Checkpoint{
+		StartURL: c.startURL,
+		SavedAt:  time.Now(),
+		Visited:  []string{},
+		Frontier: make([]CheckpointTask, 0, len(c.frontier)),
+	}
+	for _, task := range c.frontier {
+		cp.Frontier = append(cp.Frontier, CheckpointTask{URL: task.URL, Depth: task.Depth, Source: task.Source, Score: task.Score})
+	}
+	inFrontier := make(map[string]bool, len(c.frontier))
+	for u := range c.frontier {
+		inFrontier[u] = true
+	}
+	c.frontierMu.Unlock()
+
+	if lister, ok := c.visited.(interface{ Keys() []string }); ok {
+		for _, u := range lister.Keys() {
+			if !inFrontier[u] {
+				cp.Visited = append(cp.Visited, u)
+			}
+		}
+	}
+	return cp
+}
+
+// Resume continues a crawl from a checkpoint, skipping URLs it had
+// already visited and fetching its frontier
+func (c *Crawler) Resume(ctx context.Context, cp *Checkpoint) <-chan CrawlResult {
+	if c.used() {
+		return usedResults(cp.StartURL)
+	}
+	for _, u := range cp.Visited {
+		c.visited.Add(u)
+	}
+	c.fetched.Store(int64(len(cp.Visited)))
+
+	seeds := make([]crawlTask, 0, len(cp.Frontier))
+	for _, t := range cp.Frontier {
+		seeds = append(seeds, crawlTask{URL: t.URL, Depth: t.Depth, Source: t.Source, Score: t.Score})
+	}
+	return c.start(ctx, cp.StartURL, seeds)
+}
+
+// SaveCheckpoint writes a checkpoint to path as JSON, replacing the file
+// atomically
+func SaveCheckpoint(path string, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("error encoding checkpoint: %v", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing checkpoint: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadCheckpoint reads a checkpoint written by SaveCheckpoint
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint: %v", err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("error parsing checkpoint: %v", err)
+	}
+	return &cp, nil
+}
@@ -0,0 +1,85 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// RobotsDecision records one robots.txt check: which URL was checked
+// against which robots.txt, and the rule that blocked it, if any
+type RobotsDecision struct {
+	Time              time.Time `json:"time"`
+	URL               string    `json:"url"`
+	Allowed           bool      `json:"allowed"`
+	Rule              string    `json:"rule,omitempty"` // Disallow value that blocked the URL
+	RobotsURL         string    `json:"robotsUrl"`
+	RobotsStatus      int       `json:"robotsStatus"` // 0 if robots.txt could not be fetched
+	UserAgent         string    `json:"userAgent"`
+	CrawlDelaySeconds float64   `json:"crawlDelaySeconds"`
+}
+
+// WithUserAgent sets the User-Agent sent with every request, which is also
+// the name matched against robots.txt User-agent groups
+func WithUserAgent(ua string) Option {
+	return func(c *Crawler) {
+		if ua != "" {
+			c.userAgent = ua
+		}
+	}
+}
+
+// WithRobotsHandler calls fn for every robots.txt decision the crawler
+// makes, allowed or not. It is called from worker goroutines and must be
+// safe for concurrent use.
+func WithRobotsHandler(fn func(RobotsDecision)) Option {
+	return func(c *Crawler) {
+		c.onRobots = fn
+	}
+}
+
+// checkRobots reports whether rules allow a URL, recording the decision
+func (c *Crawler) checkRobots(rules *RobotRules, urlStr string) (string, bool) {
+	rule, blocked := rules.MatchingRule(urlStr)
+	if c.onRobots != nil {
+		c.onRobots(RobotsDecision{
+			Time:              time.Now(),
+			URL:               urlStr,
+			Allowed:           !blocked,
+			Rule:              rule,
+			RobotsURL:         rules.URL(),
+			RobotsStatus:      rules.StatusCode(),
+			UserAgent:         c.userAgent,
+			CrawlDelaySeconds: rules.GetCrawlDelay().Seconds(),
+		})
+	}
+	if blocked {
+		c.emit(Event{Type: EventRobotsBlocked, URL: urlStr, Reason: "Disallow: " + rule, Detail: rules.URL()})
+	}
+	return rule, !blocked
+}
+
+// WriteComplianceCSV writes robots.txt decisions as CSV with a header row
+func WriteComplianceCSV(w io.Writer, decisions []RobotsDecision) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "url", "decision", "rule", "robots_url", "robots_status", "user_agent", "crawl_delay_seconds"})
+	for _, d := range decisions {
+		decision := "allowed"
+		if !d.Allowed {
+			decision = "disallowed"
+		}
+		cw.Write([]string{
+			d.Time.Format(time.RFC3339Nano),
+			d.URL,
+			decision,
+			d.Rule,
+			d.RobotsURL,
+			strconv.Itoa(d.RobotsStatus),
+			d.UserAgent,
+			strconv.FormatFloat(d.CrawlDelaySeconds, 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -1,161 +1,784 @@
 package crawler
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/html"
 )
 
+// Crawler crawls with a fixed set of options. It runs one crawl at a time:
+// Start, Resume, Revalidate, Visit and DryRun each begin a crawl, and a
+// Crawler that has run one must be Reset before it begins another.
 type Crawler struct {
-	maxWorkers   int
-	maxDepth     int
-	crawlDelay   time.Duration
-	userAgent    string
-	httpClient   *http.Client
-	visitedURLs  *sync.Map
-	urlsToCrawl  chan crawlTask
-	results      chan CrawlResult
-	wg           sync.WaitGroup
-	robotsMap    *sync.Map // Maps domain to *RobotRules
+	maxWorkers  int
+	maxDepth    int
+	crawlDelay  time.Duration
+	userAgent   string
+	httpClient  *http.Client
+	visited     VisitedStore // URLs fetched, or that failed for good
+	claimed     sync.Map     // Visit keys of the URLs being fetched
+	failures    sync.Map     // Maps visit key to transient failures so far
+	requeues    int          // Times a transient failure is queued again
+	urlsToCrawl chan crawlTask
+	results     chan CrawlResult
+	wg          sync.WaitGroup
+	pending     sync.WaitGroup // Tasks queued or in flight
+	robotsMap   *sync.Map      // Maps domain to *RobotRules
+	rateLimiter *RateLimiter
+	urlFilter   func(*url.URL) bool
+	onSkip      func(SkippedURL)
+	onRobots    func(RobotsDecision)
+	sameHost    bool
+	hostPolicy  HostPolicy
+	subdomains  *SubdomainPolicy
+	startURL    string
+	startHost   string
+	maxPages    int64
+	traps       *trapDetector
+	fetched     atomic.Int64 // Pages taken for fetching, for the page budget
+	bytesRead   atomic.Int64 // Page and feed body bytes read
+
+	maxURLLength      int
+	maxSegmentRepeats int
+	maxLinksPerPage   int
+	maxRedirects      int
+
+	jitter       time.Duration
+	perHostLimit int
+	hostSlots    sync.Map // Maps host to *hostSlot
+	retries      int
+	retryBackoff time.Duration
+	errorPolicy  ErrorPolicy // Nil for the defaults
+	bandwidth    *BandwidthLimiter
+	throttle     *throttleRegistry // Per-host pauses requested with 429 and 503
+	resolver     *Resolver
+	transport    http.RoundTripper // Replaces the network when set
+	proxy        *url.URL          // Every connection goes through it when set
+	headers      http.Header       // Sent with every request
+	journey      *Journey          // Run before the crawl by RunJourney
+	httpRecorder *HTTPRecorder     // Records sampled exchanges for debugging
+	authUser     string
+	authPassword string
+	authHost     string // Host the credentials are sent to
+	hostMappings []HostMapping
+
+	schedule       *Schedule
+	checkpointFile string
+	frontierMu     sync.Mutex
+	frontier       map[string]crawlTask // Queued or in-flight tasks by URL
+	pauseMu        sync.Mutex
+	paused         bool
+
+	revisit         map[string]RevisitEntry // Set in refresh mode, by URL
+	discoverFeeds   bool
+	followCanonical bool
+	followRefresh   bool
+	skipFresh       bool
+	scriptLinks     bool
+	hashbangRoutes  bool
+	refreshHops     sync.Map // Maps meta refresh target to the refreshes leading to it
+
+	scorer Scorer
+	scored *scoreQueue // Frontier of a focused crawl, when scorer is set
+	hosts  *hostQueue  // Frontier of other crawls kept in memory
+
+	workerStatus []*workerStatus // Guarded by frontierMu, for DebugState
+
+	// settingsMu guards crawlDelay and perHostLimit, which UpdateSettings
+	// changes while the crawl runs. maxWorkers, liveWorkers and workerCtx
+	// are guarded by frontierMu.
+	settingsMu  sync.RWMutex
+	liveWorkers int             // Worker goroutines that haven't exited
+	workerCtx   context.Context // Context of the run, for workers added later
+
+	frontierDir string
+	disk        *diskQueue // Frontier kept in frontierDir, when set
+
+	search    []*regexp.Regexp
+	languages []string // Languages whose pages' links are followed
+
+	extractText bool
+
+	textProcessors []TextProcessor
+
+	checkAccessibility bool
+
+	checkHTML bool
+
+	checkAssets bool
+	assets      sync.Map // Maps asset URL to *assetCheck
+
+	checkExternal time.Duration // Interval between checks of an external host, 0 when off
+	externalLinks sync.Map      // Maps external link URL to *assetCheck
+	externalHosts sync.Map      // Maps external host to *externalHost
+
+	excludeMu  sync.RWMutex
+	exclusions []*exclusion // Added by Exclude while the crawl runs
+
+	crawlAlternates bool
+	checkAlternates bool
+	alternates      sync.Map // Maps alternate URL to *alternateCheck
+
+	processors []Processor
+	parsers    Parsers // Link parsers of non-HTML documents, by media type
+	events     *EventBus
+
+	runState atomic.Int32 // runIdle, runActive or runDone
 }
 
 type CrawlResult struct {
 	URL   string
+	Depth int
 	Links []string
+	Feeds []string // Feeds advertised by the page, with feed discovery on
 	Error error
+	Score float64 // Priority the page was fetched with in a focused crawl
+
+	Matches []ContentMatch    // Content search matches in the page's text
+	Fields  map[string]string // Custom fields extracted by processors
+	Text    string            // Main content of an HTML page, with WithTextExtraction
+
+	anchors []string    // Anchor text of each link in Links
+	assets  []pageAsset // Scripts, stylesheets and images the page uses
+
+	// LinksTruncated counts the links found past WithMaxLinksPerPage's
+	// cap, which were left out of Links and not followed
+	LinksTruncated int
+	// WordCount is the number of words in Text. textExtracted tells a page
+	// without text from one whose text wasn't extracted.
+	WordCount     int
+	textExtracted bool
+	// Findings are what text processors reported in the page's text
+	Findings []TextFinding
+	// Accessibility lists the page's issues, with WithAccessibilityChecks
+	Accessibility []AccessibilityIssue
+	// HTMLWarnings lists the page's markup anomalies, with WithHTMLChecks
+	HTMLWarnings []HTMLWarning
+	// Canonical is the page's rel=canonical URL when it names another page
+	Canonical string
+	// MetaRefresh is set when the page redirects with a meta refresh
+	MetaRefresh *MetaRefresh
+	// Alternates are the AMP and mobile versions the page advertises
+	Alternates []Alternate
+	// Language is the page's declared or detected language, e.g. "en-us"
+	Language string
+	// Title, MetaDescription and H1Count describe an HTML page's markup
+	Title           string
+	MetaDescription string
+	H1Count         int
+	// UnavailableAfter is the page's robots unavailable_after date, if any.
+	// Links on pages past that date are not followed.
+	UnavailableAfter time.Time
+
+	StatusCode   int
+	Challenge    *AuthChallenge // Set when the page answered 401 Unauthorized
+	ContentType  string
+	SniffedType  string // Detected from the body when ContentType didn't say how to parse the page
+	ETag         string
+	LastModified string
+	ContentHash  string      // SHA-256 of the body of a parsed page
+	Change       ChangeState // Set in refresh mode
+	FreshUntil   time.Time   // When the response goes stale, zero without caching headers
+	Metrics      PageMetrics // Size and timing of the fetch
 }
 
 type crawlTask struct {
-	URL   string
-	Depth int
+	URL    string
+	Depth  int
+	Source string  // Page the URL was found on, empty for the start URL
+	Score  float64 // Set in focused crawls
+
+	status *workerStatus // Of the worker handling the task
 }
 
-func NewCrawler(maxWorkers, maxDepth int, crawlDelay time.Duration) *Crawler {
-	return &Crawler{
-		maxWorkers:  maxWorkers,
-		maxDepth:    maxDepth,
-		crawlDelay:  crawlDelay,
-		userAgent:   "GoCrawler/1.0",
-		httpClient:  &http.Client{Timeout: 10 * time.Second},
-		visitedURLs: &sync.Map{},
-		urlsToCrawl: make(chan crawlTask, 1000),
-		results:     make(chan CrawlResult, 1000),
-		robotsMap:   &sync.Map{},
+// Option configures optional crawler behaviour
+type Option func(*Crawler)
+
+// WithRateLimiter shares a request rate limit with other crawlers
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(c *Crawler) {
+		c.rateLimiter = l
 	}
 }
 
+// WithURLFilter skips discovered links for which keep returns false
+func WithURLFilter(keep func(*url.URL) bool) Option {
+	return func(c *Crawler) {
+		c.urlFilter = keep
+	}
+}
+
+// WithTransport sends every request the crawler makes, including for
+// robots.txt, through t instead of the network. It takes precedence over
+// WithProxy and WithResolver.
+func WithTransport(t http.RoundTripper) Option {
+	return func(c *Crawler) {
+		c.transport = t
+	}
+}
+
+func NewCrawler(maxWorkers, maxDepth int, crawlDelay time.Duration, opts ...Option) *Crawler {
+	c := &Crawler{
+		maxWorkers: maxWorkers,
+		maxDepth:   maxDepth,
+		crawlDelay: crawlDelay,
+		userAgent:  "GoCrawler/1.0",
+		requeues:   1,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		throttle:   newThrottleRegistry(),
+		parsers:    defaultParsers(),
+	}
+	c.httpClient.CheckRedirect = c.checkRedirect
+	c.maxRedirects = DefaultMaxRedirects
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.events == nil {
+		c.events = NewEventBus()
+	}
+	if c.journey != nil {
+		c.httpClient.Jar = newCookieJar()
+	}
+	if c.transport != nil {
+		c.httpClient.Transport = c.transport
+	} else if c.proxy != nil {
+		c.httpClient.Transport = proxyTransport(c.proxy)
+	} else if c.resolver != nil {
+		c.httpClient.Transport = c.resolver.transport()
+	}
+	if c.httpRecorder != nil {
+		c.httpClient.Transport = c.httpRecorder.transport(c.httpClient.Transport)
+	}
+	c.newRun()
+	return c
+}
+
 func (c *Crawler) Start(ctx context.Context, startURL string) <-chan CrawlResult {
+	if u, err := url.Parse(startURL); err == nil && u.Host != "" {
+		normalizeURL(u)
+		startURL = u.String()
+	}
+	return c.start(ctx, startURL, []crawlTask{{URL: startURL, Depth: 0}})
+}
+
+// start launches the workers and seeds the queue with tasks
+func (c *Crawler) start(ctx context.Context, startURL string, seeds []crawlTask) <-chan CrawlResult {
+	if err := c.beginRun(); err != nil {
+		return usedResults(startURL)
+	}
+	c.setStartURL(startURL)
+	if c.frontierDir != "" && c.scored == nil {
+		q, err := openDiskQueue(c.frontierDir, startURL)
+		if err != nil {
+			log.Printf("Warning: %v; keeping the frontier in memory", err)
+		} else {
+			c.frontierMu.Lock()
+			c.disk = q
+			c.hosts = nil
+			c.frontierMu.Unlock()
+		}
+	}
+
 	// Start worker goroutines
-	for i := 0; i < c.maxWorkers; i++ {
+	c.frontierMu.Lock()
+	workers := make([]*workerStatus, c.maxWorkers)
+	for i := range workers {
+		workers[i] = &workerStatus{}
+	}
+	c.workerStatus = workers
+	c.liveWorkers = len(workers)
+	c.workerCtx = ctx
+	c.frontierMu.Unlock()
+	for _, status := range workers {
 		c.wg.Add(1)
-		go c.worker(ctx)
+		go c.worker(ctx, status)
 	}
 
 	// Start the crawling process
+	dispatched := make(chan struct{})
+	if c.scored != nil {
+		for _, task := range seeds {
+			c.enqueue(task)
+		}
+		go c.dispatchScored(dispatched)
+	} else if c.disk != nil {
+		if c.disk.recovered > 0 {
+			// Continue where the crawl stopped instead of from the seeds
+			for u := range c.disk.finished {
+				c.visited.Add(c.visitKey(u))
+			}
+			c.fetched.Store(int64(len(c.disk.finished)))
+			c.pending.Add(c.disk.unfinished)
+		} else {
+			for _, task := range seeds {
+				c.enqueue(task)
+			}
+		}
+		go c.dispatchDisk(dispatched)
+	} else {
+		// Seeds are queued even beyond a host's cap
+		c.pending.Add(len(seeds))
+		for _, task := range seeds {
+			c.trackTask(task)
+			c.hosts.push(task, false)
+		}
+		go c.dispatchHosts(dispatched)
+	}
+
+	// Close the queue once every task has been processed so idle workers exit
+	go func() {
+		c.pending.Wait()
+		if c.scored != nil {
+			c.scored.close()
+		}
+		if c.disk != nil {
+			c.disk.close()
+		}
+		if c.hosts != nil {
+			c.hosts.close()
+		}
+		<-dispatched
+		close(c.urlsToCrawl)
+	}()
+
+	results := c.results
 	go func() {
-		c.urlsToCrawl <- crawlTask{URL: startURL, Depth: 0}
 		c.wg.Wait()
-		close(c.results)
+		if c.disk != nil {
+			c.disk.release(ctx.Err() == nil)
+		}
+		c.closeProcessors()
+		// Done before closing, so Reset works as soon as results are drained
+		c.runState.Store(runDone)
+		close(results)
 	}()
 
-	return c.results
+	return results
 }
 
-func (c *Crawler) worker(ctx context.Context) {
+// setStartURL records the URL a crawl starts from, which scopes same-host
+// crawls and basic auth
+func (c *Crawler) setStartURL(startURL string) {
+	c.startURL = startURL
+	if u, err := url.Parse(startURL); err == nil {
+		c.startHost = c.hostPolicy.Host(u)
+		c.authHost = u.Host
+	}
+}
+
+func (c *Crawler) worker(ctx context.Context, status *workerStatus) {
 	defer c.wg.Done()
+	defer c.workerExited()
 
-	for {
-		select {
-		case <-ctx.Done():
+	for task := range c.urlsToCrawl {
+		task.status = status
+		status.begin(task.URL)
+		requeue := c.handleTask(ctx, task)
+		status.end()
+		if c.disk != nil && ctx.Err() == nil && !requeue {
+			c.disk.markDone(task.URL)
+		}
+		if c.hosts != nil {
+			c.hosts.release(taskHost(task.URL))
+		}
+		if requeue {
+			// Counted before this task is done, so the crawl can't end
+			// in between
+			c.pending.Add(1)
+		}
+		c.taskDone(task)
+		if requeue {
+			c.push(task)
+		}
+		if c.retire(status) {
 			return
-		case task, ok := <-c.urlsToCrawl:
-			if !ok {
-				return
+		}
+	}
+}
+
+// handleTask fetches a single queued URL and queues the links it finds. It
+// returns true when the fetch failed transiently and the URL should be
+// queued again.
+func (c *Crawler) handleTask(ctx context.Context, task crawlTask) bool {
+	// Drain remaining tasks without fetching once the crawl is cancelled
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// Skip URLs fetched already or being fetched by another worker. The
+	// claim comes first, so that a worker finishing the URL has marked it
+	// visited by the time the claim is free.
+	key := c.visitKey(task.URL)
+	if _, claimed := c.claimed.LoadOrStore(key, struct{}{}); claimed {
+		c.skip(task.URL, task.Source, SkipDuplicate, "")
+		return false
+	}
+	defer c.claimed.Delete(key)
+	if c.visited.Contains(key) {
+		c.skip(task.URL, task.Source, SkipDuplicate, "")
+		return false
+	}
+
+	// Drop queued URLs excluded after they were queued
+	if c.excluding() {
+		if u, err := url.Parse(task.URL); err == nil {
+			if pattern, ok := c.excluded(u); ok {
+				c.skip(task.URL, task.Source, SkipExcluded, pattern)
+				return false
 			}
+		}
+	}
 
-			// Respect crawl delay
-			time.Sleep(c.crawlDelay)
+	// Stop fetching once the page budget is used up
+	if c.maxPages > 0 && c.fetched.Add(1) > c.maxPages {
+		c.skip(task.URL, task.Source, SkipBudget, fmt.Sprintf("max pages %d", c.maxPages))
+		return false
+	}
 
-			// Process the URL
-			links, err := c.processURL(task.URL)
+	// Pause outside the allowed time windows
+	task.stage(stageWindow)
+	if err := c.waitForWindow(ctx); err != nil {
+		return false
+	}
 
+	// Respect crawl delay
+	task.stage(stageDelay)
+	if err := c.politeDelay(ctx); err != nil {
+		return false
+	}
 
-			// Send result
-			c.results <- CrawlResult{
-				URL:   task.URL,
-				Links: links,
-				Error: err,
-			}
+	// Respect the shared rate limit
+	if c.rateLimiter != nil {
+		task.stage(stageRateLimit)
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			return false
+		}
+	}
 
-			// Queue up new URLs if we haven't reached max depth
-			if task.Depth < c.maxDepth && err == nil {
-				c.queueLinks(task.URL, links, task.Depth+1)
+	// Process the URL
+	result := CrawlResult{URL: task.URL, Depth: task.Depth, Score: task.Score}
+	err := c.processURL(ctx, task, &result)
+	if c.retryLater(key, err) {
+		log.Printf("Fetching %s failed, queueing it again: %v", task.URL, err)
+		c.emit(Event{Type: EventRetryScheduled, URL: task.URL, Source: task.Source, Depth: task.Depth, Reason: "requeue", Detail: err.Error()})
+		if c.maxPages > 0 {
+			c.fetched.Add(-1) // Charged again on the next attempt
+		}
+		return true
+	}
+	c.visited.Add(key)
+	c.failures.Delete(key)
+	if errors.Is(err, ErrFiltered) {
+		c.skip(task.URL, task.Source, SkipFilter, err.Error())
+		return false
+	}
+	if err != nil && c.errorPolicy.Action(Classify(err)) == ActionSkip {
+		if errors.Is(err, ErrNonHTML) {
+			// Fetched fine, just not parsed for links
+			err = nil
+		} else {
+			// robots.txt disallows are already reported as skipped
+			if !errors.Is(err, ErrRobotsDisallowed) {
+				c.skip(task.URL, task.Source, SkipError, err.Error())
 			}
+			return false
 		}
 	}
+	result.Error = err
+	c.processResult(result)
+
+	// Send result
+	c.emit(Event{Type: EventPageFetched, URL: result.URL, Source: task.Source, Depth: result.Depth, Result: &result})
+	c.results <- result
+
+	// Queue up new URLs; links beyond max depth are only reported. Refresh
+	// mode only revisits the URLs it was given.
+	if err == nil && c.revisit == nil {
+		task.stage(stageLinks)
+		c.queuePageLinks(task, result)
+	}
+
+	if err == nil && c.checkAssets {
+		task.stage(stageAssets)
+		c.checkPageAssets(ctx, task.URL, result.assets)
+	}
+
+	if err == nil && c.checkExternal > 0 {
+		task.stage(stageExternal)
+		c.checkExternalLinks(ctx, task.URL, result.Links)
+	}
+
+	if err == nil && c.checkAlternates {
+		task.stage(stageAlternates)
+		c.checkPageAlternates(ctx, task.URL, result.Alternates)
+	}
+	return false
 }
 
-func (c *Crawler) processURL(urlStr string) ([]string, error) {
-	// Check if we've already visited this URL
-	if _, loaded := c.visitedURLs.LoadOrStore(urlStr, struct{}{}); loaded {
-		return nil, nil
+// queuePageLinks queues the URLs a fetched page leads to
+func (c *Crawler) queuePageLinks(task crawlTask, result CrawlResult) {
+	if !result.UnavailableAfter.IsZero() && time.Now().After(result.UnavailableAfter) {
+		log.Printf("%s expired on %s, not following its links", task.URL, result.UnavailableAfter.Format(time.RFC3339))
+		return
 	}
+	if !c.languageAllowed(result.Language) {
+		log.Printf("%s is in %s, not following its links", task.URL, result.Language)
+		return
+	}
+	if c.followRefresh && result.MetaRefresh != nil {
+		c.followMetaRefresh(task, result.MetaRefresh.URL)
+	}
+	if c.followCanonical && result.Canonical != "" {
+		// Treat the page as a duplicate of its canonical URL and crawl
+		// that instead, as if it had redirected there
+		c.queueLinks(task.URL, []string{result.Canonical}, nil, task.Depth)
+		return
+	}
+	c.queueLinks(task.URL, result.Links, result.anchors, task.Depth+1)
+	c.queueLinks(task.URL, result.Feeds, nil, task.Depth)
+	if c.crawlAlternates {
+		c.queueLinks(task.URL, alternateURLs(result.Alternates), nil, task.Depth)
+	}
+}
+
+// processURL fetches a URL, filling in the result's response details and
+// the links found on HTML pages
+func (c *Crawler) processURL(ctx context.Context, task crawlTask, result *CrawlResult) error {
+	urlStr := task.URL
 
 	// Parse the URL
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid URL %s: %v", urlStr, err)
+		return fmt.Errorf("%w %s: %v", ErrInvalidURL, urlStr, err)
+	}
+
+	// In refresh mode, don't refetch pages that are still fresh
+	if entry, ok := c.revisit[urlStr]; ok && c.skipFresh && entry.FreshUntil.After(time.Now()) {
+		result.Change = ChangeFresh
+		result.ETag, result.LastModified, result.ContentHash = entry.ETag, entry.LastModified, entry.ContentHash
+		result.FreshUntil = entry.FreshUntil
+		return nil
 	}
 
 	// Check robots.txt rules
+	task.stage(stageRobots)
 	robotsRules, err := c.getRobotsRules(parsedURL)
 	if err != nil {
-		return nil, fmt.Errorf("error getting robots.txt rules: %v", err)
+		return fmt.Errorf("error getting robots.txt rules: %w", err)
 	}
 
 	// Check if this URL is allowed by robots.txt
-	if !robotsRules.IsAllowed(urlStr) {
-		return nil, fmt.Errorf("disallowed by robots.txt: %s", urlStr)
+	if rule, allowed := c.checkRobots(robotsRules, urlStr); !allowed {
+		c.skip(urlStr, task.Source, SkipRobots, "Disallow: "+rule)
+		return fmt.Errorf("%w: %s", ErrRobotsDisallowed, urlStr)
 	}
 
+	// Respect the per-host concurrency limit
+	task.stage(stageHostSlot)
+	release, err := c.acquireHost(ctx, parsedURL.Host)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Respect crawl delay
+	task.stage(stageCrawlDelay)
 	robotsRules.Wait()
+	task.stage(stageFetching)
 
 	// Set User-Agent header
-	req, err := http.NewRequest("GET", urlStr, nil)
+	fetchURL := urlStr
+	if c.hashbangRoutes && isHashbang(parsedURL) {
+		fetchURL = escapedFragmentURL(parsedURL)
+	}
+	req, err := http.NewRequest("GET", fetchURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
+		return fmt.Errorf("error creating request: %w: %v", ErrInvalidURL, err)
 	}
 	req.Header.Set("User-Agent", c.userAgent)
 
-	// Fetch the URL
-	resp, err := c.httpClient.Do(req)
+	// In refresh mode, ask the server whether the page changed
+	entry, revisiting := c.revisit[urlStr]
+	if revisiting {
+		setConditionalHeaders(req, entry)
+	}
+
+	// Fetch the URL, timing it
+	timer := &fetchTimer{}
+	resp, err := c.doWithRetries(timer.trace(ctx), req)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching %s: %v", urlStr, err)
+		return fmt.Errorf("error fetching %s: %w", urlStr, requestFailed(err))
 	}
 	defer resp.Body.Close()
+	var bodyBytes atomic.Int64
+	defer func() {
+		result.Metrics.TTFB = timer.ttfb()
+		result.Metrics.Download = time.Since(timer.startedAt())
+		result.Metrics.Bytes = bodyBytes.Load()
+		// Pages that weren't parsed were read no further than sniffing
+		if resp.ContentLength > result.Metrics.Bytes {
+			result.Metrics.Bytes = resp.ContentLength
+		}
+	}()
+
+	result.StatusCode = resp.StatusCode
+	result.ContentType = resp.Header.Get("Content-Type")
+	result.ETag = resp.Header.Get("ETag")
+	result.LastModified = resp.Header.Get("Last-Modified")
+	result.FreshUntil = freshUntil(resp.Header, time.Now())
+
+	if revisiting {
+		if state, done := revalidationState(resp.StatusCode); done {
+			result.Change = state
+			if state == ChangeUnchanged {
+				// Keep the validators and hash from the previous crawl
+				result.ETag, result.LastModified, result.ContentHash = entry.ETag, entry.LastModified, entry.ContentHash
+				return nil
+			}
+		}
+	}
 
 	// Check response status
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := parseAuthChallenge(resp.Header.Get("WWW-Authenticate"))
+		result.Challenge = &challenge
+		return authError(urlStr, challenge)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, urlStr)
+		if err := checkChallenge(resp, urlStr); err != nil {
+			return err
+		}
+		return &ErrStatus{Code: resp.StatusCode, URL: urlStr}
+	}
+
+	var body io.Reader = &countingReader{r: resp.Body, n: &c.bytesRead}
+	body = &countingReader{r: body, n: &bodyBytes}
+	if c.bandwidth != nil {
+		body = c.bandwidth.Reader(ctx, body)
 	}
 
-	// Only process HTML content
-	contentType := resp.Header.Get("Content-Type")
-	if !strings.Contains(contentType, "text/html") {
-		return nil, nil
+	// Parse HTML pages as HTML and documents with a link parser, such as
+	// feeds, with it. Responses labelled otherwise, generically or not at
+	// all are sniffed: HTML is parsed as HTML, other types by their parser.
+	var parser LinkParser
+	if !isHTML(result.ContentType) {
+		parser = c.parserFor(result.ContentType, parsedURL.Path)
+		if parser == nil || isGenericType(mediaType(result.ContentType)) {
+			var sniffed string
+			if body, sniffed, err = sniffContent(body); err != nil {
+				return fmt.Errorf("error reading %s: %w", urlStr, requestFailed(err))
+			}
+			switch {
+			case isHTML(sniffed):
+				parser, result.SniffedType = nil, sniffed
+			case parser == nil:
+				if parser = c.parsers[mediaType(sniffed)]; parser == nil {
+					if revisiting {
+						result.Change = ChangeChanged
+					}
+					if result.ContentType == "" {
+						return fmt.Errorf("%w: %s has no Content-Type and looks like %s", ErrNonHTML, urlStr, sniffed)
+					}
+					return fmt.Errorf("%w: %s is %s", ErrNonHTML, urlStr, result.ContentType)
+				}
+				result.SniffedType = sniffed
+			}
+		}
 	}
 
-	// Parse the HTML to extract links
-	return extractLinks(resp.Body, urlStr)
+	// Read the body into a pooled buffer, then parse and hash it from there
+	raw := getBuffer()
+	defer putBuffer(raw)
+	if _, err := raw.ReadFrom(body); err != nil {
+		return fmt.Errorf("error reading %s: %w", urlStr, requestFailed(err))
+	}
+	if parser == nil {
+		// Some challenges are served with 200 OK
+		if provider := detectChallenge(resp.StatusCode, resp.Header, raw.Bytes()); provider != "" {
+			return &ChallengeError{Provider: provider, Code: resp.StatusCode, URL: urlStr}
+		}
+	}
+	robots := resp.Header.Values("X-Robots-Tag")
+	if parser != nil {
+		result.Links, err = parser(raw.Bytes())
+	} else {
+		var page *pageLinks
+		if page, err = extractLinks(bytes.NewReader(raw.Bytes()), urlStr); err == nil {
+			if c.scriptLinks {
+				page.addScriptLinks()
+			}
+			result.Links, result.anchors, result.assets = page.links, page.anchors, page.assets
+			result.Metrics.countAssets(urlStr, page.assets)
+			if c.discoverFeeds {
+				result.Feeds = page.feeds
+			}
+			result.Canonical = c.redirectTarget(parsedURL, page.canonical)
+			result.MetaRefresh = c.metaRefresh(parsedURL, page.refresh)
+			result.Alternates = resolveAlternates(parsedURL, page.alternates)
+			result.Language = pageLanguage(page.doc, resp.Header.Get("Content-Language"))
+			result.Title, result.MetaDescription, result.H1Count = page.title, page.descr, page.h1Count
+			robots = append(robots, page.robots...)
+			if len(c.search) > 0 {
+				result.Matches = searchText(c.search, pageText(page.doc))
+			}
+			if c.extractText {
+				result.Text = mainText(page.doc)
+				result.WordCount, result.textExtracted = CountWords(result.Text), true
+			}
+			if c.checkAccessibility {
+				result.Accessibility = checkAccessibility(page.doc)
+			}
+			if c.checkHTML {
+				result.HTMLWarnings = checkHTML(raw.Bytes(), page.doc)
+			}
+			if len(c.textProcessors) > 0 {
+				result.Findings = c.processText(PageText{
+					URL:      urlStr,
+					Title:    result.Title,
+					Language: result.Language,
+					Text:     visibleText(page.doc),
+				})
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	c.capLinks(result)
+	result.UnavailableAfter = unavailableAfter(robots)
+	hash := sha256.Sum256(raw.Bytes())
+	result.ContentHash = hex.EncodeToString(hash[:])
+	if len(c.processors) > 0 && parser == nil {
+		// Copied out, as the buffer goes back to the pool
+		result.Fields = c.extractFields(Page{
+			URL:         urlStr,
+			StatusCode:  result.StatusCode,
+			ContentType: result.ContentType,
+			Body:        raw.String(),
+		})
+	}
+
+	if revisiting {
+		result.Change = ChangeChanged
+		if entry.ContentHash != "" && entry.ContentHash == result.ContentHash {
+			result.Change = ChangeUnchanged
+		}
+	}
+	return nil
 }
 
 // UserAgent returns the User-Agent string used by the crawler
@@ -165,16 +788,11 @@ func (c *Crawler) UserAgent() string {
 
 // VisitedCount returns the number of unique URLs visited by the crawler
 func (c *Crawler) VisitedCount() int {
-	count := 0
-	c.visitedURLs.Range(func(_, _ interface{}) bool {
-		count++
-		return true
-	})
-	return count
+	return c.visited.Len()
 }
 
-func (c *Crawler) queueLinks(baseURL string, links []string, depth int) {
-	for _, link := range links {
+func (c *Crawler) queueLinks(baseURL string, links, anchors []string, depth int) {
+	for i, link := range links {
 		// Convert relative URLs to absolute
 		absURL, err := resolveURL(baseURL, link)
 		if err != nil {
@@ -185,49 +803,253 @@ func (c *Crawler) queueLinks(baseURL string, links []string, depth int) {
 		if absURL.Scheme != "http" && absURL.Scheme != "https" {
 			continue
 		}
+		normalizeURL(absURL)
+		c.mapHost(absURL)
+		c.emit(Event{Type: EventLinkDiscovered, URL: absURL.String(), Host: absURL.Host, Source: baseURL, Depth: depth})
+
+		// Let processors reject or rewrite the link
+		if len(c.processors) > 0 {
+			var ok bool
+			if absURL, ok = c.followLink(absURL, baseURL, depth); !ok {
+				continue
+			}
+		}
+
+		if !c.admit(absURL, baseURL, depth) {
+			continue
+		}
 
 		// Queue the URL for crawling
-		select {
-		case c.urlsToCrawl <- crawlTask{URL: absURL.String(), Depth: depth}:
-		default:
-			log.Printf("Warning: URL queue full, dropping %s", absURL)
+		task := crawlTask{URL: absURL.String(), Depth: depth, Source: baseURL}
+		if c.scorer != nil {
+			anchor := ""
+			if i < len(anchors) {
+				anchor = anchors[i]
+			}
+			task.Score = c.scorer.Score(task.URL, anchor, depth, baseURL)
 		}
+		c.enqueue(task)
 	}
 }
 
-func extractLinks(body io.Reader, baseURL string) ([]string, error) {
+// admit applies the crawl's scope and limits to a discovered link,
+// reporting the reason when it is skipped
+func (c *Crawler) admit(absURL *url.URL, baseURL string, depth int) bool {
+	// Skip URLs that break the length or segment repetition caps
+	if detail, ok := c.checkURLLimits(absURL); !ok {
+		c.skip(absURL.String(), baseURL, SkipURLLimit, detail)
+		return false
+	}
+
+	// Skip URLs excluded since the crawl started
+	if pattern, ok := c.excluded(absURL); ok {
+		c.skip(absURL.String(), baseURL, SkipExcluded, pattern)
+		return false
+	}
+
+	// Skip URLs outside the start host when scoped to it, or off the start
+	// site or on subdomains the policy excludes
+	if off, detail := c.offSite(absURL); off {
+		c.skip(absURL.String(), baseURL, SkipOffDomain, detail)
+		return false
+	}
+
+	// Skip URLs rejected by the caller's filter
+	if c.urlFilter != nil && !c.urlFilter(absURL) {
+		c.skip(absURL.String(), baseURL, SkipFilter, "")
+		return false
+	}
+	if c.journey != nil && c.journey.skips(absURL) {
+		c.skip(absURL.String(), baseURL, SkipFilter, "skipped by the journey")
+		return false
+	}
+
+	// Skip URLs we've already visited
+	if c.visited.Contains(c.hostPolicy.Key(absURL)) {
+		c.skip(absURL.String(), baseURL, SkipDuplicate, "")
+		return false
+	}
+
+	// Don't go deeper than the max depth
+	if depth > c.maxDepth {
+		c.skip(absURL.String(), baseURL, SkipDepth, fmt.Sprintf("max depth %d", c.maxDepth))
+		return false
+	}
+
+	// Skip URLs that fall into a detected crawl trap
+	if c.traps != nil {
+		if trap, trapped := c.traps.check(absURL); trapped {
+			c.skip(absURL.String(), baseURL, SkipTrap, trap.Kind+": "+trap.Pattern)
+			return false
+		}
+	}
+
+	// Don't queue more pages than the budget allows
+	if c.maxPages > 0 && c.fetched.Load() >= c.maxPages {
+		c.skip(absURL.String(), baseURL, SkipBudget, fmt.Sprintf("max pages %d", c.maxPages))
+		return false
+	}
+	return true
+}
+
+// enqueue adds a task to the queue, dropping it (or, in a focused crawl,
+// the lowest-scored task) when the queue, or its host's share of it, is
+// full
+func (c *Crawler) enqueue(task crawlTask) {
+	c.pending.Add(1)
+	c.push(task)
+}
+
+// push adds a task counted as pending to the frontier
+func (c *Crawler) push(task crawlTask) {
+	c.trackTask(task)
+
+	if c.scored != nil {
+		if dropped, full := c.scored.push(task); full {
+			c.taskDone(dropped)
+			c.skip(dropped.URL, dropped.Source, SkipQueueFull, fmt.Sprintf("score %.2f", dropped.Score))
+		}
+		return
+	}
+	if c.disk != nil {
+		if err := c.disk.push(task); err != nil {
+			c.taskDone(task)
+			c.skip(task.URL, task.Source, SkipQueueFull, err.Error())
+		}
+		return
+	}
+
+	if !c.hosts.push(task, true) {
+		c.taskDone(task)
+		c.skip(task.URL, task.Source, SkipQueueFull, "")
+		log.Printf("Warning: URL queue full for %s, dropping %s", taskHost(task.URL), task.URL)
+	}
+}
+
+// pageLinks is what extractLinks finds in an HTML page
+type pageLinks struct {
+	links      []string
+	anchors    []string    // Anchor text of each link
+	feeds      []string    // <link rel="alternate"> feeds
+	canonical  string      // <link rel="canonical"> target
+	refresh    string      // First <meta http-equiv="refresh"> content
+	alternates []Alternate // AMP and mobile versions, unresolved
+	robots     []string    // <meta name="robots"> contents
+	assets     []pageAsset
+	title      string // First <title>
+	descr      string // <meta name="description"> content
+	h1Count    int
+	doc        *html.Node
+}
+
+func extractLinks(body io.Reader, baseURL string) (*pageLinks, error) {
 	doc, err := html.Parse(body)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing HTML: %v", err)
 	}
 
-	var links []string
+	page := &pageLinks{doc: doc}
 	var f func(*html.Node)
 
 	f = func(n *html.Node) {
 		if n.Type == html.ElementNode && n.Data == "a" {
 			for _, a := range n.Attr {
 				if a.Key == "href" {
-					links = append(links, a.Val)
+					page.links = append(page.links, a.Val)
+					page.anchors = append(page.anchors, nodeText(n))
 					break
 				}
 			}
 		}
+		if n.Type == html.ElementNode && n.Data == "link" {
+			rel, typ, href, media := attr(n, "rel"), attr(n, "type"), attr(n, "href"), attr(n, "media")
+			rels := strings.Fields(strings.ToLower(rel))
+			switch {
+			case href == "":
+			case hasField(rels, "canonical"):
+				page.canonical = href
+			case hasField(rels, "stylesheet"):
+				page.assets = append(page.assets, pageAsset{AssetStylesheet, href})
+			case hasField(rels, "alternate") && isFeedLink(typ):
+				page.feeds = append(page.feeds, href)
+			case hasField(rels, "amphtml"):
+				page.alternates = append(page.alternates, Alternate{AlternateAMP, href})
+			case hasField(rels, "alternate") && isMobileAlternate(media):
+				page.alternates = append(page.alternates, Alternate{AlternateMobile, href})
+			}
+		}
+		if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "img") {
+			if src := attr(n, "src"); src != "" {
+				kind := AssetScript
+				if n.Data == "img" {
+					kind = AssetImage
+				}
+				page.assets = append(page.assets, pageAsset{kind, src})
+			}
+		}
+		if n.Type == html.ElementNode && n.Data == "meta" && strings.EqualFold(attr(n, "name"), "robots") {
+			page.robots = append(page.robots, attr(n, "content"))
+		}
+		if n.Type == html.ElementNode && n.Data == "meta" && strings.EqualFold(attr(n, "http-equiv"), "refresh") && page.refresh == "" {
+			page.refresh = attr(n, "content")
+		}
+		if n.Type == html.ElementNode && n.Data == "meta" && strings.EqualFold(attr(n, "name"), "description") {
+			page.descr = strings.Join(strings.Fields(attr(n, "content")), " ")
+		}
+		if n.Type == html.ElementNode && n.Data == "title" && n.Namespace == "" && page.title == "" {
+			page.title = nodeText(n)
+		}
+		if n.Type == html.ElementNode && n.Data == "h1" {
+			page.h1Count++
+		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
 			f(c)
 		}
 	}
 
 	f(doc)
-	return links, nil
+	return page, nil
+}
+
+// nodeText returns the text inside a node with whitespace collapsed
+func nodeText(n *html.Node) string {
+	return collapsedText(n, nil)
+}
+
+// attr returns the value of an element's attribute, or "" if it is unset
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasField(fields []string, want string) bool {
+	for _, f := range fields {
+		if f == want {
+			return true
+		}
+	}
+	return false
+}
+
+// RobotsRules returns the robots.txt rules that apply to a URL's host
+func (c *Crawler) RobotsRules(urlStr string) (*RobotRules, error) {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %v", urlStr, err)
+	}
+	return c.getRobotsRules(parsedURL)
 }
 
 // getRobotsRules fetches and caches robots.txt rules for a domain
 func (c *Crawler) getRobotsRules(parsedURL *url.URL) (*RobotRules, error) {
-	// Use the host as the cache key
-	host := parsedURL.Hostname()
-	if host == "" {
-		return nil, fmt.Errorf("invalid host in URL: %s", parsedURL.String())
+	// Use the host (including any port) as the cache key
+	host := parsedURL.Host
+	if parsedURL.Hostname() == "" {
+		return nil, fmt.Errorf("%w: no host in %s", ErrInvalidURL, parsedURL.String())
 	}
 
 	// Check if we already have rules for this domain
@@ -240,11 +1062,13 @@ func (c *Crawler) getRobotsRules(parsedURL *url.URL) (*RobotRules, error) {
 
 	// Try to fetch robots.txt
 	robotsURL := fmt.Sprintf("%s://%s/robots.txt", parsedURL.Scheme, host)
+	rules.robotsURL = robotsURL
 	req, err := http.NewRequest("GET", robotsURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error creating robots.txt request: %v", err)
+		return nil, fmt.Errorf("error creating robots.txt request: %w: %v", ErrInvalidURL, err)
 	}
 	req.Header.Set("User-Agent", c.userAgent)
+	c.setAuth(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -253,13 +1077,15 @@ func (c *Crawler) getRobotsRules(parsedURL *url.URL) (*RobotRules, error) {
 		return rules, nil
 	}
 	defer resp.Body.Close()
+	rules.statusCode = resp.StatusCode
 
 	// Only parse if we got a successful response
 	if resp.StatusCode == http.StatusOK {
-		content, err := io.ReadAll(resp.Body)
-		if err == nil {
-			rules.Parse(robotsURL, string(content))
+		content := getBuffer()
+		if _, err := content.ReadFrom(resp.Body); err == nil {
+			rules.Parse(robotsURL, content.String())
 		}
+		putBuffer(content)
 	}
 
 	// Cache the rules (even if empty or failed to parse)
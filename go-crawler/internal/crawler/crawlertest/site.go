@@ -0,0 +1,227 @@
+// Package crawlertest provides an in-memory web site for deterministic tests
+// of code that embeds the crawler, without starting HTTP servers
+package crawlertest
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go-crawler/internal/crawler"
+)
+
+// defaultRobots is served for hosts without a robots.txt of their own. It
+// allows everything and waives the default one second crawl delay so that
+// crawls of a Site run at full speed.
+const defaultRobots = "User-agent: *\nCrawl-delay: 0\n"
+
+// Response is what a Site answers for a URL
+type Response struct {
+	Status int // 200 when zero
+	Header http.Header
+	Body   string
+}
+
+// Site is an in-memory web of pages that a Crawler fetches through its
+// transport. Pages are added by path, relative to the site's base URL, or
+// by absolute URL to put them on other hosts. Fetching a URL that wasn't
+// added returns 404 on a host the site has pages on, and a DNS error on
+// any other host.
+//
+// Sites are safe for concurrent use. For results in a fixed order, crawl
+// with a single worker.
+type Site struct {
+	base *url.URL
+
+	mu        sync.Mutex
+	responses map[string]Response // By absolute URL without fragment
+	hosts     map[string]bool
+	requests  []string
+}
+
+// NewSite returns an empty site at baseURL, e.g. "https://example.com/". It
+// panics if baseURL is not an absolute http(s) URL.
+func NewSite(baseURL string) *Site {
+	base, err := url.Parse(baseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		panic(fmt.Sprintf("crawlertest: invalid base URL %q", baseURL))
+	}
+	return &Site{
+		base:      base,
+		responses: make(map[string]Response),
+		hosts:     map[string]bool{strings.ToLower(base.Host): true},
+	}
+}
+
+// URL returns the absolute URL of a path on the site
+func (s *Site) URL(path string) string {
+	u, err := s.base.Parse(path)
+	if err != nil {
+		panic(fmt.Sprintf("crawlertest: invalid path %q", path))
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// Handle makes the site answer path with r
+func (s *Site) Handle(path string, r Response) *Site {
+	u, err := url.Parse(s.URL(path))
+	if err != nil {
+		panic(fmt.Sprintf("crawlertest: invalid path %q", path))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[u.String()] = r
+	s.hosts[strings.ToLower(u.Host)] = true
+	return s
+}
+
+// HTML adds an HTML page with the given markup
+func (s *Site) HTML(path, body string) *Site {
+	return s.Handle(path, Response{
+		Header: http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:   body,
+	})
+}
+
+// Page adds an HTML page with a title and a link to each of links, which
+// may be relative to the page
+func (s *Site) Page(path, title string, links ...string) *Site {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html><head><title>%s</title></head><body>\n<h1>%s</h1>\n", html.EscapeString(title), html.EscapeString(title))
+	for _, link := range links {
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(link), html.EscapeString(link))
+	}
+	b.WriteString("</body></html>\n")
+	return s.HTML(path, b.String())
+}
+
+// Redirect makes path redirect to target with a 3xx status, 301 when zero
+func (s *Site) Redirect(path, target string, status int) *Site {
+	if status == 0 {
+		status = http.StatusMovedPermanently
+	}
+	return s.Handle(path, Response{Status: status, Header: http.Header{"Location": {target}}})
+}
+
+// Error makes path answer with an error status and its status text
+func (s *Site) Error(path string, status int) *Site {
+	return s.Handle(path, Response{
+		Status: status,
+		Header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:   http.StatusText(status) + "\n",
+	})
+}
+
+// Robots sets the robots.txt of the site's base host. Note that without a
+// Crawl-delay line the crawler waits a second between requests.
+func (s *Site) Robots(body string) *Site {
+	return s.Handle("/robots.txt", Response{
+		Header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:   body,
+	})
+}
+
+// Requests returns the requests the site answered, in order, as
+// "METHOD URL"
+func (s *Site) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Reset forgets the requests answered so far
+func (s *Site) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// Option returns the crawler option that fetches through the site
+func (s *Site) Option() crawler.Option {
+	return crawler.WithTransport(s)
+}
+
+// RoundTrip answers a request from the site's pages
+func (s *Site) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	u := *req.URL
+	u.Fragment = ""
+	key := u.String()
+	host := strings.ToLower(u.Host)
+
+	s.mu.Lock()
+	r, ok := s.responses[key]
+	known := s.hosts[host]
+	if known {
+		s.requests = append(s.requests, req.Method+" "+key)
+	}
+	s.mu.Unlock()
+
+	if !known {
+		return nil, &net.DNSError{Err: "no such host", Name: u.Hostname(), IsNotFound: true}
+	}
+	if !ok {
+		switch {
+		case u.Path == "/robots.txt":
+			r = Response{Header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}}, Body: defaultRobots}
+		default:
+			r = Response{Status: http.StatusNotFound, Header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}}, Body: "Not Found\n"}
+		}
+	}
+	return r.response(req), nil
+}
+
+// response builds the HTTP response to req
+func (r Response) response(req *http.Request) *http.Response {
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if header.Get("Content-Type") == "" && r.Body != "" {
+		header.Set("Content-Type", "text/html; charset=utf-8")
+	}
+
+	body := r.Body
+	if req.Method == http.MethodHead {
+		body = ""
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// Crawl runs a crawl of the site from path with c and returns every result,
+// in the order they arrived. It is a shortcut for tests that look at the
+// results as a whole.
+func (s *Site) Crawl(ctx context.Context, c *crawler.Crawler, path string) []crawler.CrawlResult {
+	var results []crawler.CrawlResult
+	for result := range c.Start(ctx, s.URL(path)) {
+		results = append(results, result)
+	}
+	return results
+}
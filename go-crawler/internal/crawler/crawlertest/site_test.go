@@ -0,0 +1,162 @@
+package crawlertest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"go-crawler/internal/crawler"
+)
+
+// get fetches u from site, returning the status and body
+func get(t *testing.T, site *Site, method, u string) (int, string, http.Header) {
+	t.Helper()
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := site.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, u, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), resp.Header
+}
+
+func TestNewSitePanicsOnInvalidURL(t *testing.T) {
+	for _, base := range []string{"", "/relative", "ftp://example.com/", "https://"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewSite(%q) didn't panic", base)
+				}
+			}()
+			NewSite(base)
+		}()
+	}
+}
+
+func TestSiteURL(t *testing.T) {
+	site := NewSite("https://example.com/docs/")
+	for path, want := range map[string]string{
+		"/":                        "https://example.com/",
+		"guide":                    "https://example.com/docs/guide",
+		"/a#top":                   "https://example.com/a",
+		"https://other.example/x":  "https://other.example/x",
+		"?q=1":                     "https://example.com/docs/?q=1",
+		"https://example.com/b#re": "https://example.com/b",
+	} {
+		if got := site.URL(path); got != want {
+			t.Errorf("URL(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestSiteResponses(t *testing.T) {
+	site := NewSite("https://example.com/").
+		Page("/", "Home & away", "/a").
+		Redirect("/old", "/", 0).
+		Error("/broken", http.StatusServiceUnavailable).
+		Handle("https://cdn.example/app.js", Response{Header: http.Header{"Content-Type": {"text/javascript"}}, Body: "1"})
+
+	status, body, header := get(t, site, "GET", site.URL("/"))
+	if status != http.StatusOK || header.Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("page answered %d with %q", status, header.Get("Content-Type"))
+	}
+	for _, want := range []string{"<title>Home &amp; away</title>", `<a href="/a">`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page body %q doesn't contain %q", body, want)
+		}
+	}
+
+	if status, _, header := get(t, site, "GET", site.URL("/old")); status != http.StatusMovedPermanently || header.Get("Location") != "/" {
+		t.Errorf("redirect answered %d to %q", status, header.Get("Location"))
+	}
+	if status, body, _ := get(t, site, "GET", site.URL("/broken")); status != http.StatusServiceUnavailable || body != "Service Unavailable\n" {
+		t.Errorf("error page answered %d with %q", status, body)
+	}
+	if status, _, _ := get(t, site, "GET", site.URL("/missing")); status != http.StatusNotFound {
+		t.Errorf("missing page answered %d, want 404", status)
+	}
+	if status, body, _ := get(t, site, "GET", "https://cdn.example/app.js"); status != http.StatusOK || body != "1" {
+		t.Errorf("page on another host answered %d with %q", status, body)
+	}
+	if _, body, _ := get(t, site, "GET", site.URL("/robots.txt")); body != defaultRobots {
+		t.Errorf("default robots.txt is %q", body)
+	}
+	if _, body, header := get(t, site, "HEAD", site.URL("/")); body != "" || header.Get("Content-Type") == "" {
+		t.Errorf("HEAD answered with body %q", body)
+	}
+}
+
+func TestSiteUnknownHost(t *testing.T) {
+	site := NewSite("https://example.com/")
+	req, _ := http.NewRequest("GET", "https://elsewhere.example/", nil)
+	_, err := site.RoundTrip(req)
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("request to an unknown host returned %v, want a not found DNS error", err)
+	}
+	if reqs := site.Requests(); len(reqs) != 0 {
+		t.Errorf("request to an unknown host was recorded: %v", reqs)
+	}
+}
+
+func TestSiteCanceledRequest(t *testing.T) {
+	site := NewSite("https://example.com/").Page("/", "Home")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", site.URL("/"), nil)
+	if _, err := site.RoundTrip(req); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled request returned %v", err)
+	}
+}
+
+func TestSiteRequests(t *testing.T) {
+	site := NewSite("https://example.com/").Page("/", "Home")
+	get(t, site, "GET", site.URL("/#section"))
+	get(t, site, "HEAD", site.URL("/missing"))
+
+	want := []string{"GET https://example.com/", "HEAD https://example.com/missing"}
+	if got := site.Requests(); !slices.Equal(got, want) {
+		t.Errorf("Requests are %v, want %v", got, want)
+	}
+	site.Reset()
+	if got := site.Requests(); len(got) != 0 {
+		t.Errorf("Requests after Reset are %v", got)
+	}
+}
+
+func TestSiteCrawl(t *testing.T) {
+	site := NewSite("https://example.com/").
+		Robots("User-agent: *\nCrawl-delay: 0\n").
+		Page("/", "Home", "/a", "/b").
+		Page("/a", "A", "/b").
+		Page("/b", "B", "/gone")
+	c := crawler.NewCrawler(1, 3, 0, site.Option())
+
+	results := site.Crawl(context.Background(), c, "/")
+
+	// A single worker crawls breadth first, in link order
+	var got []string
+	for _, r := range results {
+		got = append(got, r.URL)
+	}
+	want := []string{site.URL("/"), site.URL("/a"), site.URL("/b"), site.URL("/gone")}
+	if !slices.Equal(got, want) {
+		t.Fatalf("crawl returned %v, want %v", got, want)
+	}
+	var status *crawler.ErrStatus
+	if !errors.As(results[3].Error, &status) || status.Code != http.StatusNotFound {
+		t.Errorf("missing page failed with %v, want a 404", results[3].Error)
+	}
+	if results[1].Title != "A" || !slices.Equal(results[1].Links, []string{"/b"}) {
+		t.Errorf("page /a is %+v", results[1])
+	}
+}
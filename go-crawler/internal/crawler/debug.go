@@ -0,0 +1,197 @@
+package crawler
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stages a worker reports while handling a task, for DebugState
+const (
+	stageWindow     = "waiting for time window"
+	stageDelay      = "waiting for delay"
+	stageRateLimit  = "waiting for rate limit"
+	stageRobots     = "fetching robots.txt"
+	stageHostSlot   = "waiting for host slot"
+	stageCrawlDelay = "waiting for crawl delay"
+	stageFetching   = "fetching"
+	stageLinks      = "queueing links"
+	stageAssets     = "checking assets"
+	stageAlternates = "checking alternates"
+	stageExternal   = "checking external links"
+)
+
+// workerStatus is what one worker is doing
+type workerStatus struct {
+	mu      sync.Mutex
+	url     string
+	stage   string
+	since   time.Time // When the task was taken
+	stageAt time.Time // When the stage began
+}
+
+// begin records that the worker took a task
+func (w *workerStatus) begin(url string) {
+	now := time.Now()
+	w.mu.Lock()
+	w.url, w.stage, w.since, w.stageAt = url, "", now, now
+	w.mu.Unlock()
+}
+
+// end records that the worker is idle
+func (w *workerStatus) end() {
+	w.mu.Lock()
+	w.url, w.stage = "", ""
+	w.mu.Unlock()
+}
+
+// stage records the step a task is at; tasks not taken by a worker have
+// no status
+func (t crawlTask) stage(stage string) {
+	if w := t.status; w != nil {
+		w.mu.Lock()
+		w.stage, w.stageAt = stage, time.Now()
+		w.mu.Unlock()
+	}
+}
+
+// DebugState is a snapshot of a crawl's internals for diagnosing stuck or
+// slow crawls. Its shape may change between versions.
+type DebugState struct {
+	State     string        `json:"state"` // idle, running or done
+	Paused    bool          `json:"paused"`
+	Visited   int           `json:"visited"`
+	Fetched   int64         `json:"fetched"`
+	BytesRead int64         `json:"bytesRead"`
+	Frontier  FrontierState `json:"frontier"`
+	Workers   []WorkerState `json:"workers"`
+	Hosts     []HostState   `json:"hosts"`
+}
+
+// FrontierState describes the queue of URLs waiting to be crawled
+type FrontierState struct {
+	Kind    string `json:"kind"`    // hosts, scored or disk
+	Queued  int    `json:"queued"`  // Waiting in the queue
+	Tracked int    `json:"tracked"` // Queued or in flight, as kept for checkpoints
+}
+
+// WorkerState is what a worker is doing
+type WorkerState struct {
+	ID       int           `json:"id"`
+	URL      string        `json:"url,omitempty"` // Empty when idle
+	Stage    string        `json:"stage,omitempty"`
+	For      time.Duration `json:"for,omitempty"`      // Time on the task
+	StageFor time.Duration `json:"stageFor,omitempty"` // Time in the stage
+}
+
+// HostState is the queue and limits of one host the crawl has URLs for or
+// is holding back
+type HostState struct {
+	Host        string        `json:"host"`
+	Queued      int           `json:"queued"`
+	InFlight    int           `json:"inFlight"`
+	Slots       int           `json:"slots,omitempty"` // Per-host limit slots taken
+	CrawlDelay  time.Duration `json:"crawlDelay"`
+	NextAt      *time.Time    `json:"nextAt,omitempty"`      // When the crawl delay lets the next URL go
+	PausedUntil *time.Time    `json:"pausedUntil,omitempty"` // End of a 429 or 503 pause
+}
+
+// DebugState returns a snapshot of the crawl's frontier, workers and
+// per-host limits
+func (c *Crawler) DebugState() DebugState {
+	state := DebugState{
+		State:     [...]string{runIdle: "idle", runActive: "running", runDone: "done"}[c.runState.Load()],
+		Visited:   c.visited.Len(),
+		Fetched:   c.fetched.Load(),
+		BytesRead: c.bytesRead.Load(),
+		Workers:   []WorkerState{},
+	}
+	c.pauseMu.Lock()
+	state.Paused = c.paused
+	c.pauseMu.Unlock()
+
+	c.frontierMu.Lock()
+	state.Frontier.Tracked = len(c.frontier)
+	hosts, scored, disk, workers := c.hosts, c.scored, c.disk, c.workerStatus
+	c.frontierMu.Unlock()
+
+	now := time.Now()
+	byHost := map[string]*HostState{}
+	host := func(name string) *HostState {
+		h := byHost[name]
+		if h == nil {
+			h = &HostState{Host: name}
+			h.CrawlDelay, _ = c.hostCrawlDelay(name)
+			byHost[name] = h
+		}
+		return h
+	}
+	switch {
+	case hosts != nil:
+		state.Frontier.Kind = "hosts"
+		hosts.mu.Lock()
+		for name, t := range hosts.hosts {
+			h := host(name)
+			h.Queued, h.InFlight = len(t.tasks), t.inFlight
+			state.Frontier.Queued += len(t.tasks)
+			if t.nextAt.After(now) {
+				next := t.nextAt
+				h.NextAt = &next
+			}
+		}
+		hosts.mu.Unlock()
+	case scored != nil:
+		state.Frontier.Kind = "scored"
+		scored.mu.Lock()
+		state.Frontier.Queued = len(scored.tasks)
+		scored.mu.Unlock()
+	case disk != nil:
+		state.Frontier.Kind = "disk"
+		disk.mu.Lock()
+		state.Frontier.Queued = int(disk.written - disk.read)
+		disk.mu.Unlock()
+	}
+
+	c.hostSlots.Range(func(name, value interface{}) bool {
+		slot := value.(*hostSlot)
+		slot.mu.Lock()
+		taken := slot.taken
+		slot.mu.Unlock()
+		if taken > 0 {
+			host(name.(string)).Slots = taken
+		}
+		return true
+	})
+	c.throttle.mu.Lock()
+	for name, t := range c.throttle.hosts {
+		if t.until.After(now) {
+			until := t.until
+			host(name).PausedUntil = &until
+		}
+	}
+	c.throttle.mu.Unlock()
+
+	for i, w := range workers {
+		ws := WorkerState{ID: i}
+		w.mu.Lock()
+		if w.url != "" {
+			ws.URL, ws.Stage = w.url, w.stage
+			ws.For, ws.StageFor = now.Sub(w.since), now.Sub(w.stageAt)
+		}
+		w.mu.Unlock()
+		state.Workers = append(state.Workers, ws)
+	}
+
+	state.Hosts = make([]HostState, 0, len(byHost))
+	for _, h := range byHost {
+		state.Hosts = append(state.Hosts, *h)
+	}
+	sort.Slice(state.Hosts, func(i, j int) bool {
+		a, b := state.Hosts[i], state.Hosts[j]
+		if a.Queued != b.Queued {
+			return a.Queued > b.Queued
+		}
+		return a.Host < b.Host
+	})
+	return state
+}
@@ -0,0 +1,141 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// FetchDiagnosis explains what the crawler makes of a single URL: how it
+// normalizes it, whether a crawl would fetch it, what robots.txt says and
+// every request the fetch took
+type FetchDiagnosis struct {
+	URL        string `json:"url"`                // As given
+	Normalized string `json:"normalized"`         // After normalization and host mapping
+	FetchURL   string `json:"fetchUrl,omitempty"` // Requested instead, for a hashbang route
+	VisitKey   string `json:"visitKey"`           // What duplicates are detected by
+	// Skip is why a crawl from the start URL would not fetch the URL when
+	// linked from it, or why robots.txt stopped the fetch
+	Skip   *SkippedURL     `json:"skip,omitempty"`
+	Robots *RobotsDecision `json:"robots,omitempty"`
+	// Exchanges are the requests sent, robots.txt and redirects included,
+	// in order
+	Exchanges []HTTPExchange `json:"exchanges"`
+	Duration  time.Duration  `json:"duration"`
+	// Result is the page as a crawl would record it
+	Result CrawlResult `json:"-"`
+}
+
+// HTTPExchange is one request of a diagnosed fetch and its response.
+// Credentials are redacted from the headers.
+type HTTPExchange struct {
+	Method          string        `json:"method"`
+	URL             string        `json:"url"`
+	RequestHeaders  http.Header   `json:"requestHeaders"`
+	StatusCode      int           `json:"statusCode,omitempty"`
+	ResponseHeaders http.Header   `json:"responseHeaders,omitempty"`
+	Duration        time.Duration `json:"duration"`
+	Bytes           int64         `json:"bytes"`
+	Error           string        `json:"error,omitempty"`
+}
+
+// Diagnose fetches a single URL the way a crawl would, through robots.txt,
+// the host limits, the request headers and the parsers, and reports each
+// step. With from set, the URL is also checked against the crawl's scope
+// and limits as a link on from, the start URL; it is fetched even when the
+// checks would skip it, so that the response can be seen. Like DryRun, it
+// runs on its own and can't be combined with a crawl.
+func (c *Crawler) Diagnose(ctx context.Context, rawURL, from string) (*FetchDiagnosis, error) {
+	if err := c.beginRun(); err != nil {
+		return nil, err
+	}
+	defer c.runState.Store(runDone)
+	started := time.Now()
+
+	d := &FetchDiagnosis{URL: rawURL, Exchanges: []HTTPExchange{}}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	normalizeURL(u)
+	c.mapHost(u)
+	d.Normalized, d.VisitKey = u.String(), c.hostPolicy.Key(u)
+	if c.hashbangRoutes && isHashbang(u) {
+		d.FetchURL = escapedFragmentURL(u)
+	}
+
+	onSkip, onRobots := c.onSkip, c.onRobots
+	c.onSkip = func(s SkippedURL) {
+		if d.Skip == nil {
+			d.Skip = &s
+		}
+		if onSkip != nil {
+			onSkip(s)
+		}
+	}
+	c.onRobots = func(decision RobotsDecision) {
+		d.Robots = &decision
+		if onRobots != nil {
+			onRobots(decision)
+		}
+	}
+	defer func() { c.onSkip, c.onRobots = onSkip, onRobots }()
+
+	if from == "" {
+		c.setStartURL(d.Normalized)
+	} else {
+		c.setStartURL(from)
+		c.admit(u, from, 1)
+	}
+
+	// Record the exchanges with a client of our own, so the crawler's
+	// recorder, if any, still sees them
+	recorder := &HTTPRecorder{sample: 1}
+	client := *c.httpClient
+	client.Transport = recorder.transport(client.Transport)
+	saved := c.httpClient
+	c.httpClient = &client
+	defer func() { c.httpClient = saved }()
+
+	if c.rateLimiter != nil {
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	d.Result = CrawlResult{URL: d.Normalized}
+	d.Result.Error = c.processURL(ctx, crawlTask{URL: d.Normalized}, &d.Result)
+	d.Duration = time.Since(started)
+
+	recorder.mu.Lock()
+	entries := append([]harEntry{}, recorder.entries...)
+	recorder.mu.Unlock()
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].StartedDateTime.Before(entries[j].StartedDateTime) })
+	for _, e := range entries {
+		d.Exchanges = append(d.Exchanges, HTTPExchange{
+			Method:          e.Request.Method,
+			URL:             e.Request.URL,
+			RequestHeaders:  pairHeader(e.Request.Headers),
+			StatusCode:      e.Response.Status,
+			ResponseHeaders: pairHeader(e.Response.Headers),
+			Duration:        time.Duration(e.Time * float64(time.Millisecond)),
+			Bytes:           e.Response.Content.Size,
+			Error:           e.Error,
+		})
+	}
+	return d, ctx.Err()
+}
+
+// pairHeader turns recorded header pairs back into a header, or nil if
+// there are none
+func pairHeader(pairs []harPair) http.Header {
+	if len(pairs) == 0 {
+		return nil
+	}
+	h := http.Header{}
+	for _, p := range pairs {
+		h.Add(p.Name, p.Value)
+	}
+	return h
+}
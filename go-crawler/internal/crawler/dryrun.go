@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+)
+
+// DryRunReport is what a crawl would fetch, as far as can be told without
+// fetching pages
+type DryRunReport struct {
+	StartURL string       `json:"startUrl"`
+	URLs     []string     `json:"urls"` // URLs that would be crawled
+	Skipped  []SkippedURL `json:"skipped"`
+	Sitemap  *Sitemap     `json:"sitemap"`
+}
+
+// DryRun checks the start URL and the URLs listed in the site's sitemaps
+// against robots.txt and the crawler's scope and limits without fetching
+// any pages, only robots.txt files and sitemaps. Sitemap URLs are checked
+// as if linked from the start page, at depth 1; a real crawl only finds
+// those its link graph reaches. Skipped URLs are also passed to the skip
+// handler.
+func (c *Crawler) DryRun(ctx context.Context, startURL string) (*DryRunReport, error) {
+	if err := c.beginRun(); err != nil {
+		return nil, err
+	}
+	defer c.runState.Store(runDone)
+	c.setStartURL(startURL)
+	report := &DryRunReport{StartURL: startURL, URLs: []string{}, Skipped: []SkippedURL{}}
+
+	onSkip := c.onSkip
+	c.onSkip = func(s SkippedURL) {
+		report.Skipped = append(report.Skipped, s)
+		if onSkip != nil {
+			onSkip(s)
+		}
+	}
+	defer func() { c.onSkip = onSkip }()
+
+	// The start URL is seeded without the link checks, like in a crawl
+	if u, err := url.Parse(startURL); err == nil && c.dryRunAllowed(u, "") {
+		report.URLs = append(report.URLs, u.String())
+	}
+
+	sitemap, err := c.ReadSitemaps(ctx, startURL)
+	if err != nil {
+		return nil, err
+	}
+	report.Sitemap = sitemap
+	for _, loc := range sitemap.URLs {
+		u, err := resolveURL(startURL, loc)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		c.mapHost(u)
+		if !c.admit(u, startURL, 1) || !c.dryRunAllowed(u, startURL) {
+			continue
+		}
+		report.URLs = append(report.URLs, u.String())
+	}
+	return report, ctx.Err()
+}
+
+// dryRunAllowed checks a URL against robots.txt and, if it is allowed,
+// counts it as visited for the duplicate and budget checks
+func (c *Crawler) dryRunAllowed(u *url.URL, source string) bool {
+	rules, err := c.getRobotsRules(u)
+	if err != nil {
+		return false
+	}
+	if rule, allowed := c.checkRobots(rules, u.String()); !allowed {
+		c.skip(u.String(), source, SkipRobots, "Disallow: "+rule)
+		return false
+	}
+	c.visited.Add(c.hostPolicy.Key(u))
+	c.fetched.Add(1)
+	return true
+}
@@ -0,0 +1,166 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrorAction is what the crawler does with a failure of some class
+type ErrorAction string
+
+const (
+	ActionReport ErrorAction = "report" // Send the result with its error
+	ActionRetry  ErrorAction = "retry"  // Retry the request, then report the error if it persists
+	ActionSkip   ErrorAction = "skip"   // Don't report the error; see WithErrorPolicy
+)
+
+// ErrorPolicy maps error classes to what the crawler does with them.
+// Classes it doesn't list keep their default action.
+type ErrorPolicy map[ErrorClass]ErrorAction
+
+// defaultErrorPolicy retries network errors, timeouts and 5xx responses,
+// doesn't count non-HTML pages as errors and reports everything else
+var defaultErrorPolicy = ErrorPolicy{
+	ClassTimeout: ActionRetry,
+	ClassFetch:   ActionRetry,
+	ClassStatus:  ActionRetry,
+	ClassNonHTML: ActionSkip,
+}
+
+// retryableClasses are the classes of failed requests, which can be retried
+var retryableClasses = map[ErrorClass]bool{ClassTimeout: true, ClassFetch: true, ClassStatus: true}
+
+// DefaultErrorPolicy returns the action for every class when none is set
+func DefaultErrorPolicy() ErrorPolicy {
+	p := ErrorPolicy{}
+	for _, class := range ErrorClasses {
+		p[class] = defaultErrorPolicy.Action(class)
+	}
+	return p
+}
+
+// ParseErrorPolicy parses a comma-separated list of class=action pairs,
+// e.g. "timeout=skip,non-html=report"
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	p := ErrorPolicy{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		class, action, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid error policy %q: want class=action", field)
+		}
+		p[ErrorClass(strings.TrimSpace(class))] = ErrorAction(strings.ToLower(strings.TrimSpace(action)))
+	}
+	return p, p.Validate()
+}
+
+// Validate checks the policy's classes and actions. Only failed requests,
+// i.e. timeouts, network errors and error statuses, can be retried.
+func (p ErrorPolicy) Validate() error {
+	for class, action := range p {
+		if _, err := ParseErrorClass(string(class)); err != nil {
+			return err
+		}
+		switch action {
+		case ActionReport, ActionSkip:
+		case ActionRetry:
+			if !retryableClasses[class] {
+				return fmt.Errorf("%s errors can't be retried", class)
+			}
+		default:
+			return fmt.Errorf("unknown action %q for %s errors: want report, retry or skip", action, class)
+		}
+	}
+	return nil
+}
+
+// Action returns what the policy does with errors of a class
+func (p ErrorPolicy) Action(class ErrorClass) ErrorAction {
+	if action, ok := p[class]; ok {
+		return action
+	}
+	if action, ok := defaultErrorPolicy[class]; ok {
+		return action
+	}
+	return ActionReport
+}
+
+// WithErrorPolicy sets which error classes are retried, reported or
+// skipped. Retries follow WithRetries; a status retry only applies to 5xx
+// responses, as 429s are always retried after the host's pause. Skipped
+// failures are left out of the results and reported to the skip handler
+// instead, while skipped non-HTML pages are reported without an error.
+func WithErrorPolicy(p ErrorPolicy) Option {
+	return func(c *Crawler) {
+		c.errorPolicy = p
+	}
+}
+
+// retryable reports whether a fetch failed in a way the error policy
+// retries
+func (c *Crawler) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return c.errorPolicy.Action(Classify(requestFailed(err))) == ActionRetry
+	}
+	if challengeHeader(resp.Header) != "" {
+		// Retrying only asks for the same challenge
+		return false
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return resp.StatusCode >= 500 && c.errorPolicy.Action(ClassStatus) == ActionRetry
+}
+
+// WithRequeues queues a URL again, behind the other URLs of its host, when
+// its fetch fails with an error the policy retries after the request's own
+// retries, up to n times. It is fetched again if found anew until then,
+// and only then counted as visited. The default is 1; 0 gives up on the
+// first failure.
+func WithRequeues(n int) Option {
+	return func(c *Crawler) {
+		c.requeues = n
+	}
+}
+
+// retryLater reports whether a fetch failed transiently and has failures
+// left to queue its URL again for, counting the failure
+func (c *Crawler) retryLater(key string, err error) bool {
+	if err == nil || !c.transient(err) {
+		return false
+	}
+	n := 0
+	if v, ok := c.failures.Load(key); ok {
+		n = v.(int)
+	}
+	if n >= c.requeues {
+		return false
+	}
+	// Only the worker holding the URL's claim gets here
+	c.failures.Store(key, n+1)
+	return true
+}
+
+// transient reports whether a crawl error is one the error policy retries:
+// a timeout, a network error, a 5xx response or a 429. A connection the
+// resolver refused will be refused again.
+func (c *Crawler) transient(err error) bool {
+	if errors.Is(err, ErrPrivateAddress) {
+		return false
+	}
+	var status *ErrStatus
+	switch class := Classify(err); class {
+	case ClassTimeout, ClassFetch:
+		return c.errorPolicy.Action(class) == ActionRetry
+	case ClassStatus:
+		errors.As(err, &status)
+		return status.Code == http.StatusTooManyRequests ||
+			(status.Code >= 500 && c.errorPolicy.Action(class) == ActionRetry)
+	}
+	return false
+}
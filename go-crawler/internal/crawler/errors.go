@@ -0,0 +1,140 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Errors a page's crawl fails with, to test CrawlResult.Error against
+// with errors.Is. Messages carry the URL and details as before.
+var (
+	ErrRobotsDisallowed = errors.New("disallowed by robots.txt")
+	ErrNonHTML          = errors.New("not an HTML page or feed") // Only reported if the error policy says so
+	ErrTimeout          = errors.New("request timed out")
+	ErrFetchFailed      = errors.New("request failed") // DNS, connection and other transport errors
+	ErrAuthRequired     = errors.New("authentication required")
+	ErrInvalidURL       = errors.New("invalid URL")
+	ErrRedirect         = errors.New("redirect loop or too many redirects") // See RedirectError
+	ErrBotChallenge     = errors.New("blocked by a bot challenge")          // See ChallengeError
+	ErrFiltered         = errors.New("rejected by the URL filter")          // A redirect to a URL WithURLFilter rejects
+	// ErrTooDeep is what SkippedURL.Err returns for links beyond the
+	// maximum depth, which are skipped rather than fetched
+	ErrTooDeep = errors.New("beyond the maximum depth")
+)
+
+// ErrStatus is returned for pages that answered with a status other than
+// 200 OK, other than 401
+type ErrStatus struct {
+	Code int
+	URL  string
+}
+
+func (e *ErrStatus) Error() string {
+	return fmt.Sprintf("unexpected status code %d for %s", e.Code, e.URL)
+}
+
+// ErrorClass names the kind of a crawl error, for reports and filters
+type ErrorClass string
+
+const (
+	ClassRobots     ErrorClass = "robots"
+	ClassNonHTML    ErrorClass = "non-html"
+	ClassStatus     ErrorClass = "status"
+	ClassChallenge  ErrorClass = "challenge" // A bot challenge or CAPTCHA blocked the crawler
+	ClassTimeout    ErrorClass = "timeout"
+	ClassFetch      ErrorClass = "fetch"
+	ClassAuth       ErrorClass = "auth"
+	ClassInvalidURL ErrorClass = "invalid-url"
+	ClassTooDeep    ErrorClass = "too-deep"
+	ClassRedirect   ErrorClass = "redirect" // A redirect loop or too long a chain
+	ClassCanceled   ErrorClass = "canceled"
+	ClassOther      ErrorClass = "other" // Such as HTML that can't be parsed
+)
+
+// ErrorClasses lists every class, in the order reports show them
+var ErrorClasses = []ErrorClass{
+	ClassStatus, ClassChallenge, ClassTimeout, ClassFetch, ClassRedirect, ClassAuth, ClassRobots, ClassNonHTML,
+	ClassInvalidURL, ClassTooDeep, ClassCanceled, ClassOther,
+}
+
+// Classify returns the class of a crawl error, or "" for nil
+func Classify(err error) ErrorClass {
+	var status *ErrStatus
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrBotChallenge):
+		return ClassChallenge
+	case errors.As(err, &status):
+		return ClassStatus
+	case errors.Is(err, ErrRedirect):
+		return ClassRedirect
+	case errors.Is(err, ErrTimeout):
+		return ClassTimeout
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	case errors.Is(err, ErrFetchFailed):
+		return ClassFetch
+	case errors.Is(err, ErrAuthRequired):
+		return ClassAuth
+	case errors.Is(err, ErrRobotsDisallowed):
+		return ClassRobots
+	case errors.Is(err, ErrNonHTML):
+		return ClassNonHTML
+	case errors.Is(err, ErrInvalidURL):
+		return ClassInvalidURL
+	case errors.Is(err, ErrTooDeep):
+		return ClassTooDeep
+	}
+	return ClassOther
+}
+
+// ParseErrorClass checks an error class name
+func ParseErrorClass(name string) (ErrorClass, error) {
+	for _, class := range ErrorClasses {
+		if string(class) == name {
+			return class, nil
+		}
+	}
+	return "", fmt.Errorf("unknown error class %q", name)
+}
+
+// Err returns the error a URL skipped by robots.txt or the depth limit
+// would have been reported with, or nil for other skips
+func (s SkippedURL) Err() error {
+	switch s.Reason {
+	case SkipRobots:
+		return fmt.Errorf("%w: %s", ErrRobotsDisallowed, s.URL)
+	case SkipDepth:
+		return fmt.Errorf("%w: %s", ErrTooDeep, s.URL)
+	}
+	return nil
+}
+
+// fetchError marks an error from a request as ErrTimeout or
+// ErrFetchFailed, keeping its message and the error it wraps
+type fetchError struct {
+	err error
+}
+
+// requestFailed wraps an error from sending a request or reading its
+// response
+func requestFailed(err error) error {
+	var ferr *fetchError
+	if err == nil || errors.As(err, &ferr) || errors.Is(err, context.Canceled) || errors.Is(err, ErrRedirect) || errors.Is(err, ErrFiltered) {
+		return err
+	}
+	return &fetchError{err: err}
+}
+
+func (e *fetchError) Error() string { return e.err.Error() }
+func (e *fetchError) Unwrap() error { return e.err }
+
+// Is reports a timeout as ErrTimeout and anything else as ErrFetchFailed
+func (e *fetchError) Is(target error) bool {
+	var netErr net.Error
+	timeout := errors.Is(e.err, context.DeadlineExceeded) || (errors.As(e.err, &netErr) && netErr.Timeout())
+	return (target == ErrTimeout && timeout) || (target == ErrFetchFailed && !timeout)
+}
@@ -0,0 +1,142 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// Estimate settings
+const (
+	estimateSamples  = 5        // Pages requested with HEAD to measure size and latency
+	defaultPageBytes = 64 << 10 // Assumed page size when no sample reports one
+	defaultLatency   = 300 * time.Millisecond
+)
+
+// Estimate is the projected size and cost of a crawl
+type Estimate struct {
+	Pages       int           `json:"pages"`       // Pages expected to be fetched
+	SitemapURLs int           `json:"sitemapUrls"` // URLs listed in the site's sitemaps
+	PageBytes   int64         `json:"pageBytes"`   // Average page size
+	Latency     time.Duration `json:"latency"`     // Average response time
+	Bytes       int64         `json:"bytes"`
+	Duration    time.Duration `json:"duration"`
+	// LimitedBy names the setting that bounds the duration: "workers",
+	// "crawl-delay", "rate-limit" or "bandwidth"
+	LimitedBy string `json:"limitedBy"`
+}
+
+// EstimateCrawl projects how many pages a crawl from startURL would fetch,
+// how long it would take and how much it would download with the crawler's
+// settings. The page count comes from a dry run over the site's sitemaps,
+// so a site without sitemaps is estimated at its start page only. Page
+// size and latency are measured on a few sampled pages with HEAD requests.
+// Like DryRun, it marks URLs as visited: crawl with a separate Crawler.
+func (c *Crawler) EstimateCrawl(ctx context.Context, startURL string) (*Estimate, error) {
+	report, err := c.DryRun(ctx, startURL)
+	if err != nil {
+		return nil, err
+	}
+	est := &Estimate{Pages: len(report.URLs), SitemapURLs: len(report.Sitemap.URLs)}
+	est.PageBytes, est.Latency = c.samplePages(ctx, report.URLs)
+	est.Bytes = int64(est.Pages) * est.PageBytes
+
+	// Workers each wait for the delay and the response in turn
+	perPage := c.crawlDelay + c.jitter/2 + est.Latency
+	est.Duration = time.Duration(est.Pages) * perPage / time.Duration(max(c.maxWorkers, 1))
+	est.LimitedBy = "workers"
+	limit := func(d time.Duration, by string) {
+		if d > est.Duration {
+			est.Duration, est.LimitedBy = d, by
+		}
+	}
+
+	// Each host is limited by its robots.txt crawl delay and the per-host
+	// concurrency cap; hosts are crawled in parallel
+	perHost := make(map[string]int)
+	for _, u := range report.URLs {
+		if parsed, err := url.Parse(u); err == nil {
+			perHost[parsed.Host]++
+		}
+	}
+	for host, pages := range perHost {
+		if rules, ok := c.robotsMap.Load(host); ok {
+			limit(time.Duration(pages)*rules.(*RobotRules).GetCrawlDelay(), "crawl-delay")
+		}
+		if c.perHostLimit > 0 {
+			limit(time.Duration(pages)*est.Latency/time.Duration(c.perHostLimit), "workers")
+		}
+	}
+
+	if c.rateLimiter != nil && c.rateLimiter.Rate() > 0 {
+		limit(time.Duration(float64(est.Pages)/c.rateLimiter.Rate()*float64(time.Second)), "rate-limit")
+	}
+	if c.bandwidth != nil && c.bandwidth.Rate() > 0 {
+		limit(time.Duration(float64(est.Bytes)/float64(c.bandwidth.Rate())*float64(time.Second)), "bandwidth")
+	}
+	return est, nil
+}
+
+// samplePages requests up to estimateSamples of urls, spread over the
+// list, with HEAD and returns their average size and response time
+func (c *Crawler) samplePages(ctx context.Context, urls []string) (int64, time.Duration) {
+	var sizes, sized int64
+	var latency time.Duration
+	var timed int
+	step := max(len(urls)/estimateSamples, 1)
+	for i := 0; i < len(urls) && timed < estimateSamples && ctx.Err() == nil; i += step {
+		req, err := http.NewRequest(http.MethodHead, urls[i], nil)
+		if err != nil {
+			continue
+		}
+		req.Header.Set("User-Agent", c.userAgent)
+		if c.rateLimiter != nil {
+			if err := c.rateLimiter.Wait(ctx); err != nil {
+				break
+			}
+		}
+		start := time.Now()
+		resp, err := c.doWithRetries(ctx, req)
+		if err != nil {
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		latency += time.Since(start)
+		timed++
+		if resp.ContentLength > 0 {
+			sizes += resp.ContentLength
+			sized++
+		}
+	}
+
+	pageBytes := int64(defaultPageBytes)
+	if sized > 0 {
+		pageBytes = sizes / sized
+	}
+	avgLatency := defaultLatency
+	if timed > 0 {
+		avgLatency = latency / time.Duration(timed)
+	}
+	return pageBytes, avgLatency
+}
+
+// BytesRead returns the number of page and feed body bytes read so far
+func (c *Crawler) BytesRead() int64 {
+	return c.bytesRead.Load()
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
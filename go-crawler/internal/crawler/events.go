@@ -0,0 +1,174 @@
+package crawler
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// EventType names something that happened during a crawl
+type EventType string
+
+const (
+	EventPageFetched    EventType = "page-fetched"    // A result was sent; Result is set and must not be changed
+	EventLinkDiscovered EventType = "link-discovered" // A link was found on Source, before any filtering
+	EventURLSkipped     EventType = "url-skipped"     // Reason is the SkipReason
+	EventRobotsBlocked  EventType = "robots-blocked"  // Reason is the rule that blocked the URL
+	EventRetryScheduled EventType = "retry-scheduled" // A request is retried after Delay, or its URL queued again
+	EventHostThrottled  EventType = "host-throttled"  // A host answered 429 or 503 and is paused for Delay
+)
+
+// EventTypes lists every event type
+var EventTypes = []EventType{
+	EventPageFetched, EventLinkDiscovered, EventURLSkipped, EventRobotsBlocked,
+	EventRetryScheduled, EventHostThrottled,
+}
+
+// Event is one thing that happened during a crawl. Fields that don't
+// apply to its type are left empty.
+type Event struct {
+	Type    EventType     `json:"type"`
+	Time    time.Time     `json:"time"`
+	URL     string        `json:"url"`
+	Host    string        `json:"host,omitempty"`
+	Source  string        `json:"source,omitempty"` // Page the link was found on
+	Depth   int           `json:"depth,omitempty"`
+	Reason  string        `json:"reason,omitempty"`
+	Detail  string        `json:"detail,omitempty"`  // Such as the error that caused a retry
+	Attempt int           `json:"attempt,omitempty"` // Which retry of a request this is
+	Delay   time.Duration `json:"delay,omitempty"`
+	Result  *CrawlResult  `json:"-"`
+}
+
+// EventBus hands crawl events to its subscribers. Handlers are called in
+// the goroutine that published the event, usually a worker, so they must
+// be quick and safe for concurrent use; see SubscribeChan for slow ones.
+type EventBus struct {
+	mu   sync.RWMutex
+	subs map[int]*subscription
+	next int
+}
+
+type subscription struct {
+	fn    func(Event)
+	types map[EventType]bool // Nil for every type
+}
+
+// NewEventBus returns a bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[int]*subscription)}
+}
+
+// Subscribe calls fn for every event of the given types, or of every type
+// if none are given, until unsubscribe is called
+func (b *EventBus) Subscribe(fn func(Event), types ...EventType) (unsubscribe func()) {
+	sub := &subscription{fn: fn}
+	if len(types) > 0 {
+		sub.types = make(map[EventType]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.subs[id] = sub
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// SubscribeChan delivers events of the given types on a channel with room
+// for buffer events. Events that find it full are dropped rather than
+// holding up the crawl. The channel is closed by unsubscribe.
+func (b *EventBus) SubscribeChan(buffer int, types ...EventType) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	var mu sync.Mutex
+	closed := false
+	unsubscribe := b.Subscribe(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}, types...)
+	return ch, func() {
+		unsubscribe()
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			closed = true
+			close(ch)
+		}
+	}
+}
+
+// Publish hands an event to the subscribers of its type. Handlers may
+// subscribe and unsubscribe; the change applies from the next event.
+func (b *EventBus) Publish(e Event) {
+	b.mu.RLock()
+	subs := make([]*subscription, 0, len(b.subs))
+	for _, sub := range b.subs {
+		if sub.types == nil || sub.types[e.Type] {
+			subs = append(subs, sub)
+		}
+	}
+	b.mu.RUnlock()
+	for _, sub := range subs {
+		sub.fn(e)
+	}
+}
+
+// wants reports whether any subscriber takes events of a type
+func (b *EventBus) wants(t EventType) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subs {
+		if sub.types == nil || sub.types[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// WithEventBus publishes the crawl's events on b, so that several crawls
+// can share subscribers. Without it each crawler has a bus of its own.
+func WithEventBus(b *EventBus) Option {
+	return func(c *Crawler) {
+		c.events = b
+	}
+}
+
+// Events returns the bus the crawler publishes its events on
+func (c *Crawler) Events() *EventBus {
+	return c.events
+}
+
+// emit publishes an event, filling in its time and host, if anyone takes
+// events of its type
+func (c *Crawler) emit(e Event) {
+	if !c.events.wants(e.Type) {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Host == "" {
+		if u, err := url.Parse(e.URL); err == nil {
+			e.Host = u.Host
+		}
+	}
+	c.events.Publish(e)
+}
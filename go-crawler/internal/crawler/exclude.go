@@ -0,0 +1,96 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Exclusion is a URL pattern added to a running crawl by Exclude
+type Exclusion struct {
+	Pattern string    `json:"pattern"`
+	AddedAt time.Time `json:"addedAt"`
+	Skipped int64     `json:"skipped"` // URLs skipped since it was added
+}
+
+// exclusion is a compiled Exclusion
+type exclusion struct {
+	pattern string
+	prefix  string         // Path prefix, for patterns starting with /
+	re      *regexp.Regexp // Otherwise matched against the whole URL
+	added   time.Time
+	skipped atomic.Int64
+}
+
+// matches reports whether a URL falls under the exclusion
+func (e *exclusion) matches(u *url.URL, urlStr string) bool {
+	if e.re != nil {
+		return e.re.MatchString(urlStr)
+	}
+	return strings.HasPrefix(u.Path, e.prefix)
+}
+
+// Exclude stops the crawl from fetching URLs that match pattern, from now
+// on: links found later are skipped with SkipExcluded, and so are URLs
+// already queued when they come up. A pattern starting with / is a path
+// prefix; anything else is a regular expression matched against the whole
+// URL. It may be called while the crawl runs.
+func (c *Crawler) Exclude(pattern string) (Exclusion, error) {
+	e := &exclusion{pattern: pattern, added: time.Now()}
+	switch {
+	case pattern == "":
+		return Exclusion{}, fmt.Errorf("empty exclusion pattern")
+	case strings.HasPrefix(pattern, "/"):
+		e.prefix = pattern
+	default:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return Exclusion{}, fmt.Errorf("invalid exclusion pattern %q: %v", pattern, err)
+		}
+		e.re = re
+	}
+
+	c.excludeMu.Lock()
+	c.exclusions = append(c.exclusions, e)
+	c.excludeMu.Unlock()
+	return Exclusion{Pattern: e.pattern, AddedAt: e.added}, nil
+}
+
+// Exclusions returns the patterns added by Exclude, oldest first
+func (c *Crawler) Exclusions() []Exclusion {
+	c.excludeMu.RLock()
+	defer c.excludeMu.RUnlock()
+	list := make([]Exclusion, len(c.exclusions))
+	for i, e := range c.exclusions {
+		list[i] = Exclusion{Pattern: e.pattern, AddedAt: e.added, Skipped: e.skipped.Load()}
+	}
+	return list
+}
+
+// excluded returns the pattern of the first exclusion a URL matches,
+// counting the skip against it
+func (c *Crawler) excluded(u *url.URL) (string, bool) {
+	c.excludeMu.RLock()
+	defer c.excludeMu.RUnlock()
+	if len(c.exclusions) == 0 {
+		return "", false
+	}
+	urlStr := u.String()
+	for _, e := range c.exclusions {
+		if e.matches(u, urlStr) {
+			e.skipped.Add(1)
+			return e.pattern, true
+		}
+	}
+	return "", false
+}
+
+// excluding reports whether any exclusions have been added
+func (c *Crawler) excluding() bool {
+	c.excludeMu.RLock()
+	defer c.excludeMu.RUnlock()
+	return len(c.exclusions) > 0
+}
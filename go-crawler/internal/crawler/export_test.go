@@ -0,0 +1,5 @@
+package crawler
+
+// ExtractLinks lets the benchmarks in package crawler_test, which can use
+// crawlertest, parse a page the way workers do
+var ExtractLinks = extractLinks
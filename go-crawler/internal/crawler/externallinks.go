@@ -0,0 +1,135 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ExternalLink is a link to another site that failed its check, with the
+// pages linking to it
+type ExternalLink struct {
+	URL        string   `json:"url"`
+	StatusCode int      `json:"statusCode,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Pages      []string `json:"pages"`
+}
+
+// externalHost spaces out the checks of one external host
+type externalHost struct {
+	mu   sync.Mutex
+	next time.Time // When the next check may start
+}
+
+// WithExternalLinkCheck checks every link to outside the crawl's scope,
+// set by WithSameHost or WithSubdomains, once per URL, so that dead
+// outbound links can be reported by BrokenExternalLinks without crawling
+// other sites. Links are requested with HEAD, falling back to GET when HEAD
+// returns an error status, and redirects are followed, but nothing else is
+// fetched from the external site besides its robots.txt. Checks of the same
+// host start at least interval apart, one second if interval is 0.
+func WithExternalLinkCheck(interval time.Duration) Option {
+	return func(c *Crawler) {
+		if interval <= 0 {
+			interval = time.Second
+		}
+		c.checkExternal = interval
+	}
+}
+
+// BrokenExternalLinks returns the external links that returned an error
+// status or could not be fetched, sorted by URL
+func (c *Crawler) BrokenExternalLinks() []ExternalLink {
+	var broken []ExternalLink
+	c.externalLinks.Range(func(key, value any) bool {
+		a := value.(*assetCheck)
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if !a.checked || (a.err == nil && a.status < 400) {
+			return true
+		}
+		l := ExternalLink{URL: key.(string), StatusCode: a.status, Pages: append([]string(nil), a.pages...)}
+		if a.err != nil {
+			l.Error = a.err.Error()
+		}
+		sort.Strings(l.Pages)
+		broken = append(broken, l)
+		return true
+	})
+	sort.Slice(broken, func(i, j int) bool { return broken[i].URL < broken[j].URL })
+	return broken
+}
+
+// offSite reports whether a URL is outside the crawl's host or subdomain
+// scope, with the subdomain policy's explanation
+func (c *Crawler) offSite(u *url.URL) (bool, string) {
+	if c.sameHost && c.hostPolicy.Host(u) != c.startHost {
+		return true, ""
+	}
+	if c.subdomains != nil {
+		if ok, detail := c.subdomains.Allows(c.startHost, u.Hostname()); !ok {
+			return true, detail
+		}
+	}
+	return false, ""
+}
+
+// checkExternalLinks records the external links of a page and checks the
+// ones not seen before. Like assets, each URL is checked once; pages that
+// link to it while it is checked wait for the outcome.
+func (c *Crawler) checkExternalLinks(ctx context.Context, pageURL string, links []string) {
+	seen := make(map[string]bool, len(links))
+	for _, link := range links {
+		u, err := resolveURL(pageURL, link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		normalizeURL(u)
+		c.mapHost(u)
+		if off, _ := c.offSite(u); !off {
+			continue
+		}
+		if c.urlFilter != nil && !c.urlFilter(u) {
+			continue
+		}
+		u.Fragment = ""
+		linkURL := u.String()
+		if seen[linkURL] {
+			continue
+		}
+		seen[linkURL] = true
+
+		value, _ := c.externalLinks.LoadOrStore(linkURL, &assetCheck{})
+		l := value.(*assetCheck)
+		l.mu.Lock()
+		if len(l.pages) < maxAssetPages {
+			l.pages = append(l.pages, pageURL)
+		}
+		l.mu.Unlock()
+
+		l.once.Do(func() {
+			if err := c.waitExternalHost(ctx, u.Host); err != nil {
+				return
+			}
+			status, checked, err := c.headOrGet(ctx, u, func(status int) bool { return status >= 400 })
+			l.mu.Lock()
+			l.status, l.err, l.checked = status, err, checked
+			l.mu.Unlock()
+		})
+	}
+}
+
+// waitExternalHost blocks until a check of an external host may start
+func (c *Crawler) waitExternalHost(ctx context.Context, host string) error {
+	value, _ := c.externalHosts.LoadOrStore(host, &externalHost{})
+	h := value.(*externalHost)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := sleepCtx(ctx, time.Until(h.next)); err != nil {
+		return err
+	}
+	h.next = time.Now().Add(c.checkExternal)
+	return nil
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// feedTypes are the content types of RSS and Atom feeds
+var feedTypes = []string{"application/rss+xml", "application/atom+xml", "application/feed+xml"}
+
+// WithFeedDiscovery makes the crawler follow feeds advertised by pages with
+// <link rel="alternate">. Discovered feeds are queued at the depth of the
+// page that links them, so their entries are crawled like the page's own
+// links.
+func WithFeedDiscovery() Option {
+	return func(c *Crawler) {
+		c.discoverFeeds = true
+	}
+}
+
+// isFeedLink reports whether a <link> element's type advertises a feed
+func isFeedLink(linkType string) bool {
+	linkType = strings.ToLower(strings.TrimSpace(linkType))
+	for _, t := range feedTypes {
+		if linkType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFeed returns the item links of an RSS or Atom feed. Documents that
+// turn out not to be feeds, such as sitemaps, yield no links.
+func parseFeed(body io.Reader) ([]string, error) {
+	d := xml.NewDecoder(body)
+	d.Strict = false
+	d.CharsetReader = charset.NewReaderLabel
+
+	var links []string
+	inEntry := false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return links, nil
+		}
+		if err != nil {
+			return links, fmt.Errorf("error parsing feed: %v", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "item", "entry":
+				inEntry = true
+			case "link":
+				if !inEntry {
+					continue
+				}
+				if link, ok := atomLink(t); ok {
+					links = append(links, link)
+					continue
+				}
+				// RSS keeps the URL in the element's text
+				var text string
+				if err := d.DecodeElement(&text, &t); err != nil {
+					return links, fmt.Errorf("error parsing feed: %v", err)
+				}
+				if text = strings.TrimSpace(text); text != "" {
+					links = append(links, text)
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "item" || t.Name.Local == "entry" {
+				inEntry = false
+			}
+		}
+	}
+}
+
+// atomLink returns the href of an Atom <link> pointing at the entry itself
+func atomLink(el xml.StartElement) (string, bool) {
+	var href, rel string
+	for _, a := range el.Attr {
+		switch a.Name.Local {
+		case "href":
+			href = a.Value
+		case "rel":
+			rel = a.Value
+		}
+	}
+	if href == "" || (rel != "" && rel != "alternate") {
+		return "", false
+	}
+	return href, true
+}
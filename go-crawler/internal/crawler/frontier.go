@@ -0,0 +1,258 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Files of a disk frontier
+const (
+	frontierQueueFile = "queue.jsonl" // Every task queued, after a header line
+	frontierDoneFile  = "done.log"    // URLs of finished tasks, one per line
+)
+
+// WithFrontierDir keeps the crawl's queue in files in dir instead of in
+// memory, so a crawl's size is bounded by disk rather than RAM. Queued
+// tasks are appended to one file and the URLs of finished tasks to
+// another, each as it happens, so if the process dies the crawl can be
+// continued by starting it again with the same start URL and directory:
+// pages that were finished are not fetched again, and URLs that were
+// queued or in flight are. The files are removed when a crawl finishes
+// without being cancelled. The visited set is still kept in memory, and
+// focused crawls keep their frontier in memory.
+func WithFrontierDir(dir string) Option {
+	return func(c *Crawler) {
+		c.frontierDir = dir
+	}
+}
+
+// diskQueue is a FIFO of tasks in an append-only file, read back by a
+// single dispatcher
+type diskQueue struct {
+	dir string
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   *os.File      // Appended to
+	reader  *bufio.Reader // Reads queue from the start
+	readFd  *os.File
+	written int64 // Tasks in the queue file
+	read    int64 // Tasks read back
+	closed  bool
+
+	// recovered is the number of tasks a continued crawl found in the
+	// queue file, finished the URLs that were done and unfinished how
+	// many of the tasks weren't
+	recovered  int64
+	finished   map[string]bool
+	unfinished int
+
+	doneMu sync.Mutex
+	done   *os.File
+}
+
+// frontierHeader is the first line of a queue file
+type frontierHeader struct {
+	StartURL string `json:"startUrl"`
+}
+
+// openDiskQueue opens the frontier of a crawl from startURL in dir,
+// continuing the one already there if it was for the same start URL
+func openDiskQueue(dir, startURL string) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating frontier directory: %v", err)
+	}
+	q := &diskQueue{dir: dir, finished: map[string]bool{}}
+	q.cond = sync.NewCond(&q.mu)
+
+	queuePath := filepath.Join(dir, frontierQueueFile)
+	donePath := filepath.Join(dir, frontierDoneFile)
+	if err := q.recover(queuePath, donePath, startURL); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if q.queue, err = os.OpenFile(queuePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return nil, fmt.Errorf("error opening frontier: %v", err)
+	}
+	if q.recovered == 0 {
+		header, _ := json.Marshal(frontierHeader{StartURL: startURL})
+		if err := q.queue.Truncate(0); err != nil {
+			q.queue.Close()
+			return nil, fmt.Errorf("error resetting frontier: %v", err)
+		}
+		q.queue.Write(append(header, '\n'))
+	}
+	doneFlags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if q.recovered == 0 {
+		doneFlags |= os.O_TRUNC
+	}
+	if q.done, err = os.OpenFile(donePath, doneFlags, 0o644); err != nil {
+		q.queue.Close()
+		return nil, fmt.Errorf("error opening frontier: %v", err)
+	}
+	if q.readFd, err = os.Open(queuePath); err != nil {
+		q.queue.Close()
+		q.done.Close()
+		return nil, fmt.Errorf("error opening frontier: %v", err)
+	}
+	q.reader = bufio.NewReader(q.readFd)
+	q.reader.ReadBytes('\n') // Header
+	q.written = q.recovered
+	return q, nil
+}
+
+// recover reads the frontier of an earlier run of the same crawl, if there
+// is one
+func (q *diskQueue) recover(queuePath, donePath, startURL string) error {
+	f, err := os.Open(queuePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening frontier: %v", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	line, err := r.ReadBytes('\n')
+	var header frontierHeader
+	if err != nil || json.Unmarshal(line, &header) != nil || header.StartURL != startURL {
+		return nil // Another crawl's frontier, replaced
+	}
+
+	if done, err := os.Open(donePath); err == nil {
+		scanner := bufio.NewScanner(done)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			q.finished[scanner.Text()] = true
+		}
+		done.Close()
+	}
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// A partial last line was cut short by the crash
+			if len(line) > 0 {
+				if err := os.Truncate(queuePath, fileOffset(f, r)-int64(len(line))); err != nil {
+					return fmt.Errorf("error repairing frontier: %v", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading frontier: %v", err)
+		}
+		q.recovered++
+		var t CheckpointTask
+		if json.Unmarshal(line, &t) == nil && !q.finished[t.URL] {
+			q.unfinished++
+		}
+	}
+	return nil
+}
+
+// fileOffset is how far into f a reader of it has read
+func fileOffset(f *os.File, r *bufio.Reader) int64 {
+	pos, _ := f.Seek(0, io.SeekCurrent)
+	return pos - int64(r.Buffered())
+}
+
+// push appends a task to the queue file
+func (q *diskQueue) push(task crawlTask) error {
+	line, err := json.Marshal(CheckpointTask{URL: task.URL, Depth: task.Depth, Source: task.Source, Score: task.Score})
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, err := q.queue.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing frontier: %v", err)
+	}
+	q.written++
+	q.cond.Signal()
+	return nil
+}
+
+// pop waits for the next task, returning false once the queue is closed
+// and every task was read. Tasks of a continued crawl that were finished
+// before it stopped are passed over.
+func (q *diskQueue) pop() (crawlTask, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		for q.read == q.written && !q.closed {
+			q.cond.Wait()
+		}
+		if q.read == q.written {
+			return crawlTask{}, false
+		}
+		line, err := q.reader.ReadBytes('\n')
+		if err != nil {
+			return crawlTask{}, false
+		}
+		q.read++
+		var t CheckpointTask
+		if err := json.Unmarshal(line, &t); err != nil {
+			continue
+		}
+		if q.read <= q.recovered && q.finished[t.URL] {
+			continue
+		}
+		return crawlTask{URL: t.URL, Depth: t.Depth, Source: t.Source, Score: t.Score}, true
+	}
+}
+
+// markDone records that a task's URL was finished
+func (q *diskQueue) markDone(url string) {
+	q.doneMu.Lock()
+	defer q.doneMu.Unlock()
+	q.done.Write([]byte(url + "\n"))
+}
+
+// close wakes the dispatcher once no more tasks will be pushed
+func (q *diskQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// release closes the files, removing them if the crawl is complete
+func (q *diskQueue) release(complete bool) {
+	q.mu.Lock()
+	q.queue.Close()
+	q.readFd.Close()
+	q.mu.Unlock()
+	q.doneMu.Lock()
+	q.done.Close()
+	q.doneMu.Unlock()
+
+	if complete {
+		os.Remove(filepath.Join(q.dir, frontierQueueFile))
+		os.Remove(filepath.Join(q.dir, frontierDoneFile))
+	}
+}
+
+// dispatchDisk feeds the workers from the disk frontier until it is closed
+func (c *Crawler) dispatchDisk(done chan<- struct{}) {
+	defer close(done)
+	for {
+		task, ok := c.disk.pop()
+		if !ok {
+			return
+		}
+		c.frontierMu.Lock()
+		c.frontier[task.URL] = task // In flight, for checkpoints
+		c.frontierMu.Unlock()
+		c.urlsToCrawl <- task
+	}
+}
@@ -0,0 +1,123 @@
+package crawler_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"go-crawler/internal/crawler"
+	"go-crawler/internal/crawler/crawlertest"
+)
+
+// frontierSite is a home page linking to a, b and c, with d linked from b
+func frontierSite() *crawlertest.Site {
+	return crawlertest.NewSite("https://example.com/").
+		Page("/", "Home", "/a", "/b", "/c").
+		Page("/a", "A").
+		Page("/b", "B", "/d").
+		Page("/c", "C").
+		Page("/d", "D")
+}
+
+// pages returns the pages site answered, without robots.txt
+func pages(site *crawlertest.Site) []string {
+	var list []string
+	for _, req := range site.Requests() {
+		if !strings.HasSuffix(req, "/robots.txt") {
+			list = append(list, req)
+		}
+	}
+	slices.Sort(list)
+	return list
+}
+
+// writeFrontier writes the files a disk frontier leaves behind when the
+// process dies: every task queued, the URLs finished, and tail, a last
+// line cut short
+func writeFrontier(t *testing.T, dir, startURL string, queued []crawler.CheckpointTask, done []string, tail string) {
+	t.Helper()
+	var queue strings.Builder
+	header, _ := json.Marshal(map[string]string{"startUrl": startURL})
+	queue.Write(append(header, '\n'))
+	for _, task := range queued {
+		line, _ := json.Marshal(task)
+		queue.Write(append(line, '\n'))
+	}
+	queue.WriteString(tail)
+	if err := os.WriteFile(filepath.Join(dir, "queue.jsonl"), []byte(queue.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var finished string
+	for _, u := range done {
+		finished += u + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "done.log"), []byte(finished), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFrontierDirContinuesCrashedCrawl(t *testing.T) {
+	site := frontierSite()
+	dir := t.TempDir()
+	writeFrontier(t, dir, site.URL("/"), []crawler.CheckpointTask{
+		{URL: site.URL("/"), Depth: 0},
+		{URL: site.URL("/a"), Depth: 1, Source: site.URL("/")},
+		{URL: site.URL("/b"), Depth: 1, Source: site.URL("/")},
+	}, []string{site.URL("/"), site.URL("/a")}, `{"url":"https://exam`)
+
+	c := crawler.NewCrawler(1, 2, 0, site.Option(), crawler.WithFrontierDir(dir))
+	results := site.Crawl(context.Background(), c, "/")
+
+	for _, r := range results {
+		if r.Error != nil {
+			t.Errorf("%s failed: %v", r.URL, r.Error)
+		}
+	}
+	// / and /a were finished and the task cut short is dropped; /b was
+	// queued, and /d is found on it
+	want := []string{"GET " + site.URL("/b"), "GET " + site.URL("/d")}
+	if got := pages(site); !slices.Equal(got, want) {
+		t.Errorf("continued crawl fetched %v, want %v", got, want)
+	}
+	for _, name := range []string{"queue.jsonl", "done.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was left after the crawl finished", name)
+		}
+	}
+}
+
+func TestFrontierDirReplacesOtherCrawl(t *testing.T) {
+	site := frontierSite()
+	dir := t.TempDir()
+	writeFrontier(t, dir, "https://other.example/", []crawler.CheckpointTask{
+		{URL: "https://other.example/", Depth: 0},
+	}, []string{"https://other.example/"}, "")
+
+	c := crawler.NewCrawler(1, 2, 0, site.Option(), crawler.WithFrontierDir(dir))
+	site.Crawl(context.Background(), c, "/")
+
+	want := []string{
+		"GET " + site.URL("/"), "GET " + site.URL("/a"), "GET " + site.URL("/b"),
+		"GET " + site.URL("/c"), "GET " + site.URL("/d"),
+	}
+	if got := pages(site); !slices.Equal(got, want) {
+		t.Errorf("crawl fetched %v, want %v", got, want)
+	}
+}
+
+func TestFrontierDirKeptWhenCancelled(t *testing.T) {
+	site := frontierSite()
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := crawler.NewCrawler(1, 2, 0, site.Option(), crawler.WithFrontierDir(dir))
+	site.Crawl(ctx, c, "/")
+
+	if _, err := os.Stat(filepath.Join(dir, "queue.jsonl")); err != nil {
+		t.Errorf("the frontier of a cancelled crawl was not kept: %v", err)
+	}
+}
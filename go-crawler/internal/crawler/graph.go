@@ -0,0 +1,210 @@
+package crawler
+
+import (
+	"math"
+	"net/url"
+	"sort"
+)
+
+const (
+	pageRankDamping    = 0.85
+	pageRankIterations = 100
+	pageRankTolerance  = 1e-9
+)
+
+// LinkGraph is the internal link graph of a crawl: its nodes are the pages
+// that were fetched and its edges the links between pages on the same host
+type LinkGraph struct {
+	root   string
+	policy HostPolicy
+	nodes  map[string]int // URL -> index
+	urls   []string
+	links  [][]string // Raw links per node, resolved in Stats
+}
+
+// NewLinkGraph returns an empty graph for a crawl that started at root
+func NewLinkGraph(root string) *LinkGraph {
+	return &LinkGraph{root: root, nodes: make(map[string]int)}
+}
+
+// SetHostPolicy sets which URL variants are the same page and host, so
+// that links to a variant count as links to the page
+func (g *LinkGraph) SetHostPolicy(p HostPolicy) {
+	g.policy = p
+}
+
+// Add records a fetched page and the links found on it, as reported in
+// CrawlResult.Links
+func (g *LinkGraph) Add(pageURL string, links []string) {
+	i, ok := g.nodes[pageURL]
+	if !ok {
+		i = len(g.urls)
+		g.nodes[pageURL] = i
+		g.urls = append(g.urls, pageURL)
+		g.links = append(g.links, nil)
+	}
+	g.links[i] = append(g.links[i], links...)
+}
+
+// PageStat is a page's position in the link graph
+type PageStat struct {
+	URL       string  `json:"url"`
+	InDegree  int     `json:"inDegree"`
+	OutDegree int     `json:"outDegree"`
+	PageRank  float64 `json:"pageRank"`
+}
+
+// GraphStats summarizes a link graph
+type GraphStats struct {
+	Pages int `json:"pages"`
+	Links int `json:"links"`
+	// TopPages have the highest PageRank
+	TopPages []PageStat `json:"topPages"`
+	// MostLinked have the most pages linking to them
+	MostLinked []PageStat `json:"mostLinked"`
+	// Orphans are pages other than the start page with at most one
+	// internal link pointing at them, lowest PageRank first. They are hard
+	// to find by navigating the site.
+	Orphans []PageStat `json:"orphans"`
+}
+
+// Stats computes in-degree, out-degree and PageRank for every page and
+// returns the top n pages of each list
+func (g *LinkGraph) Stats(n int) GraphStats {
+	out := g.edges()
+	stats := make([]PageStat, len(g.urls))
+	total := 0
+	for i, u := range g.urls {
+		stats[i].URL = u
+		stats[i].OutDegree = len(out[i])
+		total += len(out[i])
+		for _, j := range out[i] {
+			stats[j].InDegree++
+		}
+	}
+	for i, rank := range pageRank(out) {
+		stats[i].PageRank = rank
+	}
+
+	result := GraphStats{
+		Pages:      len(stats),
+		Links:      total,
+		TopPages:   topPages(stats, n, func(a, b PageStat) bool { return a.PageRank > b.PageRank }),
+		MostLinked: topPages(stats, n, func(a, b PageStat) bool { return a.InDegree > b.InDegree }),
+	}
+
+	var orphans []PageStat
+	for _, s := range stats {
+		if s.InDegree <= 1 && s.URL != g.root {
+			orphans = append(orphans, s)
+		}
+	}
+	result.Orphans = topPages(orphans, n, func(a, b PageStat) bool { return a.PageRank < b.PageRank })
+	return result
+}
+
+// edges resolves every page's links to the distinct crawled pages on the
+// same host that they point at
+func (g *LinkGraph) edges() [][]int {
+	nodes := g.nodes
+	if g.policy != (HostPolicy{}) {
+		nodes = make(map[string]int, len(g.urls))
+		for i, page := range g.urls {
+			if u, err := url.Parse(page); err == nil {
+				if _, dup := nodes[g.policy.Key(u)]; !dup {
+					nodes[g.policy.Key(u)] = i
+				}
+			}
+		}
+	}
+
+	out := make([][]int, len(g.urls))
+	for i, page := range g.urls {
+		base, err := url.Parse(page)
+		if err != nil {
+			continue
+		}
+		seen := make(map[int]bool)
+		for _, link := range g.links[i] {
+			u, err := resolveURL(page, link)
+			if err != nil {
+				continue
+			}
+			u.Fragment = ""
+			j, ok := nodes[g.policy.Key(u)]
+			if !ok || j == i || seen[j] || !g.policy.SameHost(base, u) {
+				continue
+			}
+			seen[j] = true
+			out[i] = append(out[i], j)
+		}
+	}
+	return out
+}
+
+// pageRank runs the power iteration over an adjacency list. Pages without
+// outgoing links spread their rank evenly over every page.
+func pageRank(out [][]int) []float64 {
+	n := len(out)
+	if n == 0 {
+		return nil
+	}
+	rank := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+
+	next := make([]float64, n)
+	for iter := 0; iter < pageRankIterations; iter++ {
+		dangling := 0.0
+		for i := range out {
+			if len(out[i]) == 0 {
+				dangling += rank[i]
+			}
+		}
+		base := (1-pageRankDamping)/float64(n) + pageRankDamping*dangling/float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for i, targets := range out {
+			if len(targets) == 0 {
+				continue
+			}
+			share := pageRankDamping * rank[i] / float64(len(targets))
+			for _, j := range targets {
+				next[j] += share
+			}
+		}
+
+		delta := 0.0
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if delta < pageRankTolerance {
+			break
+		}
+	}
+	return rank
+}
+
+// topPages returns the first n pages in the given order, ties broken by URL
+func topPages(pages []PageStat, n int, before func(a, b PageStat) bool) []PageStat {
+	sorted := append([]PageStat(nil), pages...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if before(sorted[i], sorted[j]) {
+			return true
+		}
+		if before(sorted[j], sorted[i]) {
+			return false
+		}
+		return sorted[i].URL < sorted[j].URL
+	})
+	if n > 0 && len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	if sorted == nil {
+		sorted = []PageStat{}
+	}
+	return sorted
+}
@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// HostMapping rewrites links to one site into links to another, e.g. from
+// production to a staging copy whose pages still use production URLs
+type HostMapping struct {
+	From hostOrigin
+	To   hostOrigin
+}
+
+// hostOrigin is a host with an optional scheme; an empty scheme matches
+// and keeps any scheme
+type hostOrigin struct {
+	Scheme string
+	Host   string
+}
+
+// ParseHostMappings parses from=to pairs such as
+// "https://www.example.com=http://staging.example.com:8080" or
+// "www.example.com=staging.example.com". A side without a scheme keeps the
+// link's scheme. Mappings for a scheme come before those for any scheme of
+// the same host, so they take precedence whatever the pairs' order; a host
+// mapped twice for the same scheme is an error.
+func ParseHostMappings(pairs []string) ([]HostMapping, error) {
+	var parsed []HostMapping
+	seen := make(map[hostOrigin]bool, len(pairs))
+	for _, pair := range pairs {
+		from, to, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid host mapping %q: want from=to", pair)
+		}
+		f, err := parseHostOrigin(from)
+		if err != nil {
+			return nil, err
+		}
+		t, err := parseHostOrigin(to)
+		if err != nil {
+			return nil, err
+		}
+		if seen[f] {
+			return nil, fmt.Errorf("invalid host mapping %q: %s is mapped twice", pair, strings.TrimSpace(from))
+		}
+		seen[f] = true
+		parsed = append(parsed, HostMapping{From: f, To: t})
+	}
+	sort.SliceStable(parsed, func(i, j int) bool {
+		return parsed[i].From.Scheme != "" && parsed[j].From.Scheme == ""
+	})
+	return parsed, nil
+}
+
+func parseHostOrigin(s string) (hostOrigin, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "/")
+	scheme, host, ok := strings.Cut(s, "://")
+	if !ok {
+		scheme, host = "", s
+	}
+	scheme = strings.ToLower(scheme)
+	if scheme != "" && scheme != "http" && scheme != "https" {
+		return hostOrigin{}, fmt.Errorf("invalid host mapping %q: scheme must be http or https", s)
+	}
+	if host == "" || strings.ContainsAny(host, "/?#@") {
+		return hostOrigin{}, fmt.Errorf("invalid host mapping %q: want a host or scheme://host", s)
+	}
+	return hostOrigin{Scheme: scheme, Host: strings.ToLower(host)}, nil
+}
+
+// WithHostMappings rewrites discovered links, assets and alternates whose
+// host matches a mapping before they are queued, so that they count as
+// part of the mapped site, e.g. for scoping to the start host
+func WithHostMappings(mappings ...HostMapping) Option {
+	return func(c *Crawler) {
+		c.hostMappings = mappings
+	}
+}
+
+// mapHost rewrites u in place according to the first matching mapping
+func (c *Crawler) mapHost(u *url.URL) {
+	for _, m := range c.hostMappings {
+		if !strings.EqualFold(u.Host, m.From.Host) || (m.From.Scheme != "" && u.Scheme != m.From.Scheme) {
+			continue
+		}
+		u.Host = m.To.Host
+		if m.To.Scheme != "" {
+			u.Scheme = m.To.Scheme
+		}
+		return
+	}
+}
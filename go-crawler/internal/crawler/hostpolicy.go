@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// HostPolicy decides which variants of a URL are the same page, both for
+// skipping duplicates and for telling internal links from external ones.
+// The zero value treats every variant as a different page.
+type HostPolicy struct {
+	// WWW treats www.example.com and example.com as the same host
+	WWW bool `json:"www,omitempty"`
+	// TrailingSlash treats /docs and /docs/ as the same page
+	TrailingSlash bool `json:"trailingSlash,omitempty"`
+}
+
+// ParseHostPolicy parses a comma-separated list of the variants to treat as
+// the same page: "www" and "trailing-slash"
+func ParseHostPolicy(s string) (HostPolicy, error) {
+	var p HostPolicy
+	for _, field := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "":
+		case "www":
+			p.WWW = true
+		case "trailing-slash", "slash":
+			p.TrailingSlash = true
+		default:
+			return p, fmt.Errorf("unknown URL variant %q: want www or trailing-slash", field)
+		}
+	}
+	return p, nil
+}
+
+// WithHostPolicy sets which URL variants the crawler treats as one page.
+// The first variant found is the one fetched and reported.
+func WithHostPolicy(p HostPolicy) Option {
+	return func(c *Crawler) {
+		c.hostPolicy = p
+	}
+}
+
+// Host returns the lower-case host name of u as the policy compares it
+func (p HostPolicy) Host(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	if p.WWW {
+		host = strings.TrimPrefix(host, "www.")
+	}
+	return host
+}
+
+// SameHost reports whether two URLs are on the same host under the policy
+func (p HostPolicy) SameHost(a, b *url.URL) bool {
+	return p.Host(a) == p.Host(b)
+}
+
+// Key returns the URL that identifies u's page under the policy: u itself
+// for the zero policy, otherwise u without a www. prefix or a trailing
+// slash as configured
+func (p HostPolicy) Key(u *url.URL) string {
+	if !p.WWW && !p.TrailingSlash {
+		return u.String()
+	}
+	k := *u
+	if p.WWW {
+		k.Host = strings.TrimPrefix(strings.ToLower(k.Host), "www.")
+	}
+	if p.TrailingSlash && len(k.Path) > 1 && strings.HasSuffix(k.Path, "/") {
+		k.Path = strings.TrimRight(k.Path, "/")
+		k.RawPath = strings.TrimRight(k.RawPath, "/")
+		if k.Path == "" {
+			k.Path = "/"
+		}
+	}
+	if k.Path == "" && k.Host != "" {
+		k.Path = "/"
+	}
+	return k.String()
+}
+
+// visitKey returns the key a URL is recorded under as visited
+func (c *Crawler) visitKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return c.hostPolicy.Key(u)
+}
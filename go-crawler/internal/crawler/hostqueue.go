@@ -0,0 +1,247 @@
+package crawler
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// maxHostTasks bounds the URLs queued for a single host. Links beyond it
+// are dropped as queue-full without affecting other hosts.
+const maxHostTasks = 10000
+
+// hostQueue is the frontier of a breadth-first crawl, sharded into a FIFO
+// per host. Its dispatcher hands workers a task from the next host, in
+// round-robin order, that may be fetched now: one below the per-host limit,
+// past its robots.txt crawl delay and not paused by a 429 or 503. A slow or
+// throttled host then holds back only its own URLs instead of the workers
+// and queue space every other host needs.
+type hostQueue struct {
+	mu     sync.Mutex
+	hosts  map[string]*hostTasks
+	ring   []string // Hosts with queued tasks, in round-robin order
+	next   int      // Position in ring to look at first, modulo its length
+	closed bool
+	wake   chan struct{} // Signalled when tasks are pushed or released
+	limits hostLimits
+}
+
+// hostTasks is one host's shard of the frontier
+type hostTasks struct {
+	tasks    []crawlTask
+	inFlight int       // Tasks handed out and not yet released
+	nextAt   time.Time // When the crawl delay allows the next request
+	queued   bool      // Whether the host is in the ring
+	probing  bool      // A task is out before the crawl delay is known
+}
+
+func newHostQueue(limits hostLimits) *hostQueue {
+	return &hostQueue{
+		hosts:  make(map[string]*hostTasks),
+		wake:   make(chan struct{}, 1),
+		limits: limits,
+	}
+}
+
+// taskHost returns the host, with any port, a task's URL is fetched from
+func taskHost(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+// push queues a task on its host's shard. With capped set it reports false
+// instead when the shard already holds maxHostTasks.
+func (q *hostQueue) push(task crawlTask, capped bool) bool {
+	host := taskHost(task.URL)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	h := q.hosts[host]
+	if h == nil {
+		h = &hostTasks{}
+		q.hosts[host] = h
+	}
+	if capped && len(h.tasks) >= maxHostTasks {
+		return false
+	}
+	h.tasks = append(h.tasks, task)
+	if !h.queued {
+		h.queued = true
+		q.ring = append(q.ring, host)
+	}
+	q.signal()
+	return true
+}
+
+// hostLimits are what pop checks a host's readiness against
+type hostLimits struct {
+	perHost func() int                              // Tasks in flight per host, 0 = unlimited
+	delay   func(host string) (time.Duration, bool) // Crawl delay, if known yet
+	paused  func(host string) time.Time             // End of a 429 or 503 pause
+	visited func(url string) bool                   // Whether a URL was already crawled
+}
+
+// pop takes the next task from a host that is ready at now. Otherwise it
+// returns when the earliest waiting host will be ready, or the zero time
+// if none is waiting on time, and done once the queue is closed and empty.
+// Tasks for URLs already crawled are taken off the front of a ready host's
+// queue and returned as duplicates, so they don't use up its crawl delay.
+func (q *hostQueue) pop(now time.Time) (task crawlTask, ok bool, duplicates []crawlTask, readyAt time.Time, done bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	limits := q.limits
+	pos := q.next
+	for tries := len(q.ring); tries > 0; tries-- {
+		pos %= len(q.ring)
+		host := q.ring[pos]
+		h := q.hosts[host]
+		delay, known := limits.delay(host)
+		if perHost := limits.perHost(); (perHost > 0 && h.inFlight >= perHost) || (!known && h.inFlight > 0) {
+			// Until robots.txt gives its crawl delay, one request at a time
+			pos++
+			continue
+		}
+		ready := h.nextAt
+		if until := limits.paused(host); until.After(ready) {
+			ready = until
+		}
+		if ready.After(now) {
+			if readyAt.IsZero() || ready.Before(readyAt) {
+				readyAt = ready
+			}
+			pos++
+			continue
+		}
+
+		for len(h.tasks) > 0 && limits.visited(h.tasks[0].URL) {
+			duplicates = append(duplicates, h.tasks[0])
+			h.tasks[0] = crawlTask{}
+			h.tasks = h.tasks[1:]
+		}
+		if len(h.tasks) == 0 {
+			q.leave(pos, h) // The next host moves to pos
+			if len(q.ring) == 0 {
+				break
+			}
+			continue
+		}
+
+		task = h.tasks[0]
+		h.tasks[0] = crawlTask{}
+		h.tasks = h.tasks[1:]
+		h.inFlight++
+		h.nextAt = now.Add(delay)
+		h.probing = !known
+		if len(h.tasks) == 0 {
+			q.leave(pos, h)
+			q.next = pos
+		} else {
+			q.next = pos + 1
+		}
+		return task, true, duplicates, time.Time{}, false
+	}
+	return crawlTask{}, false, duplicates, readyAt, q.closed && len(q.ring) == 0
+}
+
+// leave takes the host at pos, whose queue is empty, out of the ring. Its
+// shard stays while tasks are in flight or its crawl delay runs.
+func (q *hostQueue) leave(pos int, h *hostTasks) {
+	h.tasks = nil
+	h.queued = false
+	q.ring = append(q.ring[:pos], q.ring[pos+1:]...)
+}
+
+// release marks a task of host as finished
+func (q *hostQueue) release(host string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if h := q.hosts[host]; h != nil {
+		h.inFlight--
+		if h.probing {
+			// The first request learnt the crawl delay; count it from now
+			h.probing = false
+			delay, _ := q.limits.delay(host)
+			h.nextAt = time.Now().Add(delay)
+		}
+		if h.inFlight == 0 && !h.queued && !h.nextAt.After(time.Now()) {
+			delete(q.hosts, host)
+		}
+	}
+	q.signal()
+}
+
+// close wakes the dispatcher once no more tasks will be pushed
+func (q *hostQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+// signal wakes the dispatcher without blocking
+func (q *hostQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// hostCrawlDelay returns the robots.txt crawl delay of a host, and false
+// if its rules weren't fetched yet
+func (c *Crawler) hostCrawlDelay(host string) (time.Duration, bool) {
+	if rules, ok := c.robotsMap.Load(host); ok {
+		return rules.(*RobotRules).GetCrawlDelay(), true
+	}
+	return 0, false
+}
+
+// hostLimits returns what the per-host frontier holds hosts to
+func (c *Crawler) hostLimits() hostLimits {
+	return hostLimits{
+		perHost: c.hostLimit,
+		delay:   c.hostCrawlDelay,
+		paused:  c.throttle.pausedUntil,
+		visited: func(u string) bool { return c.visited.Contains(c.visitKey(u)) },
+	}
+}
+
+// dispatchHosts feeds the workers from the per-host frontier until it is
+// closed
+func (c *Crawler) dispatchHosts(done chan<- struct{}) {
+	defer close(done)
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		task, ok, duplicates, readyAt, finished := c.hosts.pop(time.Now())
+		for _, dup := range duplicates {
+			c.skip(dup.URL, dup.Source, SkipDuplicate, "")
+			c.taskDone(dup)
+		}
+		if ok {
+			c.urlsToCrawl <- task
+			continue
+		}
+		if finished {
+			return
+		}
+
+		// Sleep until a task is pushed or released, or a host is ready
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if !readyAt.IsZero() {
+			timer.Reset(time.Until(readyAt))
+		}
+		select {
+		case <-c.hosts.wake:
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package crawler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// testLimits lets one task per host run at once, with crawl delays and
+// visited URLs from the maps given
+func testLimits(delays map[string]time.Duration, visited map[string]bool) hostLimits {
+	return hostLimits{
+		perHost: func() int { return 1 },
+		delay: func(host string) (time.Duration, bool) {
+			return delays[host], true
+		},
+		paused:  func(string) time.Time { return time.Time{} },
+		visited: func(u string) bool { return visited[u] },
+	}
+}
+
+// popURL pops the next task at now and returns its URL, or "" if no task
+// is ready
+func popURL(t *testing.T, q *hostQueue, now time.Time) string {
+	t.Helper()
+	task, ok, _, _, _ := q.pop(now)
+	if !ok {
+		return ""
+	}
+	return task.URL
+}
+
+func TestHostQueueRoundRobin(t *testing.T) {
+	q := newHostQueue(testLimits(nil, nil))
+	for _, u := range []string{"http://a/1", "http://a/2", "http://a/3", "http://b/1", "http://b/2"} {
+		q.push(crawlTask{URL: u}, true)
+	}
+
+	now := time.Now()
+	var got []string
+	for i := 0; i < 5; i++ {
+		u := popURL(t, q, now)
+		got = append(got, u)
+		q.release(taskHost(u))
+	}
+	want := []string{"http://a/1", "http://b/1", "http://a/2", "http://b/2", "http://a/3"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("popped %v, want %v", got, want)
+	}
+}
+
+func TestHostQueuePerHostLimit(t *testing.T) {
+	q := newHostQueue(testLimits(nil, nil))
+	q.push(crawlTask{URL: "http://a/1"}, true)
+	q.push(crawlTask{URL: "http://a/2"}, true)
+
+	now := time.Now()
+	if u := popURL(t, q, now); u != "http://a/1" {
+		t.Fatalf("popped %q, want http://a/1", u)
+	}
+	if u := popURL(t, q, now); u != "" {
+		t.Errorf("popped %q while the host's only slot was in use", u)
+	}
+	q.release("a")
+	if u := popURL(t, q, now); u != "http://a/2" {
+		t.Errorf("popped %q after release, want http://a/2", u)
+	}
+}
+
+func TestHostQueueCrawlDelay(t *testing.T) {
+	q := newHostQueue(testLimits(map[string]time.Duration{"a": time.Minute}, nil))
+	q.push(crawlTask{URL: "http://a/1"}, true)
+	q.push(crawlTask{URL: "http://a/2"}, true)
+
+	now := time.Now()
+	popURL(t, q, now)
+	q.release("a")
+	_, ok, _, readyAt, done := q.pop(now)
+	if ok || done {
+		t.Fatalf("pop during the crawl delay returned ok=%v done=%v", ok, done)
+	}
+	if readyAt.Before(now.Add(59 * time.Second)) {
+		t.Errorf("host ready at %v, want a minute after %v", readyAt, now)
+	}
+	if u := popURL(t, q, readyAt.Add(time.Second)); u != "http://a/2" {
+		t.Errorf("popped %q after the crawl delay, want http://a/2", u)
+	}
+}
+
+func TestHostQueueDuplicates(t *testing.T) {
+	q := newHostQueue(testLimits(nil, map[string]bool{"http://a/1": true, "http://a/2": true}))
+	for _, u := range []string{"http://a/1", "http://a/2", "http://a/3"} {
+		q.push(crawlTask{URL: u}, true)
+	}
+
+	task, ok, duplicates, _, _ := q.pop(time.Now())
+	if !ok || task.URL != "http://a/3" {
+		t.Errorf("popped %q, want http://a/3", task.URL)
+	}
+	if len(duplicates) != 2 {
+		t.Errorf("returned %d duplicates, want 2", len(duplicates))
+	}
+}
+
+func TestHostQueueCap(t *testing.T) {
+	q := newHostQueue(testLimits(nil, nil))
+	for i := 0; i < maxHostTasks; i++ {
+		if !q.push(crawlTask{URL: fmt.Sprintf("http://a/%d", i)}, true) {
+			t.Fatalf("push %d was refused below the cap", i)
+		}
+	}
+	if q.push(crawlTask{URL: "http://a/over"}, true) {
+		t.Error("push over the cap was accepted")
+	}
+	if !q.push(crawlTask{URL: "http://a/seed"}, false) {
+		t.Error("uncapped push over the cap was refused")
+	}
+	if !q.push(crawlTask{URL: "http://b/1"}, true) {
+		t.Error("a full host refused pushes for another host")
+	}
+}
+
+func TestHostQueueDone(t *testing.T) {
+	q := newHostQueue(testLimits(nil, nil))
+	q.push(crawlTask{URL: "http://a/1"}, true)
+	q.close()
+
+	now := time.Now()
+	if _, _, _, _, done := q.pop(now); done {
+		t.Fatal("closed queue with a task reported done")
+	}
+	if _, _, _, _, done := q.pop(now); !done {
+		t.Error("closed, empty queue not reported done")
+	}
+}
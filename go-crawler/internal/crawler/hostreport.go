@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"time"
+)
+
+// HostPage is a crawl result as SummarizeHosts counts it
+type HostPage struct {
+	URL    string
+	Failed bool
+	TTFB   time.Duration // Zero for pages that weren't fetched
+}
+
+// NewHostPage returns the per-host report entry of a crawl result
+func NewHostPage(r CrawlResult) HostPage {
+	return HostPage{URL: r.URL, Failed: r.Error != nil, TTFB: r.Metrics.TTFB}
+}
+
+// HostReport aggregates a crawl's pages, robots.txt and throttling by host
+type HostReport struct {
+	Host   string `json:"host"` // With the port, if the URLs name one
+	Pages  int    `json:"pages"`
+	Errors int    `json:"errors"`
+	// AvgLatency is the mean time to first byte of the host's fetched
+	// pages
+	AvgLatency time.Duration `json:"avgLatency"`
+	// CrawlDelay is the delay between requests the host's robots.txt set,
+	// one second when it names none or couldn't be fetched; nil when the
+	// crawl didn't check robots.txt
+	CrawlDelay *time.Duration `json:"crawlDelay,omitempty"`
+	// Throttled counts the host's 429 and 503 responses, and Paused is how
+	// long requests to it were held back for them
+	Throttled int           `json:"throttled"`
+	Paused    time.Duration `json:"paused"`
+}
+
+// SummarizeHosts aggregates pages by host along with the crawl delays and
+// throttling the crawler recorded for them, most pages first
+func SummarizeHosts(pages []HostPage, crawlDelays map[string]time.Duration, throttling []HostThrottle) []HostReport {
+	byHost := make(map[string]*HostReport)
+	latency := make(map[string]time.Duration)
+	timed := make(map[string]int)
+	host := func(name string) *HostReport {
+		h := byHost[name]
+		if h == nil {
+			h = &HostReport{Host: name}
+			if d, ok := crawlDelays[name]; ok {
+				h.CrawlDelay = &d
+			}
+			byHost[name] = h
+		}
+		return h
+	}
+
+	for _, p := range pages {
+		u, err := url.Parse(p.URL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		h := host(u.Host)
+		h.Pages++
+		if p.Failed {
+			h.Errors++
+		}
+		if p.TTFB > 0 {
+			latency[u.Host] += p.TTFB
+			timed[u.Host]++
+		}
+	}
+	for _, t := range throttling {
+		h := host(t.Host)
+		h.Throttled, h.Paused = t.Responses, t.Paused
+	}
+
+	reports := make([]HostReport, 0, len(byHost))
+	for name, h := range byHost {
+		if timed[name] > 0 {
+			h.AvgLatency = latency[name] / time.Duration(timed[name])
+		}
+		reports = append(reports, *h)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Pages != reports[j].Pages {
+			return reports[i].Pages > reports[j].Pages
+		}
+		return reports[i].Host < reports[j].Host
+	})
+	return reports
+}
+
+// RobotsCrawlDelays returns the crawl delay of every host whose robots.txt
+// the crawler fetched or tried to
+func (c *Crawler) RobotsCrawlDelays() map[string]time.Duration {
+	delays := make(map[string]time.Duration)
+	c.robotsMap.Range(func(host, rules any) bool {
+		delays[host.(string)] = rules.(*RobotRules).GetCrawlDelay()
+		return true
+	})
+	return delays
+}
@@ -0,0 +1,154 @@
+package crawler
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLCheck names a check of a page's markup
+type HTMLCheck string
+
+const (
+	HTMLUnclosedTag HTMLCheck = "unclosed-tag"  // An element whose end tag is missing
+	HTMLStrayEndTag HTMLCheck = "stray-end-tag" // An end tag with no open element to close
+	HTMLDuplicateID HTMLCheck = "duplicate-id"  // An id shared by several elements
+	HTMLMultipleH1  HTMLCheck = "multiple-h1"   // More than one h1 on the page
+)
+
+// HTMLChecks lists every markup check, in the order reports show them
+var HTMLChecks = []HTMLCheck{HTMLUnclosedTag, HTMLStrayEndTag, HTMLDuplicateID, HTMLMultipleH1}
+
+// maxHTMLWarnings bounds the markup warnings reported for a page
+const maxHTMLWarnings = 100
+
+// HTMLWarning is a parse anomaly found in a page's markup. Browsers, like
+// the crawler, recover from these, but not always the same way.
+type HTMLWarning struct {
+	Check   HTMLCheck `json:"check"`
+	Line    int       `json:"line,omitempty"` // Where the tag is, for tag checks
+	Message string    `json:"message"`
+}
+
+// WithHTMLChecks checks every HTML page's markup for unclosed elements,
+// stray end tags, duplicate ids and more than one h1, reporting them in
+// each result's HTMLWarnings
+func WithHTMLChecks() Option {
+	return func(c *Crawler) {
+		c.checkHTML = true
+	}
+}
+
+// optionalEndTags are the elements the HTML spec lets pages leave open
+var optionalEndTags = map[atom.Atom]bool{
+	atom.Html: true, atom.Head: true, atom.Body: true, atom.P: true, atom.Li: true,
+	atom.Dt: true, atom.Dd: true, atom.Option: true, atom.Optgroup: true,
+	atom.Rb: true, atom.Rt: true, atom.Rtc: true, atom.Rp: true, atom.Colgroup: true,
+	atom.Caption: true, atom.Thead: true, atom.Tbody: true, atom.Tfoot: true,
+	atom.Tr: true, atom.Td: true, atom.Th: true,
+}
+
+// voidElements never have content or an end tag
+var voidElements = map[atom.Atom]bool{
+	atom.Area: true, atom.Base: true, atom.Br: true, atom.Col: true, atom.Embed: true,
+	atom.Hr: true, atom.Img: true, atom.Input: true, atom.Link: true, atom.Meta: true,
+	atom.Param: true, atom.Source: true, atom.Track: true, atom.Wbr: true, atom.Keygen: true,
+}
+
+// checkHTML checks a page's markup: the tags of its body, then the
+// document the parser made of them
+func checkHTML(body []byte, doc *html.Node) []HTMLWarning {
+	var warnings []HTMLWarning
+	report := func(check HTMLCheck, line int, format string, args ...any) {
+		if len(warnings) < maxHTMLWarnings {
+			warnings = append(warnings, HTMLWarning{Check: check, Line: line, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	// Match end tags to start tags on a stack of open elements, as the
+	// markup was written rather than as the parser repaired it
+	type openTag struct {
+		name string
+		line int
+	}
+	var open []openTag
+	unclosed := func(t openTag) {
+		if a := atom.Lookup([]byte(t.name)); !optionalEndTags[a] {
+			report(HTMLUnclosedTag, t.line, "<%s> is never closed", t.name)
+		}
+	}
+	z := html.NewTokenizer(bytes.NewReader(body))
+	line := 1
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := z.Raw()
+		at := line
+		line += bytes.Count(raw, []byte("\n"))
+		switch tt {
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			if !voidElements[atom.Lookup(name)] {
+				open = append(open, openTag{string(name), at})
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if voidElements[atom.Lookup(name)] {
+				continue
+			}
+			i := len(open) - 1
+			for i >= 0 && open[i].name != string(name) {
+				i--
+			}
+			if i < 0 {
+				report(HTMLStrayEndTag, at, "</%s> has no open <%s> to close", name, name)
+				continue
+			}
+			for _, t := range open[i+1:] {
+				unclosed(t)
+			}
+			open = open[:i]
+		}
+	}
+	for _, t := range open {
+		unclosed(t)
+	}
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].Line < warnings[j].Line })
+
+	ids := map[string]int{}
+	h1s := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if id := attr(n, "id"); id != "" {
+				ids[id]++
+			}
+			if n.DataAtom == atom.H1 {
+				h1s++
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	var duplicates []string
+	for id, n := range ids {
+		if n > 1 {
+			duplicates = append(duplicates, id)
+		}
+	}
+	sort.Strings(duplicates)
+	for _, id := range duplicates {
+		report(HTMLDuplicateID, 0, "id %q is used by %d elements", id, ids[id])
+	}
+	if h1s > 1 {
+		report(HTMLMultipleH1, 0, "The page has %d h1 elements", h1s)
+	}
+	return warnings
+}
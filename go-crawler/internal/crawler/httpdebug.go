@@ -0,0 +1,337 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxDebugBody is how much of each body an HTTPRecorder keeps
+const maxDebugBody = 1 << 20
+
+// HTTPRecorder records the HTTP exchanges of a crawl for debugging: the
+// request and response headers and, optionally, the response bodies of a
+// sample of fetches, including robots.txt and redirects. Authorization
+// headers are redacted. With a log, exchanges are written to it as they
+// complete; otherwise they are kept in memory for WriteHAR.
+type HTTPRecorder struct {
+	sample float64
+	bodies bool
+	limit  int // Bytes of each body kept, or -1 for all of it
+	log    io.Writer
+
+	mu       sync.Mutex
+	entries  []harEntry
+	recorded int
+}
+
+// NewHTTPRecorder records a fraction sample (0 to 1) of fetches, with their
+// bodies when bodies is set, writing each to log if it isn't nil and
+// keeping them otherwise
+func NewHTTPRecorder(sample float64, bodies bool, log io.Writer) *HTTPRecorder {
+	return &HTTPRecorder{sample: sample, bodies: bodies, limit: maxDebugBody, log: log}
+}
+
+// NewCrawlRecorder records every exchange with its whole body, for a HAR
+// that LoadReplay can re-run the crawl from
+func NewCrawlRecorder() *HTTPRecorder {
+	return &HTTPRecorder{sample: 1, bodies: true, limit: -1}
+}
+
+// WithHTTPRecorder passes every request the crawler sends through r
+func WithHTTPRecorder(r *HTTPRecorder) Option {
+	return func(c *Crawler) {
+		c.httpRecorder = r
+	}
+}
+
+// transport wraps next so that sampled exchanges are recorded
+func (r *HTTPRecorder) transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &recordingTransport{recorder: r, next: next}
+}
+
+// Len returns the number of exchanges recorded
+func (r *HTTPRecorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.recorded
+}
+
+type recordingTransport struct {
+	recorder *HTTPRecorder
+	next     http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := t.recorder
+	if r.sample < 1 && rand.Float64() >= r.sample {
+		return t.next.RoundTrip(req)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	started := time.Now()
+	entry := harEntry{
+		StartedDateTime: started,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     []harPair{},
+			Headers:     harHeaders(req.Header, host),
+			QueryString: harQuery(req),
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Cache: struct{}{},
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	entry.Timings.Wait = millis(time.Since(started))
+	if err != nil {
+		entry.Error = err.Error()
+		entry.Response = harResponse{Cookies: []harPair{}, Headers: []harPair{}, HeadersSize: -1, BodySize: -1}
+		entry.Time = entry.Timings.Wait
+		r.add(entry)
+		return nil, err
+	}
+
+	entry.Response = harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     []harPair{},
+		Headers:     harHeaders(resp.Header, ""),
+		Content:     harContent{Size: -1, MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	resp.Body = &recordingBody{ReadCloser: resp.Body, recorder: r, entry: entry, bodies: r.bodies, limit: r.limit, received: time.Now()}
+	return resp, nil
+}
+
+// recordingBody completes its exchange's entry when the body is closed
+type recordingBody struct {
+	io.ReadCloser
+	recorder *HTTPRecorder
+	entry    harEntry
+	bodies   bool
+	limit    int
+	received time.Time
+	size     int64
+	body     bytes.Buffer
+	once     sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if b.bodies {
+		keep := n
+		if rest := b.limit - b.body.Len(); b.limit >= 0 && keep > rest {
+			keep = rest
+		}
+		b.body.Write(p[:keep])
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	if b.bodies && b.body.Len() < maxDebugBody {
+		// Error pages are often closed unread, yet they are what explains
+		// a block
+		io.CopyN(io.Discard, b, int64(maxDebugBody-b.body.Len()))
+	}
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		e := b.entry
+		e.Timings.Receive = millis(time.Since(b.received))
+		e.Time = e.Timings.Wait + e.Timings.Receive
+		e.Response.Content.Size = b.size
+		e.Response.BodySize = b.size
+		if b.bodies && b.body.Len() > 0 {
+			if utf8.Valid(b.body.Bytes()) {
+				e.Response.Content.Text = b.body.String()
+			} else {
+				e.Response.Content.Text = base64.StdEncoding.EncodeToString(b.body.Bytes())
+				e.Response.Content.Encoding = "base64"
+			}
+		}
+		b.recorder.add(e)
+	})
+	return err
+}
+
+func (r *HTTPRecorder) add(e harEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorded++
+	if r.log != nil {
+		writeDebugEntry(r.log, e)
+		return
+	}
+	r.entries = append(r.entries, e)
+}
+
+// writeDebugEntry writes an exchange in the style of curl -v
+func writeDebugEntry(w io.Writer, e harEntry) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "* %s\n", e.StartedDateTime.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "> %s %s %s\n", e.Request.Method, e.Request.URL, e.Request.HTTPVersion)
+	for _, h := range e.Request.Headers {
+		fmt.Fprintf(&b, "> %s: %s\n", h.Name, h.Value)
+	}
+	if e.Error != "" {
+		fmt.Fprintf(&b, "* Error after %.0fms: %s\n\n", e.Time, e.Error)
+		io.WriteString(w, b.String())
+		return
+	}
+	fmt.Fprintf(&b, "< %s %d %s (%.0fms, %d bytes)\n", e.Response.HTTPVersion, e.Response.Status, e.Response.StatusText, e.Time, e.Response.Content.Size)
+	for _, h := range e.Response.Headers {
+		fmt.Fprintf(&b, "< %s: %s\n", h.Name, h.Value)
+	}
+	if text := e.Response.Content.Text; text != "" {
+		if e.Response.Content.Encoding == "base64" {
+			text = fmt.Sprintf("[%d bytes of binary data]", e.Response.Content.Size)
+		}
+		fmt.Fprintf(&b, "\n%s\n", text)
+	}
+	b.WriteString("\n")
+	io.WriteString(w, b.String())
+}
+
+// WriteHAR writes the recorded exchanges as an HTTP Archive (HAR 1.2) that
+// browser developer tools and HAR viewers can open
+func (r *HTTPRecorder) WriteHAR(w io.Writer) error {
+	r.mu.Lock()
+	entries := append([]harEntry{}, r.entries...)
+	r.mu.Unlock()
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].StartedDateTime.Before(entries[j].StartedDateTime) })
+
+	var har struct {
+		Log struct {
+			Version string     `json:"version"`
+			Creator harCreator `json:"creator"`
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	har.Log.Version = "1.2"
+	har.Log.Creator = harCreator{Name: "GoCrawler", Version: "1.0"}
+	har.Log.Entries = entries
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(har)
+}
+
+// HAR 1.2 structures; fields prefixed with _ are custom
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harRequest struct {
+	Method      string    `json:"method"`
+	URL         string    `json:"url"`
+	HTTPVersion string    `json:"httpVersion"`
+	Cookies     []harPair `json:"cookies"`
+	Headers     []harPair `json:"headers"`
+	QueryString []harPair `json:"queryString"`
+	HeadersSize int       `json:"headersSize"`
+	BodySize    int       `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int        `json:"status"`
+	StatusText  string     `json:"statusText"`
+	HTTPVersion string     `json:"httpVersion"`
+	Cookies     []harPair  `json:"cookies"`
+	Headers     []harPair  `json:"headers"`
+	Content     harContent `json:"content"`
+	RedirectURL string     `json:"redirectURL"`
+	HeadersSize int        `json:"headersSize"`
+	BodySize    int64      `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// harTimings splits an exchange's time: wait is until the response
+// headers arrived, receive is reading the body
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harHeaders lists headers sorted by name, with credentials redacted. A
+// non-empty host is listed first, as the transport sends it.
+func harHeaders(h http.Header, host string) []harPair {
+	pairs := []harPair{}
+	if host != "" {
+		pairs = append(pairs, harPair{"Host", host})
+	}
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h[name] {
+			if name == "Authorization" || name == "Proxy-Authorization" {
+				scheme, _, _ := strings.Cut(v, " ")
+				v = scheme + " [redacted]"
+			}
+			pairs = append(pairs, harPair{name, v})
+		}
+	}
+	return pairs
+}
+
+func harQuery(req *http.Request) []harPair {
+	pairs := []harPair{}
+	for name, values := range req.URL.Query() {
+		for _, v := range values {
+			pairs = append(pairs, harPair{name, v})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,72 @@
+package crawler
+
+import "net/url"
+
+// Inlink is a link to a URL from a crawled page
+type Inlink struct {
+	From string `json:"from"` // The linking page
+	Href string `json:"href"` // The link as the page wrote it
+}
+
+// InlinkIndex maps every URL the crawled pages link to onto the pages
+// linking to it, to find where a broken link is referenced. Links are
+// resolved against their page and compared without their fragment; a page
+// that links to a URL several times, or to itself, is left out.
+type InlinkIndex struct {
+	inlinks map[string][]Inlink
+}
+
+// NewInlinkIndex returns an empty index
+func NewInlinkIndex() *InlinkIndex {
+	return &InlinkIndex{inlinks: make(map[string][]Inlink)}
+}
+
+// Add indexes the links found on a page, as reported in CrawlResult.Links
+func (x *InlinkIndex) Add(pageURL string, links []string) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, link := range links {
+		u, err := base.Parse(link)
+		if err != nil {
+			continue
+		}
+		u.Fragment = ""
+		target := u.String()
+		if target == pageURL || seen[target] {
+			continue
+		}
+		seen[target] = true
+		x.inlinks[target] = append(x.inlinks[target], Inlink{From: pageURL, Href: link})
+	}
+}
+
+// Inlinks returns the links to a URL in the order their pages were added
+func (x *InlinkIndex) Inlinks(target string) []Inlink {
+	return x.inlinks[stripFragment(target)]
+}
+
+// Count returns the number of pages linking to a URL
+func (x *InlinkIndex) Count(target string) int {
+	return len(x.inlinks[stripFragment(target)])
+}
+
+// pages returns the pages linking to a URL
+func (x *InlinkIndex) pages(target string) []string {
+	inlinks := x.Inlinks(target)
+	pages := make([]string, len(inlinks))
+	for i, l := range inlinks {
+		pages[i] = l.From
+	}
+	return pages
+}
+
+func stripFragment(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		u.Fragment = ""
+		return u.String()
+	}
+	return rawURL
+}
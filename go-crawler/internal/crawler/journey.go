@@ -0,0 +1,265 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxJourneyBody bounds the bytes of a journey step's response kept to
+// check ExpectText and read hidden fields from
+const maxJourneyBody = 1 << 20
+
+// Journey is a sequence of requests run before a crawl, such as posting a
+// login form, so that the crawl covers the pages behind it. The session
+// cookies the steps receive are sent with the crawl's requests.
+type Journey struct {
+	Steps []JourneyStep `json:"steps"`
+	// Skip lists URL path prefixes the crawl must not follow once the
+	// journey has run, such as /logout
+	Skip []string `json:"skip,omitempty"`
+}
+
+// JourneyStep is one request of a Journey. Without expectations a step
+// fails when it ends, after redirects, with a 4xx or 5xx status.
+type JourneyStep struct {
+	// URL is resolved against the previous step's final URL, or the
+	// crawl's start URL for the first step
+	URL    string `json:"url"`
+	Method string `json:"method,omitempty"` // GET, or POST when Form is set
+	// Form is sent URL-encoded as the request body
+	Form map[string]string `json:"form,omitempty"`
+	// HiddenFields also sends the hidden inputs of the previous step's
+	// page, such as CSRF tokens; Form overrides them
+	HiddenFields bool              `json:"hiddenFields,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	ExpectStatus int               `json:"expectStatus,omitempty"` // Final status after redirects
+	ExpectText   string            `json:"expectText,omitempty"`   // Text the final page must contain
+}
+
+// ParseJourney reads a journey as JSON and validates it
+func ParseJourney(r io.Reader) (*Journey, error) {
+	var j Journey
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&j); err != nil {
+		return nil, fmt.Errorf("invalid journey: %v", err)
+	}
+	if err := j.Validate(); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// Validate checks that a journey has steps and that each names a URL and
+// a method
+func (j *Journey) Validate() error {
+	if len(j.Steps) == 0 {
+		return fmt.Errorf("invalid journey: no steps")
+	}
+	for i, step := range j.Steps {
+		if strings.TrimSpace(step.URL) == "" {
+			return fmt.Errorf("invalid journey step %d: no URL", i+1)
+		}
+		if _, err := url.Parse(step.URL); err != nil {
+			return fmt.Errorf("invalid journey step %d: %v", i+1, err)
+		}
+		switch strings.ToUpper(step.Method) {
+		case "", http.MethodGet, http.MethodPost:
+		default:
+			return fmt.Errorf("invalid journey step %d: method %s, want GET or POST", i+1, step.Method)
+		}
+	}
+	for _, prefix := range j.Skip {
+		if !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("invalid journey skip %q: want a path starting with /", prefix)
+		}
+	}
+	return nil
+}
+
+// Redacted returns a copy of the journey with its form values and headers
+// masked, to show it without the credentials it carries
+func (j *Journey) Redacted() *Journey {
+	if j == nil {
+		return nil
+	}
+	redacted := &Journey{Skip: j.Skip, Steps: make([]JourneyStep, len(j.Steps))}
+	mask := func(values map[string]string) map[string]string {
+		if values == nil {
+			return nil
+		}
+		masked := make(map[string]string, len(values))
+		for k := range values {
+			masked[k] = "xxxxx"
+		}
+		return masked
+	}
+	for i, step := range j.Steps {
+		step.Form, step.Headers = mask(step.Form), mask(step.Headers)
+		redacted.Steps[i] = step
+	}
+	return redacted
+}
+
+// skips reports whether the crawl must not follow a URL
+func (j *Journey) skips(u *url.URL) bool {
+	for _, prefix := range j.Skip {
+		if strings.HasPrefix(u.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// JourneyError is returned by RunJourney for the step that failed
+type JourneyError struct {
+	Step int // Counted from 1
+	URL  string
+	Err  error
+}
+
+func (e *JourneyError) Error() string {
+	return fmt.Sprintf("journey step %d (%s): %v", e.Step, e.URL, e.Err)
+}
+
+func (e *JourneyError) Unwrap() error { return e.Err }
+
+// WithJourney runs a journey before the crawl when the caller calls
+// RunJourney, keeping the cookies it receives for the crawl's requests and
+// leaving out the URLs under its Skip prefixes
+func WithJourney(j *Journey) Option {
+	return func(c *Crawler) {
+		c.journey = j
+	}
+}
+
+// RunJourney runs the crawler's journey, step by step, before Start. Steps
+// are sent with the crawler's User-Agent, headers and basic auth, ignoring
+// robots.txt, and follow redirects. Steps and redirects to URLs the URL
+// filter rejects fail. It returns a JourneyError for the first step that
+// fails; a crawler without a journey returns nil at once.
+func (c *Crawler) RunJourney(ctx context.Context, startURL string) error {
+	if c.journey == nil {
+		return nil
+	}
+	base, err := url.Parse(startURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %s: %v", startURL, err)
+	}
+	if c.authHost == "" {
+		c.authHost = base.Host
+	}
+
+	var page []byte // Body of the previous step's final response
+	for i, step := range c.journey.Steps {
+		u, err := base.Parse(step.URL)
+		if err != nil {
+			return &JourneyError{Step: i + 1, URL: step.URL, Err: err}
+		}
+		final, body, err := c.journeyStep(ctx, step, u, page)
+		if err != nil {
+			return &JourneyError{Step: i + 1, URL: u.String(), Err: err}
+		}
+		base, page = final, body
+	}
+	return nil
+}
+
+// journeyStep sends a step's request and checks its response, returning
+// the final URL and the start of the body
+func (c *Crawler) journeyStep(ctx context.Context, step JourneyStep, u *url.URL, previous []byte) (*url.URL, []byte, error) {
+	if c.urlFilter != nil && !c.urlFilter(u) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrFiltered, u)
+	}
+	method := strings.ToUpper(step.Method)
+	if method == "" {
+		method = http.MethodGet
+		if step.Form != nil {
+			method = http.MethodPost
+		}
+	}
+
+	var body io.Reader
+	if step.Form != nil || step.HiddenFields {
+		form := url.Values{}
+		if step.HiddenFields {
+			form = hiddenFields(previous)
+		}
+		for name, value := range step.Form {
+			form.Set(name, value)
+		}
+		if method == http.MethodGet {
+			u.RawQuery = form.Encode()
+		} else {
+			body = strings.NewReader(form.Encode())
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	c.setHeaders(req)
+	for name, value := range step.Headers {
+		req.Header.Set(name, value)
+	}
+	c.setAuth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxJourneyBody))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading response: %v", err)
+	}
+
+	switch {
+	case step.ExpectStatus != 0 && resp.StatusCode != step.ExpectStatus:
+		return nil, nil, fmt.Errorf("status %d, want %d", resp.StatusCode, step.ExpectStatus)
+	case step.ExpectStatus == 0 && resp.StatusCode >= 400:
+		return nil, nil, fmt.Errorf("status %d", resp.StatusCode)
+	case step.ExpectText != "" && !bytes.Contains(page, []byte(step.ExpectText)):
+		return nil, nil, fmt.Errorf("%s doesn't contain %q", resp.Request.URL, step.ExpectText)
+	}
+	return resp.Request.URL, page, nil
+}
+
+// hiddenFields returns the names and values of the hidden inputs on a page
+func hiddenFields(page []byte) url.Values {
+	fields := url.Values{}
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return fields
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "input" && strings.EqualFold(attr(n, "type"), "hidden") && attr(n, "name") != "" {
+			fields.Set(attr(n, "name"), attr(n, "value"))
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return fields
+}
+
+// newCookieJar returns a jar for a journey's session cookies
+func newCookieJar() http.CookieJar {
+	jar, _ := cookiejar.New(nil)
+	return jar
+}
@@ -0,0 +1,128 @@
+package crawler
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// WithScriptLinks also follows URLs found by heuristics in inline
+// JavaScript: location assignments, location.assign and replace,
+// window.open and fetch calls and history.pushState and replaceState routes
+// with a literal URL, in <script> elements,
+// on* event handlers and javascript: links, plus data-href and data-url
+// attributes. Scripts are not run, so URLs built at runtime are missed.
+func WithScriptLinks() Option {
+	return func(c *Crawler) {
+		c.scriptLinks = true
+	}
+}
+
+// jsQuoted matches a string literal without interpolation
+const jsQuoted = `(?:'([^'\\\n]*)'|"([^"\\\n]*)"|` + "`([^`$\\\\]*)`" + `)`
+
+// jsURLPatterns find URLs passed to navigation and fetch calls
+var jsURLPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\blocation(?:\.href)?\s*=\s*` + jsQuoted),
+	regexp.MustCompile(`\blocation\.(?:assign|replace)\(\s*` + jsQuoted),
+	regexp.MustCompile(`\bwindow\.open\(\s*` + jsQuoted),
+	regexp.MustCompile(`\bfetch\(\s*` + jsQuoted),
+	regexp.MustCompile(`\bhistory\.(?:pushState|replaceState)\([^,()]*,[^,()]*,\s*` + jsQuoted),
+}
+
+// dataURLAttrs are attributes scripts commonly turn into navigation
+var dataURLAttrs = []string{"data-href", "data-url"}
+
+// scriptLinks returns the URLs the heuristics find in a page, each once
+func scriptLinks(doc *html.Node) []string {
+	var links []string
+	seen := make(map[string]bool)
+	add := func(link string) {
+		link = strings.TrimSpace(link)
+		if link == "" || seen[link] || strings.ContainsAny(link, " \t\n<>") || strings.HasPrefix(link, "#") {
+			return
+		}
+		seen[link] = true
+		links = append(links, link)
+	}
+	addJS := func(code string) {
+		for _, m := range scriptURLs(code) {
+			add(m)
+		}
+	}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if n.Data == "script" && attr(n, "src") == "" && isJavaScript(attr(n, "type")) {
+				var b strings.Builder
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.Type == html.TextNode {
+						b.WriteString(c.Data)
+					}
+				}
+				addJS(b.String())
+			}
+			for _, a := range n.Attr {
+				switch {
+				case strings.HasPrefix(a.Key, "on"):
+					addJS(a.Val)
+				case a.Key == "href" && strings.HasPrefix(strings.ToLower(strings.TrimSpace(a.Val)), "javascript:"):
+					addJS(a.Val)
+				}
+			}
+			for _, key := range dataURLAttrs {
+				add(attr(n, key))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return links
+}
+
+// addScriptLinks appends the page's script links that aren't among its
+// links already, with empty anchor text
+func (p *pageLinks) addScriptLinks() {
+	have := make(map[string]bool, len(p.links))
+	for _, link := range p.links {
+		have[link] = true
+	}
+	for _, link := range scriptLinks(p.doc) {
+		if !have[link] {
+			p.links = append(p.links, link)
+			p.anchors = append(p.anchors, "")
+		}
+	}
+}
+
+// scriptURLs returns the literal URLs a piece of JavaScript navigates to or
+// fetches
+func scriptURLs(code string) []string {
+	var urls []string
+	for _, re := range jsURLPatterns {
+		for _, m := range re.FindAllStringSubmatch(code, -1) {
+			for _, group := range m[1:] {
+				if group != "" {
+					urls = append(urls, group)
+					break
+				}
+			}
+		}
+	}
+	return urls
+}
+
+// isJavaScript reports whether a <script> type attribute denotes
+// JavaScript rather than data such as JSON or templates
+func isJavaScript(typ string) bool {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	switch typ {
+	case "", "module", "text/javascript", "application/javascript", "text/ecmascript", "application/ecmascript":
+		return true
+	}
+	return false
+}
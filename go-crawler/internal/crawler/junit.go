@@ -0,0 +1,167 @@
+package crawler
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// LinkCheck is the outcome of fetching one URL, for link-check reports
+type LinkCheck struct {
+	URL        string
+	StatusCode int
+	Error      string     // Empty when the URL was fetched successfully
+	Class      ErrorClass // The kind of error, if any
+	Links      []string   // As reported in CrawlResult.Links
+}
+
+// NewLinkCheck returns the link-check view of a crawl result
+func NewLinkCheck(r CrawlResult) LinkCheck {
+	check := LinkCheck{URL: r.URL, StatusCode: r.StatusCode, Links: r.Links}
+	if r.Error != nil {
+		check.Error, check.Class = r.Error.Error(), Classify(r.Error)
+	}
+	return check
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (s *junitTestSuite) add(name, className string, failure *junitFailure) {
+	s.Tests++
+	if failure != nil {
+		s.Failures++
+	}
+	s.Cases = append(s.Cases, junitTestCase{Name: name, ClassName: className, Failure: failure})
+}
+
+// WriteJUnitReport writes a link check as JUnit XML, the test report format
+// CI systems display. Every fetched URL is a test case in the "links"
+// suite, failing with the pages that link to it when it could not be
+// fetched; broken assets and alternates, when checked, are failing cases
+// in suites of their own.
+func WriteJUnitReport(w io.Writer, checks []LinkCheck, assets []BrokenAsset, alternates []BrokenAlternate) error {
+	referrers := NewInlinkIndex()
+	for _, c := range checks {
+		referrers.Add(c.URL, c.Links)
+	}
+
+	links := junitTestSuite{Name: "links"}
+	for _, c := range checks {
+		var failure *junitFailure
+		if c.Error != "" {
+			failure = &junitFailure{
+				Message: c.Error,
+				Type:    c.failureType(),
+				Text:    linkedFrom(referrers.pages(c.URL)),
+			}
+		}
+		links.add(c.URL, junitClassName(c.URL), failure)
+	}
+
+	suites := junitTestSuites{Name: "link check", Suites: []junitTestSuite{links}}
+	if len(assets) > 0 {
+		suite := junitTestSuite{Name: "assets"}
+		for _, a := range assets {
+			message := a.Error
+			if message == "" {
+				message = fmt.Sprintf("status %d", a.StatusCode)
+			}
+			suite.add(a.URL, junitClassName(a.URL), &junitFailure{
+				Message: fmt.Sprintf("%s: %s", a.Kind, message),
+				Type:    failureType(a.StatusCode),
+				Text:    linkedFrom(a.Pages),
+			})
+		}
+		suites.Suites = append(suites.Suites, suite)
+	}
+	if len(alternates) > 0 {
+		suite := junitTestSuite{Name: "alternates"}
+		for _, a := range alternates {
+			suite.add(a.URL, junitClassName(a.URL), &junitFailure{
+				Message: fmt.Sprintf("%s: %s", a.Kind, a.Problem),
+				Type:    failureType(a.StatusCode),
+				Text:    linkedFrom(a.Pages),
+			})
+		}
+		suites.Suites = append(suites.Suites, suite)
+	}
+	for _, s := range suites.Suites {
+		suites.Tests += s.Tests
+		suites.Failures += s.Failures
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// failureType names a failure by its status code, or "error" when the
+// request itself failed
+func failureType(status int) string {
+	if status == 0 {
+		return "error"
+	}
+	return fmt.Sprintf("status %d", status)
+}
+
+// failureType names a failed link by its status code or, when the request
+// itself failed or the page wasn't HTML, by its error class
+func (c LinkCheck) failureType() string {
+	if c.Class != "" && (c.StatusCode == 0 || c.Class == ClassNonHTML) {
+		return string(c.Class)
+	}
+	return failureType(c.StatusCode)
+}
+
+func linkedFrom(pages []string) string {
+	if len(pages) == 0 {
+		return ""
+	}
+	sorted := append([]string(nil), pages...)
+	sort.Strings(sorted)
+	return "Linked from:\n" + strings.Join(sorted, "\n")
+}
+
+// junitClassName groups test cases by host, which CI systems show as the
+// test class
+func junitClassName(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "links"
+}
@@ -0,0 +1,137 @@
+package crawler
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// languageStopwords are frequent words used to guess the language of a
+// page that doesn't declare one
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "for", "with", "this", "are", "was"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "sich", "auf", "ein", "eine", "für"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "dans", "pour", "pas", "qui", "sur"},
+	"es": {"el", "la", "los", "las", "y", "que", "es", "en", "por", "una", "para", "con"},
+	"it": {"il", "di", "che", "è", "e", "la", "per", "una", "sono", "non", "con", "gli"},
+	"nl": {"de", "het", "een", "en", "van", "is", "niet", "dat", "op", "te", "zijn", "voor"},
+	"pt": {"o", "a", "os", "de", "que", "e", "não", "uma", "para", "com", "do", "da"},
+}
+
+// minLanguageWords is how many words a page needs before its language is
+// guessed from its text
+const minLanguageWords = 20
+
+// WithLanguages only follows links on pages whose language is one of
+// langs, e.g. "en" or "pt-BR". A tag without a region matches every region
+// of that language. The language is taken from <html lang>, a
+// Content-Language meta tag or header, or else guessed from the page's
+// text; links on pages whose language can't be detected are followed.
+func WithLanguages(langs ...string) Option {
+	return func(c *Crawler) {
+		c.languages = nil
+		for _, lang := range langs {
+			if lang = normalizeLanguage(lang); lang != "" {
+				c.languages = append(c.languages, lang)
+			}
+		}
+	}
+}
+
+// normalizeLanguage lowercases a language tag and uses "-" as separator
+func normalizeLanguage(tag string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(tag)), "_", "-")
+}
+
+// languageAllowed reports whether a page language matches the configured
+// languages. Pages without a detected language are allowed.
+func (c *Crawler) languageAllowed(lang string) bool {
+	if len(c.languages) == 0 || lang == "" {
+		return true
+	}
+	primary, _, _ := strings.Cut(lang, "-")
+	for _, want := range c.languages {
+		if want == lang || want == primary {
+			return true
+		}
+	}
+	return false
+}
+
+// pageLanguage returns the language declared by a page or, failing that,
+// guessed from its text. header is the Content-Language response header.
+func pageLanguage(doc *html.Node, header string) string {
+	if lang := declaredLanguage(doc); lang != "" {
+		return lang
+	}
+	// Content-Language may list several languages; only trust a single one
+	if header != "" && !strings.Contains(header, ",") {
+		return normalizeLanguage(header)
+	}
+	return guessLanguage(pageText(doc))
+}
+
+// declaredLanguage returns the lang attribute of the <html> element or a
+// <meta http-equiv="content-language"> value
+func declaredLanguage(doc *html.Node) string {
+	var lang, meta string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Data == "html" && lang == "":
+				lang = attr(n, "lang")
+			case n.Data == "meta" && meta == "" && strings.EqualFold(attr(n, "http-equiv"), "content-language"):
+				if content := attr(n, "content"); !strings.Contains(content, ",") {
+					meta = content
+				}
+			case n.Data == "body":
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	if lang != "" {
+		return normalizeLanguage(lang)
+	}
+	return normalizeLanguage(meta)
+}
+
+// guessLanguage returns the language whose stopwords occur most often in
+// text, or "" if the text is too short or matches none clearly
+func guessLanguage(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) < minLanguageWords {
+		return ""
+	}
+	counts := make(map[string]int, len(words))
+	for _, w := range words {
+		counts[w]++
+	}
+
+	best, bestScore, second := "", 0, 0
+	for lang, stopwords := range languageStopwords {
+		score := 0
+		for _, w := range stopwords {
+			score += counts[w]
+		}
+		switch {
+		case score > bestScore || (score == bestScore && lang < best):
+			best, bestScore, second = lang, score, bestScore
+		case score > second:
+			second = score
+		}
+	}
+	// Require stopwords to make up a fair share of the text and the best
+	// language to stand out from the runner-up
+	if bestScore*10 < len(words) || bestScore < second*3/2 {
+		return ""
+	}
+	return best
+}
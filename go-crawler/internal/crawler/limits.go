@@ -0,0 +1,64 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// WithMaxURLLength skips URLs longer than n bytes; zero means unlimited
+func WithMaxURLLength(n int) Option {
+	return func(c *Crawler) {
+		c.maxURLLength = n
+	}
+}
+
+// WithMaxSegmentRepeats skips URLs in which any path segment occurs more
+// than n times, as in /a/b/a/b/a/b; zero means unlimited
+func WithMaxSegmentRepeats(n int) Option {
+	return func(c *Crawler) {
+		c.maxSegmentRepeats = n
+	}
+}
+
+// WithMaxLinksPerPage follows only the first n links of a page, in
+// document order, so that pages with tens of thousands of links don't
+// flood the frontier; zero means unlimited. Results report how many links
+// were dropped in LinksTruncated.
+func WithMaxLinksPerPage(n int) Option {
+	return func(c *Crawler) {
+		c.maxLinksPerPage = n
+	}
+}
+
+// capLinks drops a page's links beyond the per-page cap
+func (c *Crawler) capLinks(result *CrawlResult) {
+	if c.maxLinksPerPage <= 0 || len(result.Links) <= c.maxLinksPerPage {
+		return
+	}
+	result.LinksTruncated = len(result.Links) - c.maxLinksPerPage
+	result.Links = result.Links[:c.maxLinksPerPage]
+	if len(result.anchors) > c.maxLinksPerPage {
+		result.anchors = result.anchors[:c.maxLinksPerPage]
+	}
+}
+
+// checkURLLimits applies the cheap URL length and segment repetition caps,
+// returning a description of the violated limit
+func (c *Crawler) checkURLLimits(u *url.URL) (string, bool) {
+	if c.maxURLLength > 0 {
+		if n := len(u.String()); n > c.maxURLLength {
+			return fmt.Sprintf("length %d exceeds %d", n, c.maxURLLength), false
+		}
+	}
+
+	if c.maxSegmentRepeats > 0 {
+		counts := make(map[string]int)
+		for _, seg := range pathSegments(u.Path) {
+			counts[seg]++
+			if counts[seg] > c.maxSegmentRepeats {
+				return fmt.Sprintf("segment %q repeated more than %d times", seg, c.maxSegmentRepeats), false
+			}
+		}
+	}
+	return "", true
+}
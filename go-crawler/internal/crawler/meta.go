@@ -0,0 +1,178 @@
+package crawler
+
+import (
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithCanonicalRedirects treats rel=canonical as a redirect: a page whose
+// canonical URL names another page is considered a duplicate, its links
+// are not followed, and the canonical page is crawled at the same depth
+// instead
+func WithCanonicalRedirects() Option {
+	return func(c *Crawler) {
+		c.followCanonical = true
+	}
+}
+
+// MetaRefresh is a <meta http-equiv="refresh"> redirect
+type MetaRefresh struct {
+	URL   string `json:"url"`
+	Delay int    `json:"delay"` // Seconds before the browser follows it
+}
+
+// WithMetaRefreshRedirects follows meta refresh redirects like HTTP ones:
+// the target is crawled at the same depth as the page. Chains of more than
+// 10 refreshes in a row are not followed further.
+func WithMetaRefreshRedirects() Option {
+	return func(c *Crawler) {
+		c.followRefresh = true
+	}
+}
+
+// redirectTarget resolves a rel=canonical or meta refresh href against the
+// page URL and applies host mappings, returning "" when it is missing,
+// invalid or names the page itself
+func (c *Crawler) redirectTarget(page *url.URL, href string) string {
+	if href == "" {
+		return ""
+	}
+	target, err := page.Parse(strings.TrimSpace(href))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return ""
+	}
+	target.Fragment = ""
+	c.mapHost(target)
+	self := *page
+	self.Fragment = ""
+	if target.String() == self.String() {
+		return ""
+	}
+	return target.String()
+}
+
+// metaRefresh returns the redirect of a meta refresh content value, or nil
+// if it is invalid or only reloads the page
+func (c *Crawler) metaRefresh(page *url.URL, content string) *MetaRefresh {
+	delay, href, ok := parseMetaRefresh(content)
+	if !ok {
+		return nil
+	}
+	target := c.redirectTarget(page, href)
+	if target == "" {
+		return nil
+	}
+	return &MetaRefresh{URL: target, Delay: delay}
+}
+
+// parseMetaRefresh splits a meta refresh content value such as
+// "5; url='/next'" into its delay and URL, which is empty when the page
+// only reloads itself
+func parseMetaRefresh(content string) (int, string, bool) {
+	content = strings.TrimSpace(content)
+	i := 0
+	for i < len(content) && (content[i] >= '0' && content[i] <= '9' || content[i] == '.') {
+		i++
+	}
+	if i == 0 {
+		return 0, "", false
+	}
+	whole, _, _ := strings.Cut(content[:i], ".")
+	delay, _ := strconv.Atoi(whole)
+
+	rest := strings.TrimSpace(content[i:])
+	if rest == "" {
+		return delay, "", true
+	}
+	if rest[0] != ';' && rest[0] != ',' {
+		return 0, "", false
+	}
+	rest = strings.TrimSpace(rest[1:])
+	if len(rest) >= 3 && strings.EqualFold(rest[:3], "url") {
+		if after := strings.TrimSpace(rest[3:]); strings.HasPrefix(after, "=") {
+			rest = strings.TrimSpace(after[1:])
+		}
+	}
+	if rest != "" && (rest[0] == '\'' || rest[0] == '"') {
+		quote := rest[0]
+		rest = rest[1:]
+		if end := strings.IndexByte(rest, quote); end >= 0 {
+			rest = rest[:end]
+		}
+	}
+	return delay, strings.TrimSpace(rest), true
+}
+
+// followMetaRefresh queues a page's meta refresh target at the page's
+// depth, as if the page had redirected there, unless c.maxRedirects
+// refreshes in a row already led to the page
+func (c *Crawler) followMetaRefresh(task crawlTask, target string) {
+	hops := 0
+	if v, ok := c.refreshHops.Load(task.URL); ok {
+		hops = v.(int)
+	}
+	if hops >= c.maxRedirects {
+		log.Printf("Not following meta refresh from %s to %s: %d refreshes in a row", task.URL, target, hops)
+		return
+	}
+	c.refreshHops.LoadOrStore(target, hops+1)
+	c.queueLinks(task.URL, []string{target}, nil, task.Depth)
+}
+
+// unavailableAfterLayouts are the date formats accepted for the
+// unavailable_after directive: RFC 850 as originally specified, RFC 822 and
+// RFC 1123 variants, and ISO 8601
+var unavailableAfterLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	time.RFC850,
+	"02-Jan-2006 15:04:05 MST",
+	"2-Jan-2006 15:04:05 MST",
+	time.RFC1123,
+	time.RFC1123Z,
+	time.RFC822,
+	time.RFC822Z,
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006",
+}
+
+// unavailableAfter returns the earliest unavailable_after date in a set of
+// robots directives, from <meta name="robots"> or X-Robots-Tag headers
+func unavailableAfter(directives []string) time.Time {
+	var earliest time.Time
+	for _, d := range directives {
+		i := strings.Index(strings.ToLower(d), "unavailable_after:")
+		if i < 0 {
+			continue
+		}
+		t, ok := parseDirectiveDate(strings.TrimSpace(d[i+len("unavailable_after:"):]))
+		if ok && (earliest.IsZero() || t.Before(earliest)) {
+			earliest = t
+		}
+	}
+	return earliest
+}
+
+// parseDirectiveDate parses the date at the start of s. Other directives
+// may follow it after a comma, and some date formats contain commas
+// themselves, so every comma-separated prefix is tried.
+func parseDirectiveDate(s string) (time.Time, bool) {
+	candidates := []string{s}
+	for i := len(s) - 1; i > 0; i-- {
+		if s[i] == ',' {
+			candidates = append(candidates, strings.TrimSpace(s[:i]))
+		}
+	}
+	for _, c := range candidates {
+		for _, layout := range unavailableAfterLayouts {
+			if t, err := time.Parse(layout, c); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,128 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fields and issues of a MetadataGroup
+const (
+	MetadataTitle       = "title"
+	MetadataDescription = "description"
+
+	MetadataDuplicate = "duplicate"
+	MetadataMissing   = "missing"
+)
+
+// MetadataGroup is a set of pages that share a title or meta description,
+// or that lack one
+type MetadataGroup struct {
+	Field string   `json:"field"`           // MetadataTitle or MetadataDescription
+	Issue string   `json:"issue"`           // MetadataDuplicate or MetadataMissing
+	Value string   `json:"value,omitempty"` // What the duplicates share
+	URLs  []string `json:"urls"`
+}
+
+// MetadataReport is the outcome of CheckMetadata. The counts are of pages,
+// not groups.
+type MetadataReport struct {
+	Pages                 int             `json:"pages"` // Pages checked
+	DuplicateTitles       int             `json:"duplicateTitles"`
+	MissingTitles         int             `json:"missingTitles"`
+	DuplicateDescriptions int             `json:"duplicateDescriptions"`
+	MissingDescriptions   int             `json:"missingDescriptions"`
+	Groups                []MetadataGroup `json:"groups"`
+}
+
+// CheckMetadata finds the pages that share their title or meta description
+// with another page, and those without one. Only HTML pages answered with
+// 200 OK are checked, leaving out those whose rel=canonical names another
+// page since search engines index that page instead. Values are compared
+// with whitespace collapsed. Duplicates come first, the largest groups
+// first.
+func CheckMetadata(pages []AuditPage) MetadataReport {
+	report := MetadataReport{Groups: []MetadataGroup{}}
+	titles := newMetadataIndex()
+	descriptions := newMetadataIndex()
+	for _, p := range pages {
+		if p.StatusCode != http.StatusOK || !isHTML(p.ContentType) || p.Canonical != "" {
+			continue
+		}
+		report.Pages++
+		titles.add(p.URL, p.Title)
+		descriptions.add(p.URL, p.MetaDescription)
+	}
+
+	report.Groups = append(report.Groups, titles.groups(MetadataTitle)...)
+	report.Groups = append(report.Groups, descriptions.groups(MetadataDescription)...)
+	for _, g := range report.Groups {
+		switch {
+		case g.Field == MetadataTitle && g.Issue == MetadataDuplicate:
+			report.DuplicateTitles += len(g.URLs)
+		case g.Field == MetadataTitle:
+			report.MissingTitles += len(g.URLs)
+		case g.Issue == MetadataDuplicate:
+			report.DuplicateDescriptions += len(g.URLs)
+		default:
+			report.MissingDescriptions += len(g.URLs)
+		}
+	}
+	return report
+}
+
+// metadataIndex groups pages by the value of one field
+type metadataIndex struct {
+	urls    map[string][]string // By normalized value
+	values  map[string]string   // Normalized value to the first page's value
+	order   []string            // Normalized values in crawl order
+	missing []string
+}
+
+func newMetadataIndex() *metadataIndex {
+	return &metadataIndex{urls: make(map[string][]string), values: make(map[string]string)}
+}
+
+func (m *metadataIndex) add(url, value string) {
+	key := strings.Join(strings.Fields(value), " ")
+	if key == "" {
+		m.missing = append(m.missing, url)
+		return
+	}
+	if _, ok := m.urls[key]; !ok {
+		m.values[key] = value
+		m.order = append(m.order, key)
+	}
+	m.urls[key] = append(m.urls[key], url)
+}
+
+// groups returns the groups of duplicates, largest first, then the pages
+// missing the field
+func (m *metadataIndex) groups(field string) []MetadataGroup {
+	var groups []MetadataGroup
+	for _, key := range m.order {
+		if urls := m.urls[key]; len(urls) > 1 {
+			groups = append(groups, MetadataGroup{Field: field, Issue: MetadataDuplicate, Value: m.values[key], URLs: urls})
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return len(groups[i].URLs) > len(groups[j].URLs) })
+	if len(m.missing) > 0 {
+		groups = append(groups, MetadataGroup{Field: field, Issue: MetadataMissing, URLs: m.missing})
+	}
+	return groups
+}
+
+// WriteMetadataCSV writes one row per group of a metadata report, with its
+// URLs separated by spaces
+func WriteMetadataCSV(w io.Writer, report MetadataReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"field", "issue", "value", "pages", "urls"})
+	for _, g := range report.Groups {
+		cw.Write([]string{g.Field, g.Issue, g.Value, strconv.Itoa(len(g.URLs)), strings.Join(g.URLs, " ")})
+	}
+	cw.Flush()
+	return cw.Error()
+}
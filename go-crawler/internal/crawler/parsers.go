@@ -0,0 +1,264 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LinkParser extracts the links of a fetched document that isn't HTML.
+// Relative links are resolved against the document's URL.
+type LinkParser func(body []byte) ([]string, error)
+
+// Parsers maps media types, such as "text/plain", to the parsers for
+// documents of that type
+type Parsers map[string]LinkParser
+
+// defaultParsers read RSS and Atom feeds, including feeds served as
+// generic XML
+func defaultParsers() Parsers {
+	p := Parsers{"application/xml": parseXMLLinks(false), "text/xml": parseXMLLinks(false)}
+	for _, t := range feedTypes {
+		p[t] = func(body []byte) ([]string, error) { return parseFeed(bytes.NewReader(body)) }
+	}
+	return p
+}
+
+// builtinParsers are the optional parsers, by name
+var builtinParsers = map[string]Parsers{
+	// XML sitemaps and sitemap indexes, gzipped or not; other XML is read
+	// as a feed
+	"sitemap": {
+		"application/xml":    parseXMLLinks(true),
+		"text/xml":           parseXMLLinks(true),
+		"application/gzip":   parseXMLLinks(true),
+		"application/x-gzip": parseXMLLinks(true),
+	},
+	// The http and https URLs in plain text, such as URL lists
+	"text": {"text/plain": parseTextLinks},
+	// The link annotations of PDF documents
+	"pdf": {"application/pdf": parsePDFLinks},
+}
+
+// BuiltinParserNames lists the optional parsers BuiltinParsers knows
+func BuiltinParserNames() []string {
+	names := make([]string, 0, len(builtinParsers))
+	for name := range builtinParsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuiltinParsers returns the optional parsers with the given names for
+// WithParsers: sitemap, text and pdf
+func BuiltinParsers(names ...string) (Parsers, error) {
+	p := Parsers{}
+	for _, name := range names {
+		parsers, ok := builtinParsers[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown parser %q (want %s)", name, strings.Join(BuiltinParserNames(), ", "))
+		}
+		for t, parse := range parsers {
+			p[t] = parse
+		}
+	}
+	return p, nil
+}
+
+// WithParsers adds or replaces the parsers of non-HTML documents whose
+// links are followed. RSS and Atom feeds are parsed unless replaced.
+func WithParsers(p Parsers) Option {
+	return func(c *Crawler) {
+		for t, parse := range p {
+			c.parsers[strings.ToLower(t)] = parse
+		}
+	}
+}
+
+// extensionTypes are the media types assumed for responses with a generic
+// content type or none, by file extension
+var extensionTypes = map[string]string{
+	".xml":  "application/xml",
+	".rss":  "application/rss+xml",
+	".atom": "application/atom+xml",
+	".gz":   "application/gzip",
+	".txt":  "text/plain",
+	".pdf":  "application/pdf",
+}
+
+// isGenericType reports whether a media type says nothing about the
+// document, so that its file extension is a better guide
+func isGenericType(mediaType string) bool {
+	return mediaType == "" || mediaType == "text/plain" || mediaType == "application/octet-stream"
+}
+
+// mediaType returns the lower-case media type of a Content-Type value
+func mediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t, _, _ = strings.Cut(contentType, ";")
+	}
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+// parserFor returns the parser for a response by its content type or, for
+// generic types, its URL's file extension, and nil if there is none
+func (c *Crawler) parserFor(contentType, urlPath string) LinkParser {
+	t := mediaType(contentType)
+	if isGenericType(t) {
+		if byExt, ok := extensionTypes[strings.ToLower(path.Ext(urlPath))]; ok {
+			t = byExt
+		}
+	}
+	return c.parsers[t]
+}
+
+// parseXMLLinks returns a parser for generic XML documents that reads
+// sitemaps, when sitemaps is set, and feeds otherwise
+func parseXMLLinks(sitemaps bool) LinkParser {
+	return func(body []byte) ([]string, error) {
+		if sitemaps && len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+			gz, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, fmt.Errorf("error decompressing sitemap: %v", err)
+			}
+			defer gz.Close()
+			if body, err = io.ReadAll(io.LimitReader(gz, maxInflated)); err != nil {
+				return nil, fmt.Errorf("error decompressing sitemap: %v", err)
+			}
+		}
+		if sitemaps && isSitemap(body) {
+			pages, nested, err := parseSitemap(bytes.NewReader(body))
+			return append(pages, nested...), err
+		}
+		return parseFeed(bytes.NewReader(body))
+	}
+}
+
+// sitemapRoot matches the root element of a sitemap or sitemap index
+var sitemapRoot = regexp.MustCompile(`<(?:\w+:)?(?:urlset|sitemapindex)[\s>]`)
+
+// isSitemap reports whether an XML document is a sitemap, by its start
+func isSitemap(body []byte) bool {
+	if len(body) > 4096 {
+		body = body[:4096]
+	}
+	return sitemapRoot.Match(body)
+}
+
+// textURL matches an http or https URL in plain text
+var textURL = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// parseTextLinks returns the http and https URLs in a plain text document
+func parseTextLinks(body []byte) ([]string, error) {
+	var links []string
+	for _, m := range textURL.FindAll(body, -1) {
+		// Sentence punctuation and closing brackets aren't part of the URL
+		link := strings.TrimRight(string(m), ".,;:!?)]}")
+		if len(link) > len("https://") {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+// maxInflated bounds how much a compressed sitemap or PDF stream is
+// inflated to, against compression bombs
+const maxInflated = 50 << 20
+
+// pdfURI matches the target of a PDF URI action as a literal or hex string
+var pdfURI = regexp.MustCompile(`/URI\s*(?:\(((?:[^()\\]|\\.)*)\)|<([0-9A-Fa-f\s]*)>)`)
+
+// pdfStream matches the start of a PDF stream's data
+var pdfStream = regexp.MustCompile(`stream\r?\n`)
+
+// parsePDFLinks returns the URIs of a PDF's link annotations, looking in
+// its Flate-compressed object streams too. It doesn't follow links inside
+// encrypted documents.
+func parsePDFLinks(body []byte) ([]string, error) {
+	links := pdfURIs(body)
+	for _, loc := range pdfStream.FindAllIndex(body, -1) {
+		start := loc[1]
+		end := bytes.Index(body[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(body[start : start+end]))
+		if err != nil {
+			continue // Not Flate-compressed
+		}
+		// A stream cut short still yields what was inflated before
+		data, _ := io.ReadAll(io.LimitReader(zr, maxInflated))
+		zr.Close()
+		links = append(links, pdfURIs(data)...)
+	}
+	return links, nil
+}
+
+// pdfURIs returns the URI action targets in PDF object data
+func pdfURIs(data []byte) []string {
+	var uris []string
+	for _, m := range pdfURI.FindAllSubmatch(data, -1) {
+		var uri string
+		if m[1] != nil {
+			uri = pdfLiteral(m[1])
+		} else {
+			uri = pdfHex(m[2])
+		}
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+// pdfLiteral decodes the escapes of a PDF literal string
+func pdfLiteral(s []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '\r', '\n':
+			// A line continuation
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// pdfHex decodes a PDF hex string, ignoring whitespace
+func pdfHex(s []byte) string {
+	var digits []byte
+	for _, c := range s {
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		fmt.Sscanf(string(digits[2*i:2*i+2]), "%02x", &out[i])
+	}
+	return string(out)
+}
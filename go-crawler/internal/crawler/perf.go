@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// PageMetrics describes how heavy and how fast a page was to fetch
+type PageMetrics struct {
+	// Bytes is the size of the body as read, after any transfer
+	// compression was removed, or the Content-Length of pages whose body
+	// wasn't read
+	Bytes int64 `json:"bytes"`
+	// TTFB is the time until the first response byte, including connecting
+	TTFB time.Duration `json:"ttfb"`
+	// Download is the time until the whole body was read
+	Download time.Duration `json:"download"`
+	// Scripts, Stylesheets and Images count the resources an HTML page
+	// references, each distinct URL once
+	Scripts     int `json:"scripts,omitempty"`
+	Stylesheets int `json:"stylesheets,omitempty"`
+	Images      int `json:"images,omitempty"`
+}
+
+// Resources returns the number of resources the page references
+func (m PageMetrics) Resources() int {
+	return m.Scripts + m.Stylesheets + m.Images
+}
+
+// fetchTimer measures a fetch's time to first byte. Only the last attempt
+// of a retried fetch counts.
+type fetchTimer struct {
+	start     atomic.Int64 // Unix nanoseconds the attempt started connecting
+	firstByte atomic.Int64
+}
+
+// trace returns ctx with a client trace that feeds the timer
+func (t *fetchTimer) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.start.Store(time.Now().UnixNano())
+			t.firstByte.Store(0)
+		},
+		GotFirstResponseByte: func() {
+			t.firstByte.Store(time.Now().UnixNano())
+		},
+	})
+}
+
+// ttfb returns the time to first byte, or 0 if no response arrived
+func (t *fetchTimer) ttfb() time.Duration {
+	start, first := t.start.Load(), t.firstByte.Load()
+	if start == 0 || first == 0 {
+		return 0
+	}
+	return time.Duration(first - start)
+}
+
+// startedAt returns when the last attempt started connecting
+func (t *fetchTimer) startedAt() time.Time {
+	return time.Unix(0, t.start.Load())
+}
+
+// countAssets sets the resource counts from a page's assets
+func (m *PageMetrics) countAssets(pageURL string, assets []pageAsset) {
+	seen := make(map[string]bool, len(assets))
+	for _, asset := range assets {
+		u, err := resolveURL(pageURL, asset.href)
+		if err != nil {
+			continue
+		}
+		u.Fragment = ""
+		if seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+		switch asset.kind {
+		case AssetScript:
+			m.Scripts++
+		case AssetStylesheet:
+			m.Stylesheets++
+		case AssetImage:
+			m.Images++
+		}
+	}
+}
+
+// PagePerformance is a page's URL with its metrics
+type PagePerformance struct {
+	URL string `json:"url"`
+	PageMetrics
+}
+
+// PerformanceSummary describes the weight and speed of a crawl's pages
+type PerformanceSummary struct {
+	Pages      int               `json:"pages"`
+	TotalBytes int64             `json:"totalBytes"`
+	MedianTTFB time.Duration     `json:"medianTtfb"`
+	P95TTFB    time.Duration     `json:"p95Ttfb"`
+	Heaviest   []PagePerformance `json:"heaviest"` // Largest first
+	Slowest    []PagePerformance `json:"slowest"`  // Longest download first
+}
+
+// SummarizePerformance totals the metrics of fetched pages and lists the
+// top heaviest and slowest of them
+func SummarizePerformance(pages []PagePerformance, top int) PerformanceSummary {
+	s := PerformanceSummary{Pages: len(pages), Heaviest: []PagePerformance{}, Slowest: []PagePerformance{}}
+	if len(pages) == 0 {
+		return s
+	}
+
+	ttfbs := make([]time.Duration, len(pages))
+	for i, p := range pages {
+		s.TotalBytes += p.Bytes
+		ttfbs[i] = p.TTFB
+	}
+	sort.Slice(ttfbs, func(i, j int) bool { return ttfbs[i] < ttfbs[j] })
+	s.MedianTTFB = ttfbs[len(ttfbs)/2]
+	s.P95TTFB = ttfbs[len(ttfbs)*95/100]
+
+	sorted := append([]PagePerformance{}, pages...)
+	if top > len(sorted) {
+		top = len(sorted)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Bytes > sorted[j].Bytes })
+	s.Heaviest = append(s.Heaviest, sorted[:top]...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Download > sorted[j].Download })
+	s.Slowest = append(s.Slowest, sorted[:top]...)
+	return s
+}
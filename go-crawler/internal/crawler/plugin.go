@@ -0,0 +1,307 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// PluginProtocol is the version of the plugin protocol. It changes only
+// when existing plugins would break.
+const PluginProtocol = 1
+
+const (
+	pluginCallTimeout  = 30 * time.Second
+	pluginCloseTimeout = 5 * time.Second
+)
+
+// Plugin hooks names a plugin can declare
+const (
+	PluginHookFollow  = "follow"
+	PluginHookExtract = "extract"
+	PluginHookResult  = "result"
+)
+
+// Plugin is a processor run as an external program, so a crawl can be
+// extended in any language without recompiling the crawler. The program is
+// started on first use and talks over stdin and stdout, one JSON object per
+// line. It first writes a handshake:
+//
+//	{"name": "my-plugin", "protocol": 1, "hooks": ["follow", "extract", "result"]}
+//
+// and then answers each request the crawler writes, in order:
+//
+//	{"hook": "follow", "link": {"url": ..., "page": ..., "depth": 2}}  -> {"follow": true, "url": "optional rewrite"}
+//	{"hook": "extract", "page": {"url": ..., "statusCode": 200, "contentType": ..., "body": ...}}  -> {"fields": {"price": "9.99"}}
+//	{"hook": "result", "result": {"url": ..., "statusCode": 200, ...}}  -> {}
+//
+// A response with "error" set is logged. Hooks the plugin did not declare
+// are never called. Requests are sent one at a time; a plugin that takes
+// longer than 30 seconds to answer is stopped and ignored for the rest of
+// the crawl. Its stderr is passed through to the crawler's.
+type Plugin struct {
+	path string
+	args []string
+
+	startOnce sync.Once
+	startErr  error
+	name      string
+	hooks     map[string]bool
+
+	mu     sync.Mutex // Serializes requests
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	failed error // Set once the program stopped answering
+}
+
+// pluginHandshake is the first line a plugin writes
+type pluginHandshake struct {
+	Name     string   `json:"name"`
+	Protocol int      `json:"protocol"`
+	Hooks    []string `json:"hooks"`
+}
+
+type pluginRequest struct {
+	Hook   string        `json:"hook"`
+	Link   *Link         `json:"link,omitempty"`
+	Page   *Page         `json:"page,omitempty"`
+	Result *PluginResult `json:"result,omitempty"`
+}
+
+type pluginResponse struct {
+	Follow *bool             `json:"follow"`
+	URL    string            `json:"url"`
+	Fields map[string]string `json:"fields"`
+	Error  string            `json:"error"`
+}
+
+// PluginResult is a crawl result as plugins' result hooks receive it
+type PluginResult struct {
+	URL         string            `json:"url"`
+	Depth       int               `json:"depth"`
+	StatusCode  int               `json:"statusCode,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Language    string            `json:"language,omitempty"`
+	Canonical   string            `json:"canonical,omitempty"`
+	Links       []string          `json:"links,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// NewPlugin returns a plugin that runs the program at path with args
+func NewPlugin(path string, args ...string) *Plugin {
+	return &Plugin{path: path, args: args}
+}
+
+// Start runs the program and reads its handshake, if that has not happened
+// yet. The crawler starts plugins itself; calling Start first reports a
+// broken plugin before the crawl begins.
+func (p *Plugin) Start() error {
+	p.startOnce.Do(func() {
+		p.startErr = p.start()
+	})
+	return p.startErr
+}
+
+func (p *Plugin) start() error {
+	p.cmd = exec.Command(p.path, p.args...)
+	p.cmd.Stderr = os.Stderr
+	stdin, err := p.cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("plugin %s: %v", p.path, err)
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("plugin %s: %v", p.path, err)
+	}
+	if err := p.cmd.Start(); err != nil {
+		return fmt.Errorf("plugin %s: %v", p.path, err)
+	}
+	p.stdin, p.stdout = stdin, bufio.NewReader(stdout)
+
+	line, err := p.readLine()
+	if err != nil {
+		p.kill()
+		return fmt.Errorf("plugin %s: reading handshake: %v", p.path, err)
+	}
+	var hello pluginHandshake
+	if err := json.Unmarshal(line, &hello); err != nil {
+		p.kill()
+		return fmt.Errorf("plugin %s: invalid handshake: %v", p.path, err)
+	}
+	if hello.Protocol != PluginProtocol {
+		p.kill()
+		return fmt.Errorf("plugin %s: speaks protocol %d, want %d", p.path, hello.Protocol, PluginProtocol)
+	}
+	p.name = hello.Name
+	p.hooks = make(map[string]bool)
+	for _, hook := range hello.Hooks {
+		switch hook {
+		case PluginHookFollow, PluginHookExtract, PluginHookResult:
+			p.hooks[hook] = true
+		default:
+			p.kill()
+			return fmt.Errorf("plugin %s: unknown hook %q", p.path, hook)
+		}
+	}
+	return nil
+}
+
+// Name returns the name from the plugin's handshake, or its file name
+func (p *Plugin) Name() string {
+	if p.Start() == nil && p.name != "" {
+		return p.name
+	}
+	return filepath.Base(p.path)
+}
+
+// Follow implements Processor
+func (p *Plugin) Follow(link Link) (bool, string, error) {
+	resp, ok, err := p.call(pluginRequest{Hook: PluginHookFollow, Link: &link})
+	if !ok || err != nil {
+		return true, "", err
+	}
+	return resp.Follow == nil || *resp.Follow, resp.URL, nil
+}
+
+// Extract implements Processor
+func (p *Plugin) Extract(page Page) (map[string]string, error) {
+	resp, ok, err := p.call(pluginRequest{Hook: PluginHookExtract, Page: &page})
+	if !ok || err != nil {
+		return nil, err
+	}
+	return resp.Fields, nil
+}
+
+// Result implements Processor
+func (p *Plugin) Result(r CrawlResult) error {
+	_, _, err := p.call(pluginRequest{Hook: PluginHookResult, Result: NewPluginResult(r)})
+	return err
+}
+
+// NewPluginResult returns the plugin view of a crawl result
+func NewPluginResult(r CrawlResult) *PluginResult {
+	result := &PluginResult{
+		URL:         r.URL,
+		Depth:       r.Depth,
+		StatusCode:  r.StatusCode,
+		ContentType: r.ContentType,
+		Title:       r.Title,
+		Language:    r.Language,
+		Canonical:   r.Canonical,
+		Links:       r.Links,
+		Fields:      r.Fields,
+	}
+	if r.Error != nil {
+		result.Error = r.Error.Error()
+	}
+	return result
+}
+
+// call sends a request for a hook and reads the response. ok is false when
+// the plugin did not declare the hook.
+func (p *Plugin) call(req pluginRequest) (resp pluginResponse, ok bool, err error) {
+	if err := p.Start(); err != nil {
+		return resp, false, err
+	}
+	if !p.hooks[req.Hook] {
+		return resp, false, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failed != nil {
+		return resp, false, p.failed
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return resp, false, err
+	}
+	if _, err := p.stdin.Write(append(data, '\n')); err != nil {
+		p.failed = fmt.Errorf("writing request: %v", err)
+		p.kill()
+		return resp, false, p.failed
+	}
+	line, err := p.readLine()
+	if err != nil {
+		p.failed = fmt.Errorf("reading response: %v", err)
+		p.kill()
+		return resp, false, p.failed
+	}
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return resp, true, fmt.Errorf("invalid response: %v", err)
+	}
+	if resp.Error != "" {
+		return resp, true, fmt.Errorf("%s: %s", req.Hook, resp.Error)
+	}
+	return resp, true, nil
+}
+
+// readLine reads one line of output, giving up after pluginCallTimeout
+func (p *Plugin) readLine() ([]byte, error) {
+	type read struct {
+		line []byte
+		err  error
+	}
+	done := make(chan read, 1)
+	go func() {
+		line, err := p.stdout.ReadBytes('\n')
+		done <- read{line, err}
+	}()
+
+	timer := time.NewTimer(pluginCallTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		if r.err != nil && len(r.line) == 0 {
+			return nil, r.err
+		}
+		return r.line, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("no answer after %s", pluginCallTimeout)
+	}
+}
+
+func (p *Plugin) kill() {
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+}
+
+// Close ends the program by closing its stdin, killing it if it has not
+// exited after five seconds. A closed plugin starts again when it is next
+// used, so a Crawler that is Reset can run another crawl with it.
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer func() {
+		p.startOnce = sync.Once{}
+		p.startErr, p.failed = nil, nil
+	}()
+
+	if p.cmd == nil || p.cmd.Process == nil || p.cmd.ProcessState != nil {
+		return nil
+	}
+	p.stdin.Close()
+	done := make(chan error, 1)
+	go func() { done <- p.cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("plugin %s: %v", p.path, err)
+		}
+		return nil
+	case <-time.After(pluginCloseTimeout):
+		p.cmd.Process.Kill()
+		<-done
+		return fmt.Errorf("plugin %s: killed after not exiting", p.path)
+	}
+}
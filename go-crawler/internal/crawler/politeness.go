@@ -0,0 +1,198 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Politeness bundles the settings that control how hard a crawl hits a site
+type Politeness struct {
+	Name         string        `json:"name"`
+	Workers      int           `json:"workers"`
+	Delay        time.Duration `json:"delay"`        // Fixed delay before each request
+	Jitter       time.Duration `json:"jitter"`       // Random extra delay, up to this much
+	PerHostLimit int           `json:"perHostLimit"` // Concurrent requests per host, 0 = unlimited
+	Retries      int           `json:"retries"`      // Retries for transient failures
+	RetryBackoff time.Duration `json:"retryBackoff"` // First retry delay, doubled each attempt
+}
+
+// politenessPresets are the named presets, from fastest to gentlest
+var politenessPresets = map[string]Politeness{
+	"aggressive": {Workers: 20, Delay: 0, PerHostLimit: 8, Retries: 1, RetryBackoff: 500 * time.Millisecond},
+	"default":    {Workers: 5, Delay: 100 * time.Millisecond, PerHostLimit: 2, Retries: 2, RetryBackoff: time.Second},
+	"polite":     {Workers: 2, Delay: time.Second, PerHostLimit: 1, Retries: 3, RetryBackoff: 5 * time.Second},
+	"stealth":    {Workers: 1, Delay: 5 * time.Second, Jitter: 5 * time.Second, PerHostLimit: 1, Retries: 3, RetryBackoff: 30 * time.Second},
+}
+
+// PolitenessPreset returns the preset with the given name
+func PolitenessPreset(name string) (Politeness, error) {
+	p, ok := politenessPresets[strings.ToLower(name)]
+	if !ok {
+		return Politeness{}, fmt.Errorf("unknown politeness preset %q (want %s)", name, strings.Join(PolitenessPresetNames(), ", "))
+	}
+	p.Name = strings.ToLower(name)
+	return p, nil
+}
+
+// PolitenessPresetNames lists the available presets
+func PolitenessPresetNames() []string {
+	names := make([]string, 0, len(politenessPresets))
+	for name := range politenessPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Options returns the crawler options for the preset's jitter, per-host
+// limit and retry behaviour. Workers and Delay are passed to NewCrawler.
+func (p Politeness) Options() []Option {
+	return []Option{
+		WithJitter(p.Jitter),
+		WithPerHostLimit(p.PerHostLimit),
+		WithRetries(p.Retries, p.RetryBackoff),
+	}
+}
+
+// WithJitter adds a random delay of up to d before each request
+func WithJitter(d time.Duration) Option {
+	return func(c *Crawler) {
+		c.jitter = d
+	}
+}
+
+// WithPerHostLimit caps concurrent requests to any single host; zero means
+// unlimited
+func WithPerHostLimit(n int) Option {
+	return func(c *Crawler) {
+		c.perHostLimit = n
+	}
+}
+
+// WithRetries retries network errors, 429 and 5xx responses up to n times,
+// waiting backoff before the first retry and doubling it after each one.
+// WithErrorPolicy changes which of them are retried.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Crawler) {
+		c.retries = n
+		c.retryBackoff = backoff
+	}
+}
+
+// politeDelay sleeps for the crawl delay plus any jitter
+func (c *Crawler) politeDelay(ctx context.Context) error {
+	d := c.delay()
+	if c.jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(c.jitter)))
+	}
+	return sleepCtx(ctx, d)
+}
+
+// acquireHost blocks until a request slot for host is free and returns the
+// function that releases it
+func (c *Crawler) acquireHost(ctx context.Context, host string) (func(), error) {
+	value, _ := c.hostSlots.LoadOrStore(host, &hostSlot{freed: make(chan struct{})})
+	slot := value.(*hostSlot)
+	for {
+		limit := c.hostLimit()
+		slot.mu.Lock()
+		if limit <= 0 || slot.taken < limit {
+			slot.taken++
+			slot.mu.Unlock()
+			return slot.release, nil
+		}
+		freed := slot.freed
+		slot.mu.Unlock()
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// hostSlot counts a host's requests in flight for the per-host limit. The
+// limit may change while the crawl runs, so it is checked on every acquire
+// rather than fixed as a semaphore's capacity.
+type hostSlot struct {
+	mu    sync.Mutex
+	taken int
+	freed chan struct{} // Closed and replaced whenever a slot may be free
+}
+
+// release frees a slot taken by acquireHost
+func (s *hostSlot) release() {
+	s.mu.Lock()
+	s.taken--
+	s.mu.Unlock()
+	s.wake()
+}
+
+// wake lets the requests waiting for a slot check the limit again
+func (s *hostSlot) wake() {
+	s.mu.Lock()
+	close(s.freed)
+	s.freed = make(chan struct{})
+	s.mu.Unlock()
+}
+
+// doWithRetries sends a request, retrying transient failures. Requests
+// wait while the host is paused by an earlier 429 or 503 response.
+func (c *Crawler) doWithRetries(ctx context.Context, req *http.Request) (*http.Response, error) {
+	c.setHeaders(req)
+	c.setAuth(req)
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		if err := c.throttle.wait(ctx, req.URL.Host); err != nil {
+			return nil, err
+		}
+		resp, err := c.httpClient.Do(req.WithContext(ctx))
+		throttled := err == nil && c.throttle.record(req.URL.Host, resp)
+		if throttled {
+			c.emit(Event{Type: EventHostThrottled, URL: req.URL.String(), Host: req.URL.Host,
+				Reason: resp.Status, Delay: time.Until(c.throttle.pausedUntil(req.URL.Host))})
+		}
+		if attempt >= c.retries || !c.retryable(resp, err) {
+			return resp, err
+		}
+		retry := Event{Type: EventRetryScheduled, URL: req.URL.String(), Host: req.URL.Host, Attempt: attempt + 1, Delay: backoff}
+		if err != nil {
+			retry.Detail = err.Error()
+		} else {
+			retry.Detail = resp.Status
+			resp.Body.Close()
+		}
+		if throttled {
+			// The host's pause replaces the backoff
+			retry.Delay = time.Until(c.throttle.pausedUntil(req.URL.Host))
+			c.emit(retry)
+			continue
+		}
+		c.emit(retry)
+		if err := sleepCtx(ctx, backoff); err != nil {
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// sleepCtx sleeps for d or until ctx is cancelled
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+package crawler
+
+import (
+	"io"
+	"log"
+	"net/url"
+)
+
+// Link is a link the crawler found, offered to processors before it is
+// queued
+type Link struct {
+	URL   string `json:"url"`
+	Page  string `json:"page"`  // Page the link was found on
+	Depth int    `json:"depth"` // Depth the URL would be crawled at
+}
+
+// Page is a fetched HTML page offered to processors' extractors
+type Page struct {
+	URL         string `json:"url"`
+	StatusCode  int    `json:"statusCode"`
+	ContentType string `json:"contentType"`
+	Body        string `json:"body"`
+}
+
+// Processor extends a crawl with custom link filters, extractors and
+// result sinks. Workers call it concurrently. A processor that is also an
+// io.Closer is closed when the crawl finishes.
+type Processor interface {
+	// Name identifies the processor in skip reports and logs
+	Name() string
+	// Follow decides whether a link is crawled. A non-empty rewritten URL,
+	// resolved against the page, replaces the link.
+	Follow(link Link) (follow bool, rewritten string, err error)
+	// Extract returns custom fields for a page, stored in CrawlResult.Fields
+	Extract(page Page) (map[string]string, error)
+	// Result is called with every result, before it is delivered
+	Result(result CrawlResult) error
+}
+
+// WithProcessors extends the crawl with processors, which run in order
+func WithProcessors(processors ...Processor) Option {
+	return func(c *Crawler) {
+		c.processors = append(c.processors, processors...)
+	}
+}
+
+// followLink runs a link past every processor, returning the URL to crawl
+// or false when one of them rejects it. Processor errors are logged and
+// leave the link as it is.
+func (c *Crawler) followLink(absURL *url.URL, baseURL string, depth int) (*url.URL, bool) {
+	for _, p := range c.processors {
+		follow, rewritten, err := p.Follow(Link{URL: absURL.String(), Page: baseURL, Depth: depth})
+		if err != nil {
+			log.Printf("Processor %s: %v", p.Name(), err)
+			continue
+		}
+		if !follow {
+			c.skip(absURL.String(), baseURL, SkipFilter, p.Name())
+			return nil, false
+		}
+		if rewritten == "" {
+			continue
+		}
+		u, err := absURL.Parse(rewritten)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			log.Printf("Processor %s: invalid rewritten URL %q", p.Name(), rewritten)
+			continue
+		}
+		u.Fragment = ""
+		absURL = u
+	}
+	return absURL, true
+}
+
+// extractFields runs every processor's extractor over a page
+func (c *Crawler) extractFields(page Page) map[string]string {
+	var fields map[string]string
+	for _, p := range c.processors {
+		extracted, err := p.Extract(page)
+		if err != nil {
+			log.Printf("Processor %s: %v", p.Name(), err)
+			continue
+		}
+		for k, v := range extracted {
+			if fields == nil {
+				fields = make(map[string]string)
+			}
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// processResult passes a result to every processor's sink
+func (c *Crawler) processResult(result CrawlResult) {
+	for _, p := range c.processors {
+		if err := p.Result(result); err != nil {
+			log.Printf("Processor %s: %v", p.Name(), err)
+		}
+	}
+}
+
+// closeProcessors closes the processors that hold resources
+func (c *Crawler) closeProcessors() {
+	for _, p := range c.processors {
+		if closer, ok := p.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Printf("Processor %s: %v", p.Name(), err)
+			}
+		}
+	}
+}
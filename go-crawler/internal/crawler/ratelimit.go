@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces out requests to a fixed rate. It is safe for
+// concurrent use and can be shared between crawlers to enforce a global
+// limit. The rate can be changed while crawls are running.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter creates a limiter allowing perSecond requests per second;
+// zero or less means unlimited
+func NewRateLimiter(perSecond float64) *RateLimiter {
+	l := &RateLimiter{}
+	l.SetRate(perSecond)
+	return l
+}
+
+// SetRate changes the allowed requests per second; zero or less means unlimited
+func (l *RateLimiter) SetRate(perSecond float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if perSecond <= 0 {
+		l.interval = 0
+		return
+	}
+	l.interval = time.Duration(float64(time.Second) / perSecond)
+}
+
+// Rate returns the allowed requests per second, or 0 when unlimited
+func (l *RateLimiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.interval == 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(l.interval)
+}
+
+// Wait blocks until the caller may make a request or ctx is cancelled
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	if l.interval == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(slot.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
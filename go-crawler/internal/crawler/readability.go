@@ -0,0 +1,333 @@
+package crawler
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// WithTextExtraction extracts the main content of every HTML page as clean
+// text into each result's Text, leaving out navigation, headers, footers,
+// sidebars, scripts and other boilerplate the way browser reader modes do
+func WithTextExtraction() Option {
+	return func(c *Crawler) {
+		c.extractText = true
+	}
+}
+
+// PageText is a page's extracted text, as exported for search and NLP
+// pipelines
+type PageText struct {
+	URL      string `json:"url"`
+	Title    string `json:"title,omitempty"`
+	Language string `json:"language,omitempty"`
+	Text     string `json:"text"`
+}
+
+// NewPageText returns the text export of a crawl result
+func NewPageText(r CrawlResult) PageText {
+	return PageText{URL: r.URL, Title: r.Title, Language: r.Language, Text: r.Text}
+}
+
+// boilerplateTags are elements whose content is never part of a page's
+// main text
+var boilerplateTags = map[string]bool{
+	"aside": true, "button": true, "canvas": true, "dialog": true, "embed": true, "footer": true,
+	"form": true, "header": true, "iframe": true, "menu": true, "nav": true, "noscript": true,
+	"object": true, "script": true, "select": true, "style": true, "svg": true, "template": true,
+	"textarea": true,
+}
+
+// boilerplateRoles are ARIA landmark roles of boilerplate
+var boilerplateRoles = map[string]bool{
+	"banner": true, "complementary": true, "contentinfo": true, "dialog": true, "menu": true,
+	"menubar": true, "navigation": true, "search": true,
+}
+
+// Class and id hints that an element is boilerplate, or content
+var (
+	unlikelyContent = regexp.MustCompile(`(?i)\bads?\b|advert|banner|breadcrumb|comment|cookie|disqus|footer|header|legal|masthead|menu|modal|nav|popup|promo|related|share|sidebar|skip|social|sponsor|subscribe|widget`)
+	likelyContent   = regexp.MustCompile(`(?i)article|body|content|entry|main|post|story|text`)
+)
+
+// Paragraphs shorter than minParagraph bytes don't vote for their
+// container
+const minParagraph = 25
+
+// isBoilerplate reports whether an element is left out of a page's main
+// text
+func isBoilerplate(n *html.Node) bool {
+	if n.Type == html.CommentNode {
+		return true
+	}
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if boilerplateTags[n.Data] || boilerplateRoles[attr(n, "role")] || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	if _, hidden := attrValue(n, "hidden"); hidden {
+		return true
+	}
+	if style := strings.ReplaceAll(strings.ToLower(attr(n, "style")), " ", ""); strings.Contains(style, "display:none") {
+		return true
+	}
+	switch n.Data {
+	case "html", "body", "article", "main":
+		return false
+	}
+	hints := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyContent.MatchString(hints) && !likelyContent.MatchString(hints)
+}
+
+func attrValue(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// mainText returns the text of a document's main content, found the way
+// Readability does: paragraphs vote for their parent and grandparent with
+// their length and commas, the container with the most votes once its
+// link density is discounted wins, and siblings that score nearly as well
+// join it. Paragraphs are separated by blank lines.
+func mainText(doc *html.Node) string {
+	scores := make(map[*html.Node]float64)
+	var order []*html.Node // Candidates in document order
+	vote := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = containerWeight(n)
+			order = append(order, n)
+		}
+		scores[n] += score
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if isBoilerplate(n) {
+			return
+		}
+		if n.Type == html.ElementNode && isParagraph(n) {
+			text := collapsedText(n, isBoilerplate)
+			if len(text) >= minParagraph {
+				score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text)/100), 3)
+				vote(n.Parent, score)
+				if n.Parent != nil {
+					vote(n.Parent.Parent, score/2)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	var top *html.Node
+	for _, n := range order {
+		scores[n] *= 1 - linkDensity(n)
+		if top == nil || scores[n] > scores[top] {
+			top = n
+		}
+	}
+	if top == nil {
+		return renderText(findElement(doc, "body"))
+	}
+
+	// Content is often split across sibling containers, e.g. a lead and
+	// the article body
+	var w textWriter
+	threshold := max(10, scores[top]*0.2)
+	for s := top.Parent.FirstChild; s != nil; s = s.NextSibling {
+		score, scored := scores[s]
+		switch {
+		case s == top, scored && score >= threshold:
+			w.render(s)
+		case s.Type == html.ElementNode && s.Data == "p" && !isBoilerplate(s):
+			if text := collapsedText(s, isBoilerplate); len(text) > 80 && linkDensity(s) < 0.25 {
+				w.render(s)
+			}
+		}
+	}
+	return w.String()
+}
+
+// isParagraph reports whether an element is a unit of text that votes for
+// its container: a paragraph-like element, or a div that holds text rather
+// than further blocks
+func isParagraph(n *html.Node) bool {
+	switch n.Data {
+	case "p", "pre", "td", "blockquote":
+		return true
+	case "div":
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && blockLevel[c.Data] == breakParagraph {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// containerWeight is a candidate container's starting score, from its tag
+// and its class and id hints
+func containerWeight(n *html.Node) float64 {
+	var w float64
+	switch n.Data {
+	case "article", "main":
+		w = 10
+	case "div":
+		w = 5
+	case "pre", "td", "blockquote":
+		w = 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li":
+		w = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		w = -5
+	}
+	hints := attr(n, "class") + " " + attr(n, "id")
+	if likelyContent.MatchString(hints) {
+		w += 25
+	}
+	if unlikelyContent.MatchString(hints) {
+		w -= 25
+	}
+	return w
+}
+
+// linkDensity is the fraction of an element's text that is link text
+func linkDensity(n *html.Node) float64 {
+	text := len(collapsedText(n, isBoilerplate))
+	if text == 0 {
+		return 0
+	}
+	links := 0
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if isBoilerplate(n) {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "a" {
+			links += len(collapsedText(n, isBoilerplate))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return float64(links) / float64(text)
+}
+
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// renderText returns the text under n without boilerplate
+func renderText(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var w textWriter
+	w.render(n)
+	return w.String()
+}
+
+// Breaks between words, weakest first
+const (
+	breakNone = iota
+	breakSpace
+	breakLine
+	breakParagraph
+)
+
+// blockLevel is the break elements put around their content
+var blockLevel = map[string]int{
+	"br": breakLine, "li": breakLine, "tr": breakLine, "dt": breakLine, "dd": breakLine,
+	"address": breakParagraph, "article": breakParagraph, "blockquote": breakParagraph, "div": breakParagraph,
+	"dl": breakParagraph, "figcaption": breakParagraph, "figure": breakParagraph, "h1": breakParagraph,
+	"h2": breakParagraph, "h3": breakParagraph, "h4": breakParagraph, "h5": breakParagraph, "h6": breakParagraph,
+	"hr": breakParagraph, "main": breakParagraph, "ol": breakParagraph, "p": breakParagraph, "pre": breakParagraph,
+	"section": breakParagraph, "table": breakParagraph, "ul": breakParagraph,
+}
+
+// textWriter renders text with whitespace collapsed within paragraphs and
+// blank lines between them
+type textWriter struct {
+	b       strings.Builder
+	pending int // Strongest break asked for since the last word
+}
+
+func (w *textWriter) breakAt(level int) {
+	w.pending = max(w.pending, level)
+}
+
+func (w *textWriter) word(s string) {
+	if w.b.Len() > 0 {
+		switch w.pending {
+		case breakSpace:
+			w.b.WriteByte(' ')
+		case breakLine:
+			w.b.WriteByte('\n')
+		case breakParagraph:
+			w.b.WriteString("\n\n")
+		}
+	}
+	w.pending = breakNone
+	w.b.WriteString(s)
+}
+
+func (w *textWriter) render(n *html.Node) {
+	if isBoilerplate(n) {
+		return
+	}
+	switch n.Type {
+	case html.TextNode:
+		text := n.Data
+		if r, _ := utf8.DecodeRuneInString(text); unicode.IsSpace(r) {
+			w.breakAt(breakSpace)
+		}
+		for i, word := range strings.Fields(text) {
+			if i > 0 {
+				w.breakAt(breakSpace)
+			}
+			w.word(word)
+		}
+		if r, _ := utf8.DecodeLastRuneInString(text); unicode.IsSpace(r) {
+			w.breakAt(breakSpace)
+		}
+		return
+	case html.ElementNode:
+		if n.Data == "head" || n.Data == "title" {
+			return
+		}
+	}
+	level := blockLevel[n.Data]
+	w.breakAt(level)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.render(c)
+	}
+	w.breakAt(level)
+}
+
+func (w *textWriter) String() string {
+	return w.b.String()
+}
@@ -0,0 +1,148 @@
+package crawler
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// RedirectRule is one entry of a redirect map: From should redirect to To,
+// with Status as the first hop's status code when set
+type RedirectRule struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status,omitempty"`
+}
+
+// RedirectCheck is the outcome of verifying a redirect rule. Problem is
+// empty when the rule holds.
+type RedirectCheck struct {
+	RedirectRule
+	Result  URLStatus `json:"result"`
+	Problem string    `json:"problem,omitempty"`
+}
+
+// RedirectCheckHeader is the CSV header matching RedirectCheck.Record
+var RedirectCheckHeader = []string{"from", "expected", "expected_status", "status_code", "location", "final_url", "final_status", "redirects", "problem"}
+
+// Record returns the check as a CSV row
+func (c RedirectCheck) Record() []string {
+	return []string{
+		c.From, c.To, statusString(c.Status), statusString(c.Result.StatusCode), c.Result.Location,
+		c.Result.FinalURL, statusString(c.Result.FinalStatus), strconv.Itoa(c.Result.Redirects), c.Problem,
+	}
+}
+
+// ParseRedirectMap reads a redirect map from CSV rows of from, to and an
+// optional expected status code. To may be relative to From. A header row
+// and blank lines are skipped.
+func ParseRedirectMap(r io.Reader) ([]RedirectRule, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	var rules []RedirectRule
+	seen := make(map[string]string)
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading redirect map: %v", err)
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "from") {
+			continue
+		}
+		if len(record) < 2 || len(record) > 3 {
+			return nil, fmt.Errorf("invalid redirect map line %d: want from,to[,status]", line)
+		}
+
+		rule := RedirectRule{From: strings.TrimSpace(record[0])}
+		from, err := url.Parse(rule.From)
+		if err != nil || (from.Scheme != "http" && from.Scheme != "https") || from.Host == "" {
+			return nil, fmt.Errorf("invalid redirect map line %d: %q is not an absolute http(s) URL", line, rule.From)
+		}
+		to, err := from.Parse(strings.TrimSpace(record[1]))
+		if err != nil || strings.TrimSpace(record[1]) == "" {
+			return nil, fmt.Errorf("invalid redirect map line %d: invalid target %q", line, record[1])
+		}
+		rule.To = to.String()
+		if len(record) == 3 && strings.TrimSpace(record[2]) != "" {
+			status, err := strconv.Atoi(strings.TrimSpace(record[2]))
+			if err != nil || status < 300 || status > 399 {
+				return nil, fmt.Errorf("invalid redirect map line %d: status %q is not a redirect status", line, record[2])
+			}
+			rule.Status = status
+		}
+
+		if prev, ok := seen[rule.From]; ok {
+			if prev != rule.To {
+				return nil, fmt.Errorf("invalid redirect map line %d: %s is mapped to both %s and %s", line, rule.From, prev, rule.To)
+			}
+			continue
+		}
+		seen[rule.From] = rule.To
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// check compares a rule with the status its From URL returned
+func (r RedirectRule) check(s URLStatus) RedirectCheck {
+	c := RedirectCheck{RedirectRule: r, Result: s}
+	switch {
+	case s.Error != "":
+		c.Problem = s.Error
+	case s.Redirects == 0:
+		c.Problem = fmt.Sprintf("not redirected (%d)", s.StatusCode)
+	case r.Status != 0 && s.StatusCode != r.Status:
+		c.Problem = fmt.Sprintf("redirected with %d, want %d", s.StatusCode, r.Status)
+	case s.FinalURL != r.To:
+		c.Problem = "redirected to " + s.FinalURL
+	case s.FinalStatus >= 400:
+		c.Problem = fmt.Sprintf("target returned %d", s.FinalStatus)
+	}
+	return c
+}
+
+// VerifyRedirects checks every rule of a redirect map with VerifyURLs and
+// sends the outcome of each as it completes. A rule fails when its From URL
+// doesn't redirect, redirects with another status than the rule's, or its
+// chain doesn't end at To with a successful status.
+func (c *Crawler) VerifyRedirects(ctx context.Context, rules []RedirectRule) <-chan RedirectCheck {
+	byURL := make(map[string]RedirectRule, len(rules))
+	for _, rule := range rules {
+		byURL[rule.From] = rule
+	}
+
+	urls := make(chan string)
+	go func() {
+		defer close(urls)
+		for _, rule := range rules {
+			select {
+			case urls <- rule.From:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	checks := make(chan RedirectCheck, c.maxWorkers)
+	go func() {
+		defer close(checks)
+		for status := range c.VerifyURLs(ctx, urls) {
+			checks <- byURL[status.URL].check(status)
+		}
+	}()
+	return checks
+}
@@ -0,0 +1,65 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxRedirects is how many redirects in a row are followed before
+// giving up, the same limit as net/http's default
+const DefaultMaxRedirects = 10
+
+// RedirectError reports a redirect loop, or a redirect chain longer than
+// WithMaxRedirects allows. It matches ErrRedirect with errors.Is.
+type RedirectError struct {
+	// Chain is every URL requested, from the page's to the one that
+	// repeats an earlier URL or is a redirect too many
+	Chain []string
+	Loop  bool
+}
+
+func (e *RedirectError) Error() string {
+	if e.Loop {
+		return "redirect loop: " + strings.Join(e.Chain, " -> ")
+	}
+	return fmt.Sprintf("more than %d redirects: %s", len(e.Chain)-2, strings.Join(e.Chain, " -> "))
+}
+
+func (e *RedirectError) Is(target error) bool {
+	return target == ErrRedirect
+}
+
+// WithMaxRedirects sets how many redirects in a row are followed, and how
+// many meta refreshes, before the page fails with a RedirectError. The
+// default is DefaultMaxRedirects.
+func WithMaxRedirects(n int) Option {
+	return func(c *Crawler) {
+		if n > 0 {
+			c.maxRedirects = n
+		}
+	}
+}
+
+// checkRedirect is the crawler's http.Client redirect policy. Redirects to
+// URLs the URL filter rejects are not followed.
+func (c *Crawler) checkRedirect(req *http.Request, via []*http.Request) error {
+	if req.Context().Value(noRedirectsKey{}) != nil {
+		return http.ErrUseLastResponse
+	}
+	if c.urlFilter != nil && !c.urlFilter(req.URL) {
+		return fmt.Errorf("%w: redirect to %s", ErrFiltered, req.URL)
+	}
+	target := req.URL.String()
+	chain := make([]string, 0, len(via)+1)
+	loop := false
+	for _, r := range via {
+		chain = append(chain, r.URL.String())
+		loop = loop || r.URL.String() == target
+	}
+	chain = append(chain, target)
+	if loop || len(via) > c.maxRedirects {
+		return &RedirectError{Chain: chain, Loop: loop}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package crawler_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+
+	"go-crawler/internal/crawler"
+	"go-crawler/internal/crawler/crawlertest"
+)
+
+// result returns the result for u, failing the test if there is none
+func result(t *testing.T, results []crawler.CrawlResult, u string) crawler.CrawlResult {
+	t.Helper()
+	for _, r := range results {
+		if r.URL == u {
+			return r
+		}
+	}
+	t.Fatalf("no result for %s in %d results", u, len(results))
+	return crawler.CrawlResult{}
+}
+
+func TestRedirectLoop(t *testing.T) {
+	site := crawlertest.NewSite("https://example.com/").
+		Page("/", "Home", "/a").
+		Redirect("/a", "/b", 0).
+		Redirect("/b", "/a", 302)
+	c := crawler.NewCrawler(1, 2, 0, site.Option())
+
+	r := result(t, site.Crawl(context.Background(), c, "/"), site.URL("/a"))
+	var redirect *crawler.RedirectError
+	if !errors.As(r.Error, &redirect) {
+		t.Fatalf("loop failed with %v, want a RedirectError", r.Error)
+	}
+	if !redirect.Loop {
+		t.Error("RedirectError.Loop is false for a loop")
+	}
+	want := []string{site.URL("/a"), site.URL("/b"), site.URL("/a")}
+	if !slices.Equal(redirect.Chain, want) {
+		t.Errorf("chain is %v, want %v", redirect.Chain, want)
+	}
+	if class := crawler.Classify(r.Error); class != crawler.ClassRedirect {
+		t.Errorf("loop classified as %q, want %q", class, crawler.ClassRedirect)
+	}
+}
+
+func TestRedirectChainTooLong(t *testing.T) {
+	site := crawlertest.NewSite("https://example.com/").
+		Page("/", "Home", "/1").
+		Redirect("/1", "/2", 0).
+		Redirect("/2", "/3", 0).
+		Redirect("/3", "/4", 0).
+		Page("/4", "Four")
+	c := crawler.NewCrawler(1, 2, 0, site.Option(), crawler.WithMaxRedirects(2))
+
+	r := result(t, site.Crawl(context.Background(), c, "/"), site.URL("/1"))
+	var redirect *crawler.RedirectError
+	if !errors.As(r.Error, &redirect) {
+		t.Fatalf("long chain failed with %v, want a RedirectError", r.Error)
+	}
+	if redirect.Loop {
+		t.Error("RedirectError.Loop is true for a chain without a loop")
+	}
+	if !errors.Is(r.Error, crawler.ErrRedirect) {
+		t.Error("RedirectError doesn't match ErrRedirect")
+	}
+	if len(redirect.Chain) != 4 {
+		t.Errorf("chain is %v, want the page and 3 redirects", redirect.Chain)
+	}
+}
+
+func TestRedirectFollowed(t *testing.T) {
+	site := crawlertest.NewSite("https://example.com/").
+		Page("/", "Home", "/old").
+		Redirect("/old", "/new", 0).
+		Page("/new", "New")
+	c := crawler.NewCrawler(1, 2, 0, site.Option())
+
+	r := result(t, site.Crawl(context.Background(), c, "/"), site.URL("/old"))
+	if r.Error != nil {
+		t.Errorf("redirect failed: %v", r.Error)
+	}
+	if r.Title != "New" {
+		t.Errorf("redirected page has title %q, want the target's", r.Title)
+	}
+}
+
+func TestRedirectToFilteredURL(t *testing.T) {
+	site := crawlertest.NewSite("https://example.com/").
+		Page("/", "Home", "/out").
+		Redirect("/out", "/private/page", 0).
+		Page("/private/page", "Private")
+	var skipped []crawler.SkippedURL
+	c := crawler.NewCrawler(1, 2, 0, site.Option(),
+		crawler.WithURLFilter(func(u *url.URL) bool { return u.Path != "/private/page" }),
+		crawler.WithSkipHandler(func(s crawler.SkippedURL) { skipped = append(skipped, s) }))
+
+	results := site.Crawl(context.Background(), c, "/")
+
+	for _, r := range results {
+		if r.URL == site.URL("/out") {
+			t.Errorf("redirect to a filtered URL reported as a result: %+v", r)
+		}
+	}
+	for _, req := range site.Requests() {
+		if req == "GET "+site.URL("/private/page") {
+			t.Error("redirect to a filtered URL was followed")
+		}
+	}
+	if len(skipped) != 1 || skipped[0].URL != site.URL("/out") || skipped[0].Reason != crawler.SkipFilter {
+		t.Errorf("skipped %+v, want %s with reason %q", skipped, site.URL("/out"), crawler.SkipFilter)
+	}
+}
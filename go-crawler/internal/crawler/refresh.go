@@ -0,0 +1,69 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ChangeState classifies a page revisited in refresh mode
+type ChangeState string
+
+const (
+	ChangeUnchanged ChangeState = "unchanged"
+	ChangeChanged   ChangeState = "changed"
+	ChangeGone      ChangeState = "gone"
+)
+
+// RevisitEntry is a previously crawled page with the validators needed to
+// revalidate it cheaply
+type RevisitEntry struct {
+	URL          string
+	ETag         string
+	LastModified string
+	ContentHash  string
+	FreshUntil   time.Time // From the caching headers, zero if there were none
+}
+
+// Revalidate revisits exactly the given pages with conditional requests
+// instead of crawling. No links are followed. Each result's Change reports
+// whether the page is unchanged (304, or the same content hash), changed,
+// or gone (404 or 410).
+func (c *Crawler) Revalidate(ctx context.Context, startURL string, entries []RevisitEntry) <-chan CrawlResult {
+	if c.used() {
+		return usedResults(startURL)
+	}
+	c.revisit = make(map[string]RevisitEntry, len(entries))
+	seeds := make([]crawlTask, 0, len(entries))
+	for _, e := range entries {
+		if _, dup := c.revisit[e.URL]; dup {
+			continue
+		}
+		c.revisit[e.URL] = e
+		seeds = append(seeds, crawlTask{URL: e.URL})
+	}
+	return c.start(ctx, startURL, seeds)
+}
+
+// setConditionalHeaders adds If-None-Match and If-Modified-Since from a
+// previous fetch
+func setConditionalHeaders(req *http.Request, e RevisitEntry) {
+	if e.ETag != "" {
+		req.Header.Set("If-None-Match", e.ETag)
+	}
+	if e.LastModified != "" {
+		req.Header.Set("If-Modified-Since", e.LastModified)
+	}
+}
+
+// revalidationState classifies a conditional response by its status code
+// alone, reporting false when the body must be compared instead
+func revalidationState(status int) (ChangeState, bool) {
+	switch status {
+	case http.StatusNotModified:
+		return ChangeUnchanged, true
+	case http.StatusNotFound, http.StatusGone:
+		return ChangeGone, true
+	}
+	return "", false
+}
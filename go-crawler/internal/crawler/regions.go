@@ -0,0 +1,156 @@
+package crawler
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxMeasuredBody bounds the bytes read of a response whose size
+// CompareRegions compares
+const maxMeasuredBody = 50 << 20
+
+// Region is a network CompareRegions fetches URLs from, typically a proxy
+// in another country
+type Region struct {
+	Name    string
+	Crawler *Crawler // Configured to connect through the region, e.g. WithProxy
+}
+
+// RegionResponse is how a URL answered in one region
+type RegionResponse struct {
+	Region      string `json:"region"`
+	StatusCode  int    `json:"statusCode,omitempty"`  // Status of the URL itself
+	Location    string `json:"location,omitempty"`    // Where the URL redirects to
+	FinalURL    string `json:"finalUrl,omitempty"`    // Last URL of the redirect chain
+	FinalStatus int    `json:"finalStatus,omitempty"` // Status of FinalURL
+	Size        int64  `json:"size"`                  // Body bytes of FinalURL's response
+	Error       string `json:"error,omitempty"`
+}
+
+// Ways the responses of a URL can differ between regions
+const (
+	DiffError    = "error"    // It failed in some regions only
+	DiffStatus   = "status"   // The status of the URL or its final URL
+	DiffRedirect = "redirect" // Where the URL or its chain redirects to
+	DiffSize     = "size"     // Body sizes more than 10% and 256 bytes apart
+)
+
+// RegionComparison is a URL's responses in every region, in the order the
+// regions were given, and the ways they differ
+type RegionComparison struct {
+	URL         string           `json:"url"`
+	Responses   []RegionResponse `json:"responses"`
+	Differences []string         `json:"differences,omitempty"`
+}
+
+// RegionComparisonHeader is the CSV header matching
+// RegionComparison.Records
+var RegionComparisonHeader = []string{"url", "region", "status_code", "location", "final_url", "final_status", "size", "error", "differences"}
+
+// Records returns the comparison as CSV rows, one per region
+func (c RegionComparison) Records() [][]string {
+	differences := strings.Join(c.Differences, ";")
+	records := make([][]string, len(c.Responses))
+	for i, r := range c.Responses {
+		records[i] = []string{
+			c.URL, r.Region, statusString(r.StatusCode), r.Location, r.FinalURL, statusString(r.FinalStatus),
+			strconv.FormatInt(r.Size, 10), r.Error, differences,
+		}
+	}
+	return records
+}
+
+// CompareRegions fetches each URL received from urls once in every region,
+// following redirects hop by hop with GET, and sends the responses as they
+// complete, with the ways they differ, to detect geo-blocking or cloaking.
+// Each region's crawler applies its own robots.txt, delay and limits; the
+// first region's workers set how many URLs are fetched at once. Duplicate
+// URLs are compared once. The returned channel is closed once urls is
+// closed and every URL has been compared, or ctx is cancelled.
+func CompareRegions(ctx context.Context, regions []Region, urls <-chan string) <-chan RegionComparison {
+	comparisons := make(chan RegionComparison, len(regions))
+	if len(regions) == 0 {
+		close(comparisons)
+		return comparisons
+	}
+	go func() {
+		defer close(comparisons)
+		regions[0].Crawler.forEachURL(ctx, urls, func(u string) {
+			comparison := compareRegions(ctx, regions, u)
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case comparisons <- comparison:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return comparisons
+}
+
+// compareRegions fetches a URL in every region at once
+func compareRegions(ctx context.Context, regions []Region, rawURL string) RegionComparison {
+	comparison := RegionComparison{URL: rawURL, Responses: make([]RegionResponse, len(regions))}
+	var wg sync.WaitGroup
+	for i, region := range regions {
+		wg.Add(1)
+		go func(i int, region Region) {
+			defer wg.Done()
+			status, size := region.Crawler.verifyURL(ctx, rawURL, true)
+			comparison.Responses[i] = RegionResponse{
+				Region:      region.Name,
+				StatusCode:  status.StatusCode,
+				Location:    status.Location,
+				FinalURL:    status.FinalURL,
+				FinalStatus: status.FinalStatus,
+				Size:        size,
+				Error:       status.Error,
+			}
+		}(i, region)
+	}
+	wg.Wait()
+	comparison.Differences = regionDifferences(comparison.Responses)
+	return comparison
+}
+
+// regionDifferences lists the ways responses differ. Responses that failed
+// are only compared by whether they failed.
+func regionDifferences(responses []RegionResponse) []string {
+	var failed, fetched []RegionResponse
+	for _, r := range responses {
+		if r.Error != "" {
+			failed = append(failed, r)
+		} else {
+			fetched = append(fetched, r)
+		}
+	}
+
+	var differences []string
+	if len(failed) > 0 && len(fetched) > 0 {
+		differences = append(differences, DiffError)
+	}
+	if len(fetched) < 2 {
+		return differences
+	}
+	first := fetched[0]
+	minSize, maxSize := first.Size, first.Size
+	var status, redirect bool
+	for _, r := range fetched[1:] {
+		status = status || r.StatusCode != first.StatusCode || r.FinalStatus != first.FinalStatus
+		redirect = redirect || r.Location != first.Location || r.FinalURL != first.FinalURL
+		minSize, maxSize = min(minSize, r.Size), max(maxSize, r.Size)
+	}
+	if status {
+		differences = append(differences, DiffStatus)
+	}
+	if redirect {
+		differences = append(differences, DiffRedirect)
+	}
+	if diff := maxSize - minSize; diff*10 > maxSize && diff > 256 {
+		differences = append(differences, DiffSize)
+	}
+	return differences
+}
@@ -0,0 +1,127 @@
+package crawler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Replay answers a crawler's requests from a recorded HTTP Archive instead
+// of the network, so that a crawl can be re-run offline to reproduce what
+// it extracted and filtered. Requests are matched by method and URL; a URL
+// fetched several times is answered with its recorded responses in order,
+// the last one repeating.
+type Replay struct {
+	mu      sync.Mutex
+	entries map[string][]harEntry // By method and URL, in recorded order
+	served  map[string]int
+	missing []string
+}
+
+// LoadReplay reads a HAR recorded with NewCrawlRecorder. Archives recorded
+// with a debug recorder or exported by a browser work too, but answer with
+// whatever part of each body they hold.
+func LoadReplay(r io.Reader) (*Replay, error) {
+	var har struct {
+		Log struct {
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("error reading recording: %v", err)
+	}
+
+	replay := &Replay{entries: make(map[string][]harEntry), served: make(map[string]int)}
+	for _, e := range har.Log.Entries {
+		if e.Error == "" && e.Response.Status == 0 {
+			continue // Aborted in the browser
+		}
+		key := e.Request.Method + " " + e.Request.URL
+		replay.entries[key] = append(replay.entries[key], e)
+	}
+	return replay, nil
+}
+
+// WithReplay answers every request the crawler sends, including for
+// robots.txt, from r
+func WithReplay(r *Replay) Option {
+	return WithTransport(r)
+}
+
+// Len returns the number of distinct requests the recording can answer
+func (r *Replay) Len() int {
+	return len(r.entries)
+}
+
+// Missing returns the requests that weren't in the recording, as "METHOD
+// URL", each once, in the order they were first made
+func (r *Replay) Missing() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.missing...)
+}
+
+// RoundTrip answers req with its next recorded response
+func (r *Replay) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	key := req.Method + " " + req.URL.String()
+	r.mu.Lock()
+	entries := r.entries[key]
+	if len(entries) == 0 {
+		if _, seen := r.served[key]; !seen {
+			r.served[key] = 0
+			r.missing = append(r.missing, key)
+		}
+		r.mu.Unlock()
+		return nil, fmt.Errorf("%s is not in the recording", key)
+	}
+	i := r.served[key]
+	if i < len(entries)-1 {
+		r.served[key] = i + 1
+	}
+	e := entries[i]
+	r.mu.Unlock()
+
+	if e.Error != "" {
+		return nil, errors.New(e.Error)
+	}
+	body := e.Response.Content.Text
+	if e.Response.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recorded body for %s: %v", key, err)
+		}
+		body = string(decoded)
+	}
+
+	header := make(http.Header)
+	for _, h := range e.Response.Headers {
+		header.Add(h.Name, h.Value)
+	}
+	header.Del("Content-Encoding") // HAR bodies are stored decoded
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	status := strings.TrimSpace(fmt.Sprintf("%d %s", e.Response.Status, e.Response.StatusText))
+	return &http.Response{
+		Status:        status,
+		StatusCode:    e.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
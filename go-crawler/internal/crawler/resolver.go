@@ -0,0 +1,152 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Resolver overrides how host names are resolved for one crawl, so that
+// staging environments behind split-horizon DNS can be crawled with their
+// production host names. TLS still verifies certificates against the host
+// name in the URL.
+type Resolver struct {
+	Hosts     map[string]string // Host name to IP address, like /etc/hosts
+	DNSServer string            // host:port of a DNS server, or "" for the system resolver
+	// PublicOnly refuses connections to loopback, private and link-local
+	// addresses, whether they come from Hosts or the DNS server, so a
+	// crawl can't be pointed at the crawler's own network
+	PublicOnly bool
+}
+
+// ErrPrivateAddress is returned for connections PublicOnly refuses
+var ErrPrivateAddress = errors.New("private address not allowed")
+
+// isPrivateIP reports whether ip is on the loopback, private or link-local
+// ranges, or unspecified or multicast
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// CheckPublic returns an error if a host override points at an address
+// PublicOnly would refuse
+func (r *Resolver) CheckPublic() error {
+	for host, ip := range r.Hosts {
+		if isPrivateIP(net.ParseIP(ip)) {
+			return fmt.Errorf("host override %s -> %s: %w", host, ip, ErrPrivateAddress)
+		}
+	}
+	return nil
+}
+
+// ParseResolver validates host overrides and a DNS server address. A DNS
+// server without a port uses port 53.
+func ParseResolver(hosts map[string]string, dnsServer string) (*Resolver, error) {
+	r := &Resolver{Hosts: make(map[string]string, len(hosts))}
+	for host, ip := range hosts {
+		host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+		if host == "" {
+			return nil, fmt.Errorf("empty host name in host overrides")
+		}
+		if net.ParseIP(strings.TrimSpace(ip)) == nil {
+			return nil, fmt.Errorf("invalid IP address %q for host %s", ip, host)
+		}
+		r.Hosts[host] = strings.TrimSpace(ip)
+	}
+
+	if dnsServer = strings.TrimSpace(dnsServer); dnsServer != "" {
+		if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+			dnsServer = net.JoinHostPort(strings.Trim(dnsServer, "[]"), "53")
+		}
+		host, _, _ := net.SplitHostPort(dnsServer)
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("invalid DNS server %q: want an IP address", dnsServer)
+		}
+		r.DNSServer = dnsServer
+	}
+	return r, nil
+}
+
+// ParseHostsFile reads host overrides in /etc/hosts format: an IP address
+// followed by one or more host names per line, with # comments
+func ParseHostsFile(r io.Reader) (map[string]string, error) {
+	hosts := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			return nil, fmt.Errorf("invalid hosts line %d: %q", line, scanner.Text())
+		}
+		for _, host := range fields[1:] {
+			hosts[strings.ToLower(host)] = fields[0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading hosts file: %v", err)
+	}
+	return hosts, nil
+}
+
+// WithResolver makes every connection the crawler opens, including for
+// robots.txt, resolve host names with r
+func WithResolver(r *Resolver) Option {
+	return func(c *Crawler) {
+		c.resolver = r
+	}
+}
+
+// transport returns an HTTP transport that dials through the resolver
+func (r *Resolver) transport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if r.PublicOnly {
+		// Control sees the address after resolution, so it catches DNS
+		// answers as well as overrides
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+			return nil
+		}
+	}
+	if r.DNSServer != "" {
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, r.DNSServer)
+			},
+		}
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if ip, ok := r.Hosts[strings.ToLower(host)]; ok {
+			addr = net.JoinHostPort(ip, port)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	// A proxy would resolve host names itself
+	t.Proxy = nil
+	return t
+}
@@ -0,0 +1,63 @@
+package crawler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go-crawler/internal/crawler"
+)
+
+func TestResolverCheckPublic(t *testing.T) {
+	for ip, public := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1":    true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+	} {
+		r, err := crawler.ParseResolver(map[string]string{"www.example.com": ip}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.CheckPublic(); (err == nil) != public {
+			t.Errorf("CheckPublic for %s returned %v", ip, err)
+		}
+	}
+}
+
+func TestResolverPublicOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<title>Internal</title>"))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	start := "http://www.example.com:" + u.Port() + "/"
+
+	for _, publicOnly := range []bool{false, true} {
+		r, err := crawler.ParseResolver(map[string]string{"www.example.com": "127.0.0.1"}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.PublicOnly = publicOnly
+		c := crawler.NewCrawler(1, 0, 0, crawler.WithResolver(r))
+		var results []crawler.CrawlResult
+		for r := range c.Start(context.Background(), start) {
+			results = append(results, r)
+		}
+		got := result(t, results, start)
+		if refused := errors.Is(got.Error, crawler.ErrPrivateAddress); refused != publicOnly {
+			t.Errorf("PublicOnly=%v: crawl failed with %v", publicOnly, got.Error)
+		}
+	}
+}
@@ -11,22 +11,30 @@ import (
 
 type RobotRules struct {
 	disallowedPaths []*regexp.Regexp
-	crawlDelay     time.Duration
-	lastAccess     time.Time
-	userAgent      string
+	disallowRules   []string // Raw Disallow values, parallel to disallowedPaths
+	sitemaps        []string
+	crawlDelay      time.Duration
+	lastAccess      time.Time
+	userAgent       string
+	robotsURL       string
+	statusCode      int // HTTP status of the robots.txt fetch, 0 if it failed
 }
 
 func NewRobotRules(userAgent string) *RobotRules {
 	return &RobotRules{
 		disallowedPaths: make([]*regexp.Regexp, 0),
-		crawlDelay:     time.Second, // Default delay
-		userAgent:      userAgent,
+		crawlDelay:      time.Second, // Default delay
+		userAgent:       userAgent,
 	}
 }
 
 func (r *RobotRules) Parse(robotsURL string, content string) error {
+	r.robotsURL = robotsURL
+
 	// Reset existing rules
 	r.disallowedPaths = make([]*regexp.Regexp, 0)
+	r.disallowRules = nil
+	r.sitemaps = nil
 	r.crawlDelay = time.Second // Reset to default
 
 	scanner := bufio.NewScanner(strings.NewReader(content))
@@ -49,6 +57,13 @@ func (r *RobotRules) Parse(robotsURL string, content string) error {
 		field := strings.TrimSpace(strings.ToLower(parts[0]))
 		value := strings.TrimSpace(parts[1])
 
+		// Sitemap lines apply regardless of user agent
+		if field == "sitemap" {
+			if value != "" {
+				r.sitemaps = append(r.sitemaps, value)
+			}
+			continue
+		}
 
 		// Check if this is a User-agent line
 		if field == "user-agent" {
@@ -73,12 +88,13 @@ func (r *RobotRules) Parse(robotsURL string, content string) error {
 			re, err := regexp.Compile(pattern)
 			if err == nil {
 				r.disallowedPaths = append(r.disallowedPaths, re)
+				r.disallowRules = append(r.disallowRules, value)
 			}
 
 		case "crawl-delay":
 			var seconds int
 			_, err := fmt.Sscanf(value, "%d", &seconds)
-			if err == nil && seconds > 0 {
+			if err == nil && seconds >= 0 {
 				r.crawlDelay = time.Duration(seconds) * time.Second
 			}
 		}
@@ -89,9 +105,16 @@ func (r *RobotRules) Parse(robotsURL string, content string) error {
 
 // IsAllowed checks if a URL is allowed to be crawled based on robots.txt rules
 func (r *RobotRules) IsAllowed(urlStr string) bool {
+	_, blocked := r.MatchingRule(urlStr)
+	return !blocked
+}
+
+// MatchingRule returns the Disallow rule that blocks a URL, if any. Unparseable
+// URLs are reported as blocked with an empty rule.
+func (r *RobotRules) MatchingRule(urlStr string) (string, bool) {
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
-		return false
+		return "", true
 	}
 
 	path := parsedURL.Path
@@ -99,12 +122,33 @@ func (r *RobotRules) IsAllowed(urlStr string) bool {
 		path += "/"
 	}
 
-	for _, re := range r.disallowedPaths {
+	for i, re := range r.disallowedPaths {
 		if re.MatchString(path) {
-			return false
+			return r.disallowRules[i], true
 		}
 	}
-	return true
+	return "", false
+}
+
+// DisallowRules returns the Disallow values that apply to our user agent
+func (r *RobotRules) DisallowRules() []string {
+	return append([]string(nil), r.disallowRules...)
+}
+
+// Sitemaps returns the sitemap URLs listed in robots.txt
+func (r *RobotRules) Sitemaps() []string {
+	return append([]string(nil), r.sitemaps...)
+}
+
+// URL returns the robots.txt URL the rules came from
+func (r *RobotRules) URL() string {
+	return r.robotsURL
+}
+
+// StatusCode returns the HTTP status of the robots.txt fetch, or 0 if the
+// fetch failed and default rules are in effect
+func (r *RobotRules) StatusCode() int {
+	return r.statusCode
 }
 
 // GetCrawlDelay returns the required delay between requests
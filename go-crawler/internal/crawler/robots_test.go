@@ -0,0 +1,112 @@
+package crawler_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go-crawler/internal/crawler"
+	"go-crawler/internal/crawler/crawlertest"
+)
+
+const testRobots = `# Comments and blank lines are ignored
+
+User-agent: OtherBot
+Disallow: /
+
+User-agent: *
+Disallow: /private
+Disallow: /tmp/*.html
+Disallow:
+Crawl-delay: 5
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func parseRobots(t *testing.T, userAgent, content string) *crawler.RobotRules {
+	t.Helper()
+	rules := crawler.NewRobotRules(userAgent)
+	if err := rules.Parse("https://example.com/robots.txt", content); err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func TestRobotRulesAllowed(t *testing.T) {
+	rules := parseRobots(t, "GoCrawler/1.0", testRobots)
+
+	for u, allowed := range map[string]bool{
+		"https://example.com/":                 true,
+		"https://example.com/public/page":      true,
+		"https://example.com/private":          false,
+		"https://example.com/private/page":     false,
+		"https://example.com/privateer":        false,
+		"https://example.com/tmp/a/b.html":     false,
+		"https://example.com/tmp/notes.txt":    true,
+		"https://example.com/other/private":    true,
+		"https://example.com/%zz-not-a-url/ok": false,
+	} {
+		if got := rules.IsAllowed(u); got != allowed {
+			t.Errorf("IsAllowed(%s) = %v, want %v", u, got, allowed)
+		}
+	}
+	if rule, blocked := rules.MatchingRule("https://example.com/tmp/x.html"); !blocked || rule != "/tmp/*.html" {
+		t.Errorf("MatchingRule = %q, %v, want /tmp/*.html", rule, blocked)
+	}
+}
+
+func TestRobotRulesGroups(t *testing.T) {
+	rules := parseRobots(t, "OtherBot/2.0", testRobots)
+	if rules.IsAllowed("https://example.com/") {
+		t.Error("a group naming the user agent doesn't apply")
+	}
+	if got := rules.DisallowRules(); !slices.Contains(got, "/") {
+		t.Errorf("DisallowRules are %v, want / among them", got)
+	}
+}
+
+func TestRobotRulesDirectives(t *testing.T) {
+	rules := parseRobots(t, "GoCrawler/1.0", testRobots)
+	if d := rules.GetCrawlDelay(); d != 5*time.Second {
+		t.Errorf("crawl delay is %v, want 5s", d)
+	}
+	if got := rules.Sitemaps(); !slices.Equal(got, []string{"https://example.com/sitemap.xml"}) {
+		t.Errorf("sitemaps are %v", got)
+	}
+	if rules.URL() != "https://example.com/robots.txt" {
+		t.Errorf("URL is %q", rules.URL())
+	}
+
+	// Parsing again replaces the rules
+	if err := rules.Parse("https://example.com/robots.txt", "User-agent: *\n"); err != nil {
+		t.Fatal(err)
+	}
+	if !rules.IsAllowed("https://example.com/private") || rules.GetCrawlDelay() != time.Second || len(rules.Sitemaps()) != 0 {
+		t.Error("Parse kept rules from the previous robots.txt")
+	}
+}
+
+func TestCrawlObeysRobots(t *testing.T) {
+	site := crawlertest.NewSite("https://example.com/").
+		Robots("User-agent: *\nDisallow: /private\nCrawl-delay: 0\n").
+		Page("/", "Home", "/public", "/private").
+		Page("/public", "Public").
+		Page("/private", "Private")
+	var skipped []crawler.SkippedURL
+	c := crawler.NewCrawler(1, 2, 0, site.Option(),
+		crawler.WithSkipHandler(func(s crawler.SkippedURL) { skipped = append(skipped, s) }))
+
+	results := site.Crawl(context.Background(), c, "/")
+
+	if r := result(t, results, site.URL("/private")); !errors.Is(r.Error, crawler.ErrRobotsDisallowed) {
+		t.Errorf("disallowed page failed with %v, want ErrRobotsDisallowed", r.Error)
+	}
+	if want := []string{"GET " + site.URL("/"), "GET " + site.URL("/public")}; !slices.Equal(pages(site), want) {
+		t.Errorf("crawl fetched %v, want %v", pages(site), want)
+	}
+	if len(skipped) != 1 || skipped[0].Reason != crawler.SkipRobots || skipped[0].URL != site.URL("/private") {
+		t.Errorf("skipped %+v, want /private for robots", skipped)
+	}
+}
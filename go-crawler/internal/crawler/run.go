@@ -0,0 +1,104 @@
+package crawler
+
+import (
+	"errors"
+	"sync"
+)
+
+// Run states of a Crawler
+const (
+	runIdle int32 = iota
+	runActive
+	runDone
+)
+
+// ErrCrawlerUsed is the error when a crawl is begun on a Crawler that
+// already ran one and was not Reset. DryRun returns it; Start, Resume and
+// Revalidate return a results channel with it as the only result.
+var ErrCrawlerUsed = errors.New("crawler: already ran a crawl; call Reset before starting another")
+
+// ErrCrawlRunning is returned by Reset while a crawl is still running
+var ErrCrawlRunning = errors.New("crawler: crawl still running")
+
+// beginRun marks the start of a crawl, failing if the Crawler was used
+func (c *Crawler) beginRun() error {
+	if !c.runState.CompareAndSwap(runIdle, runActive) {
+		return ErrCrawlerUsed
+	}
+	return nil
+}
+
+// used reports whether a crawl can't begin on the Crawler without a Reset
+func (c *Crawler) used() bool {
+	return c.runState.Load() != runIdle
+}
+
+// usedResults is the results channel of a crawl begun on a used Crawler
+func usedResults(startURL string) <-chan CrawlResult {
+	results := make(chan CrawlResult, 1)
+	results <- CrawlResult{URL: startURL, Error: ErrCrawlerUsed}
+	close(results)
+	return results
+}
+
+// Reset clears the state of a finished crawl so the Crawler can run another
+// with the same options: visited URLs, the frontier, the page budget, bytes
+// read, detected traps, broken assets, external links and alternates, and
+// cached robots.txt rules. Pauses that hosts asked for with 429 and 503
+// responses are kept. A crawl has finished once its results channel is
+// closed; before that Reset returns ErrCrawlRunning.
+func (c *Crawler) Reset() error {
+	if c.runState.Load() == runActive {
+		return ErrCrawlRunning
+	}
+	c.newRun()
+	return nil
+}
+
+// newRun sets up the per-crawl state
+func (c *Crawler) newRun() {
+	if c.visited == nil {
+		c.visited = &mapVisited{}
+	}
+	c.visited.Reset()
+	c.robotsMap = &sync.Map{}
+	c.results = make(chan CrawlResult, 1000)
+	// Hand tasks to workers one at a time so the frontier, not the channel
+	// buffer, decides the order
+	c.urlsToCrawl = make(chan crawlTask)
+	c.hosts = nil
+	if c.scorer != nil {
+		c.scored = newScoreQueue(maxScoredTasks)
+	} else {
+		c.hosts = newHostQueue(c.hostLimits())
+	}
+	c.disk = nil
+
+	c.frontierMu.Lock()
+	c.frontier = make(map[string]crawlTask)
+	c.frontierMu.Unlock()
+	c.pauseMu.Lock()
+	c.paused = false
+	c.pauseMu.Unlock()
+
+	c.fetched.Store(0)
+	c.bytesRead.Store(0)
+	c.revisit = nil
+	if c.traps != nil {
+		c.traps = newTrapDetector(c.traps.cfg)
+	}
+	clearMap(&c.assets)
+	clearMap(&c.externalLinks)
+	clearMap(&c.alternates)
+	clearMap(&c.refreshHops)
+	clearMap(&c.claimed)
+	clearMap(&c.failures)
+	c.runState.Store(runIdle)
+}
+
+func clearMap(m *sync.Map) {
+	m.Range(func(key, _ any) bool {
+		m.Delete(key)
+		return true
+	})
+}
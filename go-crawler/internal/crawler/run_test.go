@@ -0,0 +1,87 @@
+package crawler_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+
+	"go-crawler/internal/crawler"
+	"go-crawler/internal/crawler/crawlertest"
+)
+
+// urls returns the URLs of results, sorted
+func urls(results []crawler.CrawlResult) []string {
+	var list []string
+	for _, r := range results {
+		list = append(list, r.URL)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func TestStartOnUsedCrawler(t *testing.T) {
+	site := crawlertest.NewSite("https://example.com/").
+		Page("/", "Home", "/a").
+		Page("/a", "A")
+	c := crawler.NewCrawler(1, 2, 0, site.Option())
+
+	if got := site.Crawl(context.Background(), c, "/"); len(got) != 2 {
+		t.Fatalf("first crawl returned %d results, want 2", len(got))
+	}
+	site.Reset()
+
+	got := site.Crawl(context.Background(), c, "/")
+	if len(got) != 1 || !errors.Is(got[0].Error, crawler.ErrCrawlerUsed) {
+		t.Fatalf("second crawl returned %+v, want a single ErrCrawlerUsed result", got)
+	}
+	if got[0].URL != site.URL("/") {
+		t.Errorf("ErrCrawlerUsed result has URL %q, want the start URL", got[0].URL)
+	}
+	if reqs := site.Requests(); len(reqs) != 0 {
+		t.Errorf("second crawl made requests %v", reqs)
+	}
+	if _, err := c.DryRun(context.Background(), site.URL("/")); !errors.Is(err, crawler.ErrCrawlerUsed) {
+		t.Errorf("DryRun on a used crawler returned %v, want ErrCrawlerUsed", err)
+	}
+}
+
+func TestResetRunsAnotherCrawl(t *testing.T) {
+	site := crawlertest.NewSite("https://example.com/").
+		Page("/", "Home", "/a", "/b").
+		Page("/a", "A").
+		Page("/b", "B")
+	c := crawler.NewCrawler(2, 2, 0, site.Option())
+
+	first := urls(site.Crawl(context.Background(), c, "/"))
+	if err := c.Reset(); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	second := urls(site.Crawl(context.Background(), c, "/"))
+
+	want := []string{site.URL("/"), site.URL("/a"), site.URL("/b")}
+	if !slices.Equal(first, want) {
+		t.Errorf("first crawl fetched %v, want %v", first, want)
+	}
+	if !slices.Equal(second, want) {
+		t.Errorf("crawl after Reset fetched %v, want %v: visited URLs were kept", second, want)
+	}
+}
+
+func TestResetWhileRunning(t *testing.T) {
+	site := crawlertest.NewSite("https://example.com/").
+		Page("/", "Home", "/a").
+		Page("/a", "A")
+	c := crawler.NewCrawler(1, 2, 0, site.Option())
+
+	results := c.Start(context.Background(), site.URL("/"))
+	if err := c.Reset(); !errors.Is(err, crawler.ErrCrawlRunning) {
+		t.Errorf("Reset during a crawl returned %v, want ErrCrawlRunning", err)
+	}
+	for range results {
+	}
+	if err := c.Reset(); err != nil {
+		t.Errorf("Reset after the crawl: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a daily period, as offsets from midnight. A window whose
+// end is before its start wraps past midnight, e.g. 22:00-04:00.
+type TimeWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Schedule restricts crawling to daily time windows in a given location
+type Schedule struct {
+	Windows  []TimeWindow
+	Location *time.Location
+}
+
+// ParseSchedule parses windows such as "01:00-06:00" in the IANA time zone
+// tz (empty means the local zone)
+func ParseSchedule(windows []string, tz string) (*Schedule, error) {
+	loc := time.Local
+	if tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %v", tz, err)
+		}
+	}
+
+	s := &Schedule{Location: loc}
+	for _, w := range windows {
+		start, end, ok := strings.Cut(strings.TrimSpace(w), "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid time window %q (want HH:MM-HH:MM)", w)
+		}
+		startOff, err := parseClock(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time window %q: %v", w, err)
+		}
+		endOff, err := parseClock(end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time window %q: %v", w, err)
+		}
+		s.Windows = append(s.Windows, TimeWindow{Start: startOff, End: endOff})
+	}
+	return s, nil
+}
+
+// parseClock parses HH:MM into an offset from midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Open reports whether t falls inside any window. A schedule without
+// windows is always open.
+func (s *Schedule) Open(t time.Time) bool {
+	if len(s.Windows) == 0 {
+		return true
+	}
+	t = t.In(s.Location)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	for _, w := range s.Windows {
+		if w.Start <= w.End {
+			if offset >= w.Start && offset < w.End {
+				return true
+			}
+		} else if offset >= w.Start || offset < w.End {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns the next time at or after t when a window opens
+func (s *Schedule) NextOpen(t time.Time) time.Time {
+	if s.Open(t) {
+		return t
+	}
+	t = t.In(s.Location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.Location)
+
+	var next time.Time
+	for day := 0; day <= 1; day++ {
+		base := midnight.AddDate(0, 0, day)
+		for _, w := range s.Windows {
+			start := base.Add(w.Start)
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
+}
+
+// WithSchedule only fetches pages inside the schedule's windows. Outside
+// them the crawl pauses, writing a checkpoint if a checkpoint file is set,
+// and resumes when the next window opens.
+func WithSchedule(s *Schedule) Option {
+	return func(c *Crawler) {
+		c.schedule = s
+	}
+}
+
+// Paused reports whether the crawl is waiting for a time window to open
+func (c *Crawler) Paused() bool {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	return c.paused
+}
+
+// waitForWindow blocks while the schedule is closed. The first worker to
+// notice the window closing writes the checkpoint.
+func (c *Crawler) waitForWindow(ctx context.Context) error {
+	if c.schedule == nil {
+		return nil
+	}
+
+	for {
+		now := time.Now()
+		if c.schedule.Open(now) {
+			c.pauseMu.Lock()
+			c.paused = false
+			c.pauseMu.Unlock()
+			return nil
+		}
+
+		c.pauseMu.Lock()
+		first := !c.paused
+		c.paused = true
+		c.pauseMu.Unlock()
+
+		next := c.schedule.NextOpen(now)
+		if first {
+			log.Printf("Outside crawl window, pausing until %s", next.Format(time.RFC3339))
+			if c.checkpointFile != "" {
+				if err := SaveCheckpoint(c.checkpointFile, c.Checkpoint()); err != nil {
+					log.Printf("Error saving checkpoint: %v", err)
+				}
+			}
+		}
+
+		// Re-check at least once a minute in case the clock jumps
+		wait := next.Sub(now)
+		if wait > time.Minute {
+			wait = time.Minute
+		}
+		if err := sleepCtx(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package crawler
+
+import (
+	"container/heap"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// maxScoredTasks bounds the focused crawl frontier. When it is full the
+// lowest-scored URL is dropped.
+const maxScoredTasks = 10000
+
+// Scorer rates how promising a discovered link is for a focused crawl.
+// Higher scores are fetched first. It is called from worker goroutines and
+// must be safe for concurrent use.
+type Scorer interface {
+	Score(url, anchorText string, depth int, parent string) float64
+}
+
+// ScorerFunc adapts a function to the Scorer interface
+type ScorerFunc func(url, anchorText string, depth int, parent string) float64
+
+func (f ScorerFunc) Score(url, anchorText string, depth int, parent string) float64 {
+	return f(url, anchorText, depth, parent)
+}
+
+// WithScorer turns the crawl into a focused crawl: instead of breadth-first
+// order, queued URLs are fetched highest score first
+func WithScorer(s Scorer) Option {
+	return func(c *Crawler) {
+		c.scorer = s
+	}
+}
+
+// KeywordScorer scores links by the keywords found in their anchor text
+// (worth 2 each), their URL (1) and the URL of the page linking to them
+// (0.5), case-insensitively. Each level of depth costs 0.1 so that among
+// equally relevant links the shallower ones go first.
+type KeywordScorer struct {
+	keywords []string
+}
+
+// NewKeywordScorer returns a KeywordScorer for the given keywords
+func NewKeywordScorer(keywords ...string) *KeywordScorer {
+	s := &KeywordScorer{}
+	for _, k := range keywords {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			s.keywords = append(s.keywords, k)
+		}
+	}
+	return s
+}
+
+func (s *KeywordScorer) Score(link, anchorText string, depth int, parent string) float64 {
+	anchorText, parent = strings.ToLower(anchorText), strings.ToLower(parent)
+	if u, err := url.Parse(link); err == nil {
+		// Match against the decoded path and query rather than the host
+		link = strings.ToLower(u.Path + " " + u.RawQuery)
+		if unescaped, err := url.QueryUnescape(link); err == nil {
+			link = unescaped
+		}
+	}
+
+	score := -0.1 * float64(depth)
+	for _, k := range s.keywords {
+		if strings.Contains(anchorText, k) {
+			score += 2
+		}
+		if strings.Contains(link, k) {
+			score++
+		}
+		if strings.Contains(parent, k) {
+			score += 0.5
+		}
+	}
+	return score
+}
+
+// scoreQueue is the frontier of a focused crawl, a bounded max-heap of
+// tasks by score that is FIFO among equal scores
+type scoreQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	tasks  taskHeap
+	max    int
+	seq    int64
+	closed bool
+}
+
+func newScoreQueue(max int) *scoreQueue {
+	q := &scoreQueue{max: max}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push adds a task. If the queue is full, the lowest-scored task, which may
+// be the new one, is dropped and returned.
+func (q *scoreQueue) push(task crawlTask) (crawlTask, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	item := scoredTask{task: task, seq: q.seq}
+	if len(q.tasks) >= q.max {
+		lowest := 0
+		for i := range q.tasks {
+			if q.tasks.less(lowest, i) {
+				lowest = i
+			}
+		}
+		if !item.before(q.tasks[lowest]) {
+			return task, true
+		}
+		dropped := q.tasks[lowest].task
+		q.tasks[lowest] = item
+		heap.Fix(&q.tasks, lowest)
+		q.cond.Signal()
+		return dropped, true
+	}
+	heap.Push(&q.tasks, item)
+	q.cond.Signal()
+	return crawlTask{}, false
+}
+
+// pop waits for the highest-scored task, returning false once the queue is
+// closed and empty
+func (q *scoreQueue) pop() (crawlTask, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.tasks) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.tasks) == 0 {
+		return crawlTask{}, false
+	}
+	return heap.Pop(&q.tasks).(scoredTask).task, true
+}
+
+func (q *scoreQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+type scoredTask struct {
+	task crawlTask
+	seq  int64
+}
+
+// before reports whether t should be fetched before o
+func (t scoredTask) before(o scoredTask) bool {
+	if t.task.Score != o.task.Score {
+		return t.task.Score > o.task.Score
+	}
+	return t.seq < o.seq
+}
+
+type taskHeap []scoredTask
+
+func (h taskHeap) less(i, j int) bool  { return h[i].before(h[j]) }
+func (h taskHeap) Len() int            { return len(h) }
+func (h taskHeap) Less(i, j int) bool  { return h.less(i, j) }
+func (h taskHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *taskHeap) Push(x interface{}) { *h = append(*h, x.(scoredTask)) }
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// dispatchScored feeds the workers from the focused crawl frontier until it
+// is closed
+func (c *Crawler) dispatchScored(done chan<- struct{}) {
+	defer close(done)
+	for {
+		task, ok := c.scored.pop()
+		if !ok {
+			return
+		}
+		c.urlsToCrawl <- task
+	}
+}
@@ -0,0 +1,255 @@
+package crawler
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	"go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// scriptMaxSteps bounds the work a script may do in one call, so a loop
+// cannot stall the crawl
+const scriptMaxSteps = 1000000
+
+// Script is a processor written in Starlark, a small Python dialect with no
+// access to files or the network. A script defines any of these functions:
+//
+//	def follow(link):   # link.url, link.page, link.depth
+//	    return not link.url.endswith(".pdf")
+//
+//	def rewrite(link):  # return the URL to crawl instead, or None
+//	    return re.sub(r"[?&]utm_[^&]*", "", link.url)
+//
+//	def extract(page):  # page.url, page.status_code, page.content_type, page.body
+//	    m = re.search(r'itemprop="price" content="([^"]+)"', page.body)
+//	    return {"price": m[1]} if m else {}
+//
+// rewrite runs before follow, which sees the rewritten URL. The json module
+// and re.search, re.findall and re.sub (Go regular expression syntax) are
+// available, and print writes to the log.
+type Script struct {
+	name    string
+	follow  starlark.Callable
+	rewrite starlark.Callable
+	extract starlark.Callable
+}
+
+// CompileScript runs a script's top level and looks up its hooks
+func CompileScript(name, src string) (*Script, error) {
+	s := &Script{name: name}
+	globals, err := starlark.ExecFile(s.thread(), name, src, scriptModules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid script: %v", err)
+	}
+	globals.Freeze()
+
+	hooks := map[string]*starlark.Callable{"follow": &s.follow, "rewrite": &s.rewrite, "extract": &s.extract}
+	for hook, fn := range hooks {
+		v, ok := globals[hook]
+		if !ok {
+			continue
+		}
+		callable, ok := v.(starlark.Callable)
+		if !ok {
+			return nil, fmt.Errorf("invalid script %s: %s is a %s, not a function", name, hook, v.Type())
+		}
+		*fn = callable
+	}
+	if s.follow == nil && s.rewrite == nil && s.extract == nil {
+		return nil, fmt.Errorf("invalid script %s: defines none of follow, rewrite or extract", name)
+	}
+	return s, nil
+}
+
+// thread returns a fresh thread for one call, with its step budget
+func (s *Script) thread() *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: s.name,
+		Print: func(_ *starlark.Thread, msg string) {
+			log.Printf("Script %s: %s", s.name, msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	return thread
+}
+
+// Name implements Processor
+func (s *Script) Name() string {
+	return s.name
+}
+
+// Follow implements Processor
+func (s *Script) Follow(link Link) (bool, string, error) {
+	rewritten := ""
+	if s.rewrite != nil {
+		v, err := starlark.Call(s.thread(), s.rewrite, starlark.Tuple{scriptLink(link)}, nil)
+		if err != nil {
+			return true, "", fmt.Errorf("rewrite: %v", err)
+		}
+		switch v := v.(type) {
+		case starlark.NoneType:
+		case starlark.String:
+			rewritten = string(v)
+			link.URL = rewritten
+		default:
+			return true, "", fmt.Errorf("rewrite returned a %s, want a string or None", v.Type())
+		}
+	}
+	if s.follow == nil {
+		return true, rewritten, nil
+	}
+	v, err := starlark.Call(s.thread(), s.follow, starlark.Tuple{scriptLink(link)}, nil)
+	if err != nil {
+		return true, rewritten, fmt.Errorf("follow: %v", err)
+	}
+	return bool(v.Truth()), rewritten, nil
+}
+
+// Extract implements Processor
+func (s *Script) Extract(page Page) (map[string]string, error) {
+	if s.extract == nil {
+		return nil, nil
+	}
+	arg := starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"url":          starlark.String(page.URL),
+		"status_code":  starlark.MakeInt(page.StatusCode),
+		"content_type": starlark.String(page.ContentType),
+		"body":         starlark.String(page.Body),
+	})
+	v, err := starlark.Call(s.thread(), s.extract, starlark.Tuple{arg}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("extract: %v", err)
+	}
+	if v == starlark.None {
+		return nil, nil
+	}
+	dict, ok := v.(*starlark.Dict)
+	if !ok {
+		return nil, fmt.Errorf("extract returned a %s, want a dict", v.Type())
+	}
+	fields := make(map[string]string, dict.Len())
+	for _, item := range dict.Items() {
+		fields[scriptString(item[0])] = scriptString(item[1])
+	}
+	return fields, nil
+}
+
+// Result implements Processor; scripts have no result hook
+func (s *Script) Result(CrawlResult) error {
+	return nil
+}
+
+func scriptLink(link Link) starlark.Value {
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"url":   starlark.String(link.URL),
+		"page":  starlark.String(link.Page),
+		"depth": starlark.MakeInt(link.Depth),
+	})
+}
+
+// scriptString converts a script value to a field string, without quotes
+// for strings
+func scriptString(v starlark.Value) string {
+	if s, ok := starlark.AsString(v); ok {
+		return s
+	}
+	return v.String()
+}
+
+// scriptModules are predeclared in every script
+var scriptModules = starlark.StringDict{
+	"json": json.Module,
+	"re": &starlarkstruct.Module{
+		Name: "re",
+		Members: starlark.StringDict{
+			"search":  starlark.NewBuiltin("re.search", reSearch),
+			"findall": starlark.NewBuiltin("re.findall", reFindAll),
+			"sub":     starlark.NewBuiltin("re.sub", reSub),
+		},
+	},
+}
+
+// scriptRegexps caches the patterns scripts use, by source, up to
+// maxScriptRegexps of them
+var (
+	scriptRegexps      sync.Map
+	scriptRegexpsCount atomic.Int32
+)
+
+const maxScriptRegexps = 1000
+
+func compileScriptRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := scriptRegexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if scriptRegexpsCount.Add(1) <= maxScriptRegexps {
+		scriptRegexps.Store(pattern, re)
+	}
+	return re, nil
+}
+
+// reSearch returns the first match of a pattern as a list of the whole
+// match and its groups, or None
+func reSearch(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, s string
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 2, &pattern, &s); err != nil {
+		return nil, err
+	}
+	re, err := compileScriptRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn.Name(), err)
+	}
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return starlark.None, nil
+	}
+	groups := make([]starlark.Value, len(m))
+	for i, g := range m {
+		groups[i] = starlark.String(g)
+	}
+	return starlark.NewList(groups), nil
+}
+
+// reFindAll returns every match of a pattern, or of its first group when
+// it has one
+func reFindAll(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, s string
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 2, &pattern, &s); err != nil {
+		return nil, err
+	}
+	re, err := compileScriptRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn.Name(), err)
+	}
+	var matches []starlark.Value
+	for _, m := range re.FindAllStringSubmatch(s, -1) {
+		if len(m) > 1 {
+			matches = append(matches, starlark.String(m[1]))
+		} else {
+			matches = append(matches, starlark.String(m[0]))
+		}
+	}
+	return starlark.NewList(matches), nil
+}
+
+// reSub replaces every match of a pattern, expanding $1-style references
+func reSub(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, repl, s string
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 3, &pattern, &repl, &s); err != nil {
+		return nil, err
+	}
+	re, err := compileScriptRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn.Name(), err)
+	}
+	return starlark.String(re.ReplaceAllString(s, repl)), nil
+}
@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"fmt"
+	"regexp"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxMatchesPerPage bounds the matches reported for a single page
+	maxMatchesPerPage = 100
+	// matchContext is how many bytes of text around a match are reported
+	matchContext = 40
+)
+
+// ContentMatch is a search pattern match in a page's text
+type ContentMatch struct {
+	Pattern string `json:"pattern"`
+	Match   string `json:"match"`
+	Context string `json:"context"` // The match with the text around it
+}
+
+// WithContentSearch searches the text of every HTML page, including inline
+// scripts, for the given patterns. Matches are reported in each result's
+// Matches.
+func WithContentSearch(patterns ...*regexp.Regexp) Option {
+	return func(c *Crawler) {
+		c.search = patterns
+	}
+}
+
+// CompileSearchPatterns compiles search patterns, reporting the first
+// invalid one
+func CompileSearchPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid search pattern %q: %v", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// searchText finds the matches of each pattern in text
+func searchText(patterns []*regexp.Regexp, text string) []ContentMatch {
+	var matches []ContentMatch
+	for _, re := range patterns {
+		for _, loc := range re.FindAllStringIndex(text, maxMatchesPerPage-len(matches)) {
+			matches = append(matches, ContentMatch{
+				Pattern: re.String(),
+				Match:   text[loc[0]:loc[1]],
+				Context: matchWindow(text, loc[0], loc[1]),
+			})
+		}
+		if len(matches) >= maxMatchesPerPage {
+			break
+		}
+	}
+	return matches
+}
+
+// matchWindow returns text[start:end] with up to matchContext bytes on
+// either side, without splitting UTF-8 sequences
+func matchWindow(text string, start, end int) string {
+	from, to := start-matchContext, end+matchContext
+	if from < 0 {
+		from = 0
+	}
+	if to > len(text) {
+		to = len(text)
+	}
+	for from > 0 && !isRuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !isRuneStart(text[to]) {
+		to++
+	}
+
+	window := text[from:to]
+	if from > 0 {
+		window = "..." + window
+	}
+	if to < len(text) {
+		window += "..."
+	}
+	return window
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// pageText returns a document's text with whitespace collapsed, leaving out
+// stylesheets
+func pageText(doc *html.Node) string {
+	return collapsedText(doc, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "style"
+	})
+}
@@ -0,0 +1,115 @@
+package crawler
+
+import (
+	"fmt"
+	"time"
+)
+
+// Settings are the politeness settings that UpdateSettings can change while
+// a crawl runs
+type Settings struct {
+	Workers      int           `json:"workers"`
+	Delay        time.Duration `json:"delay"`        // Fixed delay before each request
+	PerHostLimit int           `json:"perHostLimit"` // Concurrent requests per host, 0 = unlimited
+}
+
+// Settings returns the crawl's current workers, delay and per-host limit
+func (c *Crawler) Settings() Settings {
+	c.frontierMu.Lock()
+	workers := c.maxWorkers
+	c.frontierMu.Unlock()
+	c.settingsMu.RLock()
+	defer c.settingsMu.RUnlock()
+	return Settings{Workers: workers, Delay: c.crawlDelay, PerHostLimit: c.perHostLimit}
+}
+
+// UpdateSettings changes the crawl's workers, delay and per-host limit
+// without restarting it. Extra workers start at once; when there are fewer,
+// workers stop as they finish their current page. A new delay applies from
+// the next request, and a raised per-host limit lets waiting requests go.
+func (c *Crawler) UpdateSettings(s Settings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	c.settingsMu.Lock()
+	c.crawlDelay, c.perHostLimit = s.Delay, s.PerHostLimit
+	c.settingsMu.Unlock()
+	c.hostSlots.Range(func(_, value interface{}) bool {
+		value.(*hostSlot).wake()
+		return true
+	})
+
+	c.frontierMu.Lock()
+	defer c.frontierMu.Unlock()
+	c.maxWorkers = s.Workers
+	if c.hosts != nil {
+		c.hosts.signal()
+	}
+	// Only a running crawl gets more workers; once they have all exited,
+	// the next run starts maxWorkers of them
+	if c.liveWorkers == 0 {
+		return nil
+	}
+	for len(c.workerStatus) < c.maxWorkers {
+		status := &workerStatus{}
+		c.workerStatus = append(c.workerStatus[:len(c.workerStatus):len(c.workerStatus)], status)
+		c.liveWorkers++
+		c.wg.Add(1)
+		go c.worker(c.workerCtx, status)
+	}
+	return nil
+}
+
+// Validate checks that the settings can be applied
+func (s Settings) Validate() error {
+	switch {
+	case s.Workers < 1:
+		return fmt.Errorf("workers must be at least 1")
+	case s.Delay < 0:
+		return fmt.Errorf("delay must not be negative")
+	case s.PerHostLimit < 0:
+		return fmt.Errorf("perHostLimit must not be negative")
+	}
+	return nil
+}
+
+// delay returns the crawl delay
+func (c *Crawler) delay() time.Duration {
+	c.settingsMu.RLock()
+	defer c.settingsMu.RUnlock()
+	return c.crawlDelay
+}
+
+// hostLimit returns the per-host limit, 0 when unlimited
+func (c *Crawler) hostLimit() int {
+	c.settingsMu.RLock()
+	defer c.settingsMu.RUnlock()
+	return c.perHostLimit
+}
+
+// retire reports whether a worker should stop because UpdateSettings
+// lowered the worker count, removing it from the worker list if so
+func (c *Crawler) retire(status *workerStatus) bool {
+	c.frontierMu.Lock()
+	defer c.frontierMu.Unlock()
+	if len(c.workerStatus) <= c.maxWorkers {
+		return false
+	}
+	// A new list, since DebugState reads the old one without the lock
+	workers := make([]*workerStatus, 0, len(c.workerStatus)-1)
+	for _, w := range c.workerStatus {
+		if w != status {
+			workers = append(workers, w)
+		}
+	}
+	c.workerStatus = workers
+	return true
+}
+
+// workerExited records that a worker goroutine is about to exit
+func (c *Crawler) workerExited() {
+	c.frontierMu.Lock()
+	c.liveWorkers--
+	c.frontierMu.Unlock()
+}
@@ -0,0 +1,181 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Limits on how much of a site's sitemaps are read
+const (
+	maxSitemapFiles = 100
+	maxSitemapURLs  = 100000
+)
+
+// Sitemap is what was read from a site's sitemaps
+type Sitemap struct {
+	Files     []string `json:"files"`            // Sitemaps fetched, including sitemap indexes
+	URLs      []string `json:"urls"`             // Page URLs listed, in order, without duplicates
+	Errors    []string `json:"errors,omitempty"` // Sitemaps that could not be fetched or parsed
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+// parseSitemap returns the <loc> values of a sitemap: page URLs for a
+// <urlset> and further sitemaps for a <sitemapindex>
+func parseSitemap(body io.Reader) (pages, sitemaps []string, err error) {
+	d := xml.NewDecoder(body)
+	d.Strict = false
+	d.CharsetReader = charset.NewReaderLabel
+
+	var parent string
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return pages, sitemaps, nil
+		}
+		if err != nil {
+			return pages, sitemaps, fmt.Errorf("error parsing sitemap: %v", err)
+		}
+
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch t.Name.Local {
+		case "url", "sitemap":
+			parent = t.Name.Local
+		case "loc":
+			var loc string
+			if err := d.DecodeElement(&loc, &t); err != nil {
+				return pages, sitemaps, fmt.Errorf("error parsing sitemap: %v", err)
+			}
+			if loc = strings.TrimSpace(loc); loc == "" {
+				continue
+			}
+			if parent == "sitemap" {
+				sitemaps = append(sitemaps, loc)
+			} else {
+				pages = append(pages, loc)
+			}
+		}
+	}
+}
+
+// ReadSitemaps reads the sitemaps of startURL's host: those listed in its
+// robots.txt or, if there are none, /sitemap.xml. Sitemap indexes are
+// followed. Gzipped sitemaps are supported.
+func (c *Crawler) ReadSitemaps(ctx context.Context, startURL string) (*Sitemap, error) {
+	u, err := url.Parse(startURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %v", startURL, err)
+	}
+	rules, err := c.getRobotsRules(u)
+	if err != nil {
+		return nil, fmt.Errorf("error getting robots.txt rules: %v", err)
+	}
+	queue := rules.Sitemaps()
+	if len(queue) == 0 {
+		queue = []string{fmt.Sprintf("%s://%s/sitemap.xml", u.Scheme, u.Host)}
+	}
+
+	sm := &Sitemap{}
+	seenFiles := make(map[string]bool)
+	seenURLs := make(map[string]bool)
+	for len(queue) > 0 && ctx.Err() == nil {
+		file := queue[0]
+		queue = queue[1:]
+		if seenFiles[file] {
+			continue
+		}
+		if len(sm.Files) >= maxSitemapFiles {
+			sm.Truncated = true
+			break
+		}
+		seenFiles[file] = true
+		sm.Files = append(sm.Files, file)
+
+		pages, children, err := c.fetchSitemap(ctx, file)
+		if err != nil {
+			sm.Errors = append(sm.Errors, err.Error())
+		}
+		queue = append(queue, children...)
+		for _, page := range pages {
+			if seenURLs[page] {
+				continue
+			}
+			if len(sm.URLs) >= maxSitemapURLs {
+				sm.Truncated = true
+				break
+			}
+			seenURLs[page] = true
+			sm.URLs = append(sm.URLs, page)
+		}
+	}
+	return sm, ctx.Err()
+}
+
+// fetchSitemap fetches and parses one sitemap file
+func (c *Crawler) fetchSitemap(ctx context.Context, sitemapURL string) (pages, sitemaps []string, err error) {
+	u, err := url.Parse(sitemapURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w %s: %v", ErrInvalidURL, sitemapURL, err)
+	}
+	rules, err := c.getRobotsRules(u)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting robots.txt rules for %s: %w", sitemapURL, err)
+	}
+	if _, allowed := c.checkRobots(rules, sitemapURL); !allowed {
+		return nil, nil, fmt.Errorf("%w: %s", ErrRobotsDisallowed, sitemapURL)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating request for %s: %w: %v", sitemapURL, ErrInvalidURL, err)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.rateLimiter != nil {
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+	resp, err := c.doWithRetries(ctx, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error fetching %s: %w", sitemapURL, requestFailed(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, &ErrStatus{Code: resp.StatusCode, URL: sitemapURL}
+	}
+
+	var body io.Reader = resp.Body
+	if c.bandwidth != nil {
+		body = c.bandwidth.Reader(ctx, body)
+	}
+	// Sitemaps are often served as .xml.gz files rather than with
+	// Content-Encoding, so look at the content itself
+	br := bufio.NewReader(body)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error decompressing %s: %v", sitemapURL, err)
+		}
+		defer gz.Close()
+		body = gz
+	} else {
+		body = br
+	}
+
+	pages, sitemaps, err = parseSitemap(body)
+	if err != nil {
+		return pages, sitemaps, fmt.Errorf("%s: %v", sitemapURL, err)
+	}
+	return pages, sitemaps, nil
+}
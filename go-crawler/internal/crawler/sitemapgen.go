@@ -0,0 +1,185 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Limits of one generated sitemap file, set by the sitemaps protocol
+const (
+	SitemapMaxURLs  = 50000
+	SitemapMaxBytes = 50 << 20
+)
+
+// SitemapFormat is the file format of a generated sitemap
+type SitemapFormat string
+
+const (
+	SitemapXML  SitemapFormat = "xml" // <urlset> with <loc> and <lastmod>
+	SitemapText SitemapFormat = "txt" // One URL per line
+)
+
+// ParseSitemapFormat checks a sitemap format name; empty means XML
+func ParseSitemapFormat(name string) (SitemapFormat, error) {
+	switch f := SitemapFormat(strings.ToLower(name)); f {
+	case "":
+		return SitemapXML, nil
+	case SitemapXML, SitemapText:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown sitemap format %q, expected xml or txt", name)
+}
+
+// SitemapPage is a crawled page as a generated sitemap sees it
+type SitemapPage struct {
+	URL          string
+	StatusCode   int
+	Canonical    string // As reported in CrawlResult.Canonical
+	LastModified string // The Last-Modified header, if any
+}
+
+// NewSitemapPage returns the sitemap view of a crawl result
+func NewSitemapPage(r CrawlResult) SitemapPage {
+	return SitemapPage{URL: r.URL, StatusCode: r.StatusCode, Canonical: r.Canonical, LastModified: r.LastModified}
+}
+
+// SitemapFilter selects the pages a generated sitemap lists. Pages that
+// couldn't be fetched are always left out.
+type SitemapFilter struct {
+	OK        bool // Only pages answered with 200 OK
+	Canonical bool // Leave out pages whose rel=canonical names another page
+}
+
+// ParseSitemapFilter parses a comma-separated list of "ok" and "canonical"
+func ParseSitemapFilter(s string) (SitemapFilter, error) {
+	var f SitemapFilter
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "ok":
+			f.OK = true
+		case "canonical":
+			f.Canonical = true
+		default:
+			return f, fmt.Errorf("unknown sitemap filter %q, expected ok or canonical", name)
+		}
+	}
+	return f, nil
+}
+
+// SitemapEntry is one URL of a generated sitemap
+type SitemapEntry struct {
+	Loc     string
+	LastMod time.Time // Zero when the page had no Last-Modified header
+}
+
+// SitemapEntries returns the http and https pages the filter selects, in
+// crawl order and without duplicates
+func SitemapEntries(pages []SitemapPage, f SitemapFilter) []SitemapEntry {
+	var entries []SitemapEntry
+	seen := make(map[string]bool)
+	for _, p := range pages {
+		if p.StatusCode == 0 || (f.OK && p.StatusCode != http.StatusOK) || (f.Canonical && p.Canonical != "") {
+			continue
+		}
+		u, err := url.Parse(p.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		u.Fragment = ""
+		loc := u.String()
+		if seen[loc] {
+			continue
+		}
+		seen[loc] = true
+		entry := SitemapEntry{Loc: loc}
+		if t, err := http.ParseTime(p.LastModified); err == nil {
+			entry.LastMod = t.UTC()
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+const (
+	sitemapHeader      = xml.Header + `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	sitemapFooter      = "</urlset>\n"
+	sitemapIndexHeader = xml.Header + `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	sitemapIndexFooter = "</sitemapindex>\n"
+)
+
+// sitemapLine renders an entry as it appears in a sitemap file
+func sitemapLine(e SitemapEntry, format SitemapFormat) string {
+	if format == SitemapText {
+		return e.Loc + "\n"
+	}
+	var b strings.Builder
+	b.WriteString("  <url>\n    <loc>")
+	xml.EscapeText(&b, []byte(e.Loc))
+	b.WriteString("</loc>\n")
+	if !e.LastMod.IsZero() {
+		b.WriteString("    <lastmod>" + e.LastMod.Format(time.RFC3339) + "</lastmod>\n")
+	}
+	b.WriteString("  </url>\n")
+	return b.String()
+}
+
+// SplitSitemap splits entries into as few sitemap files as keep each within
+// SitemapMaxURLs and SitemapMaxBytes. It returns at least one, possibly
+// empty, file.
+func SplitSitemap(entries []SitemapEntry, format SitemapFormat) [][]SitemapEntry {
+	size := 0
+	if format == SitemapXML {
+		size = len(sitemapHeader) + len(sitemapFooter)
+	}
+	files := [][]SitemapEntry{nil}
+	used := size
+	for _, e := range entries {
+		n := len(sitemapLine(e, format))
+		last := len(files) - 1
+		if len(files[last]) == SitemapMaxURLs || (len(files[last]) > 0 && used+n > SitemapMaxBytes) {
+			files = append(files, nil)
+			last++
+			used = size
+		}
+		files[last] = append(files[last], e)
+		used += n
+	}
+	return files
+}
+
+// WriteSitemap writes entries as one sitemap file. Use SplitSitemap first
+// for crawls that may exceed a file's limits.
+func WriteSitemap(w io.Writer, entries []SitemapEntry, format SitemapFormat) error {
+	bw := bufio.NewWriter(w)
+	if format == SitemapXML {
+		bw.WriteString(sitemapHeader)
+	}
+	for _, e := range entries {
+		bw.WriteString(sitemapLine(e, format))
+	}
+	if format == SitemapXML {
+		bw.WriteString(sitemapFooter)
+	}
+	return bw.Flush()
+}
+
+// WriteSitemapIndex writes a sitemap index listing the sitemap files at
+// locs
+func WriteSitemapIndex(w io.Writer, locs []string) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(sitemapIndexHeader)
+	for _, loc := range locs {
+		bw.WriteString("  <sitemap>\n    <loc>")
+		xml.EscapeText(bw, []byte(loc))
+		bw.WriteString("</loc>\n  </sitemap>\n")
+	}
+	bw.WriteString(sitemapIndexFooter)
+	return bw.Flush()
+}
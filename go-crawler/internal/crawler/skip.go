@@ -0,0 +1,58 @@
+package crawler
+
+// SkipReason explains why a discovered URL was not fetched
+type SkipReason string
+
+const (
+	SkipRobots    SkipReason = "robots"     // Disallowed by robots.txt
+	SkipFilter    SkipReason = "filter"     // Rejected by the URL filter
+	SkipDepth     SkipReason = "depth"      // Found on a page at the maximum depth
+	SkipOffDomain SkipReason = "off-domain" // Outside the start URL's host
+	SkipDuplicate SkipReason = "duplicate"  // Already visited
+	SkipBudget    SkipReason = "budget"     // Page budget already used up
+	SkipQueueFull SkipReason = "queue-full" // Dropped because the queue was full
+	SkipTrap      SkipReason = "trap"       // Matches a detected crawl trap pattern
+	SkipURLLimit  SkipReason = "url-limit"  // Too long or too many repeated segments
+	SkipError     SkipReason = "error"      // Failed with an error the error policy skips
+	SkipExcluded  SkipReason = "excluded"   // Matches a pattern added by Exclude
+)
+
+// SkippedURL records a URL the crawler decided not to fetch
+type SkippedURL struct {
+	URL    string     `json:"url"`
+	Source string     `json:"source,omitempty"` // Page the link was found on
+	Reason SkipReason `json:"reason"`
+	Detail string     `json:"detail,omitempty"`
+}
+
+// WithSkipHandler calls fn for every URL the crawler skips. It is called
+// from worker goroutines and must be safe for concurrent use.
+func WithSkipHandler(fn func(SkippedURL)) Option {
+	return func(c *Crawler) {
+		c.onSkip = fn
+	}
+}
+
+// WithSameHost only follows links on the start URL's host
+func WithSameHost() Option {
+	return func(c *Crawler) {
+		c.sameHost = true
+	}
+}
+
+// WithMaxPages caps the number of pages fetched, including the start URL;
+// zero means unlimited
+func WithMaxPages(n int) Option {
+	return func(c *Crawler) {
+		c.maxPages = int64(n)
+	}
+}
+
+// skip reports a skipped URL to the skip handler, if one is set, and the
+// event bus
+func (c *Crawler) skip(urlStr, source string, reason SkipReason, detail string) {
+	c.emit(Event{Type: EventURLSkipped, URL: urlStr, Source: source, Reason: string(reason), Detail: detail})
+	if c.onSkip != nil {
+		c.onSkip(SkippedURL{URL: urlStr, Source: source, Reason: reason, Detail: detail})
+	}
+}
@@ -0,0 +1,29 @@
+package crawler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// sniffLen is how much of a body http.DetectContentType looks at
+const sniffLen = 512
+
+// isHTML reports whether a content type is HTML
+func isHTML(contentType string) bool {
+	return strings.Contains(strings.ToLower(contentType), "text/html")
+}
+
+// sniffContent reads the start of a body to detect its content type for
+// servers that leave out Content-Type or get it wrong. The reader returned
+// still yields the whole body.
+func sniffContent(body io.Reader) (io.Reader, string, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, "", err
+	}
+	head = head[:n]
+	return io.MultiReader(bytes.NewReader(head), body), http.DetectContentType(head), nil
+}
@@ -0,0 +1,35 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+)
+
+// WithHashbangRoutes crawls the #! routes of single-page applications as
+// pages of their own. Browsers never send fragments, so each route is
+// fetched the way the AJAX crawling scheme specifies, with the route in an
+// _escaped_fragment_ query parameter, which sites that prerender for
+// crawlers answer with the route's HTML. Results keep the #! URL.
+func WithHashbangRoutes() Option {
+	return func(c *Crawler) {
+		c.hashbangRoutes = true
+	}
+}
+
+// isHashbang reports whether a URL's fragment is a #! route
+func isHashbang(u *url.URL) bool {
+	return strings.HasPrefix(u.Fragment, "!")
+}
+
+// escapedFragmentURL returns the URL that fetches a #! route, e.g.
+// /app?_escaped_fragment_=/about for /app#!/about
+func escapedFragmentURL(u *url.URL) string {
+	fetch := *u
+	route := url.QueryEscape(strings.TrimPrefix(u.Fragment, "!"))
+	if fetch.RawQuery != "" {
+		fetch.RawQuery += "&"
+	}
+	fetch.RawQuery += "_escaped_fragment_=" + route
+	fetch.Fragment, fetch.RawFragment = "", ""
+	return fetch.String()
+}
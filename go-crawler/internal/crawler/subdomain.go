@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// SubdomainMode says which subdomains of the start URL's domain a crawl
+// follows links to
+type SubdomainMode string
+
+const (
+	SubdomainsAll    SubdomainMode = "all"    // Every subdomain
+	SubdomainsListed SubdomainMode = "listed" // Only the subdomains in Allow
+	SubdomainsNone   SubdomainMode = "none"   // Only the start host and the domain itself
+)
+
+// SubdomainPolicy scopes a crawl to the start URL's site: links to other
+// domains are skipped as off-domain, and links to subdomains such as
+// blog.example.com are followed as the mode says. The site's domain is the
+// start host's registrable domain (see RegistrableDomain).
+type SubdomainPolicy struct {
+	Mode SubdomainMode `json:"mode"`
+	// Allow lists the subdomains followed in listed mode, as labels such
+	// as "blog" or full host names. Their own subdomains are followed too.
+	Allow []string `json:"allow,omitempty"`
+}
+
+// ParseSubdomainPolicy parses "all", "none" or a comma-separated list of
+// subdomains to follow
+func ParseSubdomainPolicy(s string) (SubdomainPolicy, error) {
+	switch mode := SubdomainMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case SubdomainsAll, SubdomainsNone:
+		return SubdomainPolicy{Mode: mode}, nil
+	}
+	p := SubdomainPolicy{Mode: SubdomainsListed}
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			p.Allow = append(p.Allow, name)
+		}
+	}
+	return p, p.Validate()
+}
+
+// Validate checks the mode and that listed mode lists subdomains
+func (p SubdomainPolicy) Validate() error {
+	switch p.Mode {
+	case SubdomainsAll, SubdomainsNone:
+		return nil
+	case SubdomainsListed:
+		if len(p.Allow) == 0 {
+			return fmt.Errorf("subdomain policy %q needs subdomains to allow", p.Mode)
+		}
+		for _, name := range p.Allow {
+			if strings.TrimSpace(name) == "" || strings.ContainsAny(name, "/: ") {
+				return fmt.Errorf("invalid subdomain %q", name)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown subdomain mode %q: want all, listed or none", p.Mode)
+}
+
+// WithSubdomainPolicy limits the crawl to the start URL's site and the
+// subdomains p allows
+func WithSubdomainPolicy(p SubdomainPolicy) Option {
+	return func(c *Crawler) {
+		c.subdomains = &p
+	}
+}
+
+// RegistrableDomain returns the domain a host belongs to according to the
+// public suffix list (its eTLD+1), e.g. example.co.uk for
+// shop.example.co.uk. IP addresses, single-label hosts such as localhost
+// and public suffixes themselves are returned as they are.
+func RegistrableDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// SameSite reports whether two hosts belong to the same registrable domain
+func SameSite(a, b string) bool {
+	return RegistrableDomain(a) == RegistrableDomain(b)
+}
+
+// Allows reports whether a link to host is in scope of a crawl that
+// started on startHost, with the reason when it isn't
+func (p SubdomainPolicy) Allows(startHost, host string) (bool, string) {
+	startHost, host = strings.ToLower(startHost), strings.ToLower(host)
+	domain := RegistrableDomain(startHost)
+	if host == startHost || host == domain || host == "www."+domain {
+		return true, ""
+	}
+	sub, ok := strings.CutSuffix(host, "."+domain)
+	if !ok {
+		return false, ""
+	}
+
+	switch p.Mode {
+	case SubdomainsAll:
+		return true, ""
+	case SubdomainsListed:
+		for _, name := range p.Allow {
+			name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), "."+domain)
+			if sub == name || strings.HasSuffix(sub, "."+name) {
+				return true, ""
+			}
+		}
+	}
+	return false, "subdomain " + host + " not allowed"
+}
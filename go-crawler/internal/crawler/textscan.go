@@ -0,0 +1,202 @@
+package crawler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// TextFinding is something a TextProcessor reported in a page's text
+type TextFinding struct {
+	Processor string `json:"processor"`
+	Rule      string `json:"rule"` // What matched, e.g. a banned phrase
+	Match     string `json:"match"`
+	Context   string `json:"context"`           // The match with the text around it
+	Message   string `json:"message,omitempty"` // E.g. the wording to use instead
+}
+
+// TextProcessor checks the text of every HTML page during a crawl, e.g. for
+// misspellings, outdated product names or compliance phrases. Workers call
+// it concurrently.
+type TextProcessor interface {
+	// Name identifies the processor in findings and logs
+	Name() string
+	// ProcessText returns what the processor found in a page. The page's
+	// Text is everything a reader sees, navigation and footers included,
+	// with whitespace collapsed.
+	ProcessText(page PageText) ([]TextFinding, error)
+}
+
+// WithTextProcessors runs text processors over every HTML page, in order.
+// Their findings are reported in each result's Findings, at most 100 per
+// page.
+func WithTextProcessors(processors ...TextProcessor) Option {
+	return func(c *Crawler) {
+		c.textProcessors = append(c.textProcessors, processors...)
+	}
+}
+
+// processText runs every text processor over a page. Processor errors are
+// logged and cost the page only that processor's findings.
+func (c *Crawler) processText(page PageText) []TextFinding {
+	var findings []TextFinding
+	for _, p := range c.textProcessors {
+		found, err := p.ProcessText(page)
+		if err != nil {
+			log.Printf("Text processor %s: %v", p.Name(), err)
+			continue
+		}
+		for _, f := range found {
+			if len(findings) == maxMatchesPerPage {
+				return findings
+			}
+			f.Processor = p.Name()
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// visibleText returns the text of a document a reader sees, with
+// whitespace collapsed
+func visibleText(doc *html.Node) string {
+	return collapsedText(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return n.Type == html.CommentNode
+		}
+		switch n.Data {
+		case "head", "script", "style", "noscript", "template":
+			return true
+		}
+		return false
+	})
+}
+
+// ScanRule is a word, phrase or pattern a WordScanner reports
+type ScanRule struct {
+	Rule    string // As written, e.g. "Acme Widget" or "/colou?r/"
+	Pattern *regexp.Regexp
+	Message string
+}
+
+// ParseScanRules reads WordScanner rules, one per line, with lines starting
+// with # as comments. A rule is a word or phrase, matched as whole words
+// ignoring case and line breaks, or a regular expression between slashes,
+// optionally followed by "=>" and a message such as the replacement:
+//
+//	Acme Widget => Acme Gizmo
+//	/\bv[12]\.\d+\b/ => Versions before 3 are retired
+//	guaranteed returns
+func ParseScanRules(r io.Reader) ([]ScanRule, error) {
+	var rules []ScanRule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := parseScanRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scan rule on line %d: %v", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading scan rules: %v", err)
+	}
+	return rules, nil
+}
+
+func parseScanRule(text string) (ScanRule, error) {
+	if strings.HasPrefix(text, "/") {
+		// The first slash followed by nothing but a message closes the
+		// expression
+		for i := 1; i < len(text); i++ {
+			if text[i] != '/' {
+				continue
+			}
+			rest := strings.TrimSpace(text[i+1:])
+			if rest != "" && !strings.HasPrefix(rest, "=>") {
+				continue
+			}
+			re, err := regexp.Compile(text[1:i])
+			if err != nil {
+				return ScanRule{}, err
+			}
+			return ScanRule{Rule: text[:i+1], Pattern: re, Message: strings.TrimSpace(strings.TrimPrefix(rest, "=>"))}, nil
+		}
+		return ScanRule{}, fmt.Errorf("%q has no closing slash", text)
+	}
+
+	phrase, message, _ := strings.Cut(text, "=>")
+	words := strings.Fields(phrase)
+	if len(words) == 0 {
+		return ScanRule{}, fmt.Errorf("%q has no phrase", text)
+	}
+	for i, w := range words {
+		words[i] = regexp.QuoteMeta(w)
+	}
+	pattern := strings.Join(words, `\s+`)
+	// \b only separates ASCII word characters from the rest
+	if isWordByte(phrase[strings.IndexFunc(phrase, isNotSpace)]) {
+		pattern = `\b` + pattern
+	}
+	if isWordByte(phrase[strings.LastIndexFunc(phrase, isNotSpace)]) {
+		pattern += `\b`
+	}
+	return ScanRule{
+		Rule:    strings.Join(strings.Fields(phrase), " "),
+		Pattern: regexp.MustCompile(`(?i)` + pattern),
+		Message: strings.TrimSpace(message),
+	}, nil
+}
+
+func isNotSpace(r rune) bool {
+	return !strings.ContainsRune(" \t\r\n\f\v", r)
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// WordScanner is a TextProcessor that reports the words, phrases and
+// patterns of its rules in page titles and text, e.g. outdated product
+// names or phrases compliance has ruled out
+type WordScanner struct {
+	name  string
+	rules []ScanRule
+}
+
+// NewWordScanner returns a scanner for rules, named name in its findings
+func NewWordScanner(name string, rules []ScanRule) *WordScanner {
+	return &WordScanner{name: name, rules: rules}
+}
+
+func (s *WordScanner) Name() string {
+	return s.name
+}
+
+func (s *WordScanner) ProcessText(page PageText) ([]TextFinding, error) {
+	var findings []TextFinding
+	for _, text := range []string{page.Title, page.Text} {
+		for _, rule := range s.rules {
+			for _, loc := range rule.Pattern.FindAllStringIndex(text, maxMatchesPerPage-len(findings)) {
+				findings = append(findings, TextFinding{
+					Rule:    rule.Rule,
+					Match:   text[loc[0]:loc[1]],
+					Context: matchWindow(text, loc[0], loc[1]),
+					Message: rule.Message,
+				})
+			}
+			if len(findings) >= maxMatchesPerPage {
+				return findings, nil
+			}
+		}
+	}
+	return findings, nil
+}
@@ -0,0 +1,58 @@
+package crawler
+
+import (
+	"sort"
+	"unicode"
+)
+
+// DefaultThinWords is the word count below which SEO audits usually call
+// a page's content thin
+const DefaultThinWords = 300
+
+// PageWords is the word count of a page's main text
+type PageWords struct {
+	URL   string `json:"url"`
+	Words int    `json:"words"`
+}
+
+// NewPageWords returns the word count of a result, and false when its text
+// wasn't extracted: for errors, documents other than HTML and crawls
+// without WithTextExtraction
+func NewPageWords(r CrawlResult) (PageWords, bool) {
+	return PageWords{URL: r.URL, Words: r.WordCount}, r.textExtracted
+}
+
+// CountWords counts the words of a text: runs of letters and digits, with
+// every Chinese and Japanese character a word of its own since those
+// scripts don't separate words with spaces
+func CountWords(text string) int {
+	n := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			n++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || (inWord && (r == '\'' || r == '’' || r == '-')):
+			if !inWord {
+				n++
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+	}
+	return n
+}
+
+// ThinPages returns the pages with fewer than minWords words, fewest first
+func ThinPages(pages []PageWords, minWords int) []PageWords {
+	var thin []PageWords
+	for _, p := range pages {
+		if p.Words < minWords {
+			thin = append(thin, p)
+		}
+	}
+	sort.SliceStable(thin, func(i, j int) bool { return thin[i].Words < thin[j].Words })
+	return thin
+}
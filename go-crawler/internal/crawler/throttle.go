@@ -0,0 +1,151 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRetryAfter caps how long a single Retry-After can pause a host
+const maxRetryAfter = 10 * time.Minute
+
+// defaultRetryAfter pauses a host that answers 429 or 503 without a
+// Retry-After header
+const defaultRetryAfter = 5 * time.Second
+
+// HostThrottle summarises how a host throttled the crawl
+type HostThrottle struct {
+	Host      string        `json:"host"`
+	Responses int           `json:"responses"` // 429 and 503 responses
+	Paused    time.Duration `json:"paused"`    // Total time requests to the host were held back
+}
+
+// throttleRegistry tracks per-host pauses requested with 429 Too Many
+// Requests or 503 Service Unavailable, so that every worker honours them
+type throttleRegistry struct {
+	mu    sync.Mutex
+	hosts map[string]*hostThrottle
+}
+
+type hostThrottle struct {
+	until     time.Time
+	responses int
+	paused    time.Duration
+}
+
+func newThrottleRegistry() *throttleRegistry {
+	return &throttleRegistry{hosts: make(map[string]*hostThrottle)}
+}
+
+// record pauses a host if resp asks it to slow down and reports whether
+// it did
+func (t *throttleRegistry) record(host string, resp *http.Response) bool {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return false
+	}
+	d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		d = defaultRetryAfter
+	}
+	if d > maxRetryAfter {
+		d = maxRetryAfter
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.hosts[host]
+	if h == nil {
+		h = &hostThrottle{}
+		t.hosts[host] = h
+	}
+	h.responses++
+	now := time.Now()
+	until := now.Add(d)
+	if until.After(h.until) {
+		// Only count the part of the pause not already covered
+		from := h.until
+		if from.Before(now) {
+			from = now
+		}
+		h.paused += until.Sub(from)
+		h.until = until
+	}
+	return true
+}
+
+// wait blocks until host is no longer paused
+func (t *throttleRegistry) wait(ctx context.Context, host string) error {
+	t.mu.Lock()
+	var d time.Duration
+	if h := t.hosts[host]; h != nil {
+		d = time.Until(h.until)
+	}
+	t.mu.Unlock()
+	return sleepCtx(ctx, d)
+}
+
+// pausedUntil returns when host's pause ends, the zero time if it was never
+// paused
+func (t *throttleRegistry) pausedUntil(host string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if h := t.hosts[host]; h != nil {
+		return h.until
+	}
+	return time.Time{}
+}
+
+// stats returns the throttled hosts, most paused first
+func (t *throttleRegistry) stats() []HostThrottle {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make([]HostThrottle, 0, len(t.hosts))
+	for host, h := range t.hosts {
+		paused := h.paused
+		if remaining := time.Until(h.until); remaining > 0 {
+			// Don't count the part of a pause that hasn't happened yet
+			paused -= remaining
+		}
+		stats = append(stats, HostThrottle{Host: host, Responses: h.responses, Paused: paused})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Paused != stats[j].Paused {
+			return stats[i].Paused > stats[j].Paused
+		}
+		return stats[i].Host < stats[j].Host
+	})
+	return stats
+}
+
+// parseRetryAfter reads a Retry-After header, either a number of seconds
+// or an HTTP date
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(header); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Throttling reports the hosts that asked the crawler to slow down with
+// 429 or 503 responses and how long requests to them were held back
+func (c *Crawler) Throttling() []HostThrottle {
+	return c.throttle.stats()
+}
@@ -0,0 +1,239 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TrapConfig tunes the crawl trap heuristics. Zero fields use the defaults
+// from DefaultTrapConfig.
+type TrapConfig struct {
+	// MaxPatternURLs is how many distinct URLs may share a parameterised
+	// pattern (query keys, or dates in the path) before the pattern is
+	// blocked. Calendars and faceted navigation hit this quickly.
+	MaxPatternURLs int
+	// MaxPathDepth is the number of path segments after which a path is
+	// considered ever-growing and its prefix is blocked
+	MaxPathDepth int
+}
+
+// DefaultTrapConfig returns the default crawl trap thresholds
+func DefaultTrapConfig() TrapConfig {
+	return TrapConfig{
+		MaxPatternURLs: 100,
+		MaxPathDepth:   15,
+	}
+}
+
+// Trap kinds reported by the detector
+const (
+	TrapCalendar  = "calendar"
+	TrapFacets    = "faceted-navigation"
+	TrapSessionID = "session-id"
+	TrapDeepPath  = "deep-path"
+)
+
+// Trap is a URL pattern the crawler stopped following
+type Trap struct {
+	Kind    string `json:"kind"`
+	Pattern string `json:"pattern"`
+	Example string `json:"example"`
+	Blocked int    `json:"blocked"` // URLs skipped after detection
+}
+
+// WithTrapDetection enables crawl trap detection. URLs matching a detected
+// trap pattern are skipped with SkipTrap and listed by Traps.
+func WithTrapDetection(cfg TrapConfig) Option {
+	return func(c *Crawler) {
+		defaults := DefaultTrapConfig()
+		if cfg.MaxPatternURLs <= 0 {
+			cfg.MaxPatternURLs = defaults.MaxPatternURLs
+		}
+		if cfg.MaxPathDepth <= 0 {
+			cfg.MaxPathDepth = defaults.MaxPathDepth
+		}
+		c.traps = newTrapDetector(cfg)
+	}
+}
+
+// Traps returns the crawl traps detected so far
+func (c *Crawler) Traps() []Trap {
+	if c.traps == nil {
+		return nil
+	}
+	return c.traps.list()
+}
+
+var (
+	datePattern    = regexp.MustCompile(`^\d{4}([-/]\d{1,2}){0,2}$|^\d{1,2}[-/]\d{1,2}[-/]\d{4}$`)
+	numberPattern  = regexp.MustCompile(`^\d+$`)
+	sessionParams  = regexp.MustCompile(`(?i)^(sid|sessid|sessionid|session_id|jsessionid|phpsessid|aspsessionid\w*|cfid|cftoken)$`)
+	pathSessionIDs = regexp.MustCompile(`(?i);(jsessionid|phpsessid|sid)=[^/?#]*`)
+)
+
+// trapDetector tracks URL patterns across a crawl and blocks the ones that
+// look like infinite URL spaces
+type trapDetector struct {
+	cfg      TrapConfig
+	mu       sync.Mutex
+	patterns map[string]map[string]struct{} // pattern -> distinct URLs
+	sessions map[string]struct{}            // URLs with session IDs removed
+	blocked  map[string]*Trap
+}
+
+func newTrapDetector(cfg TrapConfig) *trapDetector {
+	return &trapDetector{
+		cfg:      cfg,
+		patterns: make(map[string]map[string]struct{}),
+		sessions: make(map[string]struct{}),
+		blocked:  make(map[string]*Trap),
+	}
+}
+
+// check records a URL and returns the trap it falls into, if any
+func (d *trapDetector) check(u *url.URL) (*Trap, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Session IDs: the same page under a different session is a duplicate
+	if stripped, ok := stripSessionIDs(u); ok {
+		if _, seen := d.sessions[stripped]; seen {
+			return d.block(TrapSessionID, stripped, u.String()), true
+		}
+		d.sessions[stripped] = struct{}{}
+	}
+
+	// Ever-growing paths: block everything under the first few segments
+	segments := pathSegments(u.Path)
+	if len(segments) > d.cfg.MaxPathDepth {
+		prefix := u.Host + "/" + strings.Join(segments[:3], "/") + "/..."
+		return d.block(TrapDeepPath, prefix, u.String()), true
+	}
+
+	pattern, kind := urlPattern(u, segments)
+	if kind == "" {
+		return nil, false
+	}
+	if trap, ok := d.blocked[pattern]; ok {
+		trap.Blocked++
+		return trap, true
+	}
+
+	urls := d.patterns[pattern]
+	if urls == nil {
+		urls = make(map[string]struct{})
+		d.patterns[pattern] = urls
+	}
+	urls[u.String()] = struct{}{}
+	if len(urls) > d.cfg.MaxPatternURLs {
+		delete(d.patterns, pattern)
+		return d.block(kind, pattern, u.String()), true
+	}
+	return nil, false
+}
+
+// block records a hit on a trap pattern; the caller must hold d.mu
+func (d *trapDetector) block(kind, pattern, example string) *Trap {
+	trap, ok := d.blocked[pattern]
+	if !ok {
+		trap = &Trap{Kind: kind, Pattern: pattern, Example: example}
+		d.blocked[pattern] = trap
+	}
+	trap.Blocked++
+	return trap
+}
+
+func (d *trapDetector) list() []Trap {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	traps := make([]Trap, 0, len(d.blocked))
+	for _, t := range d.blocked {
+		traps = append(traps, *t)
+	}
+	sort.Slice(traps, func(i, j int) bool { return traps[i].Pattern < traps[j].Pattern })
+	return traps
+}
+
+// urlPattern generalises a URL by replacing dates and numbers in the path
+// with placeholders and dropping query values. It returns an empty kind for
+// URLs that aren't parameterised enough to form a trap, so plain /item/123
+// style catalogues are never blocked.
+func urlPattern(u *url.URL, segments []string) (string, string) {
+	kind := ""
+	parts := make([]string, len(segments))
+	for i, seg := range segments {
+		switch {
+		case datePattern.MatchString(seg):
+			parts[i] = "{date}"
+			kind = TrapCalendar
+		case numberPattern.MatchString(seg):
+			parts[i] = "{n}"
+		default:
+			parts[i] = seg
+		}
+	}
+
+	pattern := u.Host + "/" + strings.Join(parts, "/")
+
+	query := u.Query()
+	if len(query) == 0 {
+		return pattern, kind
+	}
+
+	keys := make([]string, 0, len(query))
+	for key, values := range query {
+		keys = append(keys, key)
+		for _, v := range values {
+			if datePattern.MatchString(v) {
+				kind = TrapCalendar
+			}
+		}
+	}
+	sort.Strings(keys)
+	if kind == "" {
+		kind = TrapFacets
+	}
+	return fmt.Sprintf("%s?%s=", pattern, strings.Join(keys, "=&")), kind
+}
+
+// stripSessionIDs removes session ID parameters from a URL, reporting
+// whether there were any
+func stripSessionIDs(u *url.URL) (string, bool) {
+	found := false
+	stripped := *u
+
+	if pathSessionIDs.MatchString(stripped.Path) {
+		stripped.Path = pathSessionIDs.ReplaceAllString(stripped.Path, "")
+		stripped.RawPath = ""
+		found = true
+	}
+
+	query := stripped.Query()
+	for key := range query {
+		if sessionParams.MatchString(key) {
+			query.Del(key)
+			found = true
+		}
+	}
+	if !found {
+		return "", false
+	}
+	stripped.RawQuery = query.Encode()
+	return stripped.String(), true
+}
+
+// pathSegments splits a URL path into its non-empty segments
+func pathSegments(path string) []string {
+	var segments []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
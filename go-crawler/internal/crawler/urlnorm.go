@@ -0,0 +1,100 @@
+package crawler
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// normalizeURL rewrites a URL into one canonical spelling so that variants
+// of the same resource are crawled once: the host is lower-cased and
+// internationalized domain names are converted to punycode, and in the path
+// and query escapes of unreserved characters are decoded, other escapes
+// are upper-cased and non-ASCII bytes are escaped. Escapes of reserved
+// characters such as %2F are kept, since they change what the URL means.
+func normalizeURL(u *url.URL) {
+	u.Host = normalizeHost(u.Host)
+	if u.Opaque != "" {
+		return
+	}
+	path := normalizeEscapes(u.EscapedPath())
+	if p, err := url.PathUnescape(path); err == nil {
+		u.Path, u.RawPath = p, ""
+		if u.EscapedPath() != path {
+			u.RawPath = path
+		}
+	}
+	u.RawQuery = normalizeEscapes(u.RawQuery)
+}
+
+// normalizeHost lower-cases a host and converts an internationalized name
+// to its ASCII form, keeping any port. Hosts that aren't valid IDNs are
+// only lower-cased.
+func normalizeHost(host string) string {
+	name, port := host, ""
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		name, port = h, p
+	}
+	name = strings.ToLower(name)
+	if !strings.HasPrefix(name, "[") && net.ParseIP(name) == nil {
+		if ascii, err := idna.Lookup.ToASCII(name); err == nil {
+			name = ascii
+		}
+	}
+	if port != "" {
+		return net.JoinHostPort(name, port)
+	}
+	return name
+}
+
+// normalizeEscapes rewrites the percent-encoding of an escaped path or
+// query as normalizeURL describes
+func normalizeEscapes(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(decoded) {
+				b.WriteByte(decoded)
+			} else {
+				b.WriteByte('%')
+				b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+			}
+			i += 2
+		case ch == '%', ch >= 0x80, ch <= ' ', ch == '"', ch == '<', ch == '>', ch == '\\', ch == '^', ch == '`', ch == '{', ch == '|', ch == '}':
+			// A stray %, non-ASCII or a character never allowed unescaped
+			b.WriteString("%" + hexByte(ch))
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+func hexByte(c byte) string {
+	const digits = "0123456789ABCDEF"
+	return string([]byte{digits[c>>4], digits[c&15]})
+}
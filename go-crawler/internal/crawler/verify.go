@@ -0,0 +1,228 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// noRedirectsKey marks a request context whose redirects are returned to
+// the caller instead of being followed
+type noRedirectsKey struct{}
+
+// URLStatus is the outcome of requesting one URL of a verification list
+type URLStatus struct {
+	URL         string `json:"url"`
+	StatusCode  int    `json:"statusCode,omitempty"`  // Status of URL itself
+	Location    string `json:"location,omitempty"`    // Where URL redirects to
+	FinalURL    string `json:"finalUrl,omitempty"`    // Last URL of the redirect chain
+	FinalStatus int    `json:"finalStatus,omitempty"` // Status of FinalURL
+	Redirects   int    `json:"redirects"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Redirected reports whether the URL redirected somewhere else
+func (s URLStatus) Redirected() bool {
+	return s.Redirects > 0
+}
+
+// Broken reports whether the URL could not be fetched or its redirect chain
+// ended in an error status
+func (s URLStatus) Broken() bool {
+	return s.Error != "" || s.FinalStatus >= 400
+}
+
+// URLStatusHeader is the CSV header matching URLStatus.Record
+var URLStatusHeader = []string{"url", "status_code", "location", "final_url", "final_status", "redirects", "error"}
+
+// Record returns the status as a CSV row
+func (s URLStatus) Record() []string {
+	return []string{
+		s.URL, statusString(s.StatusCode), s.Location, s.FinalURL, statusString(s.FinalStatus),
+		strconv.Itoa(s.Redirects), s.Error,
+	}
+}
+
+func statusString(code int) string {
+	if code == 0 {
+		return ""
+	}
+	return strconv.Itoa(code)
+}
+
+// VerifyURLs requests each URL received from urls once, without following
+// links, and sends the status, redirect target and final URL of each as
+// they complete. Redirects are followed hop by hop so the first one is
+// reported separately from where the chain ends. Duplicate URLs are
+// checked once. Requests honour robots.txt and the crawler's workers, delay,
+// rate and per-host limits and retries. The returned channel is closed
+// once urls is closed and every URL has been checked, or ctx is cancelled.
+func (c *Crawler) VerifyURLs(ctx context.Context, urls <-chan string) <-chan URLStatus {
+	statuses := make(chan URLStatus, c.maxWorkers)
+	go func() {
+		defer close(statuses)
+		c.forEachURL(ctx, urls, func(u string) {
+			status, _ := c.verifyURL(ctx, u, false)
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case statuses <- status:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return statuses
+}
+
+// forEachURL calls check with each distinct URL received from urls on the
+// crawler's workers, and returns once urls is closed and every check has
+// returned, or ctx is cancelled
+func (c *Crawler) forEachURL(ctx context.Context, urls <-chan string, check func(rawURL string)) {
+	work := make(chan string)
+	go func() {
+		defer close(work)
+		seen := make(map[string]bool)
+		for {
+			select {
+			case u, ok := <-urls:
+				if !ok {
+					return
+				}
+				u = strings.TrimSpace(u)
+				if u == "" || seen[u] {
+					continue
+				}
+				seen[u] = true
+				select {
+				case work <- u:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.maxWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range work {
+				if ctx.Err() != nil {
+					return
+				}
+				check(u)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// verifyURL follows the redirect chain starting at rawURL. With measure,
+// every URL is requested with GET and the size of the final response's body
+// is returned too.
+func (c *Crawler) verifyURL(ctx context.Context, rawURL string, measure bool) (URLStatus, int64) {
+	status := URLStatus{URL: rawURL}
+	visited := make(map[string]bool)
+	var chain []string
+	current := rawURL
+	for {
+		u, err := url.Parse(current)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			status.Error = fmt.Sprintf("invalid URL %q", current)
+			return status, 0
+		}
+		visited[u.String()] = true
+		chain = append(chain, u.String())
+
+		code, location, size, err := c.fetchStatus(ctx, u, measure)
+		if err != nil {
+			status.Error = err.Error()
+			return status, 0
+		}
+		if status.Redirects == 0 {
+			status.StatusCode, status.Location = code, location
+		}
+		status.FinalURL, status.FinalStatus = current, code
+		if location == "" || code < 300 || code >= 400 {
+			return status, size
+		}
+
+		if visited[location] || status.Redirects == c.maxRedirects {
+			status.Error = (&RedirectError{Chain: append(chain, location), Loop: visited[location]}).Error()
+			return status, 0
+		}
+		status.Redirects++
+		current = location
+	}
+}
+
+// fetchStatus requests a URL without following redirects and returns its
+// status code, resolved Location header and the bytes of the body it read.
+// HEAD is tried first, falling back to GET for servers that don't support
+// it; with measure only GET is used and the body is read up to
+// maxMeasuredBody.
+func (c *Crawler) fetchStatus(ctx context.Context, u *url.URL, measure bool) (int, string, int64, error) {
+	rules, err := c.getRobotsRules(u)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	if _, allowed := c.checkRobots(rules, u.String()); !allowed {
+		return 0, "", 0, ErrRobotsDisallowed
+	}
+
+	if err := c.politeDelay(ctx); err != nil {
+		return 0, "", 0, err
+	}
+	if c.rateLimiter != nil {
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			return 0, "", 0, err
+		}
+	}
+	release, err := c.acquireHost(ctx, u.Host)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	defer release()
+
+	ctx = context.WithValue(ctx, noRedirectsKey{}, true)
+	methods, limit := []string{http.MethodHead, http.MethodGet}, int64(4096)
+	if measure {
+		methods, limit = []string{http.MethodGet}, maxMeasuredBody
+	}
+	for _, method := range methods {
+		req, err := http.NewRequest(method, u.String(), nil)
+		if err != nil {
+			return 0, "", 0, fmt.Errorf("error creating request: %v", err)
+		}
+		req.Header.Set("User-Agent", c.userAgent)
+		resp, err := c.doWithRetries(ctx, req)
+		if err != nil {
+			return 0, "", 0, err
+		}
+		// Drain a little of the body so small responses free the connection,
+		// or all of it when measuring
+		size, _ := io.CopyN(io.Discard, resp.Body, limit)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+			continue
+		}
+
+		location := ""
+		if loc := resp.Header.Get("Location"); loc != "" {
+			if target, err := u.Parse(loc); err == nil {
+				location = target.String()
+			}
+		}
+		return resp.StatusCode, location, size, nil
+	}
+	return http.StatusMethodNotAllowed, "", 0, nil
+}
@@ -0,0 +1,50 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStopVisit can be returned by a Visit callback to end the crawl early.
+// Visit then returns nil.
+var ErrStopVisit = errors.New("stop visiting")
+
+// Visit crawls from startURL and calls fn with each result, one at a time,
+// in the calling goroutine. While fn runs, workers keep fetching until the
+// result buffer fills up and then wait, so a slow fn slows the crawl down
+// rather than piling up results. If fn returns an error the crawl is
+// cancelled, in-flight requests are abandoned, and Visit returns the error
+// once every worker has stopped (nil for ErrStopVisit). If ctx is cancelled
+// Visit returns ctx.Err().
+func (c *Crawler) Visit(ctx context.Context, startURL string, fn func(CrawlResult) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return visitResults(ctx, cancel, c.Start(ctx, startURL), fn)
+}
+
+// VisitCheckpoint is Visit for a crawl resumed from a checkpoint
+func (c *Crawler) VisitCheckpoint(ctx context.Context, cp *Checkpoint, fn func(CrawlResult) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return visitResults(ctx, cancel, c.Resume(ctx, cp), fn)
+}
+
+func visitResults(ctx context.Context, cancel context.CancelFunc, results <-chan CrawlResult, fn func(CrawlResult) error) error {
+	var err error
+	for result := range results {
+		if err != nil {
+			// Stopping: drain so the workers can exit
+			continue
+		}
+		if err = fn(result); err != nil {
+			cancel()
+		}
+	}
+	if errors.Is(err, ErrStopVisit) {
+		return nil
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
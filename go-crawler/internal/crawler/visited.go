@@ -0,0 +1,212 @@
+package crawler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// VisitedStore is the set of URLs a crawl has visited, by the key the host
+// policy gives them. A store may be approximate: a Bloom filter answers
+// "maybe visited" for some URLs it never saw, which are then skipped as
+// duplicates.
+type VisitedStore interface {
+	// Add records key and reports whether it was already there
+	Add(key string) bool
+	// Contains reports whether key was added
+	Contains(key string) bool
+	// Len returns the number of keys added
+	Len() int
+	// Reset empties the store for a new crawl
+	Reset()
+}
+
+// WithVisitedStore keeps the visited set in s instead of a map of every
+// URL. The crawler resets s when a crawl starts and on Reset.
+func WithVisitedStore(s VisitedStore) Option {
+	return func(c *Crawler) {
+		c.visited = s
+	}
+}
+
+// mapVisited is the default, exact visited set
+type mapVisited struct {
+	keys sync.Map
+	n    atomic.Int64
+}
+
+func (m *mapVisited) Add(key string) bool {
+	if _, loaded := m.keys.LoadOrStore(key, struct{}{}); loaded {
+		return true
+	}
+	m.n.Add(1)
+	return false
+}
+
+func (m *mapVisited) Contains(key string) bool {
+	_, ok := m.keys.Load(key)
+	return ok
+}
+
+func (m *mapVisited) Len() int {
+	return int(m.n.Load())
+}
+
+func (m *mapVisited) Reset() {
+	clearMap(&m.keys)
+	m.n.Store(0)
+}
+
+// Keys lists every key, for checkpoints
+func (m *mapVisited) Keys() []string {
+	keys := []string{}
+	m.keys.Range(func(key, _ interface{}) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	return keys
+}
+
+// BloomVisited is a visited set in a Bloom filter, which takes about
+// 1.2 bytes per URL at a 1% false-positive rate instead of the URL itself.
+// A false positive makes the crawl skip a URL it never fetched. Checkpoints
+// of crawls using it can't list the visited URLs.
+type BloomVisited struct {
+	bits     []atomic.Uint64
+	m        uint64 // Bits
+	k        uint64 // Hash functions
+	expected int
+	n        atomic.Int64
+}
+
+// BloomStats describes a Bloom filter visited set
+type BloomStats struct {
+	Bytes    int     `json:"bytes"`
+	Hashes   int     `json:"hashes"`
+	Items    int     `json:"items"`    // URLs added
+	Expected int     `json:"expected"` // URLs the filter was sized for
+	Fill     float64 `json:"fill"`     // Share of bits set
+	// FalsePositiveRate is the chance that the next new URL is taken for a
+	// visited one, as estimated from the fill
+	FalsePositiveRate float64 `json:"falsePositiveRate"`
+	// EstimatedMisses is how many of the URLs found so far were likely
+	// skipped as false positives
+	EstimatedMisses float64 `json:"estimatedMisses"`
+}
+
+// NewBloomVisited sizes a Bloom filter for expected URLs at the given
+// false-positive rate, e.g. 10000000 and 0.001. Adding more URLs than
+// expected raises the rate; Stats reports it.
+func NewBloomVisited(expected int, falsePositiveRate float64) (*BloomVisited, error) {
+	if expected <= 0 {
+		return nil, fmt.Errorf("expected URLs must be positive")
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, fmt.Errorf("false-positive rate must be between 0 and 1, e.g. 0.001")
+	}
+	m := math.Ceil(-float64(expected) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(expected)*math.Ln2))
+	words := (uint64(m) + 63) / 64
+	return &BloomVisited{
+		bits:     make([]atomic.Uint64, words),
+		m:        words * 64,
+		k:        uint64(k),
+		expected: expected,
+	}, nil
+}
+
+// bloomHashes returns the two hashes the filter's k bit positions are derived
+// from, by double hashing
+func bloomHashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	// A second, independent hash from h1 with the splitmix64 finalizer
+	h2 := h1 + 0x9e3779b97f4a7c15
+	h2 = (h2 ^ (h2 >> 30)) * 0xbf58476d1ce4e5b9
+	h2 = (h2 ^ (h2 >> 27)) * 0x94d049bb133111eb
+	h2 ^= h2 >> 31
+	return h1, h2 | 1
+}
+
+// Add implements VisitedStore. Two goroutines adding the same new key at
+// once may both be told it is new.
+func (b *BloomVisited) Add(key string) bool {
+	h1, h2 := bloomHashes(key)
+	present := true
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		word, mask := &b.bits[bit/64], uint64(1)<<(bit%64)
+		for {
+			old := word.Load()
+			if old&mask != 0 {
+				break
+			}
+			if word.CompareAndSwap(old, old|mask) {
+				present = false
+				break
+			}
+		}
+	}
+	if !present {
+		b.n.Add(1)
+	}
+	return present
+}
+
+// Contains implements VisitedStore
+func (b *BloomVisited) Contains(key string) bool {
+	h1, h2 := bloomHashes(key)
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64].Load()&(uint64(1)<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Len implements VisitedStore, counting the URLs added as new
+func (b *BloomVisited) Len() int {
+	return int(b.n.Load())
+}
+
+// Reset implements VisitedStore
+func (b *BloomVisited) Reset() {
+	for i := range b.bits {
+		b.bits[i].Store(0)
+	}
+	b.n.Store(0)
+}
+
+// Stats returns the filter's size and fill with its estimated error
+func (b *BloomVisited) Stats() BloomStats {
+	set := 0
+	for i := range b.bits {
+		set += bits.OnesCount64(b.bits[i].Load())
+	}
+	fill := float64(set) / float64(b.m)
+	n := b.Len()
+
+	// Each new URL was checked against the filter as it was then: sum the
+	// false-positive rate over the URLs added so far
+	m, k := float64(b.m), float64(b.k)
+	rate := func(items float64) float64 { return math.Pow(1-math.Exp(-k*items/m), k) }
+	misses, steps := 0.0, 1000
+	for i := 0; i < steps; i++ {
+		misses += rate(float64(n)*(float64(i)+0.5)/float64(steps)) * float64(n) / float64(steps)
+	}
+
+	return BloomStats{
+		Bytes:             len(b.bits) * 8,
+		Hashes:            int(b.k),
+		Items:             n,
+		Expected:          b.expected,
+		Fill:              fill,
+		FalsePositiveRate: math.Pow(fill, k),
+		EstimatedMisses:   misses,
+	}
+}
@@ -0,0 +1,94 @@
+package crawler_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go-crawler/internal/crawler"
+	"go-crawler/internal/crawler/crawlertest"
+)
+
+func TestNewBloomVisitedChecksArguments(t *testing.T) {
+	for _, tc := range []struct {
+		expected int
+		rate     float64
+	}{{0, 0.01}, {-1, 0.01}, {100, 0}, {100, 1}, {100, 1.5}} {
+		if _, err := crawler.NewBloomVisited(tc.expected, tc.rate); err == nil {
+			t.Errorf("NewBloomVisited(%d, %v) accepted", tc.expected, tc.rate)
+		}
+	}
+}
+
+func TestBloomVisited(t *testing.T) {
+	b, err := crawler.NewBloomVisited(1000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Add("https://example.com/") {
+		t.Error("first Add reported the key present")
+	}
+	if !b.Add("https://example.com/") {
+		t.Error("second Add reported the key new")
+	}
+	if !b.Contains("https://example.com/") {
+		t.Error("added key not contained")
+	}
+	if b.Len() != 1 {
+		t.Errorf("Len is %d, want 1", b.Len())
+	}
+
+	b.Reset()
+	if b.Contains("https://example.com/") || b.Len() != 0 {
+		t.Error("Reset left the key in the filter")
+	}
+}
+
+func TestBloomVisitedFalsePositiveRate(t *testing.T) {
+	const n = 10000
+	b, err := crawler.NewBloomVisited(n, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		b.Add(fmt.Sprintf("https://example.com/page/%d", i))
+	}
+	for i := 0; i < n; i++ {
+		if !b.Contains(fmt.Sprintf("https://example.com/page/%d", i)) {
+			t.Fatalf("page %d was added but is not contained", i)
+		}
+	}
+
+	positives := 0
+	for i := 0; i < n; i++ {
+		if b.Contains(fmt.Sprintf("https://example.com/other/%d", i)) {
+			positives++
+		}
+	}
+	if rate := float64(positives) / n; rate > 0.02 {
+		t.Errorf("false-positive rate %.4f, want about 0.01", rate)
+	}
+	// Items counts the URLs added as new, which leaves out false positives
+	if stats := b.Stats(); stats.Items > n || stats.Items < n*98/100 || stats.FalsePositiveRate <= 0 || stats.FalsePositiveRate > 0.02 {
+		t.Errorf("Stats are %+v, want about %d items at about a 0.01 rate", stats, n)
+	}
+}
+
+func TestCrawlWithBloomVisited(t *testing.T) {
+	site := crawlertest.NewSite("https://example.com/").
+		Page("/", "Home", "/a", "/b").
+		Page("/a", "A", "/", "/b").
+		Page("/b", "B", "/", "/a")
+	b, err := crawler.NewBloomVisited(1000, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := crawler.NewCrawler(1, 3, 0, site.Option(), crawler.WithVisitedStore(b))
+
+	if got := urls(site.Crawl(context.Background(), c, "/")); len(got) != 3 {
+		t.Errorf("crawl returned %v, want each page once", got)
+	}
+	if b.Len() != 3 {
+		t.Errorf("filter holds %d URLs, want 3", b.Len())
+	}
+}
@@ -0,0 +1,170 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseHeader parses a request header given as "Name: value"
+func ParseHeader(header string) (string, string, error) {
+	name, value, ok := strings.Cut(header, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("invalid header %q: want Name: value", header)
+	}
+	return textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(value), nil
+}
+
+// WithHeaders sends extra headers with every request the crawler makes,
+// e.g. Accept-Encoding to warm a CDN's compressed variants. They replace
+// the crawler's own, but User-Agent is better set with WithUserAgent so
+// robots.txt is matched against it.
+func WithHeaders(h http.Header) Option {
+	return func(c *Crawler) {
+		c.headers = h
+	}
+}
+
+// setHeaders adds the extra headers to a request
+func (c *Crawler) setHeaders(req *http.Request) {
+	for name, values := range c.headers {
+		req.Header[name] = values
+	}
+}
+
+// cacheHeaders are the response headers CDNs and caching proxies report
+// hits and misses in, in the order WarmURLs prefers them
+var cacheHeaders = []string{"Cf-Cache-Status", "X-Cache", "X-Cache-Status", "X-Proxy-Cache", "X-Vercel-Cache", "X-Nf-Cache-Status"}
+
+// WarmStatus is the outcome of fetching one URL to warm caches
+type WarmStatus struct {
+	URL        string        `json:"url"`
+	StatusCode int           `json:"statusCode,omitempty"` // After following redirects
+	Bytes      int64         `json:"bytes"`
+	Duration   time.Duration `json:"duration"`        // Until the whole body was read
+	Cache      string        `json:"cache,omitempty"` // The cache's verdict, e.g. HIT or MISS
+	Error      string        `json:"error,omitempty"`
+}
+
+// Failed reports whether the URL could not be fetched or answered with an
+// error status
+func (s WarmStatus) Failed() bool {
+	return s.Error != "" || s.StatusCode >= 400
+}
+
+// Hit reports whether a cache reported the response as a hit
+func (s WarmStatus) Hit() bool {
+	return strings.Contains(strings.ToUpper(s.Cache), "HIT")
+}
+
+// WarmStatusHeader is the CSV header matching WarmStatus.Record
+var WarmStatusHeader = []string{"url", "status_code", "bytes", "duration_ms", "cache", "error"}
+
+// Record returns the status as a CSV row
+func (s WarmStatus) Record() []string {
+	return []string{
+		s.URL, statusString(s.StatusCode), strconv.FormatInt(s.Bytes, 10),
+		strconv.FormatInt(s.Duration.Milliseconds(), 10), s.Cache, s.Error,
+	}
+}
+
+// WarmURLs fetches each URL received from urls with GET and reads its
+// whole body, without extracting links, to prime CDN and other caches,
+// e.g. after a deploy. Redirects are followed. It sends the outcome of each
+// as it completes, including the cache's verdict from headers such as
+// CF-Cache-Status and X-Cache. Duplicate URLs are fetched once. Requests
+// honour robots.txt and the crawler's workers, delay, rate, bandwidth and
+// per-host limits and retries. The returned channel is closed once urls is
+// closed and every URL has been fetched, or ctx is cancelled.
+func (c *Crawler) WarmURLs(ctx context.Context, urls <-chan string) <-chan WarmStatus {
+	statuses := make(chan WarmStatus, c.maxWorkers)
+	go func() {
+		defer close(statuses)
+		c.forEachURL(ctx, urls, func(u string) {
+			status := c.warmURL(ctx, u)
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case statuses <- status:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return statuses
+}
+
+// warmURL fetches a URL and reads its body
+func (c *Crawler) warmURL(ctx context.Context, rawURL string) WarmStatus {
+	status := WarmStatus{URL: rawURL}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		status.Error = fmt.Sprintf("invalid URL %q", rawURL)
+		return status
+	}
+
+	rules, err := c.getRobotsRules(u)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	if _, allowed := c.checkRobots(rules, u.String()); !allowed {
+		status.Error = ErrRobotsDisallowed.Error()
+		return status
+	}
+	if err := c.politeDelay(ctx); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	if c.rateLimiter != nil {
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			status.Error = err.Error()
+			return status
+		}
+	}
+	release, err := c.acquireHost(ctx, u.Host)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer release()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		status.Error = fmt.Sprintf("error creating request: %v", err)
+		return status
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	start := time.Now()
+	resp, err := c.doWithRetries(ctx, req)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = &countingReader{r: resp.Body, n: &c.bytesRead}
+	if c.bandwidth != nil {
+		body = c.bandwidth.Reader(ctx, body)
+	}
+	status.Bytes, err = io.Copy(io.Discard, body)
+	status.Duration = time.Since(start)
+	status.StatusCode = resp.StatusCode
+	if err != nil {
+		status.Error = fmt.Sprintf("error reading body: %v", err)
+	}
+	for _, name := range cacheHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			status.Cache = v
+			break
+		}
+	}
+	return status
+}
@@ -0,0 +1,246 @@
+// JobDashboard lists the user's crawl jobs and shows progress and results
+// for the selected one, using the HTTP API
+class JobDashboard {
+    constructor() {
+        this.jobList = document.getElementById('jobList');
+        this.dashboard = document.getElementById('jobDashboard');
+        this.jobIdSpan = document.getElementById('dashboardJobId');
+        this.statsDiv = document.getElementById('dashboardStats');
+        this.resultTable = document.getElementById('resultTable');
+        this.resultCount = document.getElementById('resultCount');
+        this.filterInput = document.getElementById('resultFilter');
+        this.statusFilter = document.getElementById('resultStatusFilter');
+        this.domainFilter = document.getElementById('jobDomainFilter');
+        this.apiKey = new URLSearchParams(window.location.search).get('api_key');
+        this.jobId = null;
+        this.results = [];
+
+        document.getElementById('refreshJobs').addEventListener('click', () => this.refreshJobs());
+        this.domainFilter.addEventListener('change', () => this.refreshJobs());
+        document.getElementById('exportCsv').addEventListener('click', () => this.exportCsv());
+        document.getElementById('exportSeo').addEventListener('click', () => this.exportCsv('seo'));
+        document.getElementById('exportJson').addEventListener('click', () => this.exportJson());
+        this.filterInput.addEventListener('input', () => this.renderResults());
+        this.statusFilter.addEventListener('change', () => this.renderResults());
+
+        this.refreshJobs();
+        // Poll while the page is open so running jobs show live progress
+        setInterval(() => this.poll(), 2000);
+    }
+
+    async fetchJSON(path) {
+        const headers = this.apiKey ? { 'X-API-Key': this.apiKey } : {};
+        const response = await fetch(path, { headers });
+        if (!response.ok) {
+            throw new Error(`${path}: ${response.status} ${await response.text()}`);
+        }
+        return response.json();
+    }
+
+    async patchJSON(path, body) {
+        const headers = { 'Content-Type': 'application/json' };
+        if (this.apiKey) headers['X-API-Key'] = this.apiKey;
+        const response = await fetch(path, { method: 'PATCH', headers, body: JSON.stringify(body) });
+        if (!response.ok) {
+            throw new Error(`${path}: ${response.status} ${await response.text()}`);
+        }
+        return response.json();
+    }
+
+    async poll() {
+        await this.refreshJobs();
+        if (this.jobId && this.jobActive) {
+            await this.loadJob();
+        }
+    }
+
+    async refreshJobs() {
+        try {
+            // The 100 newest jobs, on the filtered domain if one is set
+            const params = new URLSearchParams({ limit: 100 });
+            const domain = this.domainFilter.value.trim();
+            if (domain) params.set('domain', domain);
+            const page = await this.fetchJSON(`/crawls?${params}`);
+            this.renderJobs(page.jobs);
+        } catch (e) {
+            console.error('Error loading jobs:', e);
+        }
+    }
+
+    renderJobs(jobs) {
+        this.jobList.innerHTML = '';
+        if (jobs.length === 0) {
+            const row = document.createElement('tr');
+            row.innerHTML = '<td colspan="7" class="py-2 text-gray-500">No jobs found</td>';
+            this.jobList.appendChild(row);
+            return;
+        }
+
+        jobs.forEach(job => {
+            const row = document.createElement('tr');
+            row.className = 'border-b cursor-pointer hover:bg-gray-50' + (job.id === this.jobId ? ' bg-blue-50' : '');
+            [
+                job.id,
+                job.request.url,
+                job.status + (job.position ? ` (#${job.position})` : ''),
+                job.priority,
+                job.pages,
+                job.errors,
+                new Date(job.createdAt).toLocaleString(),
+            ].forEach((value, i) => {
+                const cell = document.createElement('td');
+                cell.className = 'py-2 pr-4' + (i < 2 ? ' font-mono truncate max-w-xs' : '');
+                cell.textContent = value;
+                row.appendChild(cell);
+            });
+            row.addEventListener('click', () => this.openJob(job.id));
+            this.jobList.appendChild(row);
+        });
+    }
+
+    openJob(id) {
+        this.jobId = id;
+        this.results = [];
+        this.jobIdSpan.textContent = id;
+        this.dashboard.classList.remove('hidden');
+        this.loadJob();
+    }
+
+    async loadJob() {
+        const id = this.jobId;
+        try {
+            const [job, report] = await Promise.all([
+                this.fetchJSON(`/crawl/${id}`),
+                this.fetchJSON(`/crawl/${id}/results`),
+            ]);
+            if (id !== this.jobId) return;
+            this.jobActive = job.status !== 'completed';
+            this.results = report.results;
+            this.renderStats(job);
+            this.renderResults();
+        } catch (e) {
+            console.error('Error loading job:', e);
+        }
+    }
+
+    renderStats(job) {
+        const started = job.startedAt ? new Date(job.startedAt) : null;
+        const finished = job.finishedAt ? new Date(job.finishedAt) : new Date();
+        const seconds = started ? (finished - started) / 1000 : 0;
+        const speed = seconds > 0 ? (job.pages / seconds).toFixed(2) : '-';
+
+        const stats = [
+            ['Status', job.status],
+            ['Pages', job.pages],
+            ['Errors', job.errors],
+            ['Skipped', job.skipped],
+            ['Elapsed', started ? `${seconds.toFixed(1)}s` : '-'],
+            ['Pages/s', speed],
+        ];
+        this.statsDiv.innerHTML = '';
+        stats.forEach(([label, value]) => {
+            const box = document.createElement('div');
+            box.className = 'bg-gray-50 p-3 rounded border border-gray-200';
+            const labelDiv = document.createElement('div');
+            labelDiv.className = 'text-xs text-gray-500';
+            labelDiv.textContent = label;
+            const valueDiv = document.createElement('div');
+            valueDiv.className = 'text-lg font-mono text-gray-800';
+            valueDiv.textContent = value;
+            box.appendChild(labelDiv);
+            box.appendChild(valueDiv);
+            this.statsDiv.appendChild(box);
+        });
+    }
+
+    filteredResults() {
+        const text = this.filterInput.value.trim().toLowerCase();
+        const status = this.statusFilter.value;
+        return this.results.filter(r => {
+            if (status === 'ok' && r.error) return false;
+            if (status === 'error' && !r.error) return false;
+            if (!text) return true;
+            return r.url.toLowerCase().includes(text) || (r.error || '').toLowerCase().includes(text) ||
+                (r.labels || []).some(l => l.toLowerCase().includes(text));
+        });
+    }
+
+    renderResults() {
+        const results = this.filteredResults();
+        this.resultTable.innerHTML = '';
+        results.forEach(r => {
+            const row = document.createElement('tr');
+            row.className = 'border-b' + (r.error ? ' bg-red-50' : '');
+            [r.url, r.depth, r.statusCode || '', r.contentType || '', (r.links || []).length, r.error || ''].forEach((value, i) => {
+                const cell = document.createElement('td');
+                cell.className = 'py-1 pr-4' + (i === 0 ? ' font-mono' : '') + (i === 5 ? ' text-red-700' : '');
+                cell.textContent = value;
+                row.appendChild(cell);
+            });
+            row.appendChild(this.labelCell(r));
+            this.resultTable.appendChild(row);
+        });
+        this.resultCount.textContent = `Showing ${results.length} of ${this.results.length} results`;
+    }
+
+    // labelCell shows a result's labels and note; clicking it edits them
+    labelCell(result) {
+        const cell = document.createElement('td');
+        cell.className = 'py-1 pr-4 cursor-pointer';
+        cell.title = result.note || 'Click to label';
+        (result.labels || []).forEach(label => {
+            const badge = document.createElement('span');
+            badge.className = 'inline-block bg-blue-100 text-blue-800 text-xs px-2 rounded mr-1';
+            badge.textContent = label;
+            cell.appendChild(badge);
+        });
+        if (!result.labels || result.labels.length === 0) {
+            cell.innerHTML = '<i class="fas fa-tag text-gray-300"></i>';
+        }
+        cell.addEventListener('click', () => this.editLabels(result));
+        return cell;
+    }
+
+    async editLabels(result) {
+        const labels = prompt('Labels, separated by commas:', (result.labels || []).join(', '));
+        if (labels === null) return;
+        const note = prompt('Note:', result.note || '');
+        if (note === null) return;
+        try {
+            const updated = await this.patchJSON(`/crawl/${this.jobId}/results/${result.id}`, {
+                labels: labels.split(',').map(l => l.trim()).filter(l => l),
+                note,
+            });
+            Object.assign(result, updated);
+            this.renderResults();
+        } catch (e) {
+            alert(`Error saving labels: ${e.message}`);
+        }
+    }
+
+    async exportCsv(format = 'csv') {
+        if (!this.jobId) return;
+        const headers = this.apiKey ? { 'X-API-Key': this.apiKey } : {};
+        const response = await fetch(`/crawl/${this.jobId}/results?format=${format}`, { headers });
+        const suffix = format === 'seo' ? '-seo' : '';
+        this.download(await response.blob(), `crawl-${this.jobId}${suffix}.csv`);
+    }
+
+    exportJson() {
+        if (!this.jobId) return;
+        const blob = new Blob([JSON.stringify(this.results, null, 2)], { type: 'application/json' });
+        this.download(blob, `crawl-${this.jobId}.json`);
+    }
+
+    download(blob, filename) {
+        const link = document.createElement('a');
+        link.href = URL.createObjectURL(blob);
+        link.download = filename;
+        link.click();
+        URL.revokeObjectURL(link.href);
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.jobDashboard = new JobDashboard();
+});
@@ -8,6 +8,7 @@ class CrawlerUI {
         this.depthInput = document.getElementById('depth');
         this.workersInput = document.getElementById('workers');
         this.delayInput = document.getElementById('delay');
+        this.scriptInput = document.getElementById('script');
         this.progressBar = document.getElementById('progressBar');
         this.progressText = document.getElementById('progressText');
         this.summaryDiv = document.getElementById('summary');
@@ -28,7 +29,8 @@ class CrawlerUI {
     initializeWebSocket() {
         try {
             const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-            const wsUrl = `${protocol}//${window.location.host}/ws`;
+            const apiKey = new URLSearchParams(window.location.search).get('api_key');
+            const wsUrl = `${protocol}//${window.location.host}/ws${apiKey ? '?api_key=' + encodeURIComponent(apiKey) : ''}`;
             console.log('Connecting to WebSocket:', wsUrl);
 
             this.ws = new WebSocket(wsUrl);
@@ -95,6 +97,7 @@ class CrawlerUI {
                     this.handleConnected(message);
                     break;
                 case 'start':
+                case 'queued':
                     this.handleCrawlStart(message);
                     break;
                 case 'result':
@@ -124,7 +127,11 @@ class CrawlerUI {
     }
 
     handleCrawlStart(message) {
-        this.crawlId = message.crawlId;
+        this.crawlId = message.data && message.data.id;
+        if (this.crawlId && window.jobDashboard) {
+            window.jobDashboard.refreshJobs();
+            window.jobDashboard.openJob(this.crawlId);
+        }
     }
 
     startCrawl() {
@@ -160,7 +167,8 @@ class CrawlerUI {
             url: url,
             depth: depth,
             workers: workers,
-            delay: delay
+            delay: delay,
+            script: this.scriptInput.value
         }));
 
         // Log the start
@@ -283,6 +291,20 @@ class CrawlerUI {
             contentElement.appendChild(statusElement);
         }
 
+        // Add fields extracted by the crawl's script
+        if (result.fields) {
+            Object.keys(result.fields).sort().forEach(name => {
+                const fieldElement = document.createElement('div');
+                fieldElement.className = 'text-sm mb-2';
+                const label = document.createElement('span');
+                label.className = 'font-medium';
+                label.textContent = `${name}: `;
+                fieldElement.appendChild(label);
+                fieldElement.appendChild(document.createTextNode(result.fields[name]));
+                contentElement.appendChild(fieldElement);
+            });
+        }
+
         // Add links if available
         if (result.links && result.links.length > 0) {
             const linksHeader = document.createElement('div');